@@ -0,0 +1,354 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/tracks"
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Quality alerts (-alert). Rules watch the quality events over a sliding
+// window of track time and raise an alert when their condition becomes
+// true:
+//
+//	click>5/1m         more than 5 clicks within a minute
+//	saturation>2s/1m   more than 2 seconds of saturation within a minute
+//	hum                any hum (a bare event is event>0 over a minute)
+//
+// An alert stays raised, without repeating, until its condition has been
+// false for -alert-hold, and is then resolved; the time in between is one
+// incident. Alerts and resolutions go to the -alert-to channels, and each
+// track's incidents are listed when it ends. Dead air (-dead-air, see
+// deadair.go) is reported the same way.
+
+// defaultAlertRules is what "default" stands for in -alert.
+const defaultAlertRules = "click>5/1m,discontinuity,noise.burst>3/1m,saturation>1s/1m,hum"
+
+type alertRule struct {
+	text      string // as given
+	event     string
+	sum       bool // the threshold is on summed values (seconds), not a count
+	threshold float64
+	window    float64 // seconds
+}
+
+func parseAlertRules(spec string) ([]alertRule, error) {
+	var items []string
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item == "default" {
+			items = append(items, strings.Split(defaultAlertRules, ",")...)
+		} else if item != "" {
+			items = append(items, item)
+		}
+	}
+	var rules []alertRule
+	for _, item := range items {
+		r := alertRule{text: item, window: 60}
+		event, cond, ok := strings.Cut(item, ">")
+		r.event = strings.TrimSpace(event)
+		if !isEventName(r.event) {
+			return nil, fmt.Errorf("unknown event %q", r.event)
+		}
+		if ok {
+			threshold, window, hasWindow := strings.Cut(cond, "/")
+			if hasWindow {
+				d, err := time.ParseDuration(strings.TrimSpace(window))
+				if err != nil || d <= 0 {
+					return nil, fmt.Errorf("invalid window in %q (want e.g. 30s or 1m)", item)
+				}
+				r.window = d.Seconds()
+			}
+			threshold = strings.TrimSpace(threshold)
+			if t, found := strings.CutSuffix(threshold, "s"); found {
+				threshold, r.sum = t, true
+			}
+			v, err := strconv.ParseFloat(threshold, 64)
+			if err != nil || v < 0 {
+				return nil, fmt.Errorf("invalid threshold in %q", item)
+			}
+			r.threshold = v
+		}
+		rules = append(rules, r)
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("no alert rules given")
+	}
+	return rules, nil
+}
+
+// measure is the rule's count or sum of the events in its window.
+func (r alertRule) measure(pts []point) float64 {
+	if !r.sum {
+		return float64(len(pts))
+	}
+	m := 0.0
+	for _, p := range pts {
+		m += p.v
+	}
+	return m
+}
+
+// amount renders a measure: "6" or "2.40s".
+func (r alertRule) amount(m float64) string {
+	if r.sum {
+		return fmt.Sprintf("%.2fs", m)
+	}
+	return strconv.Itoa(int(m))
+}
+
+func (r alertRule) describe(m float64) string {
+	window := strconv.FormatFloat(r.window, 'f', -1, 64) + "s"
+	if r.window >= 60 && float64(int(r.window/60)*60) == r.window {
+		window = strconv.Itoa(int(r.window/60)) + "m"
+	}
+	return r.amount(m) + " in the last " + window
+}
+
+// alertIncident is one raised alert, from raising to resolving.
+type alertIncident struct {
+	Rule     string  `json:"rule"`
+	Stream   string  `json:"stream,omitempty"`
+	Track    string  `json:"track"`
+	Start    float64 `json:"start"`
+	End      float64 `json:"end,omitempty"`
+	Peak     float64 `json:"peak"`
+	Resolved bool    `json:"resolved"`
+}
+
+type alertState struct {
+	events   []point
+	incident *alertIncident // raised, or nil
+	clear    float64        // time the condition was last true
+}
+
+type alertManager struct {
+	rules   []alertRule
+	hold    float64
+	print   func(string) // the console, for the stdout channel
+	stderr  bool
+	stdout  bool
+	webhook *webhookSink
+	log     *os.File
+
+	mu       sync.Mutex
+	streams  map[string][]*alertState // per stream, one per rule
+	titles   map[string]string
+	playing  map[string]bool
+	finished map[string][]*alertIncident // per stream, for the track summary
+	deadAir  deadAirMonitor
+}
+
+// newAlertManager raises alerts by the rules in spec, which may be empty
+// when only dead air is watched, and sends them to the comma-separated
+// channels: stdout, stderr, webhook (through -webhook) and file:<path>
+// (JSON lines).
+func newAlertManager(spec, channels string, hold, deadAir time.Duration, clock tracks.Clock, print func(string), webhook *webhookSink) (*alertManager, error) {
+	var rules []alertRule
+	var err error
+	if spec != "" || deadAir == 0 {
+		if rules, err = parseAlertRules(spec); err != nil {
+			return nil, err
+		}
+	}
+	a := &alertManager{
+		rules:    rules,
+		hold:     hold.Seconds(),
+		print:    print,
+		streams:  make(map[string][]*alertState),
+		titles:   make(map[string]string),
+		playing:  make(map[string]bool),
+		finished: make(map[string][]*alertIncident),
+	}
+	for _, ch := range strings.Split(channels, ",") {
+		switch ch = strings.TrimSpace(ch); {
+		case ch == "stdout":
+			a.stdout = true
+		case ch == "stderr":
+			a.stderr = true
+		case ch == "webhook":
+			if webhook == nil {
+				return nil, fmt.Errorf("the webhook channel needs -webhook")
+			}
+			a.webhook = webhook
+		case strings.HasPrefix(ch, "file:"):
+			path := strings.TrimPrefix(ch, "file:")
+			if dir := filepath.Dir(path); dir != "." {
+				if err := os.MkdirAll(dir, 0o755); err != nil {
+					return nil, err
+				}
+			}
+			if a.log, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644); err != nil {
+				return nil, err
+			}
+		case ch == "":
+		default:
+			return nil, fmt.Errorf("unknown channel %q (want stdout, stderr, webhook or file:<path>)", ch)
+		}
+	}
+	if deadAir > 0 {
+		a.startDeadAir(deadAir, clock)
+	}
+	return a, nil
+}
+
+func (a *alertManager) handle(env *trackspb.Envelope, received time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stream := env.GetStreamId()
+	if start := env.GetTrackStart(); start != nil {
+		a.endTrack(stream)
+		a.titles[stream] = filepath.Base(start.GetFilename())
+		a.playing[stream] = true
+	}
+	a.observeDeadAir(env, received)
+	states := a.streams[stream]
+	if states == nil {
+		states = make([]*alertState, len(a.rules))
+		for i := range states {
+			states[i] = &alertState{}
+		}
+		a.streams[stream] = states
+	}
+
+	t := env.GetTimestamp()
+	name := eventName(env)
+	for i, r := range a.rules {
+		st := states[i]
+		if name == r.event {
+			v, _ := eventValue(env)
+			st.events = append(st.events, point{t, v})
+		}
+		for len(st.events) > 0 && st.events[0].t <= t-r.window {
+			st.events = st.events[1:]
+		}
+		m := r.measure(st.events)
+		if m > r.threshold {
+			st.clear = t
+			if st.incident == nil {
+				st.incident = &alertIncident{Rule: r.text, Stream: stream, Track: a.titles[stream], Start: t, Peak: m}
+				a.send("ALERT", st.incident, received, fmt.Sprintf("%s in %s at %s: %s", r.text, a.track(stream), formatClock(t), r.describe(m)))
+			}
+			st.incident.Peak = max(st.incident.Peak, m)
+		} else if st.incident != nil && t-st.clear >= a.hold {
+			st.incident.End, st.incident.Resolved = t, true
+			a.send("RESOLVED", st.incident, received, fmt.Sprintf("%s in %s at %s after %.0fs", r.text, a.track(stream), formatClock(t), t-st.incident.Start))
+			a.finished[stream] = append(a.finished[stream], st.incident)
+			st.incident = nil
+		}
+	}
+
+	if isTrackEnd(env) {
+		a.endTrack(stream)
+		a.playing[stream] = false
+	}
+}
+
+// setRules replaces the rules and hold time, when -config changes them.
+// Alerts raised under the old rules end now, as at the end of a track.
+func (a *alertManager) setRules(spec string, hold time.Duration) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var rules []alertRule
+	if spec != "" || a.deadAir.stop == nil {
+		var err error
+		if rules, err = parseAlertRules(spec); err != nil {
+			return err
+		}
+	}
+	for stream := range a.streams {
+		a.endTrack(stream)
+	}
+	a.rules, a.hold = rules, hold.Seconds()
+	return nil
+}
+
+// endTrack closes the stream's raised alerts at the end of its track.
+func (a *alertManager) endTrack(stream string) {
+	for _, st := range a.streams[stream] {
+		if st.incident != nil {
+			st.incident.End = st.clear
+			a.finished[stream] = append(a.finished[stream], st.incident)
+		}
+	}
+	delete(a.streams, stream)
+}
+
+func (a *alertManager) track(stream string) string {
+	track := a.titles[stream]
+	if track == "" {
+		track = "unknown track"
+	}
+	if stream != "" {
+		track += " [" + stream + "]"
+	}
+	return track
+}
+
+// send raises or resolves inc at time at, by the clock of the envelopes.
+func (a *alertManager) send(kind string, inc *alertIncident, at time.Time, text string) {
+	line := kind + ": " + text
+	if a.stdout {
+		a.print(line)
+	}
+	if a.stderr {
+		fmt.Fprintln(os.Stderr, line)
+	}
+	if a.webhook != nil {
+		a.webhook.notify(line)
+	}
+	if a.log != nil {
+		rec := struct {
+			Kind string    `json:"kind"`
+			Time time.Time `json:"time"`
+			*alertIncident
+		}{strings.ToLower(kind), at, inc}
+		enc := json.NewEncoder(a.log)
+		enc.SetEscapeHTML(false) // rules are full of '>'
+		if err := enc.Encode(rec); err != nil {
+			fmt.Fprintf(os.Stderr, "alert: %v\n", err)
+		}
+	}
+}
+
+// summary lists a finished track's incidents and forgets them.
+func (a *alertManager) summary(d *trackData) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	incidents := a.finished[d.stream]
+	delete(a.finished, d.stream)
+	if len(incidents) == 0 {
+		return ""
+	}
+	sort.SliceStable(incidents, func(i, j int) bool { return incidents[i].Start < incidents[j].Start })
+	var b strings.Builder
+	b.WriteString("Alerts:")
+	for _, inc := range incidents {
+		end := "end of track"
+		if inc.Resolved {
+			end = formatClock(inc.End)
+		}
+		detail := fmt.Sprintf("%.0fs", inc.Peak) // dead air: how long it lasted
+		for _, r := range a.rules {
+			if r.text == inc.Rule {
+				detail = "peak " + r.amount(inc.Peak)
+			}
+		}
+		fmt.Fprintf(&b, "\n  %s-%s  %s (%s)", formatClock(inc.Start), end, inc.Rule, detail)
+	}
+	return b.String()
+}
+
+func (a *alertManager) close() {
+	a.stopDeadAir()
+	if a.log != nil {
+		a.log.Close()
+	}
+}
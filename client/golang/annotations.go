@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// annotation is a labelled instant (start == end) or region of a track, as
+// shared by the label, marker and annotation exporters.
+type annotation struct {
+	start, end float64
+	layer      string
+	text       string
+}
+
+// annotationLayers are the layers exporters can select from, in the order
+// they are written.
+var annotationLayers = []string{"beat", "downbeat", "onset", "segment", "section", "drop", "structure", "silence", "fade", "quality", "key", "chord", "marker"}
+
+// parseLayers parses a comma-separated layer list; "all" selects every
+// layer.
+func parseLayers(spec string) (map[string]bool, error) {
+	layers := make(map[string]bool)
+	for _, l := range strings.Split(spec, ",") {
+		l = strings.TrimSpace(l)
+		switch {
+		case l == "":
+		case l == "all":
+			for _, a := range annotationLayers {
+				layers[a] = true
+			}
+		case isLayer(l):
+			layers[l] = true
+		default:
+			return nil, fmt.Errorf("unknown layer %q (want %s or all)", l, strings.Join(annotationLayers, ", "))
+		}
+	}
+	return layers, nil
+}
+
+func isLayer(s string) bool {
+	for _, l := range annotationLayers {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// trackAnnotations collects the selected layers of d, sorted by start time.
+func trackAnnotations(d *trackData, layers map[string]bool) []annotation {
+	var out []annotation
+	for _, l := range annotationLayers {
+		if !layers[l] {
+			continue
+		}
+		switch l {
+		case "beat", "downbeat":
+			for _, p := range d.series[l] {
+				out = append(out, annotation{p.t, p.t, l, l})
+			}
+		case "onset":
+			for _, p := range d.series["onset"] {
+				out = append(out, annotation{p.t, p.t, l, fmt.Sprintf("onset %.2f", p.v)})
+			}
+		case "segment":
+			out = append(out, segmentRegions(d)...)
+		case "section":
+			for _, p := range d.series["section.change"] {
+				out = append(out, annotation{p.t, p.t, l, fmt.Sprintf("section %.2f", p.v)})
+			}
+		case "drop":
+			for _, p := range d.series["drop"] {
+				out = append(out, annotation{p.t, p.t, l, fmt.Sprintf("drop %.2f", p.v)})
+			}
+		case "structure":
+			out = append(out, structureRegions(d)...)
+		case "silence":
+			out = append(out, silenceRegions(d)...)
+		case "fade":
+			out = append(out, fadeRegions(d)...)
+		case "quality":
+			out = append(out, qualityAnnotations(d)...)
+		case "key":
+			out = append(out, labelRegions(d.keys, d.duration(), "key")...)
+		case "chord":
+			out = append(out, chordRegions(d)...)
+		case "marker":
+			for i, t := range d.markers {
+				out = append(out, annotation{t, t, l, fmt.Sprintf("Marker %d", i+1)})
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].start < out[j].start })
+	return out
+}
+
+// segmentRegions splits the track at each segment.boundary. A track without
+// boundaries is one segment.
+func segmentRegions(d *trackData) []annotation {
+	cuts := append([]float64{0}, d.marks["segment.boundary"]...)
+	cuts = append(cuts, d.duration())
+	var out []annotation
+	for i := 0; i+1 < len(cuts); i++ {
+		if cuts[i+1] <= cuts[i] {
+			continue
+		}
+		out = append(out, annotation{cuts[i], cuts[i+1], "segment", fmt.Sprintf("Segment %d", len(out)+1)})
+	}
+	return out
+}
+
+// silenceRegions pairs each silence.start with the next silence.end; a
+// silence still open at the end of the track runs to its end.
+func silenceRegions(d *trackData) []annotation {
+	ends := d.marks["silence.end"]
+	var out []annotation
+	j := 0
+	for _, s := range d.marks["silence.start"] {
+		for j < len(ends) && ends[j] < s {
+			j++
+		}
+		end := d.duration()
+		if j < len(ends) {
+			end = ends[j]
+			j++
+		}
+		out = append(out, annotation{s, end, "silence", "silence"})
+	}
+	return out
+}
+
+// fadeRegions covers each fade as measured by trackFades: fade.in from the
+// event to its end_time, and fade.out from its start_time to the event, or
+// to the end of the track when the event marks the start of the fade, where
+// the loudness frames don't place them more precisely.
+func fadeRegions(d *trackData) []annotation {
+	var out []annotation
+	for _, f := range trackFades(d) {
+		out = append(out, annotation{f.Start, f.End, "fade", f.text()})
+	}
+	return out
+}
+
+func qualityAnnotations(d *trackData) []annotation {
+	var out []annotation
+	for _, name := range qualityEventNames {
+		for _, t := range d.marks[name] {
+			out = append(out, annotation{t, t, "quality", name})
+		}
+		for _, p := range d.series[name] {
+			switch name {
+			case "saturation":
+				out = append(out, annotation{p.t, p.t + p.v, "quality", name})
+			case "hum":
+				out = append(out, annotation{p.t, p.t, "quality", fmt.Sprintf("hum %.1f Hz", p.v)})
+			default:
+				out = append(out, annotation{p.t, p.t, "quality", name})
+			}
+		}
+	}
+	return out
+}
+
+// labelRegions turns a key or chord timeline into regions, each lasting
+// until the next label or the end of the track.
+func labelRegions(labels []label, end float64, layer string) []annotation {
+	var out []annotation
+	for i, l := range labels {
+		to := end
+		if i+1 < len(labels) {
+			to = labels[i+1].t
+		}
+		out = append(out, annotation{l.t, max(to, l.t), layer, l.name})
+	}
+	return out
+}
+
+// chordRegions are the chord timeline, with each chord's roman numeral
+// ("Am (vi)") when -derive=roman.numeral labelled it.
+func chordRegions(d *trackData) []annotation {
+	out := labelRegions(d.chords, d.duration(), "chord")
+	j := 0
+	for i := range out {
+		for j < len(d.numerals) && d.numerals[j].t < out[i].start {
+			j++
+		}
+		if j < len(d.numerals) && d.numerals[j].t == out[i].start {
+			out[i].text += " (" + d.numerals[j].name + ")"
+		}
+	}
+	return out
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// writeAudacityLabels writes an Audacity label track: one
+// "start<TAB>end<TAB>label" line per annotation, times in seconds, with
+// start == end for point labels. Import it with File → Import → Labels.
+func writeAudacityLabels(path string, d *trackData, layers map[string]bool) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, a := range trackAnnotations(d, layers) {
+		fmt.Fprintf(w, "%.6f\t%.6f\t%s\n", a.start, a.end, a.text)
+	}
+	return flushClose(w, f)
+}
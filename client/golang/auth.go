@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Access control for the event feeds of -serve and -web. With -auth-token
+// (static tokens), -auth-jwt-secret (HS256) or -auth-jwt-key (RS256 or
+// ES256) every client must present a token: -serve clients as a token=
+// option of their SUBSCRIBE line, web clients as an Authorization: Bearer
+// header, a ?token= query or the cookie set when the dashboard is opened
+// with one. A JWT must have an exp claim and be unexpired, and its
+// streams claim, a list of stream ids, limits what the client receives:
+//
+//	{"sub": "booth-display", "exp": 1767225600, "streams": ["deckA"]}
+//
+// -client-limit caps the feeds (subscriptions and WebSockets) open at once
+// per client: per JWT subject or static token, or per address without
+// authentication.
+type authenticator struct {
+	tokens []string
+	secret []byte           // HS256
+	key    crypto.PublicKey // RS256 or ES256
+	limit  int
+
+	mu     sync.Mutex
+	active map[string]int // open feeds per client
+}
+
+// grant is what an authorized client may receive.
+type grant struct {
+	client  string          // the client, for -client-limit
+	streams map[string]bool // nil for every stream
+}
+
+var (
+	errUnauthorized = errors.New("unauthorized")
+	errClientLimit  = errors.New("too many subscriptions for this client")
+)
+
+const authCookie = "tracks_token"
+
+func newAuthenticator(tokens, secret, keyFile string, limit int) (*authenticator, error) {
+	if limit < 0 {
+		return nil, fmt.Errorf("-client-limit must not be negative")
+	}
+	a := &authenticator{secret: []byte(secret), limit: limit, active: make(map[string]int)}
+	for _, t := range strings.Split(tokens, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			a.tokens = append(a.tokens, t)
+		}
+	}
+	if keyFile != "" {
+		b, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		block, _ := pem.Decode(b)
+		if block == nil {
+			return nil, fmt.Errorf("%s: no PEM public key", keyFile)
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", keyFile, err)
+		}
+		switch key.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey:
+		default:
+			return nil, fmt.Errorf("%s: want an RSA or ECDSA public key", keyFile)
+		}
+		a.key = key
+	}
+	return a, nil
+}
+
+// required reports whether clients must present a token.
+func (a *authenticator) required() bool {
+	return len(a.tokens) > 0 || len(a.secret) > 0 || a.key != nil
+}
+
+// authorize checks the token of a client connecting from addr.
+func (a *authenticator) authorize(token, addr string) (*grant, error) {
+	if !a.required() {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		return &grant{client: host}, nil
+	}
+	if token == "" {
+		return nil, errUnauthorized
+	}
+	for i, t := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return &grant{client: fmt.Sprintf("token %d", i+1)}, nil
+		}
+	}
+	if strings.Count(token, ".") == 2 && (len(a.secret) > 0 || a.key != nil) {
+		return a.verifyJWT(token, time.Now())
+	}
+	return nil, errUnauthorized
+}
+
+type jwtClaims struct {
+	Subject   string   `json:"sub"`
+	Expires   *float64 `json:"exp"`
+	NotBefore *float64 `json:"nbf"`
+	Streams   []string `json:"streams"`
+}
+
+// verifyJWT checks a compact JWS token's signature and times. The algorithm
+// must be the one of the configured key, never "none".
+func (a *authenticator) verifyJWT(token string, now time.Time) (*grant, error) {
+	parts := strings.Split(token, ".")
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, errUnauthorized
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errUnauthorized
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	digest := sha256.Sum256(signed)
+	valid := false
+	switch header.Alg {
+	case "HS256":
+		if len(a.secret) > 0 {
+			mac := hmac.New(sha256.New, a.secret)
+			mac.Write(signed)
+			valid = hmac.Equal(sig, mac.Sum(nil))
+		}
+	case "RS256":
+		if key, ok := a.key.(*rsa.PublicKey); ok {
+			valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+		}
+	case "ES256":
+		// ES256 signatures are r and s, 32 bytes each.
+		if key, ok := a.key.(*ecdsa.PublicKey); ok && len(sig) == 64 {
+			r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+			valid = ecdsa.Verify(key, digest[:], r, s)
+		}
+	}
+	if !valid {
+		return nil, errUnauthorized
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, errUnauthorized
+	}
+	t := float64(now.Unix())
+	// A token without exp would be good forever, past any rotation of the
+	// key that signed it.
+	if claims.Expires == nil {
+		return nil, fmt.Errorf("token has no expiry")
+	}
+	if t >= *claims.Expires {
+		return nil, fmt.Errorf("token expired")
+	}
+	if claims.NotBefore != nil && t < *claims.NotBefore {
+		return nil, fmt.Errorf("token not valid yet")
+	}
+	g := &grant{client: "jwt " + claims.Subject}
+	if claims.Subject == "" {
+		g.client = "jwt " + parts[2]
+	}
+	if claims.Streams != nil {
+		g.streams = make(map[string]bool)
+		for _, s := range claims.Streams {
+			g.streams[s] = true
+		}
+	}
+	return g, nil
+}
+
+func decodeJWTPart(part string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// allows reports whether the client may receive envelopes of stream.
+func (g *grant) allows(stream string) bool {
+	return g.streams == nil || g.streams[stream]
+}
+
+// acquire counts a feed opened by the client against -client-limit; release
+// must be called when it closes.
+func (a *authenticator) acquire(g *grant) (release func(), err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.limit > 0 && a.active[g.client] >= a.limit {
+		return nil, errClientLimit
+	}
+	a.active[g.client]++
+	return func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.active[g.client]--; a.active[g.client] <= 0 {
+			delete(a.active, g.client)
+		}
+	}, nil
+}
+
+type grantKey struct{}
+
+// middleware authorizes every web request. A token given as ?token= is
+// also set as a cookie, so the dashboard opened with one can fetch its data
+// and open its WebSocket. The cookie is hidden from scripts, not sent by
+// other sites, and only sent back over HTTPS when the page was served over
+// it, directly or behind a proxy that says so.
+func (a *authenticator) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		token, fromQuery := "", false
+		if h, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			token = strings.TrimSpace(h)
+		} else if t := r.URL.Query().Get("token"); t != "" {
+			token, fromQuery = t, true
+		} else if c, err := r.Cookie(authCookie); err == nil {
+			token = c.Value
+		}
+		g, err := a.authorize(token, r.RemoteAddr)
+		if err != nil {
+			rw.Header().Set("WWW-Authenticate", `Bearer realm="tracks"`)
+			http.Error(rw, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if fromQuery && a.required() {
+			secure := r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+			http.SetCookie(rw, &http.Cookie{Name: authCookie, Value: token, Path: "/",
+				HttpOnly: true, Secure: secure, SameSite: http.SameSiteStrictMode})
+		}
+		next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), grantKey{}, g)))
+	})
+}
+
+// requestGrant is the grant of a request passed by middleware.
+func requestGrant(r *http.Request) *grant {
+	if g, ok := r.Context().Value(grantKey{}).(*grant); ok {
+		return g
+	}
+	return &grant{}
+}
+
+// allStreams lets only clients that may receive every stream through: the
+// state endpoints mix all streams, so they are closed to tokens limited to
+// some.
+func allStreams(next http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		if g := requestGrant(r); g.streams != nil {
+			http.Error(rw, "token limited to streams "+strings.Join(sortedKeys(g.streams), ","), http.StatusForbidden)
+			return
+		}
+		next(rw, r)
+	}
+}
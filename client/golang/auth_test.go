@@ -0,0 +1,266 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var jwtNow = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// signJWT makes a compact JWS of claims with alg, signed with key: a
+// secret for HS256, a private key for RS256 and ES256, nothing for none.
+func signJWT(t *testing.T, alg string, key any, claims map[string]any) string {
+	t.Helper()
+	part := func(v any) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := part(map[string]string{"alg": alg, "typ": "JWT"}) + "." + part(claims)
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		var err error
+		if sig, err = rsa.SignPKCS1v15(nil, k, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// writePublicKey writes key's public key as PEM to a file and returns its
+// path and contents.
+func writePublicKey(t *testing.T, key crypto.Signer) (string, []byte) {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path, b
+}
+
+func TestVerifyJWT(t *testing.T) {
+	secret := []byte("s3cret")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRSA, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherEC, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaFile, rsaPEM := writePublicKey(t, rsaKey)
+	ecFile, _ := writePublicKey(t, ecKey)
+
+	hs, err := newAuthenticator("", string(secret), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := newAuthenticator("", "", rsaFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	es, err := newAuthenticator("", "", ecFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := float64(jwtNow.Add(time.Hour).Unix())
+	valid := map[string]any{"sub": "booth", "exp": exp}
+	expired := map[string]any{"sub": "booth", "exp": float64(jwtNow.Add(-time.Second).Unix())}
+	noExp := map[string]any{"sub": "booth"}
+	early := map[string]any{"sub": "booth", "exp": exp, "nbf": float64(jwtNow.Add(time.Minute).Unix())}
+	started := map[string]any{"sub": "booth", "exp": exp, "nbf": float64(jwtNow.Add(-time.Minute).Unix())}
+
+	for _, tc := range []struct {
+		name  string
+		auth  *authenticator
+		token string
+		err   string // "" if valid
+	}{
+		{"HS256 valid", hs, signJWT(t, "HS256", secret, valid), ""},
+		{"HS256 nbf passed", hs, signJWT(t, "HS256", secret, started), ""},
+		{"HS256 expired", hs, signJWT(t, "HS256", secret, expired), "token expired"},
+		{"HS256 without exp", hs, signJWT(t, "HS256", secret, noExp), "token has no expiry"},
+		{"HS256 not valid yet", hs, signJWT(t, "HS256", secret, early), "token not valid yet"},
+		{"HS256 wrong secret", hs, signJWT(t, "HS256", []byte("guess"), valid), "unauthorized"},
+		{"HS256 alg none", hs, signJWT(t, "none", nil, valid), "unauthorized"},
+		{"HS256 given an RS256 token", hs, signJWT(t, "RS256", rsaKey, valid), "unauthorized"},
+
+		{"RS256 valid", rs, signJWT(t, "RS256", rsaKey, valid), ""},
+		{"RS256 expired", rs, signJWT(t, "RS256", rsaKey, expired), "token expired"},
+		{"RS256 without exp", rs, signJWT(t, "RS256", rsaKey, noExp), "token has no expiry"},
+		{"RS256 other key", rs, signJWT(t, "RS256", otherRSA, valid), "unauthorized"},
+		{"RS256 alg none", rs, signJWT(t, "none", nil, valid), "unauthorized"},
+		// Alg confusion: an HMAC keyed with the public key, which is no
+		// secret, must not pass for a signature.
+		{"RS256 given HS256 with the public key", rs, signJWT(t, "HS256", rsaPEM, valid), "unauthorized"},
+		{"RS256 given ES256", rs, signJWT(t, "ES256", ecKey, valid), "unauthorized"},
+
+		{"ES256 valid", es, signJWT(t, "ES256", ecKey, valid), ""},
+		{"ES256 expired", es, signJWT(t, "ES256", ecKey, expired), "token expired"},
+		{"ES256 without exp", es, signJWT(t, "ES256", ecKey, noExp), "token has no expiry"},
+		{"ES256 not valid yet", es, signJWT(t, "ES256", ecKey, early), "token not valid yet"},
+		{"ES256 other key", es, signJWT(t, "ES256", otherEC, valid), "unauthorized"},
+		{"ES256 alg none", es, signJWT(t, "none", nil, valid), "unauthorized"},
+		{"ES256 given RS256", es, signJWT(t, "RS256", rsaKey, valid), "unauthorized"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g, err := tc.auth.verifyJWT(tc.token, jwtNow)
+			switch {
+			case tc.err == "" && err != nil:
+				t.Fatalf("refused: %v", err)
+			case tc.err == "" && g.client != "jwt booth":
+				t.Errorf("client %q, want jwt booth", g.client)
+			case tc.err != "" && (err == nil || err.Error() != tc.err):
+				t.Errorf("error %v, want %s", err, tc.err)
+			}
+		})
+	}
+
+	// Claims changed after signing.
+	token := signJWT(t, "HS256", secret, valid)
+	parts := strings.Split(token, ".")
+	forged, _ := json.Marshal(map[string]any{"sub": "admin", "exp": exp})
+	parts[1] = base64.RawURLEncoding.EncodeToString(forged)
+	if _, err := hs.verifyJWT(strings.Join(parts, "."), jwtNow); err != errUnauthorized {
+		t.Errorf("token with changed claims: %v, want unauthorized", err)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	open, _ := newAuthenticator("", "", "", 0)
+	if g, err := open.authorize("", "192.0.2.1:5000"); err != nil || g.client != "192.0.2.1" || g.streams != nil {
+		t.Errorf("without auth: %+v, %v; want the client's address and every stream", g, err)
+	}
+
+	a, err := newAuthenticator("one, two", "s3cret", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, err := a.authorize("two", "192.0.2.1:5000"); err != nil || g.client != "token 2" {
+		t.Errorf("static token: %+v, %v", g, err)
+	}
+	for _, token := range []string{"", "three", "a.b.c"} {
+		if _, err := a.authorize(token, "192.0.2.1:5000"); err != errUnauthorized {
+			t.Errorf("token %q: %v, want unauthorized", token, err)
+		}
+	}
+	// authorize checks against the time now.
+	claims := map[string]any{"exp": float64(time.Now().Add(time.Hour).Unix()), "streams": []string{"deckA"}}
+	g, err := a.authorize(signJWT(t, "HS256", []byte("s3cret"), claims), "192.0.2.1:5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !g.allows("deckA") || g.allows("deckB") {
+		t.Errorf("grant for streams [deckA] allows deckA %v, deckB %v", g.allows("deckA"), g.allows("deckB"))
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	secret := []byte("s3cret")
+	a, err := newAuthenticator("", string(secret), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := float64(time.Now().Add(time.Hour).Unix())
+	every := signJWT(t, "HS256", secret, map[string]any{"sub": "display", "exp": exp})
+	deckA := signJWT(t, "HS256", secret, map[string]any{"sub": "deckA", "exp": exp, "streams": []string{"deckA"}})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /feed", func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte(requestGrant(r).client))
+	})
+	mux.HandleFunc("GET /state", allStreams(func(rw http.ResponseWriter, r *http.Request) {}))
+	h := a.middleware(mux)
+	serve := func(path string, setup func(*http.Request)) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		if setup != nil {
+			setup(r)
+		}
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, r)
+		return rw
+	}
+
+	rw := serve("/feed", nil)
+	if rw.Code != http.StatusUnauthorized || rw.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("without a token: %d, WWW-Authenticate %q", rw.Code, rw.Header().Get("WWW-Authenticate"))
+	}
+	if rw := serve("/feed", func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+every) }); rw.Code != http.StatusOK || rw.Body.String() != "jwt display" {
+		t.Errorf("Bearer token: %d %q", rw.Code, rw.Body)
+	}
+
+	// A ?token= is kept in a cookie, which then authorizes on its own.
+	rw = serve("/feed?token="+every, nil)
+	cookies := rw.Result().Cookies()
+	if rw.Code != http.StatusOK || len(cookies) != 1 {
+		t.Fatalf("?token=: %d with cookies %v", rw.Code, cookies)
+	}
+	c := cookies[0]
+	if c.Name != authCookie || c.Value != every || !c.HttpOnly || c.SameSite != http.SameSiteStrictMode || c.Secure {
+		t.Errorf("cookie over HTTP: %+v", c)
+	}
+	if rw := serve("/feed", func(r *http.Request) { r.AddCookie(c) }); rw.Code != http.StatusOK || rw.Body.String() != "jwt display" {
+		t.Errorf("cookie: %d %q", rw.Code, rw.Body)
+	}
+	rw = serve("/feed?token="+every, func(r *http.Request) { r.Header.Set("X-Forwarded-Proto", "https") })
+	if cookies := rw.Result().Cookies(); len(cookies) != 1 || !cookies[0].Secure {
+		t.Errorf("cookie behind an HTTPS proxy: %v, want Secure", cookies)
+	}
+	if rw := serve("/feed", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: authCookie, Value: "forged"}) }); rw.Code != http.StatusUnauthorized {
+		t.Errorf("forged cookie: %d, want 401", rw.Code)
+	}
+
+	// Endpoints that mix streams refuse tokens limited to some.
+	if rw := serve("/state", func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+deckA) }); rw.Code != http.StatusForbidden {
+		t.Errorf("stream-limited token on /state: %d, want 403", rw.Code)
+	}
+	if rw := serve("/state", func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+every) }); rw.Code != http.StatusOK {
+		t.Errorf("unlimited token on /state: %d, want 200", rw.Code)
+	}
+}
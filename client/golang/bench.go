@@ -0,0 +1,274 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/tracks"
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"google.golang.org/protobuf/proto"
+)
+
+// The bench subcommand (tracks-recv-go bench) measures how fast this
+// receiver can go, to check performance tuning. It first decodes and
+// formats a synthetic mix of envelopes in process, for the CPU cost alone,
+// and then floods a multicast group on this host with the same mix at
+// rising rates, received through the normal socket, decoder and queue,
+// until the share of events that never reach dispatch passes -max-loss.
+// Allocations are counted with the runtime's memory statistics, so they
+// include everything the process allocates in the meantime.
+
+// benchEnvelopes is the synthetic mix: one analysis frame of the frame-rate
+// features (the bulk of a dense stream) with a beat and an onset.
+func benchEnvelopes() []*trackspb.Envelope {
+	values := func(n int) []float32 {
+		v := make([]float32, n)
+		for i := range v {
+			v[i] = float32(i+1) / float32(n)
+		}
+		return v
+	}
+	return []*trackspb.Envelope{
+		{Event: &trackspb.Envelope_Mfcc{Mfcc: &trackspb.Mfcc{Values: values(13)}}},
+		{Event: &trackspb.Envelope_Chroma{Chroma: &trackspb.Chroma{Values: values(12)}}},
+		{Event: &trackspb.Envelope_BandsMel{BandsMel: &trackspb.BandsMel{Values: values(40)}}},
+		{Event: &trackspb.Envelope_Loudness{Loudness: &trackspb.Loudness{Value: -14.2}}},
+		{Event: &trackspb.Envelope_Energy{Energy: &trackspb.Energy{Value: 0.031}}},
+		{Event: &trackspb.Envelope_SpectralCentroid{SpectralCentroid: &trackspb.SpectralCentroid{Value: 1843.5}}},
+		{Event: &trackspb.Envelope_SpectralFlux{SpectralFlux: &trackspb.SpectralFlux{Value: 0.0172}}},
+		{Event: &trackspb.Envelope_Pitch{Pitch: &trackspb.Pitch{Frequency: 220.4, Confidence: 0.91}}},
+		{Event: &trackspb.Envelope_Onset{Onset: &trackspb.Onset{Strength: 0.64}}},
+		{Event: &trackspb.Envelope_Beat{Beat: &trackspb.Beat{Confidence: 0.88}}},
+	}
+}
+
+// benchCorpus serializes the mix over a minute of track time.
+func benchCorpus() ([][]byte, error) {
+	mix := benchEnvelopes()
+	var corpus [][]byte
+	for i := 0; i < 6000; i++ {
+		env := mix[i%len(mix)]
+		env.Timestamp = float64(i/len(mix)) * 0.1
+		env.StreamId = "bench"
+		b, err := proto.Marshal(env)
+		if err != nil {
+			return nil, err
+		}
+		corpus = append(corpus, b)
+	}
+	return corpus, nil
+}
+
+// benchResult is the cost of one run over the corpus.
+type benchResult struct {
+	events  int
+	elapsed time.Duration
+	mallocs uint64
+	bytes   uint64
+}
+
+func (r benchResult) String() string {
+	n := float64(max(r.events, 1))
+	return fmt.Sprintf("%10.0f events/s %8.2f µs/event %6.1f allocs/event %6.0f B/event",
+		float64(r.events)/r.elapsed.Seconds(), float64(r.elapsed.Microseconds())/n, float64(r.mallocs)/n, float64(r.bytes)/n)
+}
+
+// measure runs f until d has passed and counts what it allocated. f handles
+// one packet and reports whether it yielded an event.
+func measure(corpus [][]byte, d time.Duration, f func([]byte) bool) benchResult {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	var r benchResult
+	start := time.Now()
+	for i := 0; ; i++ {
+		// Checking the clock every packet would show in the figures.
+		if i%1024 == 0 && time.Since(start) >= d {
+			break
+		}
+		if f(corpus[i%len(corpus)]) {
+			r.events++
+		}
+	}
+	r.elapsed = time.Since(start)
+	runtime.ReadMemStats(&after)
+	r.mallocs = after.Mallocs - before.Mallocs
+	r.bytes = after.TotalAlloc - before.TotalAlloc
+	return r
+}
+
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	group := fs.String("multicast-group", "239.255.0.99", "Multicast group to flood (packets do not leave this host)")
+	port := fs.Int("port", 5999, "UDP port")
+	rate := fs.Float64("rate", 10000, "First send rate, in events per second")
+	maxRate := fs.Float64("max-rate", 2e6, "Highest send rate to try")
+	step := fs.Float64("step", 2, "Factor the send rate grows by between steps")
+	duration := fs.Duration("duration", 2*time.Second, "Time spent on each measurement")
+	queueSize := fs.Int("queue", 1024, "Events buffered between reception and handling, as -queue")
+	decoders := fs.Int("decoders", 1, "Goroutines decoding envelopes in the loopback test, as -decoders")
+	maxLoss := fs.Float64("max-loss", 1, "Percentage of lost events that marks the drop point")
+	fs.Parse(args)
+	if *rate <= 0 || *step <= 1 || *decoders < 1 {
+		fmt.Fprintf(os.Stderr, "Error: -rate must be positive, -step above 1 and -decoders at least 1\n")
+		os.Exit(1)
+	}
+
+	corpus, err := benchCorpus()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	size := 0
+	for _, b := range corpus {
+		size += len(b)
+	}
+	fmt.Printf("Synthetic mix: %d event types, %d bytes per envelope on average.\n\n",
+		len(benchEnvelopes()), size/len(corpus))
+
+	prios := defaultPriorityMap()
+	fec := newFECDecoder()
+	decode := func(pkt []byte) *trackspb.Envelope {
+		for _, payload := range fec.push("bench", pkt) {
+			env := &trackspb.Envelope{}
+			if proto.Unmarshal(payload, env) == nil {
+				prios.classify(env)
+				return env
+			}
+		}
+		return nil
+	}
+	fmt.Println("In process:")
+	fmt.Printf("  decode            %s\n", measure(corpus, *duration, func(pkt []byte) bool {
+		return decode(pkt) != nil
+	}))
+	fmt.Printf("  decode + format   %s\n\n", measure(corpus, *duration, func(pkt []byte) bool {
+		env := decode(pkt)
+		if env == nil {
+			return false
+		}
+		io.WriteString(io.Discard, formatEvent(env))
+		return true
+	}))
+
+	if err := benchLoopback(corpus, *group, *port, *rate, *maxRate, *step, *duration, *queueSize, *decoders, *maxLoss); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// benchLoopback sends the corpus to the group at rising rates and counts
+// the events that make it through reception to dispatch.
+func benchLoopback(corpus [][]byte, group string, port int, rate, maxRate, step float64, d time.Duration, queueSize, decoders int, maxLoss float64) error {
+	src, err := newUDPSource(group, port, nil, "")
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: net.ParseIP(group), Port: port})
+	if err != nil {
+		src.Close()
+		return fmt.Errorf("send: %w", err)
+	}
+	defer conn.Close()
+	if err := keepOnHost(conn); err != nil {
+		src.Close()
+		return fmt.Errorf("send: %w", err)
+	}
+
+	queue := newEventQueue(queueSize)
+	var dispatched atomic.Int64
+	done := make(chan struct{})
+	// The corpus repeats, so duplicate suppression stays off.
+	go receive(src, tracks.SystemClock{}, nil, newFECDecoder(), nil, defaultPriorityMap(), nil, new(atomic.Pointer[string]), queue, nil, decoders)
+	go func() {
+		defer close(done)
+		for env := queue.pop(); env != nil; env = queue.pop() {
+			io.WriteString(io.Discard, formatEvent(env))
+			dispatched.Add(1)
+		}
+	}()
+	defer func() {
+		src.Close()
+		queue.close()
+		<-done
+	}()
+
+	fmt.Printf("Loopback %s:%d, %d decoder(s), %s per rate:\n", group, port, decoders, d)
+	fmt.Printf("  %10s %10s %10s %10s %10s %8s %8s\n", "RATE/S", "SENT", "RECEIVED", "QUEUE DROP", "SOCKET", "LOSS", "ALLOCS")
+	sustained := 0.0
+	for ; rate <= maxRate; rate *= step {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		dropsBefore := queue.droppedCounts()
+		startCount := dispatched.Load()
+
+		sent, elapsed, err := benchSend(conn, corpus, rate, d)
+		if err != nil {
+			return err
+		}
+		// Let the receiver catch up with what is already buffered.
+		for last := int64(-1); dispatched.Load() != last; {
+			last = dispatched.Load()
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		runtime.ReadMemStats(&after)
+		received := int(dispatched.Load() - startCount)
+		dropsAfter := queue.droppedCounts()
+		queueDrops := 0
+		for p := range dropsAfter {
+			queueDrops += dropsAfter[p] - dropsBefore[p]
+		}
+		socketLoss := max(sent-received-queueDrops, 0)
+		loss := 100 * float64(sent-received) / float64(max(sent, 1))
+		sendRate := float64(sent) / elapsed.Seconds()
+		fmt.Printf("  %10.0f %10d %10d %10d %10d %7.2f%% %8.1f\n", sendRate, sent, received, queueDrops, socketLoss,
+			loss, float64(after.Mallocs-before.Mallocs)/float64(max(received, 1)))
+
+		if loss > maxLoss {
+			fmt.Printf("\nDrop point: %.0f events/s (", sendRate)
+			if sustained > 0 {
+				fmt.Printf("%.0f events/s sustained with at most %g%% loss", sustained, maxLoss)
+			} else {
+				fmt.Printf("already lossy at the first rate; try a lower -rate")
+			}
+			fmt.Println(").")
+			return nil
+		}
+		sustained = sendRate
+		if sendRate < 0.9*rate {
+			fmt.Printf("\nThe sender topped out at %.0f events/s before the receiver lost events.\n", sendRate)
+			return nil
+		}
+	}
+	fmt.Printf("\nNo drop point up to %.0f events/s.\n", sustained)
+	return nil
+}
+
+// benchSend sends corpus packets at rate for d, in bursts every
+// millisecond, and returns the number sent and the time taken.
+func benchSend(conn *net.UDPConn, corpus [][]byte, rate float64, d time.Duration) (int, time.Duration, error) {
+	sent := 0
+	start := time.Now()
+	tick := time.NewTicker(time.Millisecond)
+	defer tick.Stop()
+	for {
+		elapsed := time.Since(start)
+		if elapsed >= d {
+			return sent, elapsed, nil
+		}
+		for due := int(rate * elapsed.Seconds()); sent < due; sent++ {
+			if _, err := conn.Write(corpus[sent%len(corpus)]); err != nil {
+				return sent, elapsed, fmt.Errorf("send: %w", err)
+			}
+		}
+		<-tick.C
+	}
+}
@@ -0,0 +1,24 @@
+//go:build linux
+
+package main
+
+import (
+	"net"
+	"syscall"
+)
+
+// keepOnHost sets a multicast TTL of 0, so the benchmark's packets are
+// looped back to this host and never reach the network.
+func keepOnHost(conn *net.UDPConn) error {
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	if err := rc.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MULTICAST_TTL, 0)
+	}); err != nil {
+		return err
+	}
+	return serr
+}
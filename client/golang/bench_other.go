@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+import "net"
+
+// keepOnHost leaves the default multicast TTL of 1 elsewhere: the
+// benchmark's packets also reach the local network segment.
+func keepOnHost(conn *net.UDPConn) error { return nil }
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"google.golang.org/protobuf/proto"
+)
+
+// The cat subcommand (tracks-recv-go cat) prints .trk recordings as event
+// lines, or converts them to the jsonl or csv of -out, or to a new
+// recording, which also repairs a damaged one. Recordings are read as
+// -transport=replay reads them (see recording.go): one cut short or damaged
+// gives every intact record, with a note of what was lost.
+
+func runCat(args []string) {
+	fs := flag.NewFlagSet("cat", flag.ExitOnError)
+	format := fs.String("format", "", "Output format: text (event lines), jsonl, csv or trk (default: from the -o extension, or text)")
+	outPath := fs.String("o", "", "Write to this file instead of standard output")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s cat [flags] recording.trk...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *format == "" {
+		if *format = outputFormatFor(*outPath); *format == "" {
+			*format = "text"
+		}
+	}
+
+	// Writing to an input would read back what is written.
+	if out, err := os.Stat(*outPath); err == nil {
+		for _, path := range fs.Args() {
+			if in, err := os.Stat(path); err == nil && os.SameFile(in, out) {
+				fmt.Fprintf(os.Stderr, "Error: -o %s is one of the recordings\n", *outPath)
+				os.Exit(1)
+			}
+		}
+	}
+
+	var w eventWriter
+	var err error
+	switch {
+	case *format == "text" && *outPath != "":
+		var f *os.File
+		if f, err = os.Create(*outPath); err == nil {
+			w = &textWriter{f: f, w: bufio.NewWriter(f)}
+		}
+	case *format == "text":
+		w = &textWriter{f: os.Stdout, w: bufio.NewWriter(os.Stdout)}
+	case *outPath != "":
+		w, err = newEventWriter(*format, *outPath)
+	default:
+		w, err = newEventWriterTo(*format, os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, path := range fs.Args() {
+		if err := catRecording(path, w); err != nil {
+			fmt.Fprintf(os.Stderr, "cat: %v\n", err)
+			failed = true
+		}
+	}
+	if err := w.close(); err != nil {
+		fmt.Fprintf(os.Stderr, "cat: %v\n", err)
+		failed = true
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// catRecording writes every intact record of the recording at path to w.
+func catRecording(path string, w eventWriter) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := newTrkReader(f, path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	undecodable := 0
+	for {
+		received, payload, err := r.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		env := &trackspb.Envelope{}
+		if err := proto.Unmarshal(payload, env); err != nil {
+			undecodable++
+			continue
+		}
+		schemas.observe(env)
+		if err := w.write(env, received); err != nil {
+			return err
+		}
+	}
+	if d := r.damage(); d != "" {
+		fmt.Fprintf(os.Stderr, "cat: %s: %s; %d records read\n", path, d, r.records)
+	}
+	if undecodable > 0 {
+		fmt.Fprintf(os.Stderr, "cat: %s: %d records are not envelopes\n", path, undecodable)
+	}
+	return nil
+}
+
+// textWriter writes event lines, as the receiver prints them.
+type textWriter struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+func (t *textWriter) write(env *trackspb.Envelope, _ time.Time) error {
+	_, err := fmt.Fprintln(t.w, formatEvent(env))
+	return err
+}
+
+func (t *textWriter) close() error { return flushClose(t.w, t.f) }
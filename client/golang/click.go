@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Metronome click (-click). Plays a short tone on every beat, accented on
+// downbeats, so beat tracking can be checked by ear against the source.
+// Audio is rendered here as 16-bit mono PCM and piped to a command-line
+// player, so no audio library or cgo is needed. Samples are written just
+// ahead of the wall clock, keeping the player's buffer — and so the added
+// latency — small.
+//
+// -click-offset shifts every click: positive values delay them; negative
+// values play them early to make up for output latency, by predicting each
+// beat from the previous beat and the recent beat interval.
+const (
+	clickRate     = 48000
+	clickBlock    = clickRate / 100 // samples per write, 10 ms
+	clickLead     = 40 * time.Millisecond
+	clickLength   = clickRate * 30 / 1000 // 30 ms tone
+	clickSameBeat = 60 * time.Millisecond // beat and downbeat closer than this are one beat
+)
+
+// clickPlayers are tried in order when -click-player isn't given; each
+// reads raw s16le mono PCM at clickRate from stdin.
+var clickPlayers = [][]string{
+	{"aplay", "-q", "-t", "raw", "-f", "S16_LE", "-r", "48000", "-c", "1", "-"},
+	{"pw-play", "--format", "s16", "--rate", "48000", "--channels", "1", "-"},
+	{"paplay", "--raw", "--format=s16le", "--rate=48000", "--channels=1"},
+	{"ffplay", "-nodisp", "-loglevel", "quiet", "-f", "s16le", "-ar", "48000", "-ac", "1", "-"},
+	{"play", "-q", "-t", "raw", "-r", "48000", "-e", "signed", "-b", "16", "-c", "1", "-"},
+}
+
+type click struct {
+	at   time.Time
+	down bool
+}
+
+type clickOutput struct {
+	cmd    *exec.Cmd
+	w      io.WriteCloser
+	offset time.Duration
+	volume float64
+
+	mu      sync.Mutex
+	pending []click
+	last    click // latest scheduled click, for merging beat and downbeat
+
+	// Beat prediction, for negative offsets.
+	lastBeat  time.Time
+	intervals []time.Duration
+	sinceDown int // beats since the last downbeat, -1 before the first
+	meter     int // beats per bar, 0 until two downbeats were seen
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newClickOutput(player string, offset time.Duration, volume float64) (*clickOutput, error) {
+	if volume <= 0 || volume > 1 {
+		return nil, fmt.Errorf("volume must be between 0 and 1")
+	}
+	var args []string
+	if player != "" {
+		args = strings.Fields(player)
+	} else {
+		for _, p := range clickPlayers {
+			if _, err := exec.LookPath(p[0]); err == nil {
+				args = p
+				break
+			}
+		}
+		if args == nil {
+			return nil, fmt.Errorf("no audio player found (tried aplay, pw-play, paplay, ffplay, play); set one with -click-player")
+		}
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty player command")
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	c := &clickOutput{
+		cmd:       cmd,
+		w:         w,
+		offset:    offset,
+		volume:    volume,
+		sinceDown: -1,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go c.run()
+	return c, nil
+}
+
+func (c *clickOutput) handle(env *trackspb.Envelope, received time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch env.Event.(type) {
+	case *trackspb.Envelope_Beat:
+		c.beat(received, false)
+	case *trackspb.Envelope_Downbeat:
+		c.beat(received, true)
+	case *trackspb.Envelope_TrackStart, *trackspb.Envelope_TrackEnd, *trackspb.Envelope_TrackAbort,
+		*trackspb.Envelope_TimelineReset:
+		c.pending = nil
+		c.lastBeat = time.Time{}
+		c.intervals = nil
+		c.sinceDown, c.meter = -1, 0
+	}
+}
+
+func (c *clickOutput) beat(at time.Time, down bool) {
+	same := !c.lastBeat.IsZero() && at.Sub(c.lastBeat) < clickSameBeat
+	if !same {
+		if !c.lastBeat.IsZero() {
+			if iv := at.Sub(c.lastBeat); iv > 200*time.Millisecond && iv < 2*time.Second {
+				c.intervals = append(c.intervals, iv)
+				if len(c.intervals) > 8 {
+					c.intervals = c.intervals[1:]
+				}
+			}
+		}
+		c.lastBeat = at
+		if c.sinceDown >= 0 {
+			c.sinceDown++
+		}
+	}
+	if down && c.sinceDown != 0 {
+		if c.sinceDown > 0 {
+			c.meter = c.sinceDown
+		}
+		c.sinceDown = 0
+	}
+
+	if c.offset >= 0 {
+		c.schedule(click{at.Add(c.offset), down})
+		return
+	}
+	if same {
+		// This beat's click was predicted from the previous one and has
+		// played already.
+		return
+	}
+	iv := c.interval()
+	if iv == 0 {
+		// No tempo yet: play this beat now, late.
+		c.schedule(click{at, down})
+		return
+	}
+	next := c.meter > 0 && c.sinceDown >= 0 && c.sinceDown+1 == c.meter
+	c.schedule(click{at.Add(iv + c.offset), next})
+}
+
+// interval is the median of the recent beat intervals, or 0.
+func (c *clickOutput) interval() time.Duration {
+	if len(c.intervals) < 2 {
+		return 0
+	}
+	s := slices.Clone(c.intervals)
+	slices.Sort(s)
+	return s[len(s)/2]
+}
+
+// schedule queues a click, merging it into the previous one when they
+// belong to the same beat.
+func (c *clickOutput) schedule(k click) {
+	if d := k.at.Sub(c.last.at); !c.last.at.IsZero() && d > -clickSameBeat && d < clickSameBeat {
+		if k.down && !c.last.down {
+			for i := range c.pending {
+				if c.pending[i].at.Equal(c.last.at) {
+					c.pending[i].down = true
+				}
+			}
+			c.last.down = true
+		}
+		return
+	}
+	c.last = k
+	c.pending = append(c.pending, k)
+}
+
+// run renders and writes PCM in real time until closed.
+func (c *clickOutput) run() {
+	defer close(c.done)
+	type voice struct {
+		pos  int
+		down bool
+	}
+	var voices []voice
+	buf := make([]byte, clickBlock*2)
+	samples := make([]float64, clickBlock)
+	start := time.Now()
+	for n := 0; ; n += clickBlock {
+		blockStart := start.Add(time.Duration(n) * time.Second / clickRate)
+		blockEnd := blockStart.Add(time.Duration(clickBlock) * time.Second / clickRate)
+		if wait := time.Until(blockStart.Add(-clickLead)); wait > 0 {
+			select {
+			case <-c.stop:
+				return
+			case <-time.After(wait):
+			}
+		}
+		select {
+		case <-c.stop:
+			return
+		default:
+		}
+
+		// Start the clicks due in this block; late ones start right away.
+		c.mu.Lock()
+		kept := c.pending[:0]
+		for _, k := range c.pending {
+			if k.at.Before(blockEnd) {
+				off := int(k.at.Sub(blockStart) * clickRate / time.Second)
+				voices = append(voices, voice{pos: -max(off, 0), down: k.down})
+			} else {
+				kept = append(kept, k)
+			}
+		}
+		c.pending = kept
+		c.mu.Unlock()
+
+		clear(samples)
+		live := voices[:0]
+		for _, v := range voices {
+			freq, gain := 1000.0, 0.6
+			if v.down {
+				freq, gain = 1500, 1
+			}
+			for i := range samples {
+				p := v.pos + i
+				if p < 0 || p >= clickLength {
+					continue
+				}
+				t := float64(p) / clickRate
+				samples[i] += gain * math.Sin(2*math.Pi*freq*t) * math.Exp(-t*150)
+			}
+			v.pos += clickBlock
+			if v.pos < clickLength {
+				live = append(live, v)
+			}
+		}
+		voices = live
+
+		for i, s := range samples {
+			s = max(min(s*c.volume, 1), -1)
+			binary.LittleEndian.PutUint16(buf[2*i:], uint16(int16(s*32767)))
+		}
+		if _, err := c.w.Write(buf); err != nil {
+			fmt.Fprintf(os.Stderr, "click: %v\n", err)
+			return
+		}
+	}
+}
+
+func (c *clickOutput) close() {
+	close(c.stop)
+	<-c.done
+	c.w.Close()
+	c.cmd.Wait()
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client statistics: every -serve subscriber and /ws WebSocket with its
+// filter, what it was sent and its send-queue backlog, in the /stats
+// snapshots and the -stats view, so operators can spot slow consumers. A
+// client whose queue is half full or more is marked slow: it is falling
+// behind and will lose low-priority events first.
+type clientStatus struct {
+	Server      string   `json:"server"` // serve or web
+	Address     string   `json:"address"`
+	Client      string   `json:"client"` // as counted by -client-limit
+	Filter      string   `json:"filter"`
+	Streams     []string `json:"streams,omitempty"` // the token's streams
+	Connected   float64  `json:"connected"`         // seconds
+	EventsPerS  float64  `json:"events_per_sec"`
+	BytesPerSec float64  `json:"bytes_per_sec"`
+	Sent        int      `json:"sent"`
+	SentBytes   int      `json:"sent_bytes"`
+	Depth       int      `json:"depth"`
+	Capacity    int      `json:"capacity"`
+	Dropped     int      `json:"dropped"`
+	Slow        bool     `json:"slow"`
+}
+
+// clientReporter is a server with clients.
+type clientReporter interface {
+	clientStatuses(now time.Time) []clientStatus
+}
+
+// feedStats counts what one client was sent. Rates are averaged over the
+// last statsWindow complete seconds, as in liveStats.
+type feedStats struct {
+	server, address, client, filter string
+	streams                         []string
+	queue                           *eventQueue
+	started                         time.Time
+
+	mu      sync.Mutex
+	total   statsCount
+	seconds [statsWindow + 1]int64 // the Unix second each bucket counts
+	buckets [statsWindow + 1]statsCount
+}
+
+func newFeedStats(server, address string, g *grant, filter string, queue *eventQueue) *feedStats {
+	f := &feedStats{server: server, address: address, client: g.client, filter: filter, queue: queue, started: time.Now()}
+	if g.streams != nil {
+		f.streams = sortedKeys(g.streams)
+	}
+	return f
+}
+
+// count records an envelope of size bytes sent at now.
+func (f *feedStats) count(size int, now time.Time) {
+	sec := now.Unix()
+	i := int(sec % int64(len(f.buckets)))
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.seconds[i] != sec {
+		f.seconds[i], f.buckets[i] = sec, statsCount{}
+	}
+	f.buckets[i].events++
+	f.buckets[i].bytes += size
+	f.total.events++
+	f.total.bytes += size
+}
+
+func (f *feedStats) status(now time.Time) clientStatus {
+	st := clientStatus{
+		Server:    f.server,
+		Address:   f.address,
+		Client:    f.client,
+		Filter:    f.filter,
+		Streams:   f.streams,
+		Connected: now.Sub(f.started).Seconds(),
+		Depth:     f.queue.len(),
+		Capacity:  f.queue.limit,
+	}
+	for _, n := range f.queue.droppedCounts() {
+		st.Dropped += n
+	}
+	st.Slow = st.Depth*2 >= st.Capacity
+
+	sec := now.Unix()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	st.Sent, st.SentBytes = f.total.events, f.total.bytes
+	// Only complete seconds since the client connected count.
+	n := min(statsWindow, int(st.Connected))
+	if n == 0 {
+		return st
+	}
+	for i, s := range f.seconds {
+		if s >= sec-int64(n) && s < sec {
+			st.EventsPerS += float64(f.buckets[i].events)
+			st.BytesPerSec += float64(f.buckets[i].bytes)
+		}
+	}
+	st.EventsPerS /= float64(n)
+	st.BytesPerSec /= float64(n)
+	return st
+}
+
+// watchClients adds servers' clients to the snapshots.
+func (s *liveStats) watchClients(servers ...clientReporter) {
+	s.mu.Lock()
+	s.servers = append(s.servers, servers...)
+	s.mu.Unlock()
+}
+
+// clientStatuses collects the clients of the watched servers, slowest
+// first: the fullest queues, then the most drops.
+func (s *liveStats) clientStatuses(now time.Time) []clientStatus {
+	s.mu.Lock()
+	servers := s.servers
+	s.mu.Unlock()
+	clients := []clientStatus{}
+	for _, srv := range servers {
+		clients = append(clients, srv.clientStatuses(now)...)
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		a, b := clients[i], clients[j]
+		if a.Depth != b.Depth {
+			return a.Depth > b.Depth
+		}
+		if a.Dropped != b.Dropped {
+			return a.Dropped > b.Dropped
+		}
+		return a.Server+a.Address < b.Server+b.Address
+	})
+	return clients
+}
+
+// subscriptionFilter is a SUBSCRIBE line as shown in the client statistics,
+// without its token.
+func subscriptionFilter(line string) string {
+	fields := strings.Fields(line)
+	kept := fields[:0]
+	for _, f := range fields[min(1, len(fields)):] {
+		if !strings.HasPrefix(f, "token=") {
+			kept = append(kept, f)
+		}
+	}
+	return strings.Join(kept, " ")
+}
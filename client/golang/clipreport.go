@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Clipping incidents (-clip-report, and the track summary's "clipping"):
+// saturation events, which span their duration, and loudness.peak values
+// above clipPeakThreshold, merged into one incident when they come within
+// clipMergeGap of each other. Each incident is graded by how long it stayed
+// saturated and how far its peak went over the threshold:
+//
+//	critical  clipCriticalSeconds of saturation or clipCriticalOver dB over
+//	major     clipMajorSeconds of saturation, clipMajorOver dB over, or
+//	          clipMajorEvents events
+//	minor     anything else
+const (
+	clipMergeGap        = 1.0 // seconds
+	clipCriticalSeconds = 0.5
+	clipCriticalOver    = 3.0 // dB
+	clipMajorSeconds    = 0.1
+	clipMajorOver       = 1.0 // dB
+	clipMajorEvents     = 3
+)
+
+// clipPeakThreshold is the loudness.peak level above which a peak is an
+// overage, set by -clip-peak.
+var clipPeakThreshold = -1.0
+
+type clipIncident struct {
+	Start     float64  `json:"start"`
+	Duration  float64  `json:"duration"`
+	Severity  string   `json:"severity"`       // minor, major or critical
+	Saturated float64  `json:"saturated"`      // seconds of saturation
+	Peak      *float64 `json:"peak,omitempty"` // highest loudness.peak over the threshold
+	Over      float64  `json:"over,omitempty"` // dB the peak went over it
+	Events    int      `json:"events"`
+}
+
+// clipIncidents lists d's incidents in time order.
+func clipIncidents(d *trackData) []clipIncident {
+	type span struct {
+		start, end, saturated, peak float64
+		isPeak                      bool
+	}
+	var spans []span
+	for _, p := range d.series["saturation"] {
+		spans = append(spans, span{start: p.t, end: p.t + max(p.v, 0), saturated: max(p.v, 0)})
+	}
+	for _, t := range d.marks["saturation"] {
+		spans = append(spans, span{start: t, end: t})
+	}
+	for _, p := range d.series["loudness.peak"] {
+		if p.v > clipPeakThreshold {
+			spans = append(spans, span{start: p.t, end: p.t, peak: p.v, isPeak: true})
+		}
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	var out []clipIncident
+	end := 0.0
+	for _, s := range spans {
+		if len(out) == 0 || s.start > end+clipMergeGap {
+			out = append(out, clipIncident{Start: s.start})
+			end = s.end
+		}
+		inc := &out[len(out)-1]
+		end = max(end, s.end)
+		inc.Duration = round3(end - inc.Start)
+		inc.Saturated = round3(inc.Saturated + s.saturated)
+		inc.Events++
+		if s.isPeak && (inc.Peak == nil || s.peak > *inc.Peak) {
+			peak := round1(s.peak)
+			inc.Peak, inc.Over = &peak, round1(s.peak-clipPeakThreshold)
+		}
+	}
+	for i := range out {
+		out[i].Severity = clipSeverity(out[i])
+	}
+	return out
+}
+
+func clipSeverity(inc clipIncident) string {
+	switch {
+	case inc.Saturated >= clipCriticalSeconds || inc.Over >= clipCriticalOver:
+		return "critical"
+	case inc.Saturated >= clipMajorSeconds || inc.Over >= clipMajorOver || inc.Events >= clipMajorEvents:
+		return "major"
+	}
+	return "minor"
+}
+
+// clipReport is the JSON layout of -clip-report.
+type clipReport struct {
+	File      string         `json:"file"`
+	Title     string         `json:"title"`
+	Stream    string         `json:"stream,omitempty"`
+	Duration  float64        `json:"duration"`
+	Threshold float64        `json:"peak_threshold"`
+	Incidents []clipIncident `json:"incidents"`
+}
+
+// writeClipReport writes d's incidents to path, as CSV for .csv files and
+// JSON otherwise, and returns how many there were. Tracks without incidents
+// get a report too, so a missing file means a track wasn't checked.
+func writeClipReport(path string, d *trackData) (int, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return 0, err
+		}
+	}
+	incidents := clipIncidents(d)
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	w := bufio.NewWriter(f)
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		c := csv.NewWriter(w)
+		c.Write([]string{"start", "time", "duration", "severity", "saturated", "peak", "over", "events"})
+		for _, inc := range incidents {
+			peak, over := "", ""
+			if inc.Peak != nil {
+				peak = strconv.FormatFloat(*inc.Peak, 'f', 1, 64)
+				over = strconv.FormatFloat(inc.Over, 'f', 1, 64)
+			}
+			c.Write([]string{
+				strconv.FormatFloat(inc.Start, 'f', 3, 64), formatClock(inc.Start),
+				strconv.FormatFloat(inc.Duration, 'f', 3, 64), inc.Severity,
+				strconv.FormatFloat(inc.Saturated, 'f', 3, 64), peak, over, strconv.Itoa(inc.Events),
+			})
+		}
+		c.Flush()
+		err = c.Error()
+	} else {
+		r := clipReport{
+			File:      d.start.GetFilename(),
+			Title:     d.meta.displayTitle(d.start),
+			Stream:    d.stream,
+			Duration:  d.duration(),
+			Threshold: clipPeakThreshold,
+			Incidents: incidents,
+		}
+		if r.Incidents == nil {
+			r.Incidents = []clipIncident{}
+		}
+		var b []byte
+		if b, err = json.MarshalIndent(r, "", "  "); err == nil {
+			_, err = w.Write(append(b, '\n'))
+		}
+	}
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+	return len(incidents), flushClose(w, f)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+// Control surfaces: Bitfocus Companion, and Stream Deck through its HTTP
+// request plugins, read the receiver's state from the web server (-web)
+// and, with -web-actions, trigger actions on it. GET /api/companion is the
+// state as flat display strings, ready to be shown on buttons:
+//
+//	{"track":"Artist - Title","bpm":"128","key":"A minor","key_code":"8A",
+//	 "position":"1:23","remaining":"2:10","playing":true,"hold":false,...}
+//
+// and GET /api/companion/<name> one of them as plain text. POST
+// /api/action/<name> runs an action:
+//
+//	hold          freeze the DMX and Hue lights, GPIO pins, serial frames
+//	              and OBS switching, e.g. while someone speaks over the music
+//	release       hand them back to the music
+//	toggle-hold   hold or release
+//	marker        mark the current position of the playing track; markers
+//	              go into track summaries and the "marker" annotation layer
+//
+// Actions change the show, so they are off unless -web-actions is given.
+const companionMarkerQueue = 64
+
+// liveControl is what actions change. It is shared by the web server's
+// handlers and the receive loop.
+type liveControl struct {
+	hold    atomic.Bool
+	markers chan operatorMarker
+}
+
+type operatorMarker struct {
+	stream string
+	t      float64 // stream time
+}
+
+func newLiveControl() *liveControl {
+	return &liveControl{markers: make(chan operatorMarker, companionMarkerQueue)}
+}
+
+// held reports whether live outputs are on hold; a nil control never is.
+func (c *liveControl) held() bool {
+	return c != nil && c.hold.Load()
+}
+
+// pendingMarkers returns the markers set since the last call.
+func (c *liveControl) pendingMarkers() []operatorMarker {
+	if c == nil {
+		return nil
+	}
+	var out []operatorMarker
+	for {
+		select {
+		case m := <-c.markers:
+			out = append(out, m)
+		default:
+			return out
+		}
+	}
+}
+
+// companionVariables is the GET /api/companion payload.
+type companionVariables struct {
+	Track     string `json:"track"`
+	Title     string `json:"title"`
+	Artist    string `json:"artist"`
+	Album     string `json:"album"`
+	Stream    string `json:"stream"`
+	Playing   bool   `json:"playing"`
+	Silent    bool   `json:"silent"`
+	Hold      bool   `json:"hold"`
+	BPM       string `json:"bpm"`
+	Key       string `json:"key"`
+	KeyCode   string `json:"key_code"`
+	Chord     string `json:"chord"`
+	Position  string `json:"position"`
+	Remaining string `json:"remaining"`
+	Loudness  string `json:"loudness"`
+}
+
+func newCompanionVariables(st liveState, hold bool) companionVariables {
+	v := companionVariables{Playing: st.Playing, Silent: st.Silent, Hold: hold, Chord: st.Chord, KeyCode: st.KeyCode}
+	if t := st.Track; t != nil {
+		v.Track, v.Title, v.Artist, v.Album, v.Stream = t.Title, t.Title, t.Artist, t.Album, t.Stream
+		if t.Artist != "" {
+			v.Track = t.Artist + " - " + t.Title
+		}
+		v.Position = shortClock(st.Position)
+		if t.Duration > 0 {
+			v.Remaining = shortClock(math.Max(t.Duration-st.Position, 0))
+		}
+	}
+	if st.BPM > 0 {
+		v.BPM = strconv.Itoa(int(math.Round(st.BPM)))
+	}
+	if st.Key != "" {
+		v.Key = keyLabel(st.Key, st.Scale)
+	}
+	if st.Loudness != nil {
+		v.Loudness = strconv.FormatFloat(*st.Loudness, 'f', 1, 64)
+	}
+	return v
+}
+
+func (w *webServer) companionState() companionVariables {
+	return newCompanionVariables(w.state.snapshot(), w.control.held())
+}
+
+func (w *webServer) handleCompanion(rw http.ResponseWriter, _ *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(w.companionState())
+}
+
+// handleCompanionVariable serves one variable as text, for buttons that
+// show a URL's response.
+func (w *webServer) handleCompanionVariable(rw http.ResponseWriter, r *http.Request) {
+	b, _ := json.Marshal(w.companionState())
+	var vars map[string]any
+	json.Unmarshal(b, &vars)
+	v, ok := vars[r.PathValue("name")]
+	if !ok {
+		http.Error(rw, "unknown variable", http.StatusNotFound)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(rw, v)
+}
+
+// handleAction triggers an action. Control surfaces post without an
+// Origin; browsers always send one, and a page of another site is refused,
+// since a form on it could otherwise post to the receiver.
+func (w *webServer) handleAction(rw http.ResponseWriter, r *http.Request) {
+	if w.control == nil {
+		http.Error(rw, "actions are off (start the receiver with -web-actions)", http.StatusForbidden)
+		return
+	}
+	if !w.allowOrigin(r) {
+		http.Error(rw, "actions from other sites are refused (see -web-origin)", http.StatusForbidden)
+		return
+	}
+	switch r.PathValue("name") {
+	case "hold":
+		w.control.hold.Store(true)
+	case "release":
+		w.control.hold.Store(false)
+	case "toggle-hold":
+		for held := w.control.hold.Load(); !w.control.hold.CompareAndSwap(held, !held); {
+			held = w.control.hold.Load()
+		}
+	case "marker":
+		st := w.state.snapshot()
+		if !st.Playing || st.Track == nil {
+			http.Error(rw, "no track is playing", http.StatusConflict)
+			return
+		}
+		select {
+		case w.control.markers <- operatorMarker{st.Track.Stream, st.Position}:
+		default:
+			http.Error(rw, "too many markers pending", http.StatusServiceUnavailable)
+			return
+		}
+	default:
+		http.Error(rw, "unknown action (want hold, release, toggle-hold or marker)", http.StatusNotFound)
+		return
+	}
+	w.handleCompanion(rw, r)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config files (-config): receiver flags, one per line as name = value,
+// with # comments, e.g.
+//
+//	# booth receiver
+//	continuous = true
+//	alert = click>5/1m,saturation>1s/1m
+//	webhook-on = track.start,quality
+//
+// A flag alone on a line is a boolean set to true. Flags given on the
+// command line win over the file. While the receiver runs the file is
+// checked every configPoll, and when it changes the live settings — the
+// -stream filter, the rules of -alert and -webhook-on, and the thresholds
+// of derived events and track exports — take effect without a restart;
+// changes to other flags are reported as needing one. A line removed from
+// the file puts the flag back to its default. -stream changes at once;
+// the others just before the next envelope is handled, so they never
+// change under an event.
+const configPoll = time.Second
+
+type configFile struct {
+	path   string
+	given  map[string]bool   // set on the command line
+	values map[string]string // the file's values in effect
+	live   map[string]liveSetting
+	mod    time.Time
+	size   int64
+
+	mu      sync.Mutex
+	pending []func()
+}
+
+// liveSetting applies a flag's new value, already set with flag.Set.
+type liveSetting struct {
+	apply func() error
+	now   bool // safe from the watcher; otherwise run between envelopes
+}
+
+// loadConfigFile sets the flags in path that the command line didn't.
+func loadConfigFile(path string) (*configFile, error) {
+	c := &configFile{
+		path:   path,
+		given:  make(map[string]bool),
+		values: make(map[string]string),
+		live:   make(map[string]liveSetting),
+	}
+	flag.Visit(func(f *flag.Flag) { c.given[f.Name] = true })
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	c.mod, c.size = info.ModTime(), info.Size()
+	values, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range sortedKeys(values) {
+		if c.given[name] {
+			continue
+		}
+		if err := flag.Set(name, values[name]); err != nil {
+			return nil, fmt.Errorf("%s: -%s: %v", path, name, err)
+		}
+		c.values[name] = values[name]
+	}
+	return c, nil
+}
+
+// setFromEnv sets each flag in vars, a flag name to environment variable
+// map, to its variable's value when neither the command line nor -config
+// gave it. Secrets are read this way rather than as flag defaults, which
+// -h would print.
+func setFromEnv(vars map[string]string) {
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	for _, name := range sortedKeys(vars) {
+		if v := os.Getenv(vars[name]); v != "" && !given[name] {
+			flag.Set(name, v)
+		}
+	}
+}
+
+func readConfigFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	values := make(map[string]string)
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		name, value, found := strings.Cut(line, "=")
+		name, value = strings.TrimLeft(strings.TrimSpace(name), "-"), strings.TrimSpace(value)
+		fl := flag.Lookup(name)
+		switch {
+		case fl == nil:
+			return nil, fmt.Errorf("%s:%d: unknown flag -%s", path, n, name)
+		case name == "config":
+			return nil, fmt.Errorf("%s:%d: -config can't be set in a config file", path, n)
+		case !found && !isBoolFlag(fl):
+			return nil, fmt.Errorf("%s:%d: -%s needs a value (name = value)", path, n, name)
+		case !found:
+			value = "true"
+		}
+		if _, dup := values[name]; dup {
+			return nil, fmt.Errorf("%s:%d: -%s set twice", path, n, name)
+		}
+		values[name] = value
+	}
+	return values, sc.Err()
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// setLive makes a flag a live setting; apply takes its new value into use.
+func (c *configFile) setLive(name string, now bool, apply func() error) {
+	c.live[name] = liveSetting{apply: apply, now: now}
+}
+
+// watch checks the file for changes until the process exits.
+func (c *configFile) watch() {
+	for range time.Tick(configPoll) {
+		info, err := os.Stat(c.path)
+		if err != nil || info.ModTime().Equal(c.mod) && info.Size() == c.size {
+			continue
+		}
+		c.mod, c.size = info.ModTime(), info.Size()
+		c.reload()
+	}
+}
+
+func (c *configFile) reload() {
+	values, err := readConfigFile(c.path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v; keeping the previous settings\n", err)
+		return
+	}
+	names := sortedKeys(values)
+	for name := range c.values {
+		if _, ok := values[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		value, ok := values[name]
+		old, had := c.values[name]
+		if ok == had && value == old {
+			continue
+		}
+		if ok {
+			c.values[name] = value
+		} else {
+			delete(c.values, name)
+			value = flag.Lookup(name).DefValue
+		}
+		if c.given[name] {
+			fmt.Fprintf(os.Stderr, "config: -%s is set on the command line, which wins over the file\n", name)
+			continue
+		}
+		s, live := c.live[name]
+		if !live {
+			fmt.Fprintf(os.Stderr, "config: -%s changed; restart the receiver to apply it\n", name)
+			continue
+		}
+		change := func() {
+			prev := flag.Lookup(name).Value.String()
+			err := flag.Set(name, value)
+			if err == nil {
+				if err = s.apply(); err != nil {
+					flag.Set(name, prev)
+					s.apply()
+				}
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "config: -%s: %v; keeping %q\n", name, err, prev)
+				return
+			}
+			fmt.Fprintf(os.Stderr, "config: -%s=%s\n", name, value)
+		}
+		if s.now {
+			change()
+		} else {
+			c.mu.Lock()
+			c.pending = append(c.pending, change)
+			c.mu.Unlock()
+		}
+	}
+}
+
+// applyPending applies the changes waiting for the next envelope.
+func (c *configFile) applyPending() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = nil
+	c.mu.Unlock()
+	for _, change := range pending {
+		change()
+	}
+}
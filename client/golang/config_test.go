@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestSetFromEnv(t *testing.T) {
+	given := flag.String("test-env-given", "", "")
+	unset := flag.String("test-env-unset", "", "")
+	fromEnv := flag.String("test-env-secret", "", "")
+	flag.Set("test-env-given", "command line")
+	t.Setenv("TEST_GIVEN", "environment")
+	t.Setenv("TEST_SECRET", "environment")
+	setFromEnv(map[string]string{
+		"test-env-given":  "TEST_GIVEN",
+		"test-env-unset":  "TEST_UNSET",
+		"test-env-secret": "TEST_SECRET",
+	})
+	if *given != "command line" || *unset != "" || *fromEnv != "environment" {
+		t.Errorf("given %q, unset %q, from the environment %q", *given, *unset, *fromEnv)
+	}
+	if f := flag.Lookup("test-env-secret"); f.DefValue != "" {
+		t.Errorf("default %q shown by -h", f.DefValue)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/hex"
+	"expvar"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Corrupt envelopes: payloads that reach the decoder but do not parse as an
+// Envelope. Envelopes carry no checksum, so this is where truncation by an
+// undersized MTU, a sender writing garbage or another protocol on the port
+// shows up. Failures are counted per source, reported when the receiver
+// exits and served under /debug/vars as decode_errors_by_source. With
+// -log-level debug each failure is logged with a hex dump of the payload.
+const hexDumpLimit = 256 // bytes of a payload dumped
+
+// debugLogging is set by -log-level debug.
+var debugLogging bool
+
+type corruptSource struct {
+	Errors    int       `json:"errors"`
+	Bytes     int       `json:"bytes"`
+	LastError string    `json:"last_error"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+type corruptionCounts struct {
+	mu      sync.Mutex
+	sources map[string]*corruptSource
+}
+
+var corruption = &corruptionCounts{sources: make(map[string]*corruptSource)}
+
+func init() {
+	expvar.Publish("decode_errors_by_source", expvar.Func(func() any { return corruption.snapshot() }))
+}
+
+// record counts a payload from src that failed to parse with err, and logs
+// it.
+func (c *corruptionCounts) record(src string, payload []byte, err error) {
+	c.mu.Lock()
+	s := c.sources[src]
+	if s == nil {
+		s = &corruptSource{}
+		c.sources[src] = s
+	}
+	s.Errors++
+	s.Bytes += len(payload)
+	s.LastError = err.Error()
+	s.LastSeen = time.Now()
+	c.mu.Unlock()
+
+	if src == "" {
+		src = "unknown source"
+	}
+	if !debugLogging {
+		fmt.Fprintf(os.Stderr, "failed to parse envelope from %s (%d bytes)\n", src, len(payload))
+		return
+	}
+	dump := payload
+	if len(dump) > hexDumpLimit {
+		dump = dump[:hexDumpLimit]
+	}
+	fmt.Fprintf(os.Stderr, "failed to parse envelope from %s (%d bytes): %v\n%s", src, len(payload), err, hex.Dump(dump))
+	if len(payload) > hexDumpLimit {
+		fmt.Fprintf(os.Stderr, "... %d more bytes\n", len(payload)-hexDumpLimit)
+	}
+}
+
+func (c *corruptionCounts) snapshot() map[string]corruptSource {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]corruptSource, len(c.sources))
+	for src, s := range c.sources {
+		out[src] = *s
+	}
+	return out
+}
+
+// report prints the failures per source, most first.
+func (c *corruptionCounts) report() {
+	snap := c.snapshot()
+	srcs := make([]string, 0, len(snap))
+	for src := range snap {
+		srcs = append(srcs, src)
+	}
+	sort.Slice(srcs, func(i, j int) bool {
+		a, b := snap[srcs[i]], snap[srcs[j]]
+		if a.Errors != b.Errors {
+			return a.Errors > b.Errors
+		}
+		return srcs[i] < srcs[j]
+	})
+	for _, src := range srcs {
+		s := snap[src]
+		name := src
+		if name == "" {
+			name = "unknown source"
+		}
+		fmt.Printf("Failed to parse %d envelope(s) from %s (last: %s).\n", s.Errors, name, s.LastError)
+	}
+}
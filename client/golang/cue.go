@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CUE sheet generation (-cue). For a long capture of the receiver's session
+// (a DJ mix or radio recorded alongside, with -continuous), each received
+// track becomes a CUE track indexed at its offset from the start of the
+// capture. With -cue-segments, segment boundaries inside a track start
+// further CUE tracks, for mixes analyzed as a single file; with
+// -cue-transitions, so do the transitions between the mix's tracks (see
+// transitions.go), each with its overlap as the pregap (INDEX 00). The sheet
+// is rewritten after every track so it survives an interrupted session.
+const cueMaxTracks = 99
+
+type cueEntry struct {
+	title      string
+	performer  string
+	offset     float64  // seconds from the capture start
+	pregap     float64  // seconds of overlap before offset
+	transition []string // the evidence of a transition
+}
+
+type cueSheet struct {
+	path        string
+	audio       string
+	segments    bool
+	transitions bool
+	origin      time.Time // capture start: the first track.start received
+	entries     []cueEntry
+	warned      bool
+}
+
+func newCUESheet(path, audio string, segments, transitions bool) *cueSheet {
+	if audio == "" {
+		audio = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + ".wav"
+	}
+	return &cueSheet{path: path, audio: audio, segments: segments, transitions: transitions}
+}
+
+// add appends a finished track and rewrites the sheet.
+func (c *cueSheet) add(d *trackData) error {
+	if c.origin.IsZero() {
+		c.origin = d.started
+	}
+	offset := d.started.Sub(c.origin).Seconds()
+	title := d.meta.displayTitle(d.start)
+
+	c.entries = append(c.entries, cueEntry{title: title, performer: d.meta.Artist, offset: offset})
+	var parts []cueEntry
+	if c.segments {
+		for _, t := range d.marks["segment.boundary"] {
+			parts = append(parts, cueEntry{offset: offset + t})
+		}
+	}
+	if c.transitions {
+		for _, tr := range trackTransitions(d) {
+			parts = append(parts, cueEntry{offset: offset + tr.End, pregap: tr.End - tr.Start, transition: tr.Evidence})
+		}
+	}
+	sort.SliceStable(parts, func(i, j int) bool { return parts[i].offset < parts[j].offset })
+	for i, p := range parts {
+		p.title, p.performer = fmt.Sprintf("%s (part %d)", title, i+2), d.meta.Artist
+		c.entries = append(c.entries, p)
+	}
+	if len(c.entries) > cueMaxTracks && !c.warned {
+		c.warned = true
+		fmt.Fprintf(os.Stderr, "cue: more than %d tracks; later ones are left out of %s\n", cueMaxTracks, c.path)
+	}
+	return c.write()
+}
+
+func (c *cueSheet) write() error {
+	if dir := filepath.Dir(c.path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	// Write a temporary file and rename it, so players never see a
+	// half-written sheet.
+	tmp := c.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, `REM GENERATOR "tracks"`)
+	fmt.Fprintf(w, "REM DATE %s\n", c.origin.Format("2006-01-02"))
+	fmt.Fprintf(w, "TITLE %s\n", cueQuote("Capture "+c.origin.Format("2006-01-02 15:04:05")))
+	fmt.Fprintf(w, "FILE %s WAVE\n", cueQuote(c.audio))
+	prev := 0.0
+	for i, e := range c.entries[:min(len(c.entries), cueMaxTracks)] {
+		fmt.Fprintf(w, "  TRACK %02d AUDIO\n", i+1)
+		fmt.Fprintf(w, "    TITLE %s\n", cueQuote(e.title))
+		if e.performer != "" {
+			fmt.Fprintf(w, "    PERFORMER %s\n", cueQuote(e.performer))
+		}
+		if len(e.transition) > 0 {
+			fmt.Fprintf(w, "    REM TRANSITION %s\n", strings.Join(e.transition, ","))
+		}
+		// A pregap can't reach back into the track before.
+		if start := max(e.offset-e.pregap, prev); e.pregap > 0 && start < e.offset {
+			fmt.Fprintf(w, "    INDEX 00 %s\n", cueTime(start))
+		}
+		fmt.Fprintf(w, "    INDEX 01 %s\n", cueTime(e.offset))
+		prev = e.offset
+	}
+	if err := flushClose(w, f); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// cueTime formats seconds as mm:ss:ff, with 75 frames per second.
+func cueTime(sec float64) string {
+	frames := int(math.Round(max(sec, 0) * 75))
+	return fmt.Sprintf("%02d:%02d:%02d", frames/75/60, frames/75%60, frames%75)
+}
+
+// cueQuote quotes a CUE string; the format has no escapes, so embedded
+// quotes become apostrophes.
+func cueQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "'") + `"`
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/tracks"
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Dead-air detection (-dead-air), for radio automation monitoring. Two
+// things count as dead air: a silence.start with no silence.end for the
+// configured time, and no events at all for that long — the sender or the
+// network is down, or nothing is playing. Time is measured by the receive
+// clock, since a stalled stream has no track time: every second on the wall
+// clock, and as envelopes arrive on the virtual clock of a replay, which
+// only moves with them. Both are raised and resolved through the alert
+// channels like the -alert rules.
+
+const deadAirRule = "dead air"
+
+type deadAirMonitor struct {
+	limit    time.Duration
+	silences map[string]*silenceState // per stream
+
+	last       time.Time // latest envelope
+	lastStream string
+	lastTS     float64
+	quiet      *alertIncident // raised for missing events, or nil
+	onArrival  bool           // checked as envelopes arrive, without a ticker
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type silenceState struct {
+	since    time.Time // received
+	at       float64   // track time of silence.start
+	incident *alertIncident
+}
+
+func (a *alertManager) startDeadAir(limit time.Duration, clock tracks.Clock) {
+	a.deadAir = deadAirMonitor{
+		limit:    limit,
+		silences: make(map[string]*silenceState),
+	}
+	if _, ok := clock.(*tracks.VirtualClock); ok {
+		// Gaps are only seen when the envelope after them arrives; until
+		// the first, there is nothing to measure from.
+		a.deadAir.onArrival = true
+		return
+	}
+	a.deadAir.last = clock.Now()
+	a.deadAir.stop = make(chan struct{})
+	a.deadAir.done = make(chan struct{})
+	go a.watchDeadAir(clock)
+}
+
+func (a *alertManager) stopDeadAir() {
+	if a.deadAir.stop == nil {
+		return
+	}
+	close(a.deadAir.stop)
+	<-a.deadAir.done
+}
+
+// observeDeadAir follows silences and event arrival; a.mu is held.
+func (a *alertManager) observeDeadAir(env *trackspb.Envelope, received time.Time) {
+	m := &a.deadAir
+	if m.limit == 0 {
+		return
+	}
+	if m.onArrival {
+		a.checkDeadAir(received)
+	}
+	stream, ts := env.GetStreamId(), env.GetTimestamp()
+	if inc := m.quiet; inc != nil {
+		gap := received.Sub(m.last)
+		inc.End, inc.Resolved, inc.Peak = ts, true, gap.Seconds()
+		a.send("RESOLVED", inc, received, fmt.Sprintf("%s: events resumed after %s", deadAirRule, gap.Round(time.Second)))
+		// Only gaps within a track belong in its summary.
+		if a.playing[inc.Stream] && env.GetTrackStart() == nil {
+			a.finished[inc.Stream] = append(a.finished[inc.Stream], inc)
+		}
+		m.quiet = nil
+	}
+	m.last, m.lastStream, m.lastTS = received, stream, ts
+
+	switch env.Event.(type) {
+	case *trackspb.Envelope_SilenceStart:
+		if m.silences[stream] == nil {
+			m.silences[stream] = &silenceState{since: received, at: ts}
+		}
+	case *trackspb.Envelope_SilenceEnd, *trackspb.Envelope_TrackStart, *trackspb.Envelope_TrackEnd, *trackspb.Envelope_TrackAbort:
+		st := m.silences[stream]
+		delete(m.silences, stream)
+		if st == nil || st.incident == nil {
+			return
+		}
+		inc := st.incident
+		inc.End, inc.Peak = ts, received.Sub(st.since).Seconds()
+		if env.GetSilenceEnd() != nil {
+			inc.Resolved = true
+			a.send("RESOLVED", inc, received, fmt.Sprintf("%s in %s: sound at %s after %.0fs", deadAirRule, a.track(stream), formatClock(ts), inc.Peak))
+		}
+		a.finished[stream] = append(a.finished[stream], inc)
+	}
+}
+
+// watchDeadAir checks for dead air every second.
+func (a *alertManager) watchDeadAir(clock tracks.Clock) {
+	m := &a.deadAir
+	defer close(m.done)
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-tick.C:
+			a.mu.Lock()
+			a.checkDeadAir(clock.Now())
+			a.mu.Unlock()
+		}
+	}
+}
+
+// checkDeadAir raises dead air for the silences and the gap in the events
+// that have lasted the limit at now; a.mu is held.
+func (a *alertManager) checkDeadAir(now time.Time) {
+	m := &a.deadAir
+	for _, stream := range sortedKeys(m.silences) {
+		st := m.silences[stream]
+		if st.incident == nil && now.Sub(st.since) >= m.limit {
+			st.incident = &alertIncident{Rule: deadAirRule, Stream: stream, Track: a.titles[stream], Start: st.at}
+			a.send("ALERT", st.incident, now, fmt.Sprintf("%s in %s: silent since %s", deadAirRule, a.track(stream), formatClock(st.at)))
+		}
+	}
+	if m.quiet == nil && !m.last.IsZero() && now.Sub(m.last) >= m.limit {
+		m.quiet = &alertIncident{Rule: deadAirRule, Stream: m.lastStream, Track: a.titles[m.lastStream], Start: m.lastTS}
+		a.send("ALERT", m.quiet, now, fmt.Sprintf("%s: no events for %s", deadAirRule, now.Sub(m.last).Round(time.Second)))
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	"time"
+)
+
+// Diagnostics for long-running receivers (-debug-addr): the Go profiler
+// under /debug/pprof/ and counters under /debug/vars, as JSON from the
+// standard expvar package (which adds cmdline and memstats). The counters
+// are kept whether or not the endpoint is served; they are atomic adds.
+var (
+	packetsReceived      = expvar.NewInt("packets_received")
+	bytesReceived        = expvar.NewInt("bytes_received")
+	envelopesDecoded     = expvar.NewInt("envelopes_decoded")
+	decodeErrors         = expvar.NewInt("decode_errors")
+	fecRecovered         = expvar.NewInt("fec_recovered")
+	fragmentsReceived    = expvar.NewInt("fragments_received")
+	envelopesReassembled = expvar.NewInt("envelopes_reassembled")
+	reassemblyTimeouts   = expvar.NewInt("reassembly_timeouts")
+	timelineResets       = expvar.NewInt("timeline_resets")
+	duplicatesDropped    = expvar.NewInt("duplicates_dropped")
+	eventsDispatched     = expvar.NewInt("events_dispatched")
+)
+
+type debugServer struct {
+	ln  net.Listener
+	srv *http.Server
+}
+
+// newDebugServer serves the profiler and counters on addr, with gauges for
+// queue.
+func newDebugServer(addr string, queue *eventQueue) (*debugServer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("debug: %w", err)
+	}
+	started := time.Now()
+	expvar.Publish("uptime_seconds", expvar.Func(func() any { return time.Since(started).Seconds() }))
+	expvar.Publish("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+	expvar.Publish("queue_length", expvar.Func(func() any { return queue.len() }))
+	expvar.Publish("queue_dropped", expvar.Func(func() any {
+		dropped := queue.droppedCounts()
+		counts := make(map[string]int)
+		for p := priorityLow; p < numPriorities; p++ {
+			counts[p.String()] = dropped[p]
+		}
+		return counts
+	}))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("GET /debug/vars", expvar.Handler())
+	// No write timeout: CPU profiles and traces run for as long as asked.
+	d := &debugServer{ln: ln, srv: &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}}
+
+	go func() {
+		if err := d.srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			fmt.Fprintf(os.Stderr, "debug: %v\n", err)
+		}
+	}()
+	return d, nil
+}
+
+func (d *debugServer) close() {
+	d.srv.Close()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/tracks"
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Parallel decoding (-decoders N). Dense MFCC, chroma and band streams from
+// several analyzers can outrun a single goroutine parsing protobufs, so
+// payloads are handed to a pool of decode workers. Every payload also goes,
+// in arrival order, to a sequencer that waits for its result, so events
+// reach the queue in exactly the order they would without the pool. FEC
+// stays in the reading goroutine, since it keeps per-sender state.
+const decodeBacklog = 256 // payloads in flight per worker
+
+type decodeJob struct {
+	payload  []byte // a copy; the reader's buffer is reused
+	src      string
+	received time.Time
+	env      *trackspb.Envelope // nil if the payload did not parse
+	done     chan struct{}
+}
+
+var decodeJobs = sync.Pool{New: func() any { return &decodeJob{done: make(chan struct{}, 1)} }}
+
+type decodePool struct {
+	work   chan *decodeJob
+	order  chan *decodeJob
+	accept func(env *trackspb.Envelope, size int, src string, received time.Time)
+	wg     sync.WaitGroup
+	done   chan struct{}
+}
+
+// newDecodePool starts n workers; accept is called from the sequencer, one
+// event at a time in arrival order.
+func newDecodePool(n int, accept func(*trackspb.Envelope, int, string, time.Time)) *decodePool {
+	p := &decodePool{
+		work:   make(chan *decodeJob, n*decodeBacklog),
+		order:  make(chan *decodeJob, n*decodeBacklog),
+		accept: accept,
+		done:   make(chan struct{}),
+	}
+	p.wg.Add(n)
+	for range n {
+		go p.worker()
+	}
+	go p.sequence()
+	return p
+}
+
+// decode queues a payload, blocking while the pool is n*decodeBacklog
+// payloads behind.
+func (p *decodePool) decode(payload []byte, src string, received time.Time) {
+	j := decodeJobs.Get().(*decodeJob)
+	j.payload = append(j.payload[:0], payload...)
+	j.src, j.received = src, received
+	p.order <- j
+	p.work <- j
+}
+
+func (p *decodePool) worker() {
+	defer p.wg.Done()
+	for j := range p.work {
+		j.env = decodeEnvelope(j.payload, j.src)
+		j.done <- struct{}{}
+	}
+}
+
+func (p *decodePool) sequence() {
+	defer close(p.done)
+	for j := range p.order {
+		<-j.done
+		if j.env != nil {
+			p.accept(j.env, len(j.payload), j.src, j.received)
+		}
+		j.env = nil
+		decodeJobs.Put(j)
+	}
+}
+
+// close waits for the payloads already queued to be decoded and accepted.
+func (p *decodePool) close() {
+	close(p.work)
+	close(p.order)
+	p.wg.Wait()
+	<-p.done
+}
+
+// decodeEnvelope parses one payload from src in the -wire encoding, or
+// counts it as corrupt (see corrupt.go) and returns nil, also when it
+// parses to an envelope that fails tracks.CheckEnvelope.
+func decodeEnvelope(payload []byte, src string) *trackspb.Envelope {
+	env := &trackspb.Envelope{}
+	err := wire.decode(payload, env)
+	if err == nil {
+		err = tracks.CheckEnvelope(env)
+	}
+	if err != nil {
+		decodeErrors.Add(1)
+		corruption.record(src, payload, err)
+		return nil
+	}
+	envelopesDecoded.Add(1)
+	return env
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"hash/maphash"
+	"time"
+)
+
+// Duplicate suppression (-dedup-window). Bonded NICs, relays and some
+// switch setups deliver multicast packets twice. Envelopes carry no
+// sequence number, but every one holds its timestamp and values, so a
+// payload identical to one seen within the window is taken as a copy and
+// dropped. Payloads are compared by a 64-bit hash, after FEC, so duplicates
+// arriving from different addresses (a relay next to the sender) are
+// caught too. When receiving from several ports, copies are only looked
+// for among packets that arrived on the same port, since analyzers run side
+// by side on one file send identical envelopes.
+const defaultDedupWindow = 2 * time.Second
+
+type dedupEntry struct {
+	hash uint64
+	seen time.Time
+}
+
+type dedupFilter struct {
+	window     time.Duration
+	seed       maphash.Seed
+	hashes     map[uint64]struct{}
+	order      []dedupEntry // arrival order, for expiry
+	suppressed int
+}
+
+func newDedupFilter(window time.Duration) *dedupFilter {
+	return &dedupFilter{window: window, seed: maphash.MakeSeed(), hashes: make(map[uint64]struct{})}
+}
+
+// duplicate reports whether payload was already seen on port (empty when
+// receiving from one) within the window.
+func (f *dedupFilter) duplicate(payload []byte, port string, now time.Time) bool {
+	for len(f.order) > 0 && now.Sub(f.order[0].seen) > f.window {
+		delete(f.hashes, f.order[0].hash)
+		f.order = f.order[1:]
+	}
+	var mh maphash.Hash
+	mh.SetSeed(f.seed)
+	mh.WriteString(port)
+	mh.Write(payload)
+	h := mh.Sum64()
+	if _, ok := f.hashes[h]; ok {
+		f.suppressed++
+		return true
+	}
+	f.hashes[h] = struct{}{}
+	f.order = append(f.order, dedupEntry{h, now})
+	return false
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Receiver-derived events (-derive). A deriver watches the incoming stream
+// and synthesizes envelopes the sender doesn't emit. Derived envelopes carry
+// the timestamp and stream id of the envelope that produced them and are
+// handled exactly like received ones: printed, relayed, written to outputs
+// and included in reports.
+type deriver interface {
+	derive(env *trackspb.Envelope) []*trackspb.Envelope
+}
+
+// eventProcessor filters and transforms the stream (-plugin, -script).
+// process returns what replaces env: env itself, a changed copy or nothing,
+// then any envelopes added. Transport events are always kept, first.
+type eventProcessor interface {
+	process(env *trackspb.Envelope) []*trackspb.Envelope
+	close()
+}
+
+// processAll runs every envelope of envs through p.
+func processAll(p eventProcessor, envs []*trackspb.Envelope) []*trackspb.Envelope {
+	var out []*trackspb.Envelope
+	for _, env := range envs {
+		out = append(out, p.process(env)...)
+	}
+	return out
+}
+
+// deriveConfig holds the tuning flags of all derivers.
+type deriveConfig struct {
+	sectionKernel    int     // novelty smoothing, frames
+	sectionThreshold float64 // standard deviations above the local mean
+	sectionMinGap    float64 // seconds between section changes
+	dropThreshold    float64 // level gain, after over before
+	dropMinGap       float64 // seconds between drops
+
+	trendWindow            float64 // seconds of frames a trend slope is fitted to
+	brightnessThreshold    float64 // centroid change per second, relative to its mean
+	loudnessTrendThreshold float64 // loudness change, dB per second
+
+	grooveInterval float64 // seconds between groove reports
+}
+
+var deriverFactories = map[string]func(*deriveConfig) deriver{
+	"roman.numeral":  func(*deriveConfig) deriver { return &romanDeriver{keys: make(map[string]*trackspb.KeyChange)} },
+	"section.change": func(cfg *deriveConfig) deriver { return newSectionDeriver(cfg) },
+	"drop":           func(cfg *deriveConfig) deriver { return newDropDeriver(cfg) },
+
+	"brightness.rising":  func(cfg *deriveConfig) deriver { return newTrendDeriver("brightness.rising", cfg) },
+	"brightness.falling": func(cfg *deriveConfig) deriver { return newTrendDeriver("brightness.falling", cfg) },
+	"loudness.trend":     func(cfg *deriveConfig) deriver { return newTrendDeriver("loudness.trend", cfg) },
+
+	"groove":       func(cfg *deriveConfig) deriver { return newGrooveDeriver(cfg) },
+	"meter.change": func(*deriveConfig) deriver { return &meterDeriver{streams: make(map[string]*meterState)} },
+}
+
+func deriverNames() string {
+	var names []string
+	for n := range deriverFactories {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+type derivePipeline []deriver
+
+func newDerivePipeline(spec string, cfg *deriveConfig) (derivePipeline, error) {
+	var p derivePipeline
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		f, ok := deriverFactories[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown derived event %q (want %s)", name, deriverNames())
+		}
+		seen[name] = true
+		p = append(p, f(cfg))
+	}
+	return p, nil
+}
+
+// process returns the envelopes derived from env.
+func (p derivePipeline) process(env *trackspb.Envelope) []*trackspb.Envelope {
+	var out []*trackspb.Envelope
+	for _, d := range p {
+		out = append(out, d.derive(env)...)
+	}
+	return out
+}
+
+// derivedEnvelope wraps a derived event with the timestamp and stream of
+// the envelope it was derived from.
+func derivedEnvelope(from *trackspb.Envelope, event any) *trackspb.Envelope {
+	return derivedEnvelopeAt(from, from.GetTimestamp(), event)
+}
+
+// derivedEnvelopeAt is derivedEnvelope for events detected in hindsight,
+// timestamped at t rather than at the envelope that revealed them.
+func derivedEnvelopeAt(from *trackspb.Envelope, t float64, event any) *trackspb.Envelope {
+	env := &trackspb.Envelope{Timestamp: t, StreamId: from.GetStreamId()}
+	switch e := event.(type) {
+	case *trackspb.Groove:
+		env.Event = &trackspb.Envelope_Groove{Groove: e}
+	case *trackspb.MeterChange:
+		env.Event = &trackspb.Envelope_MeterChange{MeterChange: e}
+	case *trackspb.RomanNumeral:
+		env.Event = &trackspb.Envelope_RomanNumeral{RomanNumeral: e}
+	case *trackspb.SectionChange:
+		env.Event = &trackspb.Envelope_SectionChange{SectionChange: e}
+	case *trackspb.Drop:
+		env.Event = &trackspb.Envelope_Drop{Drop: e}
+	case *trackspb.BrightnessRising:
+		env.Event = &trackspb.Envelope_BrightnessRising{BrightnessRising: e}
+	case *trackspb.BrightnessFalling:
+		env.Event = &trackspb.Envelope_BrightnessFalling{BrightnessFalling: e}
+	case *trackspb.LoudnessTrend:
+		env.Event = &trackspb.Envelope_LoudnessTrend{LoudnessTrend: e}
+	default:
+		panic(fmt.Sprintf("derivedEnvelope: unsupported event %T", event))
+	}
+	return env
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// The dissector subcommand (tracks-recv-go dissector) writes a Wireshark
+// dissector for the TRACKS UDP format, in Lua. The message and field tables
+// are generated from the trackspb descriptors, so the dissector always
+// matches the protocol this receiver was built with; the decoding itself is
+// a small protobuf wire-format reader. It shows the FEC and fragment headers
+// (see CLIENT.md), the event name in the Info column, and every field of
+// the event. Fragments are not reassembled; a message of one fragment is
+// decoded like an unfragmented envelope.
+
+func runDissector(args []string) {
+	fs := flag.NewFlagSet("dissector", flag.ExitOnError)
+	port := fs.Int("port", 5000, "UDP port the dissector registers for (changeable in Wireshark's preferences)")
+	outPath := fs.String("o", "", "Write the dissector to this file instead of standard output, e.g. tracks.lua")
+	fs.Parse(args)
+
+	out := io.Writer(os.Stdout)
+	if *outPath != "" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := writeDissector(out, *port); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *outPath != "" {
+		fmt.Fprintf(os.Stderr, "Dissector written to %s; copy it to Wireshark's personal Lua plugins folder.\n", *outPath)
+	}
+}
+
+// writeDissector writes the Lua dissector, registered for port.
+func writeDissector(w io.Writer, port int) error {
+	file := (&trackspb.Envelope{}).ProtoReflect().Descriptor().ParentFile()
+	var msgs []protoreflect.MessageDescriptor
+	var enums []protoreflect.EnumDescriptor
+	var walk func(protoreflect.MessageDescriptors)
+	walk = func(ms protoreflect.MessageDescriptors) {
+		for i := 0; i < ms.Len(); i++ {
+			m := ms.Get(i)
+			msgs = append(msgs, m)
+			for j := 0; j < m.Enums().Len(); j++ {
+				enums = append(enums, m.Enums().Get(j))
+			}
+			walk(m.Messages())
+		}
+	}
+	walk(file.Messages())
+	for i := 0; i < file.Enums().Len(); i++ {
+		enums = append(enums, file.Enums().Get(i))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- TRACKS dissector for Wireshark, generated by `tracks-recv-go dissector`\n")
+	fmt.Fprintf(&b, "-- from %s; regenerate it rather than edit it.\n", file.Path())
+	b.WriteString(dissectorHeader)
+	fmt.Fprintf(&b, "tracks.prefs.port = Pref.uint(\"UDP port\", %d, \"UDP port of the TRACKS stream\")\n\n", port)
+
+	b.WriteString("-- Fields by message and field number: name, kind, message or enum type,\n")
+	b.WriteString("-- and for Envelope's event fields the event name.\n")
+	b.WriteString("local messages = {\n")
+	for _, m := range msgs {
+		fmt.Fprintf(&b, "  [%q] = {\n", m.FullName())
+		fields := m.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			fd := fields.Get(i)
+			typ := ""
+			switch fd.Kind() {
+			case protoreflect.MessageKind, protoreflect.GroupKind:
+				typ = string(fd.Message().FullName())
+			case protoreflect.EnumKind:
+				typ = string(fd.Enum().FullName())
+			}
+			fmt.Fprintf(&b, "    [%d] = {name = %q, kind = %q", fd.Number(), fd.Name(), fd.Kind())
+			if typ != "" {
+				fmt.Fprintf(&b, ", type = %q", typ)
+			}
+			if fd.ContainingOneof() != nil && fd.ContainingOneof().Name() == "event" && m.FullName() == envelopeEventOneof.Parent().FullName() {
+				name := eventNames[fd.Number()]
+				if name == "" {
+					name = string(fd.Name())
+				}
+				fmt.Fprintf(&b, ", event = %q", name)
+			}
+			b.WriteString("},\n")
+		}
+		b.WriteString("  },\n")
+	}
+	b.WriteString("}\n\n")
+
+	b.WriteString("local enums = {\n")
+	for _, e := range enums {
+		fmt.Fprintf(&b, "  [%q] = {", e.FullName())
+		values := e.Values()
+		nums := make([]int, 0, values.Len())
+		names := make(map[int]string)
+		for i := 0; i < values.Len(); i++ {
+			v := values.Get(i)
+			nums = append(nums, int(v.Number()))
+			names[int(v.Number())] = string(v.Name())
+		}
+		sort.Ints(nums)
+		for i, n := range nums {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "[%d] = %q", n, names[n])
+		}
+		b.WriteString("},\n")
+	}
+	b.WriteString("}\n")
+
+	fmt.Fprintf(&b, "\nlocal ENVELOPE = %q\n", envelopeEventOneof.Parent().FullName())
+	b.WriteString(dissectorBody)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+const dissectorHeader = `-- Install: copy into the personal Lua plugins folder shown under
+-- Help > About Wireshark > Folders, then restart Wireshark or reload Lua
+-- plugins (Ctrl+Shift+L). The port can be changed under Preferences >
+-- Protocols > TRACKS, or with Decode As.
+
+local tracks = Proto("tracks", "TRACKS")
+
+local pf = {
+  fec_kind     = ProtoField.uint8("tracks.fec.kind", "FEC kind", base.DEC, {[0] = "data", [1] = "parity"}),
+  fec_block    = ProtoField.uint32("tracks.fec.block", "FEC block"),
+  fec_index    = ProtoField.uint8("tracks.fec.index", "FEC index"),
+  fec_size     = ProtoField.uint8("tracks.fec.size", "FEC block size"),
+  frag_message = ProtoField.uint32("tracks.frag.message", "Fragment message"),
+  frag_index   = ProtoField.uint8("tracks.frag.index", "Fragment index"),
+  frag_count   = ProtoField.uint8("tracks.frag.count", "Fragment count"),
+  event        = ProtoField.string("tracks.event", "Event"),
+  timestamp    = ProtoField.double("tracks.timestamp", "Timestamp"),
+  stream       = ProtoField.string("tracks.stream_id", "Stream"),
+  field        = ProtoField.none("tracks.field", "Field"),
+}
+tracks.fields = {pf.fec_kind, pf.fec_block, pf.fec_index, pf.fec_size,
+                 pf.frag_message, pf.frag_index, pf.frag_count,
+                 pf.event, pf.timestamp, pf.stream, pf.field}
+
+local ef_malformed = ProtoExpert.new("tracks.malformed", "Malformed envelope", expert.group.MALFORMED, expert.severity.ERROR)
+tracks.experts = {ef_malformed}
+
+`
+
+const dissectorBody = `
+local FEC_MAGIC, FRAG_MAGIC = 0xF7, 0xF6
+local MAX_SHOWN = 8 -- values of a repeated field listed before "..."
+
+-- varint reads a base-128 varint at off; 64-bit values lose precision
+-- beyond 2^53, as Lua numbers are doubles.
+local function varint(tvb, off, stop)
+  local v, mul = 0, 1
+  while off < stop do
+    local byte = tvb(off, 1):uint()
+    off = off + 1
+    v = v + (byte % 128) * mul
+    if byte < 128 then return v, off end
+    mul = mul * 128
+  end
+  return nil, off
+end
+
+local function signed(v, bits)
+  if v >= 2 ^ (bits - 1) then return v - 2 ^ bits end
+  return v
+end
+
+local function format(def, v)
+  local kind = def and def.kind or ""
+  if kind == "int32" or kind == "enum" then v = signed(v % 2 ^ 32, 32)
+  elseif kind == "int64" then v = signed(v, 64)
+  elseif kind == "sint32" or kind == "sint64" then
+    if v % 2 == 0 then v = v / 2 else v = -(v + 1) / 2 end
+  elseif kind == "bool" then return tostring(v ~= 0)
+  end
+  if kind == "enum" and enums[def.type] and enums[def.type][v] then
+    return enums[def.type][v] .. " (" .. string.format("%d", v) .. ")"
+  end
+  if kind == "double" or kind == "float" then return string.format("%g", v) end
+  return string.format("%.0f", v)
+end
+
+-- fixed reads a wire type 1 (8-byte) or 5 (4-byte) value.
+local function fixed(def, range)
+  local kind = def and def.kind or ""
+  if kind == "double" or kind == "float" then return range:le_float() end
+  if kind == "sfixed32" then return range:le_int() end
+  if kind == "sfixed64" then return range:le_int64():tonumber() end
+  if range:len() == 8 then return range:le_uint64():tonumber() end
+  return range:le_uint()
+end
+
+local function label(def, num)
+  if def then return def.name end
+  return "field " .. num
+end
+
+local decode_message
+
+-- packed decodes a packed repeated scalar field.
+local function packed(tvb, off, stop, def)
+  local values, n = {}, 0
+  local kind = def.kind
+  while off < stop do
+    local v
+    if kind == "double" or kind == "fixed64" or kind == "sfixed64" then
+      v = fixed(def, tvb(off, 8)); off = off + 8
+    elseif kind == "float" or kind == "fixed32" or kind == "sfixed32" then
+      v = fixed(def, tvb(off, 4)); off = off + 4
+    else
+      v, off = varint(tvb, off, stop)
+      if v == nil then break end
+    end
+    n = n + 1
+    if n <= MAX_SHOWN then values[n] = format(def, v) end
+  end
+  local text = "[" .. table.concat(values, ", ")
+  if n > MAX_SHOWN then text = text .. ", ..." end
+  return text .. "] (" .. n .. " values)"
+end
+
+local packable = {double = true, float = true, int32 = true, int64 = true, uint32 = true,
+  uint64 = true, sint32 = true, sint64 = true, fixed32 = true, fixed64 = true,
+  sfixed32 = true, sfixed64 = true, bool = true, enum = true}
+
+-- decode_message adds the fields of a message between off and stop to
+-- tree. In an envelope it returns the event name.
+decode_message = function(tvb, off, stop, tree, pinfo, name)
+  local fields = messages[name] or {}
+  local event
+  while off < stop do
+    local start = off
+    local key
+    key, off = varint(tvb, off, stop)
+    if key == nil then
+      tree:add_proto_expert_info(ef_malformed, "truncated field key")
+      return event
+    end
+    local num, wt = math.floor(key / 8), key % 8
+    local def = fields[num]
+    if wt == 0 then
+      local v
+      v, off = varint(tvb, off, stop)
+      if v == nil then
+        tree:add_proto_expert_info(ef_malformed, "truncated varint")
+        return event
+      end
+      tree:add(pf.field, tvb(start, off - start)):set_text(label(def, num) .. ": " .. format(def, v))
+    elseif wt == 1 or wt == 5 then
+      local size = (wt == 1) and 8 or 4
+      if off + size > stop then
+        tree:add_proto_expert_info(ef_malformed, "truncated fixed-size value")
+        return event
+      end
+      local range = tvb(off, size)
+      off = off + size
+      if name == ENVELOPE and def and def.name == "timestamp" then
+        tree:add_le(pf.timestamp, range)
+      else
+        tree:add(pf.field, tvb(start, off - start)):set_text(label(def, num) .. ": " .. format(def, fixed(def, range)))
+      end
+    elseif wt == 2 then
+      local len
+      len, off = varint(tvb, off, stop)
+      if len == nil or off + len > stop then
+        tree:add_proto_expert_info(ef_malformed, "truncated length-delimited field")
+        return event
+      end
+      local range = tvb(start, off + len - start)
+      local kind = def and def.kind or ""
+      if kind == "message" then
+        local text = label(def, num)
+        if def.event then
+          event = def.event
+          tree:add(pf.event, range, def.event)
+          text = def.event .. " (" .. def.name .. ")"
+        end
+        local sub = tree:add(pf.field, range):set_text(text)
+        decode_message(tvb, off, off + len, sub, pinfo, def.type)
+      elseif kind == "string" then
+        local s = len > 0 and tvb(off, len):string() or ""
+        if name == ENVELOPE and def.name == "stream_id" then
+          tree:add(pf.stream, range, s)
+        else
+          tree:add(pf.field, range):set_text(label(def, num) .. ": \"" .. s .. "\"")
+        end
+      elseif packable[kind] then
+        tree:add(pf.field, range):set_text(label(def, num) .. ": " .. packed(tvb, off, off + len, def))
+      else
+        local hex = len > 0 and tostring(tvb(off, math.min(len, 32)):bytes()) or ""
+        tree:add(pf.field, range):set_text(label(def, num) .. ": " .. len .. " bytes " .. hex)
+      end
+      off = off + len
+    else
+      tree:add_proto_expert_info(ef_malformed, "invalid wire type " .. wt)
+      return event
+    end
+  end
+  return event
+end
+
+local function dissect_envelope(tvb, off, tree, pinfo)
+  local sub = tree:add(tracks, tvb(off), "Envelope")
+  local event = decode_message(tvb, off, tvb:len(), sub, pinfo, ENVELOPE)
+  pinfo.cols.info:set(event or "envelope")
+end
+
+function tracks.dissector(tvb, pinfo, tree)
+  if tvb:len() == 0 then return 0 end
+  pinfo.cols.protocol = "TRACKS"
+  local root = tree:add(tracks, tvb(), "TRACKS")
+  local off = 0
+
+  if tvb:len() >= 8 and tvb(0, 1):uint() == FEC_MAGIC then
+    local h = root:add(tracks, tvb(0, 8), "FEC header")
+    h:add(pf.fec_kind, tvb(1, 1))
+    h:add(pf.fec_block, tvb(2, 4))
+    h:add(pf.fec_index, tvb(6, 1))
+    h:add(pf.fec_size, tvb(7, 1))
+    if tvb(1, 1):uint() ~= 0 then
+      pinfo.cols.info:set(string.format("FEC parity, block %d (%d packets)", tvb(2, 4):uint(), tvb(7, 1):uint()))
+      return tvb:len()
+    end
+    off = 8
+  end
+
+  if tvb:len() >= off + 8 and tvb(off, 1):uint() == FRAG_MAGIC then
+    local h = root:add(tracks, tvb(off, 8), "Fragment header")
+    h:add(pf.frag_message, tvb(off + 2, 4))
+    h:add(pf.frag_index, tvb(off + 6, 1))
+    h:add(pf.frag_count, tvb(off + 7, 1))
+    local index, count = tvb(off + 6, 1):uint(), tvb(off + 7, 1):uint()
+    if count ~= 1 then
+      pinfo.cols.info:set(string.format("Fragment %d/%d of message %d", index + 1, count, tvb(off + 2, 4):uint()))
+      return tvb:len()
+    end
+    off = off + 8
+  end
+
+  if off < tvb:len() then
+    dissect_envelope(tvb, off, root, pinfo)
+  end
+  return tvb:len()
+end
+
+local registered
+function tracks.prefs_changed()
+  local udp = DissectorTable.get("udp.port")
+  if registered then udp:remove(registered, tracks) end
+  registered = tracks.prefs.port
+  udp:add(registered, tracks)
+end
+tracks.prefs_changed()
+`
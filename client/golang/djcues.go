@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DJ cue points (-dj-cues): where to mix a track in and out, and its drops.
+// The mix-in point is the first strong downbeat after the track's fade in or
+// leading silence, the mix-out point the last strong downbeat before its
+// fade out or trailing silence; a downbeat is strong at cueMinConfidence or
+// more, and when a track has none, any downbeat will do. Every drop gets a
+// cue of its own. Files ending in .xml are a rekordbox collection with the
+// cues as hot cues and memory cues (File → Import Collection in rekordbox,
+// or the rekordbox xml view); others are JSON.
+const (
+	cueMinConfidence = 0.5
+	cueEdgeSilence   = 1.0 // seconds from either end a silence must reach
+	rekordboxHotCues = 8
+)
+
+type djCue struct {
+	Name string  `json:"name"`
+	Kind string  `json:"kind"` // mix-in, mix-out or drop
+	Time float64 `json:"time"`
+	Bar  int     `json:"bar,omitempty"` // of the downbeat, from 1
+}
+
+// cueColors are the rekordbox hot cue colours of each kind.
+var cueColors = map[string][3]int{
+	"mix-in":  {40, 226, 20},
+	"mix-out": {230, 40, 40},
+	"drop":    {255, 160, 0},
+}
+
+// trackCues picks d's cue points, in time order.
+func trackCues(d *trackData) []djCue {
+	type downbeat struct {
+		t, conf float64
+	}
+	var downs []downbeat
+	for _, t := range d.marks["downbeat"] {
+		downs = append(downs, downbeat{t, 1})
+	}
+	for _, p := range d.series["downbeat"] {
+		downs = append(downs, downbeat{p.t, p.v})
+	}
+	sort.SliceStable(downs, func(i, j int) bool { return downs[i].t < downs[j].t })
+	strong := 0.0
+	for _, db := range downs {
+		if db.conf >= cueMinConfidence {
+			strong = cueMinConfidence
+			break
+		}
+	}
+
+	from, to := 0.0, d.duration()
+	for _, f := range trackFades(d) {
+		if f.Kind == "in" && from == 0 {
+			from = f.End
+		}
+		if f.Kind == "out" {
+			to = f.Start
+		}
+	}
+	for _, r := range silenceRegions(d) {
+		if r.start < cueEdgeSilence {
+			from = max(from, r.end)
+		}
+		if r.end > d.duration()-cueEdgeSilence && r.start > from {
+			to = min(to, r.start)
+		}
+	}
+
+	var cues []djCue
+	for i, db := range downs {
+		if db.t >= from && db.conf >= strong {
+			cues = append(cues, djCue{Name: "Mix in", Kind: "mix-in", Time: round3(db.t), Bar: i + 1})
+			break
+		}
+	}
+	for i := len(downs) - 1; i >= 0; i-- {
+		if db := downs[i]; db.t <= to && db.conf >= strong {
+			if len(cues) == 0 || db.t > cues[0].Time {
+				cues = append(cues, djCue{Name: "Mix out", Kind: "mix-out", Time: round3(db.t), Bar: i + 1})
+			}
+			break
+		}
+	}
+	drops := append([]point(nil), d.series["drop"]...)
+	sort.SliceStable(drops, func(i, j int) bool { return drops[i].t < drops[j].t })
+	for i, p := range drops {
+		c := djCue{Name: fmt.Sprintf("Drop %d", i+1), Kind: "drop", Time: round3(p.t)}
+		if j := sort.Search(len(downs), func(k int) bool { return downs[k].t > p.t+beatMergeWindow }); j > 0 {
+			c.Bar = j
+		}
+		cues = append(cues, c)
+	}
+	sort.SliceStable(cues, func(i, j int) bool { return cues[i].Time < cues[j].Time })
+	return cues
+}
+
+// djCueFile is the JSON layout of -dj-cues.
+type djCueFile struct {
+	File     string  `json:"file"`
+	Title    string  `json:"title"`
+	Artist   string  `json:"artist,omitempty"`
+	Duration float64 `json:"duration"`
+	BPM      float64 `json:"bpm,omitempty"`
+	Meter    string  `json:"meter,omitempty"`
+	Cues     []djCue `json:"cues"`
+}
+
+func writeDJCues(path string, d *trackData) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	cf := djCueFile{
+		File:     d.start.GetFilename(),
+		Title:    d.meta.displayTitle(d.start),
+		Artist:   d.meta.Artist,
+		Duration: d.duration(),
+		BPM:      medianTempo(d),
+		Meter:    mainMeter(d),
+		Cues:     trackCues(d),
+	}
+	if cf.BPM == 0 {
+		cf.BPM = beatBPM(eventTimes(d, "beat"))
+	}
+	if cf.Cues == nil {
+		cf.Cues = []djCue{}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if strings.EqualFold(filepath.Ext(path), ".xml") {
+		writeRekordbox(w, d, cf)
+	} else {
+		b, err := json.MarshalIndent(cf, "", "  ")
+		if err != nil {
+			f.Close()
+			return err
+		}
+		w.Write(append(b, '\n'))
+	}
+	return flushClose(w, f)
+}
+
+// writeRekordbox writes a one-track rekordbox collection: the track, its
+// beat grid when it has a tempo and a downbeat, and its cues.
+func writeRekordbox(w *bufio.Writer, d *trackData, cf djCueFile) {
+	attr := func(s string) string {
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(s))
+		return strings.ReplaceAll(b.String(), `"`, "&quot;")
+	}
+	loc := filepath.ToSlash(cf.File)
+	if !strings.HasPrefix(loc, "/") {
+		loc = "/" + loc
+	}
+	location := (&url.URL{Scheme: "file", Host: "localhost", Path: loc}).String()
+
+	fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(w, `<DJ_PLAYLISTS Version="1.0.0">`)
+	fmt.Fprintln(w, `  <PRODUCT Name="tracks" Version="1.0" Company="TRACKS"/>`)
+	fmt.Fprintln(w, `  <COLLECTION Entries="1">`)
+	fmt.Fprintf(w, "    <TRACK TrackID=\"1\" Name=\"%s\" Artist=\"%s\" Album=\"%s\" TotalTime=\"%d\" AverageBpm=\"%.2f\" Location=\"%s\">\n",
+		attr(cf.Title), attr(cf.Artist), attr(d.meta.Album), int(cf.Duration+0.5), cf.BPM, attr(location))
+	downs := eventTimes(d, "downbeat")
+	sort.Float64s(downs)
+	if cf.BPM > 0 && len(downs) > 0 {
+		meter := cf.Meter
+		if meter == "" {
+			meter = "4/4"
+		}
+		fmt.Fprintf(w, "      <TEMPO Inizio=\"%.3f\" Bpm=\"%.2f\" Metro=\"%s\" Battito=\"1\"/>\n", downs[0], cf.BPM, meter)
+	}
+	for i, c := range cf.Cues {
+		rgb := cueColors[c.Kind]
+		if i < rekordboxHotCues {
+			fmt.Fprintf(w, "      <POSITION_MARK Name=\"%s\" Type=\"0\" Start=\"%.3f\" Num=\"%d\" Red=\"%d\" Green=\"%d\" Blue=\"%d\"/>\n",
+				attr(c.Name), c.Time, i, rgb[0], rgb[1], rgb[2])
+		}
+		fmt.Fprintf(w, "      <POSITION_MARK Name=\"%s\" Type=\"0\" Start=\"%.3f\" Num=\"-1\"/>\n", attr(c.Name), c.Time)
+	}
+	fmt.Fprintln(w, `    </TRACK>`)
+	fmt.Fprintln(w, `  </COLLECTION>`)
+	fmt.Fprintln(w, `  <PLAYLISTS>`)
+	fmt.Fprintln(w, `    <NODE Type="0" Name="ROOT" Count="0"/>`)
+	fmt.Fprintln(w, `  </PLAYLISTS>`)
+	fmt.Fprintln(w, `</DJ_PLAYLISTS>`)
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"gopkg.in/yaml.v3"
+)
+
+// DMX lighting output (-dmx). A YAML mapping file assigns events to DMX
+// channels, and a frame loop sends the whole universe over Art-Net or sACN
+// (E1.31) at a fixed rate, as lighting consoles do.
+const (
+	artNetPort = 6454
+	sACNPort   = 5568
+)
+
+// dmxConfig is the mapping file.
+type dmxConfig struct {
+	Protocol string        `yaml:"protocol"` // artnet or sacn
+	Target   string        `yaml:"target"`   // node address; default broadcast (Art-Net) or the universe's multicast group (sACN)
+	Universe int           `yaml:"universe"`
+	FPS      int           `yaml:"fps"`
+	Priority int           `yaml:"priority"` // sACN only
+	Channels []*dmxChannel `yaml:"channels"`
+}
+
+// dmxChannel maps one event to one channel.
+//
+//	flash: jump to value on every event, then fade to 0 over decay
+//	level: follow the event's value, scaled from [min, max] to [0, 255]
+type dmxChannel struct {
+	Channel int           `yaml:"channel"` // 1-512
+	Event   string        `yaml:"event"`
+	Mode    string        `yaml:"mode"`
+	Value   *int          `yaml:"value"` // flash level, default 255
+	Decay   time.Duration `yaml:"decay"`
+	Min     float64       `yaml:"min"`
+	Max     float64       `yaml:"max"`
+
+	level float64   // current level, 0-255
+	fired time.Time // last flash
+}
+
+func loadDMXConfig(path string) (*dmxConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &dmxConfig{Protocol: "artnet", FPS: 40, Priority: 100}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	switch cfg.Protocol {
+	case "artnet":
+		if cfg.Universe < 0 || cfg.Universe > 0x7fff {
+			return nil, fmt.Errorf("%s: Art-Net universe must be 0-32767", path)
+		}
+	case "sacn":
+		if cfg.Universe < 1 || cfg.Universe > 63999 {
+			return nil, fmt.Errorf("%s: sACN universe must be 1-63999", path)
+		}
+		if cfg.Priority < 0 || cfg.Priority > 200 {
+			return nil, fmt.Errorf("%s: sACN priority must be 0-200", path)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unknown protocol %q (want artnet or sacn)", path, cfg.Protocol)
+	}
+	if cfg.FPS < 1 || cfg.FPS > 44 {
+		return nil, fmt.Errorf("%s: fps must be 1-44", path)
+	}
+	if len(cfg.Channels) == 0 {
+		return nil, fmt.Errorf("%s: no channels mapped", path)
+	}
+	for _, ch := range cfg.Channels {
+		if ch.Channel < 1 || ch.Channel > 512 {
+			return nil, fmt.Errorf("%s: channel %d out of range 1-512", path, ch.Channel)
+		}
+		if !isEventName(ch.Event) {
+			return nil, fmt.Errorf("%s: channel %d: unknown event %q", path, ch.Channel, ch.Event)
+		}
+		switch ch.Mode {
+		case "flash":
+			if ch.Value == nil {
+				v := 255
+				ch.Value = &v
+			}
+			if *ch.Value < 0 || *ch.Value > 255 {
+				return nil, fmt.Errorf("%s: channel %d: value must be 0-255", path, ch.Channel)
+			}
+			if ch.Decay == 0 {
+				ch.Decay = 150 * time.Millisecond
+			}
+		case "level":
+			if ch.Min == 0 && ch.Max == 0 {
+				ch.Max = 1
+			}
+			if ch.Min == ch.Max {
+				return nil, fmt.Errorf("%s: channel %d: min and max must differ", path, ch.Channel)
+			}
+		default:
+			return nil, fmt.Errorf("%s: channel %d: unknown mode %q (want flash or level)", path, ch.Channel, ch.Mode)
+		}
+	}
+	return cfg, nil
+}
+
+type dmxOutput struct {
+	cfg   *dmxConfig
+	conn  *net.UDPConn
+	dst   *net.UDPAddr
+	slots int
+	cid   [16]byte // sACN source id
+	seq   byte
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newDMXOutput(path string) (*dmxOutput, error) {
+	cfg, err := loadDMXConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	target := cfg.Target
+	port := artNetPort
+	if cfg.Protocol == "sacn" {
+		port = sACNPort
+		if target == "" {
+			target = fmt.Sprintf("239.255.%d.%d", cfg.Universe>>8, cfg.Universe&0xff)
+		}
+	} else if target == "" {
+		target = "255.255.255.255"
+	}
+	dst, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(target, strconv.Itoa(port)))
+	if err != nil {
+		return nil, fmt.Errorf("dmx: %w", err)
+	}
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, fmt.Errorf("dmx: %w", err)
+	}
+
+	// Send only up to the highest mapped channel; DMX frames must have an
+	// even length.
+	slots := 2
+	for _, ch := range cfg.Channels {
+		slots = max(slots, ch.Channel)
+	}
+	slots += slots % 2
+
+	d := &dmxOutput{
+		cfg:   cfg,
+		conn:  conn,
+		dst:   dst,
+		slots: slots,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	rand.Read(d.cid[:])
+	go d.run()
+	return d, nil
+}
+
+func (d *dmxOutput) handle(env *trackspb.Envelope) {
+	name := eventName(env)
+	v, hasValue := eventValue(env)
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := env.Event.(*trackspb.Envelope_TrackEnd); ok {
+		d.blackoutLocked()
+		return
+	}
+	for _, ch := range d.cfg.Channels {
+		if ch.Event != name {
+			continue
+		}
+		switch ch.Mode {
+		case "flash":
+			ch.fired = now
+		case "level":
+			if hasValue {
+				ch.level = 255 * min(max((v-ch.Min)/(ch.Max-ch.Min), 0), 1)
+			}
+		}
+	}
+}
+
+func (d *dmxOutput) blackoutLocked() {
+	for _, ch := range d.cfg.Channels {
+		ch.level = 0
+		ch.fired = time.Time{}
+	}
+}
+
+func (d *dmxOutput) run() {
+	defer close(d.done)
+	tick := time.NewTicker(time.Second / time.Duration(d.cfg.FPS))
+	defer tick.Stop()
+	for {
+		select {
+		case <-d.stop:
+			// Leave the rig dark rather than frozen on the last frame.
+			d.mu.Lock()
+			d.blackoutLocked()
+			d.mu.Unlock()
+			d.send(d.frame(time.Now()))
+			return
+		case now := <-tick.C:
+			d.send(d.frame(now))
+		}
+	}
+}
+
+// frame computes the channel levels at now. Several mappings on one
+// channel combine by taking the highest level (HTP), as consoles do.
+func (d *dmxOutput) frame(now time.Time) []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data := make([]byte, d.slots)
+	for _, ch := range d.cfg.Channels {
+		level := ch.level
+		if ch.Mode == "flash" && !ch.fired.IsZero() {
+			left := 1 - float64(now.Sub(ch.fired))/float64(ch.Decay)
+			level = float64(*ch.Value) * max(left, 0)
+		}
+		data[ch.Channel-1] = max(data[ch.Channel-1], byte(level+0.5))
+	}
+	return data
+}
+
+func (d *dmxOutput) send(data []byte) {
+	d.seq++
+	if d.seq == 0 && d.cfg.Protocol == "artnet" {
+		d.seq = 1 // 0 disables Art-Net sequencing
+	}
+	var pkt []byte
+	if d.cfg.Protocol == "sacn" {
+		pkt = sACNPacket(d.cid, byte(d.cfg.Priority), d.seq, uint16(d.cfg.Universe), data)
+	} else {
+		pkt = artDMXPacket(d.seq, uint16(d.cfg.Universe), data)
+	}
+	if _, err := d.conn.WriteToUDP(pkt, d.dst); err != nil {
+		fmt.Fprintf(os.Stderr, "dmx: %v\n", err)
+	}
+}
+
+// close blacks out the universe and stops sending.
+func (d *dmxOutput) close() {
+	close(d.stop)
+	<-d.done
+	d.conn.Close()
+}
+
+// artDMXPacket builds an Art-Net ArtDmx packet (Art-Net 4).
+func artDMXPacket(seq byte, universe uint16, data []byte) []byte {
+	pkt := make([]byte, 18, 18+len(data))
+	copy(pkt, "Art-Net\x00")
+	binary.LittleEndian.PutUint16(pkt[8:], 0x5000) // OpDmx
+	binary.BigEndian.PutUint16(pkt[10:], 14)       // protocol version
+	pkt[12] = seq
+	pkt[13] = 0                        // physical port
+	pkt[14] = byte(universe)           // SubUni
+	pkt[15] = byte(universe>>8) & 0x7f // Net
+	binary.BigEndian.PutUint16(pkt[16:], uint16(len(data)))
+	return append(pkt, data...)
+}
+
+// sACNPacket builds an E1.31 data packet.
+func sACNPacket(cid [16]byte, priority, seq byte, universe uint16, data []byte) []byte {
+	n := 126 + len(data)
+	pkt := make([]byte, n)
+	flagsLength := func(off int) {
+		binary.BigEndian.PutUint16(pkt[off:], 0x7000|uint16(n-off))
+	}
+
+	// Root layer
+	binary.BigEndian.PutUint16(pkt[0:], 0x0010) // preamble size
+	copy(pkt[4:], "ASC-E1.17\x00\x00\x00")
+	flagsLength(16)
+	binary.BigEndian.PutUint32(pkt[18:], 0x00000004) // VECTOR_ROOT_E131_DATA
+	copy(pkt[22:38], cid[:])
+
+	// Framing layer
+	flagsLength(38)
+	binary.BigEndian.PutUint32(pkt[40:], 0x00000002) // VECTOR_E131_DATA_PACKET
+	copy(pkt[44:108], "tracks")
+	pkt[108] = priority
+	pkt[111] = seq
+	binary.BigEndian.PutUint16(pkt[113:], universe)
+
+	// DMP layer
+	flagsLength(115)
+	pkt[117] = 0x02                                            // VECTOR_DMP_SET_PROPERTY
+	pkt[118] = 0xa1                                            // address and data type
+	binary.BigEndian.PutUint16(pkt[121:], 1)                   // address increment
+	binary.BigEndian.PutUint16(pkt[123:], uint16(1+len(data))) // property count, including the start code
+	copy(pkt[126:], data)
+	return pkt
+}
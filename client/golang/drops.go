@@ -0,0 +1,177 @@
+package main
+
+import (
+	"math"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Drop detection (-derive=drop). A drop is a sudden, sustained jump in level
+// after a build-up: the mean level over the dropPost seconds after a frame
+// is compared with the dropPre seconds before it, and local maxima of that
+// gain above the threshold become drops. A drop is confirmed dropPost plus
+// dropPre after it happened and is stamped at the jump itself. The level
+// is the energy stream, or loudness converted to power when the sender
+// doesn't emit energy. Confidence grows with the gain and with how much
+// spectral.flux and onset.rate rose over the dropBuild seconds before the
+// drop, the build-up that sets a drop apart from any loud entrance.
+const (
+	dropPre     = 2.0  // seconds of level before a candidate
+	dropPost    = 2.0  // seconds of level after it, and the reporting delay
+	dropBuild   = 8.0  // seconds of flux and onset rate judged for a build-up
+	dropHistory = 40.0 // seconds of level the drop must reach the mean of
+	dropWarmup  = 10.0 // seconds into a track before drops are considered
+	dropMaxRun  = 32.0 // longest build-up reported, seconds
+)
+
+type dropDeriver struct {
+	cfg     *deriveConfig
+	streams map[string]*dropState
+}
+
+type dropState struct {
+	start    float64 // time of the first frame of the track
+	energy   []point
+	loudness []point // as power
+	flux     []point
+	rate     []point
+	gains    []point // gain per evaluated candidate frame
+	checked  float64 // latest candidate judged as a peak
+	last     float64 // time of the last drop
+}
+
+func newDropDeriver(cfg *deriveConfig) *dropDeriver {
+	return &dropDeriver{cfg: cfg, streams: make(map[string]*dropState)}
+}
+
+func (d *dropDeriver) derive(env *trackspb.Envelope) []*trackspb.Envelope {
+	stream := env.GetStreamId()
+	if env.GetTrackStart() != nil || env.GetTimelineReset() != nil {
+		delete(d.streams, stream)
+		return nil
+	}
+	st := d.streams[stream]
+	if st == nil {
+		st = &dropState{start: env.GetTimestamp(), checked: math.Inf(-1), last: math.Inf(-1)}
+		d.streams[stream] = st
+	}
+	t := env.GetTimestamp()
+	switch e := env.Event.(type) {
+	case *trackspb.Envelope_SpectralFlux:
+		st.flux = appendRecent(st.flux, point{t, e.SpectralFlux.GetValue()}, dropBuild+dropPre+dropPost)
+	case *trackspb.Envelope_OnsetRate:
+		st.rate = appendRecent(st.rate, point{t, e.OnsetRate.GetRate()}, dropBuild+dropPre+dropPost)
+	case *trackspb.Envelope_Energy:
+		st.energy = appendRecent(st.energy, point{t, e.Energy.GetValue()}, dropHistory+dropMaxRun)
+		if p, drop := st.push(d.cfg); drop != nil {
+			return []*trackspb.Envelope{derivedEnvelopeAt(env, p, drop)}
+		}
+	case *trackspb.Envelope_Loudness:
+		power := math.Pow(10, e.Loudness.GetValue()/10)
+		st.loudness = appendRecent(st.loudness, point{t, power}, dropHistory+dropMaxRun)
+		if len(st.energy) == 0 {
+			if p, drop := st.push(d.cfg); drop != nil {
+				return []*trackspb.Envelope{derivedEnvelopeAt(env, p, drop)}
+			}
+		}
+	}
+	return nil
+}
+
+// appendRecent appends p and drops points more than keep seconds older.
+func appendRecent(pts []point, p point, keep float64) []point {
+	pts = append(pts, p)
+	i := 0
+	for i < len(pts) && pts[i].t < p.t-keep {
+		i++
+	}
+	return pts[i:]
+}
+
+// push evaluates the newest candidate a dropPost behind the latest level
+// frame, and reports a confirmed drop.
+func (st *dropState) push(cfg *deriveConfig) (float64, *trackspb.Drop) {
+	level := st.energy
+	if len(level) == 0 {
+		level = st.loudness
+	}
+	now := level[len(level)-1].t
+
+	// Gain of every frame that now has a full dropPost after it.
+	from := math.Inf(-1)
+	if len(st.gains) > 0 {
+		from = st.gains[len(st.gains)-1].t
+	}
+	for _, c := range level {
+		if c.t <= from || c.t > now-dropPost {
+			continue
+		}
+		before, ok1 := meanBetween(level, c.t-dropPre, c.t)
+		after, ok2 := meanBetween(level, c.t, c.t+dropPost)
+		if !ok1 || !ok2 || after <= 0 {
+			continue
+		}
+		st.gains = appendRecent(st.gains, point{c.t, after / math.Max(before, after*1e-6)}, 2*dropPre)
+	}
+
+	// A candidate is a peak once the gains a dropPre either side are known.
+	for _, g := range st.gains {
+		if g.t <= st.checked || g.t > st.gains[len(st.gains)-1].t-dropPre {
+			continue
+		}
+		st.checked = g.t
+		if g.v < cfg.dropThreshold || g.t-st.start < dropWarmup || g.t-st.last < cfg.dropMinGap {
+			continue
+		}
+		peak := true
+		for _, o := range st.gains {
+			if math.Abs(o.t-g.t) <= dropPre && (o.v > g.v || (o.v == g.v && o.t < g.t)) {
+				peak = false
+				break
+			}
+		}
+		if !peak {
+			continue
+		}
+		// The drop must lift the level close to its recent average, so swells
+		// within a quiet passage don't count.
+		after, _ := meanBetween(level, g.t, g.t+dropPost)
+		avg, _ := meanBetween(level, g.t-dropHistory, g.t+dropPost)
+		if after < 0.75*avg {
+			continue
+		}
+
+		// The build-up runs back while the level, in one-second means, stays
+		// near where it was just before the drop.
+		before, _ := meanBetween(level, g.t-dropPre, g.t)
+		run := g.t
+		for run > g.t-dropMaxRun {
+			m, ok := meanBetween(level, run-1, run)
+			if !ok || m > 2*before {
+				break
+			}
+			run--
+		}
+
+		build := max(rise(st.flux, g.t), rise(st.rate, g.t))
+		st.last = g.t
+		conf := (1 - cfg.dropThreshold/g.v) * (0.5 + 0.5*build)
+		return g.t, &trackspb.Drop{Confidence: conf, Gain: g.v, Buildup: g.t - run}
+	}
+	return 0, nil
+}
+
+// rise measures how much pts rose over the dropBuild seconds before t,
+// comparing the last quarter of that span with the first: 0 when flat or
+// falling, 1 for a doubling or more.
+func rise(pts []point, t float64) float64 {
+	early, ok1 := meanBetween(pts, t-dropBuild, t-dropBuild*3/4)
+	late, ok2 := meanBetween(pts, t-dropBuild/4, t)
+	if !ok1 || !ok2 || late <= early {
+		return 0
+	}
+	if early <= 0 {
+		return 1
+	}
+	return min(late/early-1, 1)
+}
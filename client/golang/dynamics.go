@@ -0,0 +1,138 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// Dynamics (the track summary's "dynamics"), from the loudness frames and
+// loudness.peak events, taken as dB (or LUFS) like everywhere else in the
+// receiver. Levels are averaged as power.
+//
+//   - dr follows the DR meter: the track is cut into dynamicsBlock blocks,
+//     and dr is the second-highest block peak over the mean level of the
+//     loudest fifth of the blocks, rounded to whole dB.
+//   - crest is the track's peak over its mean level. Frame loudness
+//     smooths the waveform, so this is lower than a sample-peak crest
+//     factor; compare tracks analysed alike.
+//   - lra is the loudness range after EBU Tech 3342: the spread between
+//     the 10th and 95th percentiles of the dynamicsBlock short-term level,
+//     ignoring moments more than lraGate below its mean.
+const (
+	dynamicsBlock = 3.0  // seconds
+	lraGate       = 20.0 // dB
+)
+
+type dynamicsSummary struct {
+	DR    int     `json:"dr"`
+	Crest float64 `json:"crest"` // dB
+	LRA   float64 `json:"lra"`   // dB, or LU for LUFS
+	Peak  float64 `json:"peak"`
+	Mean  float64 `json:"mean"` // power mean of the loudness frames
+}
+
+func powerOf(db float64) float64 { return math.Pow(10, db/10) }
+func levelOf(p float64) float64  { return 10 * math.Log10(max(p, 1e-30)) }
+
+func round1(x float64) float64 { return math.Round(x*10) / 10 }
+
+// trackDynamics measures the track, or returns nil with less than a block
+// of loudness.
+func trackDynamics(d *trackData) *dynamicsSummary {
+	frames := d.series["loudness"]
+	if len(frames) < 2 || frames[len(frames)-1].t-frames[0].t < dynamicsBlock {
+		return nil
+	}
+	start := frames[0].t
+
+	// Blocks for DR, and the track's mean and peak.
+	type block struct {
+		power float64
+		n     int
+		peak  float64
+	}
+	var blocks []block
+	at := func(t float64) *block {
+		i := max(int((t-start)/dynamicsBlock), 0)
+		for len(blocks) <= i {
+			blocks = append(blocks, block{peak: math.Inf(-1)})
+		}
+		return &blocks[i]
+	}
+	total, peak := 0.0, math.Inf(-1)
+	for _, p := range frames {
+		b := at(p.t)
+		b.power += powerOf(p.v)
+		b.n++
+		b.peak = max(b.peak, p.v)
+		total += powerOf(p.v)
+		peak = max(peak, p.v)
+	}
+	for _, p := range d.series["loudness.peak"] {
+		at(p.t).peak = max(at(p.t).peak, p.v)
+		peak = max(peak, p.v)
+	}
+	var levels, peaks []float64
+	for _, b := range blocks {
+		if b.n > 0 {
+			levels = append(levels, levelOf(b.power/float64(b.n)))
+		}
+		if !math.IsInf(b.peak, -1) {
+			peaks = append(peaks, b.peak)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(levels)))
+	sort.Sort(sort.Reverse(sort.Float64Slice(peaks)))
+	loud := levels[:max(len(levels)/5, 1)]
+	loudPower := 0.0
+	for _, l := range loud {
+		loudPower += powerOf(l)
+	}
+	pk2 := peaks[min(1, len(peaks)-1)]
+	mean := levelOf(total / float64(len(frames)))
+
+	return &dynamicsSummary{
+		DR:    int(math.Round(pk2 - levelOf(loudPower/float64(len(loud))))),
+		Crest: round1(peak - mean),
+		LRA:   round1(loudnessRange(frames)),
+		Peak:  round1(peak),
+		Mean:  round1(mean),
+	}
+}
+
+// loudnessRange is the gated spread of the short-term level.
+func loudnessRange(frames []point) float64 {
+	var short []float64
+	sum, j := 0.0, 0
+	for i, p := range frames {
+		sum += powerOf(p.v)
+		for frames[j].t <= p.t-dynamicsBlock {
+			sum -= powerOf(frames[j].v)
+			j++
+		}
+		if p.t-frames[0].t >= dynamicsBlock {
+			short = append(short, levelOf(sum/float64(i-j+1)))
+		}
+	}
+	if len(short) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, l := range short {
+		total += powerOf(l)
+	}
+	gate := levelOf(total/float64(len(short))) - lraGate
+	gated := short[:0]
+	for _, l := range short {
+		if l >= gate {
+			gated = append(gated, l)
+		}
+	}
+	// Levels too extreme to sum leave nothing above the gate.
+	if len(gated) == 0 {
+		return 0
+	}
+	sort.Float64s(gated)
+	at := func(q float64) float64 { return gated[int(math.Round(q*float64(len(gated)-1)))] }
+	return at(0.95) - at(0.1)
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Event names and categories, matching the sender's names in src/events.cpp.
+// Envelope oneof field numbers are grouped by category in ranges of ten (see
+// PROTOBUF.md), which is how categories are derived here.
+
+var eventNames = map[protoreflect.FieldNumber]string{
+	10: "track.start", 11: "track.end", 12: "track.position", 13: "track.abort", 14: "track.prepare", 15: "timeline.reset", 16: "schema",
+	20: "beat", 21: "tempo.change", 22: "downbeat",
+	23: "groove", 24: "meter.change", // derived
+	30: "onset", 31: "onset.rate", 32: "novelty",
+	40: "key.change", 41: "chord.change", 42: "chroma", 43: "tuning", 44: "dissonance", 45: "inharmonicity",
+	46: "roman.numeral", // derived by receivers, see derive.go
+	50: "pitch", 51: "pitch.change", 52: "melody",
+	60: "loudness", 61: "loudness.peak", 62: "energy", 63: "dynamic.change",
+	64: "loudness.trend", // derived
+	70: "silence.start", 71: "silence.end", 72: "gap",
+	80: "spectral.centroid", 81: "spectral.flux", 82: "spectral.complexity", 83: "spectral.contrast",
+	84: "spectral.rolloff", 85: "mfcc", 86: "timbre.change",
+	87: "brightness.rising", 88: "brightness.falling", // derived
+	90: "bands.mel", 91: "bands.bark", 92: "bands.erb", 93: "hfc",
+	100: "segment.boundary", 101: "fade.in", 102: "fade.out",
+	103: "section.change", 104: "drop", // derived
+
+	110: "click", 111: "discontinuity", 112: "noise.burst", 113: "saturation", 114: "hum",
+	120: "envelope", 121: "attack", 122: "decay",
+}
+
+var categoryNames = []string{
+	1: "transport", 2: "rhythm", 3: "onset", 4: "tonal", 5: "pitch", 6: "loudness",
+	7: "silence", 8: "spectral", 9: "bands", 10: "structure", 11: "quality", 12: "envelope",
+}
+
+// continuousEvents are emitted per analysis frame (throttled by the sender's
+// --continuous-interval) rather than at detected moments.
+var continuousEvents = map[string]bool{
+	"onset.rate": true, "novelty": true,
+	"chroma": true, "dissonance": true, "inharmonicity": true,
+	"pitch": true, "melody": true,
+	"loudness": true, "energy": true,
+	"spectral.centroid": true, "spectral.flux": true, "spectral.complexity": true,
+	"spectral.contrast": true, "spectral.rolloff": true, "mfcc": true,
+	"bands.mel": true, "bands.bark": true, "bands.erb": true, "hfc": true,
+	"envelope": true,
+}
+
+var envelopeEventOneof = (&trackspb.Envelope{}).ProtoReflect().Descriptor().Oneofs().ByName("event")
+
+// eventField returns the oneof field number of the envelope's event, or 0
+// if no known event is set.
+func eventField(env *trackspb.Envelope) protoreflect.FieldNumber {
+	fd := env.ProtoReflect().WhichOneof(envelopeEventOneof)
+	if fd == nil {
+		if fd, _ := schemas.unknownEvent(env); fd != nil {
+			return fd.Number()
+		}
+		return 0
+	}
+	return fd.Number()
+}
+
+// eventName returns the dotted event name, e.g. "chord.change", or
+// "unknown". Events this receiver wasn't built with are named from the
+// sender's schema (see schema.go).
+func eventName(env *trackspb.Envelope) string {
+	if name, ok := eventNames[eventField(env)]; ok {
+		return name
+	}
+	if fd, _ := schemas.unknownEvent(env); fd != nil {
+		return schemaEventName(fd)
+	}
+	return "unknown"
+}
+
+// eventCategory returns the category of the envelope's event, e.g. "tonal",
+// or "unknown".
+func eventCategory(env *trackspb.Envelope) string {
+	if c := int(eventField(env)) / 10; c > 0 && c < len(categoryNames) {
+		return categoryNames[c]
+	}
+	return "unknown"
+}
+
+// eventValue returns the main scalar of an event: the confidence or strength
+// of detections, the value of scalar features, a duration or frequency where
+// that is the payload. Vector and empty events report false.
+func eventValue(env *trackspb.Envelope) (float64, bool) {
+	switch e := env.Event.(type) {
+	case *trackspb.Envelope_TrackPosition:
+		return e.TrackPosition.GetPosition(), true
+	case *trackspb.Envelope_Beat:
+		return e.Beat.GetConfidence(), true
+	case *trackspb.Envelope_TempoChange:
+		return e.TempoChange.GetBpm(), true
+	case *trackspb.Envelope_Downbeat:
+		return e.Downbeat.GetConfidence(), true
+	case *trackspb.Envelope_Groove:
+		return e.Groove.GetSwing(), true
+	case *trackspb.Envelope_MeterChange:
+		return e.MeterChange.GetConfidence(), true
+	case *trackspb.Envelope_Onset:
+		return e.Onset.GetStrength(), true
+	case *trackspb.Envelope_OnsetRate:
+		return e.OnsetRate.GetRate(), true
+	case *trackspb.Envelope_Novelty:
+		return e.Novelty.GetValue(), true
+	case *trackspb.Envelope_KeyChange:
+		return e.KeyChange.GetStrength(), true
+	case *trackspb.Envelope_ChordChange:
+		return e.ChordChange.GetStrength(), true
+	case *trackspb.Envelope_Tuning:
+		return e.Tuning.GetFrequency(), true
+	case *trackspb.Envelope_Dissonance:
+		return e.Dissonance.GetValue(), true
+	case *trackspb.Envelope_Inharmonicity:
+		return e.Inharmonicity.GetValue(), true
+	case *trackspb.Envelope_Pitch:
+		return e.Pitch.GetConfidence(), true
+	case *trackspb.Envelope_PitchChange:
+		return e.PitchChange.GetToHz(), true
+	case *trackspb.Envelope_Melody:
+		return e.Melody.GetFrequency(), true
+	case *trackspb.Envelope_Loudness:
+		return e.Loudness.GetValue(), true
+	case *trackspb.Envelope_LoudnessPeak:
+		return e.LoudnessPeak.GetValue(), true
+	case *trackspb.Envelope_Energy:
+		return e.Energy.GetValue(), true
+	case *trackspb.Envelope_DynamicChange:
+		return e.DynamicChange.GetMagnitude(), true
+	case *trackspb.Envelope_LoudnessTrend:
+		return e.LoudnessTrend.GetSlope(), true
+	case *trackspb.Envelope_Gap:
+		return e.Gap.GetDuration(), true
+	case *trackspb.Envelope_SpectralCentroid:
+		return e.SpectralCentroid.GetValue(), true
+	case *trackspb.Envelope_SpectralFlux:
+		return e.SpectralFlux.GetValue(), true
+	case *trackspb.Envelope_SpectralComplexity:
+		return e.SpectralComplexity.GetValue(), true
+	case *trackspb.Envelope_SpectralRolloff:
+		return e.SpectralRolloff.GetValue(), true
+	case *trackspb.Envelope_TimbreChange:
+		return e.TimbreChange.GetDistance(), true
+	case *trackspb.Envelope_BrightnessRising:
+		return e.BrightnessRising.GetSlope(), true
+	case *trackspb.Envelope_BrightnessFalling:
+		return e.BrightnessFalling.GetSlope(), true
+	case *trackspb.Envelope_Hfc:
+		return e.Hfc.GetValue(), true
+	case *trackspb.Envelope_FadeIn:
+		return e.FadeIn.GetEndTime(), true
+	case *trackspb.Envelope_FadeOut:
+		return e.FadeOut.GetStartTime(), true
+	case *trackspb.Envelope_SectionChange:
+		return e.SectionChange.GetConfidence(), true
+	case *trackspb.Envelope_Drop:
+		return e.Drop.GetConfidence(), true
+	case *trackspb.Envelope_Saturation:
+		return e.Saturation.GetDuration(), true
+	case *trackspb.Envelope_Hum:
+		return e.Hum.GetFrequency(), true
+	case *trackspb.Envelope_EnvelopeEvent:
+		return e.EnvelopeEvent.GetValue(), true
+	case *trackspb.Envelope_Attack:
+		return e.Attack.GetLogAttackTime(), true
+	case *trackspb.Envelope_Decay:
+		return e.Decay.GetValue(), true
+	}
+	return 0, false
+}
+
+// isTrackEnd reports whether env ends a track (track.end or track.abort).
+func isTrackEnd(env *trackspb.Envelope) bool {
+	switch env.Event.(type) {
+	case *trackspb.Envelope_TrackEnd, *trackspb.Envelope_TrackAbort:
+		return true
+	}
+	return false
+}
+
+// shiftTimes moves the envelope's timestamp, and the payload fields that
+// hold track times, by offset seconds (-offset-ms).
+func shiftTimes(env *trackspb.Envelope, offset float64) {
+	env.Timestamp += offset
+	switch e := env.Event.(type) {
+	case *trackspb.Envelope_TrackPosition:
+		e.TrackPosition.Position += offset
+	case *trackspb.Envelope_FadeIn:
+		e.FadeIn.EndTime += offset
+	case *trackspb.Envelope_FadeOut:
+		e.FadeOut.StartTime += offset
+	}
+}
+
+// eventRule selects events by name or category, optionally only those
+// whose value (see eventValue) is above a threshold. Webhooks, GPIO pins
+// and serial frames take lists of them.
+type eventRule struct {
+	event     string // event name or, if none is called that, category
+	category  bool
+	threshold float64
+	above     bool // only values above threshold match
+}
+
+// parseEventRules parses "event,category,event>value,...".
+func parseEventRules(spec string) ([]eventRule, error) {
+	var rules []eventRule
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		r, err := parseEventRule(item)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func parseEventRule(item string) (eventRule, error) {
+	var r eventRule
+	event, value, above := strings.Cut(item, ">")
+	r.event = strings.TrimSpace(event)
+	// A name that is both an event and a category ("loudness") means the
+	// event.
+	if !isEventName(r.event) {
+		if !isCategory(r.event) {
+			return r, fmt.Errorf("unknown event or category %q", r.event)
+		}
+		r.category = true
+	}
+	if above {
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return r, fmt.Errorf("invalid threshold in %q", item)
+		}
+		r.threshold, r.above = v, true
+	}
+	return r, nil
+}
+
+// selects reports whether env is an event the rule is about, whatever its
+// value.
+func (r eventRule) selects(env *trackspb.Envelope) bool {
+	return r.event == eventName(env) || r.category && r.event == eventCategory(env)
+}
+
+func (r eventRule) matches(env *trackspb.Envelope) bool {
+	if !r.selects(env) {
+		return false
+	}
+	if !r.above {
+		return true
+	}
+	v, ok := eventValue(env)
+	return ok && v > r.threshold
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Exit codes, for scripts wrapping the receiver. Invalid flags exit with 2
+// from the flag package, before anything else runs.
+const (
+	exitEnded       = 0   // track.end, or a clean stop of a continuous run
+	exitError       = 1   // invalid settings, or a failure to start or receive
+	exitAborted     = 3   // track.abort
+	exitIdle        = 4   // -idle-exit
+	exitInterrupted = 130 // SIGINT or SIGTERM
+)
+
+var exitOutcomes = map[int]string{
+	exitEnded:       "ended",
+	exitError:       "error",
+	exitAborted:     "aborted",
+	exitIdle:        "idle",
+	exitInterrupted: "interrupted",
+}
+
+// runSummary is written to -summary-json on exit, or nil.
+var runSummary *exitSummary
+
+// exit ends the process with code, writing the -summary-json file first.
+func exit(code int) {
+	if runSummary != nil {
+		if err := runSummary.write(code); err != nil {
+			fmt.Fprintf(os.Stderr, "summary-json: %v\n", err)
+		}
+	}
+	os.Exit(code)
+}
+
+type summaryTrack struct {
+	Filename string  `json:"filename"`
+	Stream   string  `json:"stream,omitempty"`
+	Outcome  string  `json:"outcome"` // ended, aborted or incomplete
+	Reason   string  `json:"reason,omitempty"`
+	Duration float64 `json:"duration"` // of the file, from track.start
+	Started  string  `json:"started"`
+}
+
+// exitSummary is the -summary-json file: how the run ended and what it
+// received.
+type exitSummary struct {
+	path    string
+	started time.Time
+
+	mu      sync.Mutex
+	tracks  []*summaryTrack
+	playing map[string]*summaryTrack // per stream
+	written bool
+}
+
+func newExitSummary(path string) *exitSummary {
+	return &exitSummary{path: path, started: time.Now(), playing: make(map[string]*summaryTrack)}
+}
+
+func (s *exitSummary) observe(env *trackspb.Envelope, received time.Time) {
+	stream := env.GetStreamId()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch e := env.Event.(type) {
+	case *trackspb.Envelope_TrackStart:
+		t := &summaryTrack{
+			Filename: filepath.Base(e.TrackStart.GetFilename()),
+			Stream:   stream,
+			Outcome:  "incomplete",
+			Duration: e.TrackStart.GetDuration(),
+			Started:  received.Format(time.RFC3339),
+		}
+		s.tracks = append(s.tracks, t)
+		s.playing[stream] = t
+	case *trackspb.Envelope_TrackEnd:
+		if t := s.playing[stream]; t != nil {
+			t.Outcome = "ended"
+			delete(s.playing, stream)
+		}
+	case *trackspb.Envelope_TrackAbort:
+		if t := s.playing[stream]; t != nil {
+			t.Outcome, t.Reason = "aborted", e.TrackAbort.GetReason()
+			delete(s.playing, stream)
+		}
+	}
+}
+
+func (s *exitSummary) write(code int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Only the first exit counts, e.g. when Ctrl+C comes during shutdown.
+	if s.written {
+		return nil
+	}
+	s.written = true
+	now := time.Now()
+	tracks := s.tracks
+	if tracks == nil {
+		tracks = []*summaryTrack{}
+	}
+	summary := struct {
+		Outcome    string          `json:"outcome"`
+		ExitCode   int             `json:"exit_code"`
+		Started    string          `json:"started"`
+		Finished   string          `json:"finished"`
+		Seconds    float64         `json:"seconds"`
+		Tracks     []*summaryTrack `json:"tracks"`
+		Packets    int64           `json:"packets"`
+		Envelopes  int64           `json:"envelopes"`
+		Errors     int64           `json:"decode_errors"`
+		Duplicates int64           `json:"duplicates"`
+		Recovered  int64           `json:"fec_recovered"`
+		Dispatched int64           `json:"events"`
+	}{
+		Outcome:    exitOutcomes[code],
+		ExitCode:   code,
+		Started:    s.started.Format(time.RFC3339),
+		Finished:   now.Format(time.RFC3339),
+		Seconds:    now.Sub(s.started).Seconds(),
+		Tracks:     tracks,
+		Packets:    packetsReceived.Value(),
+		Envelopes:  envelopesDecoded.Value(),
+		Errors:     decodeErrors.Value(),
+		Duplicates: duplicatesDropped.Value(),
+		Recovered:  fecRecovered.Value(),
+		Dispatched: eventsDispatched.Value(),
+	}
+	b, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(s.path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// shutdownStep closes one output when the receiver stops.
+type shutdownStep struct {
+	name  string // as reported when it is left open, e.g. "-webhook"
+	close func()
+}
+
+// shutdown runs the steps that close the outputs and remembers which are
+// left, so quitting on -drain-timeout can say what it cut off.
+type shutdown struct {
+	mu      sync.Mutex
+	started bool
+	left    []string
+}
+
+func (s *shutdown) run(steps []shutdownStep) {
+	s.mu.Lock()
+	s.started = true
+	for _, st := range steps {
+		s.left = append(s.left, st.name)
+	}
+	s.mu.Unlock()
+	for _, st := range steps {
+		st.close()
+		s.mu.Lock()
+		s.left = s.left[1:]
+		s.mu.Unlock()
+	}
+}
+
+// unclosed returns the outputs not closed yet, and whether closing them
+// has started at all.
+func (s *shutdown) unclosed() ([]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.left...), s.started
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestShutdownUnclosed(t *testing.T) {
+	var s shutdown
+	if left, started := s.unclosed(); started || len(left) != 0 {
+		t.Errorf("before run: %q, started %v", left, started)
+	}
+	closing, release, finished := make(chan bool), make(chan bool), make(chan bool)
+	go func() {
+		s.run([]shutdownStep{
+			{"-pcap", func() {}},
+			{"-webhook", func() { closing <- true; <-release }},
+			{"-out", func() {}},
+		})
+		close(finished)
+	}()
+	<-closing
+	if left, started := s.unclosed(); !started || !slices.Equal(left, []string{"-webhook", "-out"}) {
+		t.Errorf("while closing -webhook: %q, started %v", left, started)
+	}
+	close(release)
+	<-finished
+	if left, _ := s.unclosed(); len(left) != 0 {
+		t.Errorf("after run: %q left", left)
+	}
+}
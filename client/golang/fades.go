@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Fade curves (the track summary's "fades", the fade layer of -labels,
+// -reaper and -sv, and -structure): each fade.in and fade.out is measured
+// on the loudness frames around it. The settled level is the median over
+// fadeSettle after a fade in or before a fade out, the floor the quietest
+// frame within fadeSearch of the fade. The fade starts at the last frame
+// within fadeEdge of one and ends at the first frame within fadeEdge of the
+// other, to the frame rather than to the sender's estimate. Its shape is
+// whichever fits better by least squares: a straight line in dB is an
+// exponential fade, a straight line in amplitude a linear one. Fades less
+// than fadeMinDepth deep, or without the frames to tell, keep the sender's
+// boundaries and no shape.
+const (
+	fadeSettle    = 2.0 // seconds
+	fadeSearch    = 1.0 // seconds
+	fadeEdge      = 1.5 // dB
+	fadeMinDepth  = 6.0 // dB
+	fadeMinFrames = 4
+)
+
+type fadeCurve struct {
+	Kind     string  `json:"kind"` // in or out
+	Start    float64 `json:"start"`
+	End      float64 `json:"end"`
+	Duration float64 `json:"duration"`
+	Shape    string  `json:"shape,omitempty"` // linear or exponential
+	Depth    float64 `json:"depth,omitempty"` // dB between the settled level and the floor
+	Fit      float64 `json:"fit,omitempty"`   // R² of the shape
+	Measured bool    `json:"measured"`        // false: the sender's boundaries
+}
+
+// text labels the fade in annotations: "fade out (exponential)".
+func (f fadeCurve) text() string {
+	if f.Shape == "" {
+		return "fade " + f.Kind
+	}
+	return fmt.Sprintf("fade %s (%s)", f.Kind, f.Shape)
+}
+
+// trackFades measures d's fades, in time order.
+func trackFades(d *trackData) []fadeCurve {
+	frames := append([]point(nil), d.series["loudness"]...)
+	sort.SliceStable(frames, func(i, j int) bool { return frames[i].t < frames[j].t })
+	var out []fadeCurve
+	for _, p := range d.series["fade.in"] {
+		out = append(out, measureFade(frames, "in", p.t, max(p.v, p.t)))
+	}
+	for _, p := range d.series["fade.out"] {
+		end := p.t
+		if end <= p.v {
+			end = d.duration()
+		}
+		out = append(out, measureFade(frames, "out", p.v, end))
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Start < out[j].Start })
+	return out
+}
+
+// pointsBetween returns the points of sorted pts in [from, to].
+func pointsBetween(pts []point, from, to float64) []point {
+	lo := sort.Search(len(pts), func(i int) bool { return pts[i].t >= from })
+	hi := sort.Search(len(pts), func(i int) bool { return pts[i].t > to })
+	if hi < lo {
+		return nil
+	}
+	return pts[lo:hi]
+}
+
+func measureFade(frames []point, kind string, from, to float64) fadeCurve {
+	f := fadeCurve{Kind: kind, Start: from, End: to, Duration: round3(to - from)}
+	settled := pointsBetween(frames, from-fadeSettle, from)
+	if kind == "in" {
+		settled = pointsBetween(frames, to, to+fadeSettle)
+	}
+	span := pointsBetween(frames, from-fadeSearch, to+fadeSearch)
+	if len(settled) == 0 || len(span) < fadeMinFrames {
+		return f
+	}
+	levels := make([]float64, len(settled))
+	for i, p := range settled {
+		levels[i] = p.v
+	}
+	sort.Float64s(levels)
+	level := levels[len(levels)/2]
+	low := 0
+	for i, p := range span {
+		if p.v < span[low].v {
+			low = i
+		}
+	}
+	floor := span[low].v
+	if level-floor < fadeMinDepth {
+		return f
+	}
+
+	var s, e int
+	if kind == "in" {
+		for s = low; s+1 < len(span) && span[s+1].v <= floor+fadeEdge; s++ {
+		}
+		for e = s; e+1 < len(span) && span[e].v < level-fadeEdge; e++ {
+		}
+	} else {
+		for e = low; e > 0 && span[e-1].v <= floor+fadeEdge; e-- {
+		}
+		for s = e; s > 0 && span[s].v < level-fadeEdge; s-- {
+		}
+	}
+	curve := span[s : e+1]
+	if len(curve) < fadeMinFrames {
+		return f
+	}
+	f.Start, f.End = round3(curve[0].t), round3(curve[len(curve)-1].t)
+	f.Duration = round3(f.End - f.Start)
+	f.Depth, f.Measured = round1(level-floor), true
+
+	amp := make([]point, len(curve))
+	for i, p := range curve {
+		amp[i] = point{p.t, math.Pow(10, p.v/20)}
+	}
+	exp, lin := fitR2(curve), fitR2(amp)
+	f.Shape, f.Fit = "exponential", exp
+	if lin > exp {
+		f.Shape, f.Fit = "linear", lin
+	}
+	f.Fit = math.Round(f.Fit*100) / 100
+	return f
+}
+
+// fitR2 is the coefficient of determination of the least-squares line
+// through pts.
+func fitR2(pts []point) float64 {
+	slope, mean := fitSlope(pts)
+	mt := 0.0
+	for _, p := range pts {
+		mt += p.t
+	}
+	mt /= float64(len(pts))
+	var res, tot float64
+	for _, p := range pts {
+		fit := mean + slope*(p.t-mt)
+		res += (p.v - fit) * (p.v - fit)
+		tot += (p.v - mean) * (p.v - mean)
+	}
+	if tot == 0 {
+		return 0
+	}
+	return max(0, 1-res/tot)
+}
+
+// formatFades renders the fades as one line each for -structure.
+func formatFades(fades []fadeCurve) string {
+	var b strings.Builder
+	b.WriteString("Fades:")
+	for _, f := range fades {
+		fmt.Fprintf(&b, "\n  %s-%s  %s", formatClock(f.Start), formatClock(f.End), f.text())
+		if f.Measured {
+			fmt.Fprintf(&b, ", %.1f dB, fit %.2f", f.Depth, f.Fit)
+		}
+	}
+	return b.String()
+}
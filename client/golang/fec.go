@@ -0,0 +1,169 @@
+package main
+
+import "encoding/binary"
+
+// Forward error correction framing. When the sender runs with --fec N, every
+// datagram carries an 8-byte header and every N data packets are followed by
+// one XOR parity packet:
+//
+//	byte 0    magic 0xF7 (protobuf wire type 7, never a valid Envelope)
+//	byte 1    kind: 0 = data, 1 = parity
+//	bytes 2-5 block id, big-endian
+//	byte 6    index of the data packet within its block
+//	byte 7    block size (parity: number of data packets it covers)
+//
+// The parity payload is the XOR of each data payload prefixed with its 2-byte
+// big-endian length and zero-padded to the longest, so any single lost packet
+// in a block can be rebuilt from the others.
+const (
+	fecMagic      = 0xF7
+	fecKindData   = 0x00
+	fecKindParity = 0x01
+	fecHeaderLen  = 8
+
+	// Blocks kept per source while waiting for late data or parity.
+	fecMaxBlocks = 32
+)
+
+type fecBlock struct {
+	size      int
+	data      [][]byte
+	parity    []byte
+	recovered bool
+}
+
+type fecSource struct {
+	blocks map[uint32]*fecBlock
+	order  []uint32
+}
+
+// fecDecoder unwraps FEC-framed datagrams and rebuilds single lost packets.
+// Unframed datagrams pass through untouched, so it is safe to run against
+// senders that do not use FEC.
+type fecDecoder struct {
+	sources   map[string]*fecSource
+	recovered int
+}
+
+func newFECDecoder() *fecDecoder {
+	return &fecDecoder{sources: make(map[string]*fecSource)}
+}
+
+// push accepts one datagram from src and returns the envelope payloads it
+// yields: the packet itself, a packet rebuilt from parity, or nothing.
+// An unframed packet is returned as is, so it aliases pkt and is only
+// valid until the source's next read; nothing downstream keeps it (dedup
+// keeps a hash, reassembly copies fragments, and decoding copies into the
+// Envelope). The payloads of framed packets are the block's own copies.
+func (d *fecDecoder) push(src string, pkt []byte) [][]byte {
+	if len(pkt) < fecHeaderLen || pkt[0] != fecMagic {
+		return [][]byte{pkt}
+	}
+
+	s := d.sources[src]
+	if s == nil {
+		s = &fecSource{blocks: make(map[uint32]*fecBlock)}
+		d.sources[src] = s
+	}
+
+	id := binary.BigEndian.Uint32(pkt[2:6])
+	index := int(pkt[6])
+	size := int(pkt[7])
+	payload := pkt[fecHeaderLen:]
+
+	b := s.block(id)
+	var out [][]byte
+
+	switch pkt[1] {
+	case fecKindData:
+		if b.size == 0 {
+			b.size = size
+		}
+		b.grow(index + 1)
+		if b.data[index] != nil {
+			return nil // duplicate, or already rebuilt from parity
+		}
+		b.data[index] = append([]byte(nil), payload...)
+		out = append(out, b.data[index])
+	case fecKindParity:
+		if b.parity != nil {
+			return nil
+		}
+		b.size = size
+		b.grow(size)
+		b.parity = append([]byte(nil), payload...)
+	default:
+		return nil
+	}
+
+	if p := b.recover(); p != nil {
+		d.recovered++
+		out = append(out, p)
+	}
+	return out
+}
+
+func (s *fecSource) block(id uint32) *fecBlock {
+	if b, ok := s.blocks[id]; ok {
+		return b
+	}
+	b := &fecBlock{}
+	s.blocks[id] = b
+	s.order = append(s.order, id)
+	if len(s.order) > fecMaxBlocks {
+		delete(s.blocks, s.order[0])
+		s.order = s.order[1:]
+	}
+	return b
+}
+
+func (b *fecBlock) grow(n int) {
+	for len(b.data) < n {
+		b.data = append(b.data, nil)
+	}
+}
+
+// recover rebuilds the single missing data packet once parity and all other
+// packets of the block are present.
+func (b *fecBlock) recover() []byte {
+	if b.parity == nil || b.recovered || len(b.data) != b.size {
+		return nil
+	}
+	missing := -1
+	for i, p := range b.data {
+		if p == nil {
+			if missing >= 0 {
+				return nil
+			}
+			missing = i
+		}
+	}
+	if missing < 0 {
+		return nil
+	}
+
+	acc := append([]byte(nil), b.parity...)
+	for _, p := range b.data {
+		if p == nil {
+			continue
+		}
+		if len(p)+2 > len(acc) {
+			return nil // parity shorter than a data packet: corrupt block
+		}
+		acc[0] ^= byte(len(p) >> 8)
+		acc[1] ^= byte(len(p))
+		for i, c := range p {
+			acc[i+2] ^= c
+		}
+	}
+	if len(acc) < 2 {
+		return nil
+	}
+	n := int(binary.BigEndian.Uint16(acc[:2]))
+	if n+2 > len(acc) {
+		return nil
+	}
+	b.recovered = true
+	b.data[missing] = acc[2 : n+2]
+	return b.data[missing]
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// Fragmentation. When the sender runs with --mtu N, envelopes too large for
+// one packet of that path MTU (MFCC, contrast and band vectors, mostly) are
+// split into fragments, so they survive networks that drop fragmented IP
+// datagrams. Every fragment carries an 8-byte header:
+//
+//	byte 0    magic 0xF6 (protobuf wire type 6, never a valid Envelope)
+//	byte 1    reserved, 0
+//	bytes 2-5 message id, big-endian
+//	byte 6    index of the fragment within the message
+//	byte 7    number of fragments in the message
+//
+// Fragments sit below FEC, so a lost fragment can be rebuilt from parity
+// like any other packet. A message whose fragments have not all arrived
+// within -reassembly-timeout is dropped.
+const (
+	fragMagic     = 0xF6
+	fragHeaderLen = 8
+
+	// Incomplete messages kept per source; the oldest is dropped beyond.
+	fragMaxPending = 64
+)
+
+var reassemblyTimeout = 2 * time.Second
+
+type fragMessage struct {
+	parts [][]byte
+	have  int
+	first time.Time
+}
+
+// fragReassembler joins fragments into envelope payloads. Unfragmented
+// payloads pass through untouched.
+type fragReassembler struct {
+	sources map[string]map[uint32]*fragMessage
+	timeout time.Duration
+}
+
+func newFragReassembler(timeout time.Duration) *fragReassembler {
+	return &fragReassembler{sources: make(map[string]map[uint32]*fragMessage), timeout: timeout}
+}
+
+// push accepts one payload from src and returns the envelope payload it
+// completes, or nil while a message is still missing fragments (or the
+// fragment is malformed).
+func (r *fragReassembler) push(src string, pkt []byte, now time.Time) []byte {
+	if len(pkt) < fragHeaderLen || pkt[0] != fragMagic {
+		return pkt
+	}
+	fragmentsReceived.Add(1)
+	id := binary.BigEndian.Uint32(pkt[2:6])
+	index, count := int(pkt[6]), int(pkt[7])
+	if count == 0 || index >= count {
+		decodeErrors.Add(1)
+		return nil
+	}
+
+	pending := r.sources[src]
+	if pending == nil {
+		pending = make(map[uint32]*fragMessage)
+		r.sources[src] = pending
+	}
+	r.expire(pending, now)
+
+	m := pending[id]
+	if m == nil {
+		if len(pending) >= fragMaxPending {
+			r.dropOldest(pending)
+		}
+		m = &fragMessage{parts: make([][]byte, count), first: now}
+		pending[id] = m
+	}
+	if len(m.parts) != count {
+		// Fragments of one message disagree on its size; it cannot be
+		// joined.
+		delete(pending, id)
+		decodeErrors.Add(1)
+		return nil
+	}
+	if m.parts[index] != nil {
+		return nil // duplicate
+	}
+	m.parts[index] = append([]byte(nil), pkt[fragHeaderLen:]...)
+	m.have++
+	if m.have < count {
+		return nil
+	}
+
+	delete(pending, id)
+	size := 0
+	for _, p := range m.parts {
+		size += len(p)
+	}
+	payload := make([]byte, 0, size)
+	for _, p := range m.parts {
+		payload = append(payload, p...)
+	}
+	envelopesReassembled.Add(1)
+	return payload
+}
+
+// expire drops messages that have waited longer than the timeout.
+func (r *fragReassembler) expire(pending map[uint32]*fragMessage, now time.Time) {
+	for id, m := range pending {
+		if now.Sub(m.first) > r.timeout {
+			delete(pending, id)
+			reassemblyTimeouts.Add(1)
+		}
+	}
+}
+
+func (r *fragReassembler) dropOldest(pending map[uint32]*fragMessage) {
+	var oldest uint32
+	var first time.Time
+	for id, m := range pending {
+		if first.IsZero() || m.first.Before(first) {
+			oldest, first = id, m.first
+		}
+	}
+	delete(pending, oldest)
+	reassemblyTimeouts.Add(1)
+}
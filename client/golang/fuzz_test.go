@@ -0,0 +1,99 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/tracks"
+	"github.com/davesmith10/tracks/client/golang/tracks/trackstest"
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// FuzzDecode passes a datagram through FEC, reassembly and decodeEnvelope
+// in every wire encoding, and each envelope that decodes through
+// formatting, the derived events and the analyses and exports of a track
+// it is played into. The seeds in testdata/fuzz/FuzzDecode are packets
+// captured from the sender with -pcap.
+func FuzzDecode(f *testing.F) {
+	defer setWire("protobuf")
+	f.Fuzz(func(t *testing.T, pkt []byte) {
+		const src = "192.0.2.1:5000"
+		for _, name := range []string{"protobuf", "json", "cbor"} {
+			if err := setWire(name); err != nil {
+				t.Fatal(err)
+			}
+			for _, payload := range newFECDecoder().push(src, pkt) {
+				payload = newFragReassembler(reassemblyTimeout).push(src, payload, tracks.Epoch)
+				if payload == nil {
+					continue
+				}
+				if env := decodeEnvelope(payload, src); env != nil {
+					formatEvent(env)
+					playInTrack(t, env)
+				}
+			}
+		}
+	})
+}
+
+// playInTrack plays env halfway through the Steady120 fixture, through the
+// derived events and into a track whose analyses and exports run at its
+// end. Only panics matter here, not their errors.
+func playInTrack(t *testing.T, env *trackspb.Envelope) {
+	derive, err := newDerivePipeline(deriverNames(), &deriveConfig{
+		sectionKernel: 9, sectionThreshold: 2, sectionMinGap: 8, dropThreshold: 2, dropMinGap: 16,
+		trendWindow: 4, brightnessThreshold: 0.05, loudnessTrendThreshold: 0.5, grooveInterval: 8,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+	layers, _ := parseLayers("all")
+	features, _ := parseSSMFeatures("chroma,mfcc")
+	curves, _ := parsePlotCurves(defaultPlotCurves)
+	bands, _ := parseHeatmapBands("auto")
+	q, err := newQuantizer("beat", defaultQuantizeEvents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker := &trackTracker{}
+	tracker.onTrackEnd(q.apply)
+	tracker.onTrackEnd(func(d *trackData) {
+		formatStructure(trackStructure(d))
+		formatFades(trackFades(d))
+		writeReport(path("report.html"), d)
+		writeTrackSummary(path("summary.json"), d)
+		writeSMF(path("track.mid"), d)
+		writeLeadSheet(path("lead.cho"), d)
+		writeAudacityLabels(path("labels.txt"), d, layers)
+		writeJAMS(path("track.jams"), d)
+		writeSonicVisualiser(path("track.svl"), d)
+		writeReaperCSV(path("markers.csv"), d, layers)
+		writeSSM(path("ssm.png"), d, features, 64)
+		writePlot(path("plot.svg"), d, curves)
+		writeHeatmap(path("heatmap.png"), d, bands)
+		writeDJCues(path("cues.xml"), d)
+		writeClipReport(path("clips.json"), d)
+	})
+
+	handle := func(env *trackspb.Envelope, received time.Time) {
+		derived := derive.process(env)
+		formatEvent(env)
+		tracker.handle(env, received)
+		for _, d := range derived {
+			formatEvent(d)
+			tracker.handle(d, received)
+		}
+	}
+	rec := trackstest.Steady120()
+	half := len(rec.Records) / 2
+	for i, r := range rec.Records {
+		if i == half {
+			handle(env, r.Received)
+		}
+		handle(r.Envelope, r.Received)
+	}
+	tracker.finish()
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// GPIO output (-gpio), for installations driven straight from a Raspberry
+// Pi's header: each pin follows an event rule, e.g.
+// "17=beat,27=downbeat,22=energy>0.6". A pin on an event that happens at a
+// moment (beat, onset>0.5) goes high for -gpio-pulse each time it matches;
+// a pin on a continuous event with a threshold (energy>0.6) is high while
+// the latest value is above it. Pins are line offsets on -gpio-chip,
+// through the Linux GPIO character device, and go low when a track ends
+// and when the receiver stops.
+const gpioMaxPins = 64 // lines in one kernel request
+
+type gpioPin struct {
+	line  int
+	rule  eventRule
+	level bool // follows the threshold rather than pulsing
+}
+
+type gpioOutput struct {
+	lines *gpioLines
+	pins  []gpioPin
+	pulse time.Duration
+
+	mu    sync.Mutex
+	bits  uint64      // pin i is high when bit i is set
+	until []time.Time // end of each pin's pulse
+}
+
+// parseGPIOPins parses "line=rule,...".
+func parseGPIOPins(spec string) ([]gpioPin, error) {
+	var pins []gpioPin
+	seen := make(map[int]bool)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		line, rule, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid pin %q (want line=event, e.g. 17=beat)", item)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(line))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid line number in %q", item)
+		}
+		if seen[n] {
+			return nil, fmt.Errorf("line %d is given twice", n)
+		}
+		seen[n] = true
+		r, err := parseEventRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		pins = append(pins, gpioPin{line: n, rule: r, level: r.above && !r.category && continuousEvents[r.event]})
+	}
+	if len(pins) == 0 {
+		return nil, fmt.Errorf("no pins given")
+	}
+	if len(pins) > gpioMaxPins {
+		return nil, fmt.Errorf("at most %d pins", gpioMaxPins)
+	}
+	return pins, nil
+}
+
+func newGPIOOutput(chip, spec string, pulse time.Duration) (*gpioOutput, error) {
+	pins, err := parseGPIOPins(spec)
+	if err != nil {
+		return nil, err
+	}
+	if pulse <= 0 {
+		return nil, fmt.Errorf("-gpio-pulse must be positive")
+	}
+	offsets := make([]int, len(pins))
+	for i, p := range pins {
+		offsets[i] = p.line
+	}
+	lines, err := openGPIOLines(chip, offsets)
+	if err != nil {
+		return nil, err
+	}
+	return &gpioOutput{lines: lines, pins: pins, pulse: pulse, until: make([]time.Time, len(pins))}, nil
+}
+
+func (g *gpioOutput) handle(env *trackspb.Envelope) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	bits := g.bits
+	if isTrackEnd(env) {
+		bits = 0
+		clear(g.until)
+	}
+	now := time.Now()
+	for i, p := range g.pins {
+		switch {
+		case p.level && p.rule.selects(env):
+			if p.rule.matches(env) {
+				bits |= 1 << i
+			} else {
+				bits &^= 1 << i
+			}
+		case !p.level && p.rule.matches(env):
+			bits |= 1 << i
+			g.until[i] = now.Add(g.pulse)
+			time.AfterFunc(g.pulse, g.endPulses)
+		}
+	}
+	g.setLocked(bits)
+}
+
+// endPulses takes down the pins whose pulse is over.
+func (g *gpioOutput) endPulses() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	bits := g.bits
+	now := time.Now()
+	for i, p := range g.pins {
+		if !p.level && !g.until[i].IsZero() && !now.Before(g.until[i]) {
+			bits &^= 1 << i
+			g.until[i] = time.Time{}
+		}
+	}
+	g.setLocked(bits)
+}
+
+func (g *gpioOutput) setLocked(bits uint64) {
+	if bits == g.bits || g.lines == nil {
+		return
+	}
+	if err := g.lines.set(bits, uint64(1)<<len(g.pins)-1); err != nil {
+		fmt.Fprintf(os.Stderr, "gpio: %v\n", err)
+		return
+	}
+	g.bits = bits
+}
+
+// close takes every pin low and releases the lines.
+func (g *gpioOutput) close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.setLocked(0)
+	g.lines.close()
+	g.lines = nil
+}
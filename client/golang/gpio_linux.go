@@ -0,0 +1,80 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// Lines are requested through the GPIO character device's v2 interface
+// (linux/gpio.h), which needs no root on a Raspberry Pi OS install for
+// members of the gpio group. The request is encoded by hand so the layout
+// is the same on 32- and 64-bit ARM:
+//
+//	bytes 0-255    offsets, u32 each
+//	bytes 256-287  consumer label
+//	bytes 288-295  config flags (u64)
+//	bytes 560-563  number of lines
+//	bytes 588-591  line fd, set by the kernel
+const (
+	gpioGetLineIoctl   = 0xC250B407 // GPIO_V2_GET_LINE_IOCTL
+	gpioSetValuesIoctl = 0xC010B40F // GPIO_V2_LINE_SET_VALUES_IOCTL
+	gpioFlagOutput     = 1 << 3     // GPIO_V2_LINE_FLAG_OUTPUT
+
+	gpioRequestLen     = 592
+	gpioOffConsumer    = 256
+	gpioOffFlags       = 288
+	gpioOffNumLines    = 560
+	gpioOffFD          = 588
+	gpioConsumerMaxLen = 31
+)
+
+type gpioLines struct {
+	fd int
+}
+
+// openGPIOLines requests offsets on chip (gpiochip0 or /dev/gpiochip0) as
+// outputs, initially low.
+func openGPIOLines(chip string, offsets []int) (*gpioLines, error) {
+	if !strings.Contains(chip, "/") {
+		chip = "/dev/" + chip
+	}
+	f, err := os.OpenFile(chip, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	req := make([]byte, gpioRequestLen)
+	for i, off := range offsets {
+		binary.NativeEndian.PutUint32(req[i*4:], uint32(off))
+	}
+	copy(req[gpioOffConsumer:gpioOffConsumer+gpioConsumerMaxLen], "tracks")
+	binary.NativeEndian.PutUint64(req[gpioOffFlags:], gpioFlagOutput)
+	binary.NativeEndian.PutUint32(req[gpioOffNumLines:], uint32(len(offsets)))
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), gpioGetLineIoctl, uintptr(unsafe.Pointer(&req[0]))); errno != 0 {
+		return nil, fmt.Errorf("%s: requesting lines %v: %w", chip, offsets, errno)
+	}
+	return &gpioLines{fd: int(int32(binary.NativeEndian.Uint32(req[gpioOffFD:])))}, nil
+}
+
+// set drives the lines whose bit is in mask to the matching bit of bits;
+// bit i is the i-th requested line.
+func (l *gpioLines) set(bits, mask uint64) error {
+	var v [16]byte
+	binary.NativeEndian.PutUint64(v[0:], bits)
+	binary.NativeEndian.PutUint64(v[8:], mask)
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(l.fd), gpioSetValuesIoctl, uintptr(unsafe.Pointer(&v[0]))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (l *gpioLines) close() {
+	syscall.Close(l.fd)
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+type gpioLines struct{}
+
+func openGPIOLines(chip string, offsets []int) (*gpioLines, error) {
+	return nil, errors.New("GPIO output is only supported on Linux")
+}
+
+func (l *gpioLines) set(bits, mask uint64) error {
+	return errors.New("GPIO unavailable")
+}
+
+func (l *gpioLines) close() {}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"math"
+	"sort"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Groove (-derive=groove, and the track summary's "groove"): where onsets
+// fall between beats. Each onset is placed by its phase in the beat it
+// falls in, 0 on the beat and 1 on the next. Onsets near the middle of the
+// beat are off-beat eighth notes, and their median phase is the swing: 50%
+// for straight eighths, 66.7% for triplet swing. Onsets near a beat give
+// the micro-timing: their mean offset from the beat (negative when the
+// playing pushes ahead of it, positive when it lays back) and the spread
+// of those offsets. The deriver measures the last two -groove-interval of
+// beats and onsets, and reports every -groove-interval.
+const (
+	grooveMinOffbeats = 8    // off-beat onsets needed for a swing estimate
+	grooveOnBeat      = 0.12 // phase either side of a beat counted as on it
+	grooveOffLow      = 0.4  // phase range of off-beat eighths; sixteenths
+	grooveOffHigh     = 0.72 // at 0.25 and 0.75 fall outside it
+)
+
+type grooveDeriver struct {
+	cfg     *deriveConfig
+	streams map[string]*grooveState
+}
+
+type grooveState struct {
+	beats, onsets []float64
+	last          float64 // time of the last report
+}
+
+func newGrooveDeriver(cfg *deriveConfig) *grooveDeriver {
+	return &grooveDeriver{cfg: cfg, streams: make(map[string]*grooveState)}
+}
+
+func (d *grooveDeriver) derive(env *trackspb.Envelope) []*trackspb.Envelope {
+	stream := env.GetStreamId()
+	switch env.Event.(type) {
+	case *trackspb.Envelope_TrackStart, *trackspb.Envelope_TimelineReset:
+		delete(d.streams, stream)
+		return nil
+	case *trackspb.Envelope_Beat, *trackspb.Envelope_Onset:
+	default:
+		return nil
+	}
+
+	st := d.streams[stream]
+	t := env.GetTimestamp()
+	if st == nil {
+		st = &grooveState{last: t}
+		d.streams[stream] = st
+	}
+	keep := 2 * d.cfg.grooveInterval
+	if env.GetOnset() != nil {
+		st.onsets = appendRecentTimes(st.onsets, t, keep)
+		return nil
+	}
+	st.beats = appendRecentTimes(st.beats, t, keep)
+	if t-st.last < d.cfg.grooveInterval {
+		return nil
+	}
+	g := measureGroove(st.beats, st.onsets)
+	if g == nil {
+		return nil
+	}
+	st.last = t
+	return []*trackspb.Envelope{derivedEnvelope(env, g)}
+}
+
+// appendRecentTimes is appendRecent for times without values.
+func appendRecentTimes(ts []float64, t, keep float64) []float64 {
+	ts = append(ts, t)
+	i := 0
+	for i < len(ts) && ts[i] < t-keep {
+		i++
+	}
+	return ts[i:]
+}
+
+// measureGroove measures the onsets falling between consecutive beats, or
+// returns nil if there are too few off-beat onsets to tell the swing.
+// Beats further apart than 2 seconds (30 BPM), as across a breakdown, don't
+// make a beat.
+func measureGroove(beats, onsets []float64) *trackspb.Groove {
+	beats = append([]float64(nil), beats...)
+	onsets = append([]float64(nil), onsets...)
+	sort.Float64s(beats)
+	sort.Float64s(onsets)
+
+	var off, offsets []float64
+	for i := 1; i < len(beats); i++ {
+		b0, b1 := beats[i-1], beats[i]
+		iv := b1 - b0
+		if iv < 0.2 || iv > 2 {
+			continue
+		}
+		for j := sort.SearchFloat64s(onsets, b0); j < len(onsets) && onsets[j] < b1; j++ {
+			switch phase := (onsets[j] - b0) / iv; {
+			case phase <= grooveOnBeat:
+				offsets = append(offsets, phase*iv)
+			case phase >= 1-grooveOnBeat:
+				offsets = append(offsets, (phase-1)*iv)
+			case phase >= grooveOffLow && phase <= grooveOffHigh:
+				off = append(off, phase)
+			}
+		}
+	}
+	if len(off) < grooveMinOffbeats {
+		return nil
+	}
+	sort.Float64s(off)
+	swing := off[len(off)/2]
+	g := &trackspb.Groove{
+		Swing:  math.Round(swing*1000) / 10,
+		Ratio:  math.Round(swing/(1-swing)*100) / 100,
+		Onsets: int32(len(off) + len(offsets)),
+	}
+	if len(offsets) > 0 {
+		var mean, sq float64
+		for _, o := range offsets {
+			mean += o / float64(len(offsets))
+		}
+		for _, o := range offsets {
+			sq += (o - mean) * (o - mean) / float64(len(offsets))
+		}
+		g.Offset = math.Round(mean*10000) / 10
+		g.Jitter = math.Round(math.Sqrt(sq)*10000) / 10
+	}
+	return g
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Band heatmaps (-band-heatmap): a pseudo-spectrogram of each track, built
+// from its bands.mel, bands.bark or bands.erb events. Frames are averaged
+// into at most heatmapMaxColumns equal time bins; time runs left to right
+// and bands from low (bottom) to high. Energies are shown in dB, over a
+// heatmapRange window below the track's loudest bin, in the -ssm colours.
+const (
+	heatmapMaxColumns = 1200
+	heatmapRange      = 80.0 // dB shown below the maximum
+	heatmapMinWidth   = 600  // PNGs are scaled up by whole pixels to at least this size
+	heatmapMinHeight  = 240
+)
+
+var heatmapBands = []string{"bands.mel", "bands.bark", "bands.erb"}
+
+// parseHeatmapBands resolves -band-heatmap-bands: mel, bark, erb, or auto
+// for the first of them the track has.
+func parseHeatmapBands(spec string) (string, error) {
+	switch spec {
+	case "auto":
+		return "", nil
+	case "mel", "bark", "erb":
+		return "bands." + spec, nil
+	}
+	return "", fmt.Errorf("unknown bands %q (want mel, bark, erb or auto)", spec)
+}
+
+// heatmapBins averages the track's band frames into time bins, in dB.
+// Bins without frames are nil.
+func heatmapBins(frames []frame, dur float64) (times []float64, bins [][]float64) {
+	if dur <= 0 {
+		dur = frames[len(frames)-1].t
+	}
+	n := min(len(frames), heatmapMaxColumns)
+	if n == 0 || dur <= 0 {
+		return nil, nil
+	}
+	dim := len(frames[0].v)
+	edges := make([]float64, n+1)
+	for i := range edges {
+		edges[i] = dur * float64(i) / float64(n)
+	}
+	sums := make([][]float64, n)
+	counts := make([]int, n)
+	for _, fr := range frames {
+		if len(fr.v) != dim {
+			continue
+		}
+		i := sort.Search(len(edges), func(i int) bool { return edges[i] > fr.t }) - 1
+		i = min(max(i, 0), n-1)
+		if sums[i] == nil {
+			sums[i] = make([]float64, dim)
+		}
+		for k, x := range fr.v {
+			sums[i][k] += float64(x)
+		}
+		counts[i]++
+	}
+	for i, s := range sums {
+		for k := range s {
+			s[k] = 10 * math.Log10(max(s[k]/float64(counts[i]), 1e-12))
+		}
+	}
+	return edges[:n], sums
+}
+
+// writeHeatmap writes the heatmap as PNG or, for .csv paths, as CSV with a
+// row per time bin: its start time, then each band's energy in dB.
+func writeHeatmap(path string, d *trackData, bands string) error {
+	if bands == "" {
+		for _, b := range heatmapBands {
+			if len(d.features[b]) > 0 {
+				bands = b
+				break
+			}
+		}
+		if bands == "" {
+			return fmt.Errorf("no bands.mel, bands.bark or bands.erb frames in track; enable them on the sender")
+		}
+	}
+	if len(d.features[bands]) == 0 {
+		return fmt.Errorf("no %s frames in track; enable them on the sender", bands)
+	}
+	times, bins := heatmapBins(d.features[bands], d.duration())
+	dim := len(d.features[bands][0].v)
+	if len(bins) == 0 || dim == 0 {
+		return fmt.Errorf("no usable %s frames in track", bands)
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		c := csv.NewWriter(w)
+		row := []string{"time"}
+		for k := range dim {
+			row = append(row, fmt.Sprintf("band%d", k+1))
+		}
+		c.Write(row)
+		for i, b := range bins {
+			if b == nil {
+				continue
+			}
+			row = []string{strconv.FormatFloat(times[i], 'f', 3, 64)}
+			for _, v := range b {
+				row = append(row, strconv.FormatFloat(v, 'f', 2, 64))
+			}
+			c.Write(row)
+		}
+		c.Flush()
+		err = c.Error()
+	} else {
+		err = png.Encode(w, heatmapImage(bins, dim))
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return flushClose(w, f)
+}
+
+func heatmapImage(bins [][]float64, dim int) image.Image {
+	top := math.Inf(-1)
+	for _, b := range bins {
+		for _, v := range b {
+			top = max(top, v)
+		}
+	}
+	cols := len(bins)
+	xs := max((heatmapMinWidth+cols-1)/cols, 1)
+	ys := max((heatmapMinHeight+dim-1)/dim, 1)
+	img := image.NewRGBA(image.Rect(0, 0, cols*xs, dim*ys))
+	for i, b := range bins {
+		for k := range dim {
+			v := 0.0
+			if b != nil {
+				v = (b[k] - (top - heatmapRange)) / heatmapRange
+			}
+			c := viridis(v)
+			row := dim - 1 - k // low bands at the bottom
+			for y := row * ys; y < (row+1)*ys; y++ {
+				for x := i * xs; x < (i+1)*xs; x++ {
+					img.SetRGBA(x, y, c)
+				}
+			}
+		}
+	}
+	return img
+}
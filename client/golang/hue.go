@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Philips Hue output (-hue). Flashes lights on a rhythm event and shifts
+// their colour on key changes, through the bridge's local REST API.
+// Bridges handle about 10 light commands per second (one per second for
+// group commands) and queue, then drop, anything beyond that, so commands
+// are paced here instead: a flash requested while the previous one is
+// still being sent is skipped, and only the latest key colour is kept.
+const (
+	hueFlashBri    = 254
+	hueBaseBri     = 120
+	hueFlashHold   = 100 * time.Millisecond
+	hueHTTPTimeout = 2 * time.Second
+)
+
+type hueOutput struct {
+	base     string   // http://<bridge>/api/<user>/
+	targets  []string // lights/<id>/state or groups/<id>/action
+	flashOn  string
+	interval time.Duration
+	client   *http.Client
+	next     time.Time
+
+	mu    sync.Mutex
+	flash bool
+	color map[string]any
+	wake  chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// newHueOutput drives the given lights, or a group if group is not empty.
+// rate is the number of commands per second to allow.
+func newHueOutput(bridge, user, lights, group, flashOn string, rate float64) (*hueOutput, error) {
+	if user == "" {
+		return nil, fmt.Errorf("no bridge user given (-hue-user or $HUE_USERNAME)")
+	}
+	if flashOn != "" && !isEventName(flashOn) {
+		return nil, fmt.Errorf("unknown event %q", flashOn)
+	}
+	if rate <= 0 {
+		return nil, fmt.Errorf("rate must be positive")
+	}
+
+	var targets []string
+	switch {
+	case group != "" && lights != "":
+		return nil, fmt.Errorf("give either lights or a group, not both")
+	case group == "" && lights == "":
+		return nil, fmt.Errorf("no lights or group given (-hue-lights or -hue-group)")
+	case group != "":
+		if _, err := strconv.Atoi(group); err != nil {
+			return nil, fmt.Errorf("invalid group %q", group)
+		}
+		targets = []string{"groups/" + group + "/action"}
+	default:
+		for _, id := range strings.Split(lights, ",") {
+			id = strings.TrimSpace(id)
+			if _, err := strconv.Atoi(id); err != nil {
+				return nil, fmt.Errorf("invalid light id %q", id)
+			}
+			targets = append(targets, "lights/"+id+"/state")
+		}
+	}
+
+	h := &hueOutput{
+		base:     "http://" + bridge + "/api/" + user + "/",
+		targets:  targets,
+		flashOn:  flashOn,
+		interval: time.Duration(float64(time.Second) / rate),
+		client:   &http.Client{Timeout: hueHTTPTimeout},
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go h.run()
+	return h, nil
+}
+
+func (h *hueOutput) handle(env *trackspb.Envelope) {
+	h.mu.Lock()
+	switch e := env.Event.(type) {
+	case *trackspb.Envelope_KeyChange:
+		hue, sat, ok := keyColor(e.KeyChange.GetKey(), e.KeyChange.GetScale())
+		if !ok {
+			h.mu.Unlock()
+			return
+		}
+		h.color = map[string]any{"on": true, "hue": hue, "sat": sat, "bri": hueBaseBri, "transitiontime": 10}
+	default:
+		if h.flashOn == "" || eventName(env) != h.flashOn {
+			h.mu.Unlock()
+			return
+		}
+		h.flash = true
+	}
+	h.mu.Unlock()
+
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (h *hueOutput) close() {
+	close(h.stop)
+	<-h.done
+}
+
+func (h *hueOutput) run() {
+	defer close(h.done)
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-h.wake:
+		}
+
+		h.mu.Lock()
+		color, flash := h.color, h.flash
+		h.color, h.flash = nil, false
+		h.mu.Unlock()
+
+		if color != nil {
+			h.putAll(color)
+		}
+		if flash {
+			h.putAll(map[string]any{"on": true, "bri": hueFlashBri, "transitiontime": 0})
+			time.Sleep(hueFlashHold)
+			h.putAll(map[string]any{"bri": hueBaseBri, "transitiontime": 2})
+		}
+	}
+}
+
+func (h *hueOutput) putAll(state map[string]any) {
+	for _, t := range h.targets {
+		if wait := time.Until(h.next); wait > 0 {
+			time.Sleep(wait)
+		}
+		h.next = time.Now().Add(h.interval)
+		if err := h.put(t, state); err != nil {
+			fmt.Fprintf(os.Stderr, "hue: %s: %v\n", t, err)
+		}
+	}
+}
+
+func (h *hueOutput) put(path string, state map[string]any) error {
+	body, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, h.base+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bridge returned %s", resp.Status)
+	}
+
+	// The bridge answers 200 with a list of per-attribute results.
+	var results []struct {
+		Error *struct {
+			Description string `json:"description"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.Error != nil {
+			return fmt.Errorf("%s", r.Error.Description)
+		}
+	}
+	return nil
+}
+
+// keyColor maps a key to a Hue colour. Keys are placed around the colour
+// wheel by their position on the circle of fifths, so closely related keys
+// get neighbouring colours; minor keys take their relative major's hue at
+// lower saturation.
+func keyColor(key, scale string) (hue, sat int, ok bool) {
+	fifths, ok := fifthsIndex(key, scale)
+	if !ok {
+		return 0, 0, false
+	}
+	sat = 254
+	if scale == "minor" {
+		sat = 180
+	}
+	return fifths * 65536 / 12, sat, true
+}
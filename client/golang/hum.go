@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Hum profile (the track summary's "hum", and the HTML report): hum events
+// classified by the mains frequency they are a harmonic of, and grouped
+// into segments of persistent hum. A frequency within humTolerance of one
+// of the first humMaxHarmonics multiples of 50 or 60 Hz belongs to that
+// mains; frequencies that fit both, like 300 Hz, go with the mains of the
+// rest of the segment or, failing that, of the track. Hum events less than
+// humGap apart make a segment, which is reported once it spans humMinSegment.
+// A segment's confidence is the share of its events on its mains, scaled
+// down for segments of fewer than humConfidentEvents events.
+const (
+	humTolerance       = 0.02 // relative to the harmonic
+	humMaxHarmonics    = 8
+	humGap             = 10.0 // seconds
+	humMinSegment      = 5.0  // seconds
+	humConfidentEvents = 10
+)
+
+var humMains = []int{50, 60}
+
+type humProfile struct {
+	Mains     int          `json:"mains,omitempty"` // 50 or 60 Hz; omitted when unclear
+	Events    int          `json:"events"`
+	Harmonics []int        `json:"harmonics,omitempty"` // of Mains, that hum was heard on
+	Segments  []humSegment `json:"segments,omitempty"`
+}
+
+type humSegment struct {
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Mains      int     `json:"mains,omitempty"`
+	Harmonic   int     `json:"harmonic,omitempty"` // of the median frequency
+	Frequency  float64 `json:"frequency"`          // median, Hz
+	Events     int     `json:"events"`
+	Confidence float64 `json:"confidence"`
+}
+
+// humHarmonic is the harmonic of mains that f is, or 0 for none.
+func humHarmonic(f float64, mains int) int {
+	n := int(math.Round(f / float64(mains)))
+	if n < 1 || n > humMaxHarmonics {
+		return 0
+	}
+	if h := float64(n * mains); math.Abs(f-h) > humTolerance*h {
+		return 0
+	}
+	return n
+}
+
+// voteMains is the mains most of pts fit only, or fallback on a tie.
+func voteMains(pts []point, fallback int) int {
+	votes := make(map[int]int)
+	for _, p := range pts {
+		var fits []int
+		for _, m := range humMains {
+			if humHarmonic(p.v, m) > 0 {
+				fits = append(fits, m)
+			}
+		}
+		if len(fits) == 1 {
+			votes[fits[0]]++
+		}
+	}
+	switch {
+	case votes[50] > votes[60]:
+		return 50
+	case votes[60] > votes[50]:
+		return 60
+	}
+	return fallback
+}
+
+// trackHum profiles d's hum events, or returns nil if it had none.
+func trackHum(d *trackData) *humProfile {
+	pts := append([]point(nil), d.series["hum"]...)
+	if len(pts) == 0 {
+		return nil
+	}
+	sort.SliceStable(pts, func(i, j int) bool { return pts[i].t < pts[j].t })
+	h := &humProfile{Mains: voteMains(pts, 0), Events: len(pts)}
+	if h.Mains != 0 {
+		seen := make(map[int]bool)
+		for _, p := range pts {
+			if n := humHarmonic(p.v, h.Mains); n > 0 && !seen[n] {
+				seen[n] = true
+				h.Harmonics = append(h.Harmonics, n)
+			}
+		}
+		sort.Ints(h.Harmonics)
+	}
+
+	for i := 0; i < len(pts); {
+		j := i + 1
+		for j < len(pts) && pts[j].t-pts[j-1].t <= humGap {
+			j++
+		}
+		if seg := pts[i:j]; seg[len(seg)-1].t-seg[0].t >= humMinSegment {
+			h.Segments = append(h.Segments, newHumSegment(seg, h.Mains))
+		}
+		i = j
+	}
+	return h
+}
+
+func newHumSegment(pts []point, trackMains int) humSegment {
+	s := humSegment{Start: pts[0].t, End: pts[len(pts)-1].t, Events: len(pts)}
+	freqs := make([]float64, len(pts))
+	for i, p := range pts {
+		freqs[i] = p.v
+	}
+	sort.Float64s(freqs)
+	s.Frequency = round1(freqs[len(freqs)/2])
+	if s.Mains = voteMains(pts, trackMains); s.Mains == 0 {
+		return s
+	}
+	s.Harmonic = humHarmonic(s.Frequency, s.Mains)
+	on := 0
+	for _, p := range pts {
+		if humHarmonic(p.v, s.Mains) > 0 {
+			on++
+		}
+	}
+	share := float64(on) / float64(len(pts))
+	s.Confidence = math.Round(share*min(1, float64(len(pts))/humConfidentEvents)*100) / 100
+	return s
+}
+
+// describe renders a segment for the HTML report: "60 Hz mains, 3rd harmonic".
+func (s humSegment) describe() string {
+	switch {
+	case s.Mains == 0 && humHarmonic(s.Frequency, 50) > 0 && humHarmonic(s.Frequency, 60) > 0:
+		return "50 or 60 Hz mains"
+	case s.Mains == 0:
+		return "not a mains harmonic"
+	case s.Harmonic == 0:
+		return fmt.Sprintf("mostly %d Hz mains", s.Mains)
+	case s.Harmonic == 1:
+		return fmt.Sprintf("%d Hz mains", s.Mains)
+	}
+	suffix := "th"
+	switch s.Harmonic {
+	case 2:
+		suffix = "nd"
+	case 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d Hz mains, %d%s harmonic", s.Mains, s.Harmonic, suffix)
+}
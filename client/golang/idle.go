@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Idle timeout (-idle-timeout). A receiver whose sender is down, or that
+// joined on the wrong interface, would otherwise wait without a word. When
+// no packet has arrived for the timeout, a warning goes to stderr, and
+// another once packets come back. With -idle-exit the receiver instead
+// shuts down cleanly and exits with exitIdle (see exit.go), for scripts.
+
+type idleWatch struct {
+	timeout     time.Duration
+	exit        bool
+	closeSource func() // for -idle-exit
+	expired     atomic.Bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newIdleWatch(timeout time.Duration, exit bool, closeSource func()) *idleWatch {
+	w := &idleWatch{
+		timeout:     timeout,
+		exit:        exit,
+		closeSource: closeSource,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// run watches the packets_received counter (see debug.go).
+func (w *idleWatch) run() {
+	defer close(w.done)
+	tick := time.NewTicker(min(max(w.timeout/10, 50*time.Millisecond), time.Second))
+	defer tick.Stop()
+	count, last := packetsReceived.Value(), time.Now()
+	warned := false
+	for {
+		select {
+		case <-w.stop:
+			return
+		case now := <-tick.C:
+			if n := packetsReceived.Value(); n != count {
+				if warned {
+					fmt.Fprintf(os.Stderr, "Packets arriving again after %s.\n", now.Sub(last).Round(time.Second))
+					warned = false
+				}
+				count, last = n, now
+				continue
+			}
+			if warned || now.Sub(last) < w.timeout {
+				continue
+			}
+			if w.exit {
+				fmt.Fprintf(os.Stderr, "No packets received for %s; exiting.\n", w.timeout)
+				w.expired.Store(true)
+				w.closeSource()
+				return
+			}
+			fmt.Fprintf(os.Stderr, "Warning: no packets received for %s; check that the sender is running and on this network.\n", w.timeout)
+			warned = true
+		}
+	}
+}
+
+func (w *idleWatch) close() {
+	select {
+	case <-w.done:
+	default:
+		close(w.stop)
+		<-w.done
+	}
+}
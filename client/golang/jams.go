@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// JAMS export (-jams). Writes the track's beats, chords, keys and segments
+// as a JAMS 0.3 file (https://jams.readthedocs.io), the annotation format
+// used by mir_eval, librosa and most MIR datasets.
+const jamsVersion = "0.3.4"
+
+type jamsFile struct {
+	FileMetadata jamsFileMetadata `json:"file_metadata"`
+	Annotations  []jamsAnnotation `json:"annotations"`
+	Sandbox      struct{}         `json:"sandbox"`
+}
+
+type jamsFileMetadata struct {
+	Title       string            `json:"title"`
+	Artist      string            `json:"artist"`
+	Release     string            `json:"release"`
+	Duration    float64           `json:"duration"`
+	Identifiers map[string]string `json:"identifiers"`
+	JamsVersion string            `json:"jams_version"`
+}
+
+type jamsAnnotation struct {
+	Namespace          string                 `json:"namespace"`
+	Data               []jamsObservation      `json:"data"`
+	AnnotationMetadata jamsAnnotationMetadata `json:"annotation_metadata"`
+	Sandbox            struct{}               `json:"sandbox"`
+	Time               float64                `json:"time"`
+	Duration           float64                `json:"duration"`
+}
+
+type jamsObservation struct {
+	Time       float64  `json:"time"`
+	Duration   float64  `json:"duration"`
+	Value      any      `json:"value"`
+	Confidence *float64 `json:"confidence"`
+}
+
+type jamsAnnotationMetadata struct {
+	Curator         jamsCurator `json:"curator"`
+	Annotator       struct{}    `json:"annotator"`
+	Version         string      `json:"version"`
+	Corpus          string      `json:"corpus"`
+	AnnotationTools string      `json:"annotation_tools"`
+	AnnotationRules string      `json:"annotation_rules"`
+	Validation      string      `json:"validation"`
+	DataSource      string      `json:"data_source"`
+}
+
+type jamsCurator struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// beatInfo is a beat on the merged beat grid. pos is its position in the
+// bar (1 on downbeats), or 0 when unknown.
+type beatInfo struct {
+	t, confidence float64
+	pos           int
+}
+
+func beatPositions(d *trackData) []beatInfo {
+	beats, downs := beatGrid(d)
+	conf := make([]float64, len(beats))
+	for _, name := range []string{"beat", "downbeat"} {
+		for _, p := range d.series[name] {
+			i := nearestIndex(beats, p.t)
+			conf[i] = max(conf[i], p.v)
+		}
+	}
+	isDown := make([]bool, len(beats))
+	for _, t := range downs {
+		isDown[nearestIndex(beats, t)] = true
+	}
+
+	out := make([]beatInfo, len(beats))
+	pos := 0
+	for i, t := range beats {
+		switch {
+		case isDown[i]:
+			pos = 1
+		case pos > 0:
+			pos++
+		}
+		out[i] = beatInfo{t: t, confidence: conf[i], pos: pos}
+	}
+	return out
+}
+
+// harteChord converts a chord name such as "Am" or "F#" to Harte syntax
+// ("A:min", "F#:maj"), as the JAMS chord namespace requires.
+func harteChord(name string) string {
+	if name == "" || name == "N" {
+		return "N"
+	}
+	if root, ok := strings.CutSuffix(name, "m"); ok {
+		return root + ":min"
+	}
+	return name + ":maj"
+}
+
+func writeJAMS(path string, d *trackData) error {
+	dur := d.duration()
+	meta := jamsAnnotationMetadata{AnnotationTools: "tracks", DataSource: "program"}
+	annotation := func(ns string, data []jamsObservation) jamsAnnotation {
+		if data == nil {
+			data = []jamsObservation{}
+		}
+		return jamsAnnotation{Namespace: ns, Data: data, AnnotationMetadata: meta, Duration: dur}
+	}
+	conf := func(v float64) *float64 { return &v }
+
+	var beats []jamsObservation
+	for _, b := range beatPositions(d) {
+		var pos any
+		if b.pos > 0 {
+			pos = b.pos
+		}
+		beats = append(beats, jamsObservation{Time: b.t, Value: pos, Confidence: conf(b.confidence)})
+	}
+
+	var chords []jamsObservation
+	strengths := d.series["chord.change"]
+	for i, r := range labelRegions(d.chords, dur, "chord") {
+		o := jamsObservation{Time: r.start, Duration: r.end - r.start, Value: harteChord(r.text)}
+		if i < len(strengths) {
+			o.Confidence = conf(strengths[i].v)
+		}
+		chords = append(chords, o)
+	}
+
+	var keys []jamsObservation
+	strengths = d.series["key.change"]
+	for i, k := range d.keys {
+		tonic, scale, _ := strings.Cut(k.name, " ")
+		scale, _, _ = strings.Cut(scale, " ") // drop the key code
+		end := dur
+		if i+1 < len(d.keys) {
+			end = d.keys[i+1].t
+		}
+		o := jamsObservation{Time: k.t, Duration: max(end-k.t, 0), Value: tonic + ":" + scale}
+		if i < len(strengths) {
+			o.Confidence = conf(strengths[i].v)
+		}
+		keys = append(keys, o)
+	}
+
+	var segments []jamsObservation
+	for _, s := range segmentRegions(d) {
+		segments = append(segments, jamsObservation{Time: s.start, Duration: s.end - s.start, Value: s.text})
+	}
+
+	// Section letters and functions, in the SALAMI namespaces.
+	var upper, function []jamsObservation
+	for _, s := range trackStructure(d) {
+		upper = append(upper, jamsObservation{Time: s.start, Duration: s.end - s.start, Value: s.letter})
+		role := s.role
+		if role == "" {
+			role = "no_function"
+		}
+		function = append(function, jamsObservation{Time: s.start, Duration: s.end - s.start, Value: role})
+	}
+
+	doc := jamsFile{
+		FileMetadata: jamsFileMetadata{
+			Title:       d.meta.displayTitle(d.start),
+			Artist:      d.meta.Artist,
+			Release:     d.meta.Album,
+			Duration:    dur,
+			Identifiers: map[string]string{},
+			JamsVersion: jamsVersion,
+		},
+		Annotations: []jamsAnnotation{
+			annotation("beat", beats),
+			annotation("chord", chords),
+			annotation("key_mode", keys),
+			annotation("segment_open", segments),
+		},
+	}
+	if upper != nil {
+		doc.Annotations = append(doc.Annotations,
+			annotation("segment_salami_upper", upper),
+			annotation("segment_salami_function", function))
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
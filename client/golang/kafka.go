@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"google.golang.org/protobuf/proto"
+)
+
+// Kafka output (-kafka). Envelopes are produced to -kafka-topic through a
+// Confluent REST Proxy (API v2), which saves the receiver a Kafka client:
+// each is the serialized protobuf Envelope, keyed by its stream id so a
+// stream's events stay in order on one partition. With -schema-registry
+// each value is framed with the ID of the Envelope schema (see
+// schemaregistry.go), so consumers decode it with the registry's protobuf
+// deserializer. Envelopes are sent in batches of up to kafkaBatch, at most
+// kafkaLinger after the first; when the proxy falls behind, the queue of
+// kafkaQueue fills and newer envelopes are dropped and counted.
+const (
+	kafkaTimeout = 10 * time.Second
+	kafkaLinger  = 200 * time.Millisecond
+	kafkaBatch   = 500
+	kafkaQueue   = 4096
+)
+
+type kafkaRecord struct {
+	Key   []byte `json:"key,omitempty"` // base64 in JSON, as the binary format wants
+	Value []byte `json:"value"`
+}
+
+type kafkaSink struct {
+	url    string // the topic's endpoint
+	framer *schemaFramer
+	client *http.Client
+
+	queue   chan kafkaRecord
+	done    chan struct{}
+	dropped atomic.Int64
+	failed  atomic.Int64
+}
+
+// newKafkaSink produces to topic through the REST Proxy at proxy, framing
+// messages for schema ID id, or not if id is 0.
+func newKafkaSink(proxy, topic string, id int) (*kafkaSink, error) {
+	u, err := url.Parse(proxy)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid REST Proxy URL %q", proxy)
+	}
+	if topic == "" {
+		return nil, fmt.Errorf("no topic given (use -kafka-topic)")
+	}
+	k := &kafkaSink{
+		url:    u.JoinPath("topics", topic).String(),
+		client: &http.Client{Timeout: kafkaTimeout},
+		queue:  make(chan kafkaRecord, kafkaQueue),
+		done:   make(chan struct{}),
+	}
+	if id != 0 {
+		k.framer = newSchemaFramer(id)
+	}
+	go k.run()
+	return k, nil
+}
+
+func (k *kafkaSink) handle(env *trackspb.Envelope) {
+	value, err := proto.Marshal(env)
+	if err != nil {
+		k.failed.Add(1)
+		return
+	}
+	if k.framer != nil {
+		value = k.framer.frame(value)
+	}
+	rec := kafkaRecord{Value: value}
+	if s := env.GetStreamId(); s != "" {
+		rec.Key = []byte(s)
+	}
+	select {
+	case k.queue <- rec:
+	default:
+		k.dropped.Add(1)
+	}
+}
+
+func (k *kafkaSink) status() sinkStatus {
+	return sinkStatus{Name: "kafka", Policy: "drop-newest", Depth: len(k.queue), Capacity: cap(k.queue), Dropped: int(k.dropped.Load())}
+}
+
+func (k *kafkaSink) run() {
+	defer close(k.done)
+	var batch []kafkaRecord
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := k.produce(batch); err != nil {
+			k.failed.Add(int64(len(batch)))
+			fmt.Fprintf(os.Stderr, "kafka: %v\n", err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		rec, ok := <-k.queue
+		if !ok {
+			return
+		}
+		batch = append(batch, rec)
+		linger := time.NewTimer(kafkaLinger)
+	fill:
+		for len(batch) < kafkaBatch {
+			select {
+			case rec, ok := <-k.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, rec)
+			case <-linger.C:
+				break fill
+			}
+		}
+		linger.Stop()
+		flush()
+	}
+}
+
+// produce posts a batch to the topic, in the REST Proxy's binary embedded
+// format.
+func (k *kafkaSink) produce(batch []kafkaRecord) error {
+	body, err := json.Marshal(map[string][]kafkaRecord{"records": batch})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", k.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.binary.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	resp, err := k.client.Do(req)
+	if err != nil {
+		// Keep credentials in the URL out of the message.
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	var reply struct {
+		Offsets []struct {
+			Error string `json:"error"`
+		} `json:"offsets"`
+		Message string `json:"message"`
+	}
+	json.NewDecoder(resp.Body).Decode(&reply)
+	if resp.StatusCode/100 != 2 {
+		if reply.Message != "" {
+			return fmt.Errorf("%s: %s", resp.Status, reply.Message)
+		}
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	for _, o := range reply.Offsets {
+		if o.Error != "" {
+			return fmt.Errorf("produce: %s", o.Error)
+		}
+	}
+	return nil
+}
+
+// close sends the envelopes still queued and stops.
+func (k *kafkaSink) close() {
+	close(k.queue)
+	<-k.done
+	if n := k.dropped.Load(); n > 0 {
+		fmt.Fprintf(os.Stderr, "kafka: %d envelopes dropped (queue full)\n", n)
+	}
+	if n := k.failed.Load(); n > 0 {
+		fmt.Fprintf(os.Stderr, "kafka: %d envelopes not produced\n", n)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"google.golang.org/protobuf/proto"
+)
+
+// protoTokens splits .proto source into tokens, without comments.
+func protoTokens(src string) []string {
+	src = regexp.MustCompile(`//.*`).ReplaceAllString(src, "")
+	return regexp.MustCompile(`[{};=<>,]|[^\s{};=<>,]+`).FindAllString(src, -1)
+}
+
+func TestProtoSource(t *testing.T) {
+	want, err := os.ReadFile("../../proto/tracks.proto")
+	if err != nil {
+		t.Skip(err)
+	}
+	got := protoSource((*trackspb.Envelope)(nil).ProtoReflect().Descriptor().ParentFile())
+	if !slices.Equal(protoTokens(got), protoTokens(string(want))) {
+		t.Errorf("printed schema differs from proto/tracks.proto:\n%s", got)
+	}
+}
+
+func TestSchemaID(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		var req struct{ SchemaType, Schema string }
+		json.NewDecoder(r.Body).Decode(&req)
+		if user, pass, _ := r.BasicAuth(); user != "key" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if req.SchemaType != "PROTOBUF" || !strings.Contains(req.Schema, "message Envelope {") {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		if r.URL.Path == "/subjects/missing-value" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_code": 40403, "message": "Schema not found"}`))
+			return
+		}
+		w.Write([]byte(`{"id": 42}`))
+	}))
+	defer srv.Close()
+	registry := strings.Replace(srv.URL, "http://", "http://key:secret@", 1)
+
+	if id, err := schemaID(registry, "tracks-value", true); err != nil || id != 42 {
+		t.Errorf("registering: %d, %v; want 42", id, err)
+	}
+	if id, err := schemaID(registry, "tracks-value", false); err != nil || id != 42 {
+		t.Errorf("looking up: %d, %v; want 42", id, err)
+	}
+	if _, err := schemaID(registry, "missing-value", false); err == nil || !strings.Contains(err.Error(), "Schema not found") {
+		t.Errorf("looking up a missing schema: %v", err)
+	}
+	if _, err := schemaID(srv.URL, "tracks-value", true); err == nil {
+		t.Error("no error without credentials")
+	}
+	want := []string{"/subjects/tracks-value/versions", "/subjects/tracks-value", "/subjects/missing-value", "/subjects/tracks-value/versions"}
+	if !slices.Equal(paths, want) {
+		t.Errorf("requests to %q, want %q", paths, want)
+	}
+}
+
+func TestKafkaSink(t *testing.T) {
+	var mu sync.Mutex
+	var got []kafkaRecord
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/topics/events" || r.Header.Get("Content-Type") != "application/vnd.kafka.binary.v2+json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var body struct{ Records []kafkaRecord }
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		got = append(got, body.Records...)
+		mu.Unlock()
+		w.Write([]byte(`{"offsets": [{"partition": 0, "offset": 1}]}`))
+	}))
+	defer srv.Close()
+
+	k, err := newKafkaSink(srv.URL, "events", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := []*trackspb.Envelope{
+		{Timestamp: 1, StreamId: "pad1", Event: &trackspb.Envelope_Beat{Beat: &trackspb.Beat{Confidence: 0.9}}},
+		{Timestamp: 2, Event: &trackspb.Envelope_KeyChange{KeyChange: &trackspb.KeyChange{Key: "A"}}},
+	}
+	for _, env := range sent {
+		k.handle(env)
+	}
+	k.close()
+
+	if len(got) != len(sent) {
+		t.Fatalf("%d records produced, want %d", len(got), len(sent))
+	}
+	for i, rec := range got {
+		if string(rec.Key) != sent[i].StreamId {
+			t.Errorf("record %d keyed %q, want %q", i, rec.Key, sent[i].StreamId)
+		}
+		v := rec.Value
+		if len(v) < 6 || v[0] != 0 || binary.BigEndian.Uint32(v[1:]) != 42 || v[5] != 0 {
+			t.Fatalf("record %d not framed for schema 42: % x", i, v[:min(len(v), 6)])
+		}
+		env := &trackspb.Envelope{}
+		if err := proto.Unmarshal(v[6:], env); err != nil || !proto.Equal(env, sent[i]) {
+			t.Errorf("record %d is %v (%v), want %v", i, env, err, sent[i])
+		}
+	}
+}
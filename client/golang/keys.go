@@ -0,0 +1,82 @@
+package main
+
+import "fmt"
+
+var pitchClasses = map[string]int{
+	"C": 0, "C#": 1, "Db": 1, "D": 2, "D#": 3, "Eb": 3, "E": 4, "F": 5,
+	"F#": 6, "Gb": 6, "G": 7, "G#": 8, "Ab": 8, "A": 9, "A#": 10, "Bb": 10, "B": 11,
+}
+
+// keyNotation is the DJ key notation shown next to musical key names
+// (-key-notation): "" for none, "camelot" or "openkey".
+var keyNotation string
+
+func setKeyNotation(s string) error {
+	switch s {
+	case "", "camelot", "openkey":
+		keyNotation = s
+		return nil
+	}
+	return fmt.Errorf("unknown key notation %q (want camelot or openkey)", s)
+}
+
+// fifthsIndex is the position of a key's relative major on the circle of
+// fifths (C=0, G=1, D=2, ...). Relative keys share an index, which is what
+// both DJ notations number by.
+func fifthsIndex(key, scale string) (int, bool) {
+	pc, ok := pitchClasses[key]
+	if !ok {
+		return 0, false
+	}
+	if scale == "minor" {
+		pc = (pc + 3) % 12
+	}
+	return pc * 7 % 12, true
+}
+
+// camelotKey renders a key in Camelot notation: C major is 8B, A minor 8A.
+func camelotKey(key, scale string) (string, bool) {
+	i, ok := fifthsIndex(key, scale)
+	if !ok {
+		return "", false
+	}
+	letter := "B"
+	if scale == "minor" {
+		letter = "A"
+	}
+	return fmt.Sprintf("%d%s", (i+7)%12+1, letter), true
+}
+
+// openKey renders a key in Open Key notation: C major is 1d, A minor 1m.
+func openKey(key, scale string) (string, bool) {
+	i, ok := fifthsIndex(key, scale)
+	if !ok {
+		return "", false
+	}
+	letter := "d"
+	if scale == "minor" {
+		letter = "m"
+	}
+	return fmt.Sprintf("%d%s", i+1, letter), true
+}
+
+// keyCode renders a key in the selected -key-notation, or "" when none is
+// selected or the key is not recognised.
+func keyCode(key, scale string) string {
+	var code string
+	switch keyNotation {
+	case "camelot":
+		code, _ = camelotKey(key, scale)
+	case "openkey":
+		code, _ = openKey(key, scale)
+	}
+	return code
+}
+
+// keyLabel is "A minor", followed by the key code if one is selected.
+func keyLabel(key, scale string) string {
+	if code := keyCode(key, scale); code != "" {
+		return key + " " + scale + " (" + code + ")"
+	}
+	return key + " " + scale
+}
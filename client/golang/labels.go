@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Source labels (-label): names, and optionally colours, for the sources of
+// a merged stream. A source is a stream id, a sender address, a -port or the
+// multicast group, and every envelope from it gets the label as its stream
+// id, so the label is shown on every line (<deckA>) and written as the
+// stream of every output file, summary and relayed envelope. A stream id set
+// by the sender wins over its address, which wins over the port, which wins
+// over the group. Labels given a colour (red, green, yellow, blue, magenta,
+// cyan or white) colour their lines on a terminal, unless NO_COLOR is set.
+var labelColors = map[string]string{
+	"red":     "31",
+	"green":   "32",
+	"yellow":  "33",
+	"blue":    "34",
+	"magenta": "35",
+	"cyan":    "36",
+	"white":   "37",
+}
+
+type sourceLabels struct {
+	group  string            // the joined multicast group, or "" for other transports
+	labels map[string]string // source → label
+	colors map[string]string // label → SGR code
+	color  bool              // stdout takes colours
+}
+
+// parseSourceLabels parses comma-separated source:label or
+// source:label:colour items; IPv6 sources go in brackets.
+func parseSourceLabels(spec, group string) (*sourceLabels, error) {
+	l := &sourceLabels{
+		group:  group,
+		labels: make(map[string]string),
+		colors: make(map[string]string),
+		color:  term.IsTerminal(int(os.Stdout.Fd())) && os.Getenv("NO_COLOR") == "",
+	}
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		var source, rest string
+		if strings.HasPrefix(item, "[") {
+			host, after, found := strings.Cut(item[1:], "]:")
+			if !found {
+				return nil, fmt.Errorf("invalid label %q (want [address]:label)", item)
+			}
+			source, rest = host, after
+		} else {
+			source, rest, _ = strings.Cut(item, ":")
+		}
+		label, color, hasColor := strings.Cut(rest, ":")
+		if source == "" || label == "" {
+			return nil, fmt.Errorf("invalid label %q (want source:label or source:label:colour)", item)
+		}
+		if _, dup := l.labels[source]; dup {
+			return nil, fmt.Errorf("source %q labelled twice", source)
+		}
+		l.labels[source] = label
+		if hasColor {
+			code, ok := labelColors[strings.ToLower(color)]
+			if !ok {
+				return nil, fmt.Errorf("unknown colour %q in %q (want %s)", color, item, labelColorNames())
+			}
+			l.colors[label] = code
+		}
+	}
+	if len(l.labels) == 0 {
+		return nil, fmt.Errorf("no labels given")
+	}
+	return l, nil
+}
+
+func labelColorNames() string {
+	var names []string
+	for n := range labelColors {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// apply relabels env, received from src, by its source.
+func (l *sourceLabels) apply(env *trackspb.Envelope, src string) {
+	if label, ok := l.labels[env.GetStreamId()]; ok && env.GetStreamId() != "" {
+		env.StreamId = label
+		return
+	}
+	sender, port, _ := sourcePort(src)
+	if host, _, err := net.SplitHostPort(sender); err == nil {
+		sender = host
+	}
+	for _, source := range []string{sender, port, l.group} {
+		if label, ok := l.labels[source]; ok && source != "" {
+			env.StreamId = label
+			return
+		}
+	}
+}
+
+// colorize colours a line about env by its label.
+func (l *sourceLabels) colorize(env *trackspb.Envelope, line string) string {
+	if l == nil || !l.color {
+		return line
+	}
+	if code := l.colors[env.GetStreamId()]; code != "" {
+		return "\033[" + code + "m" + line + "\033[0m"
+	}
+	return line
+}
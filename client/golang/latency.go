@@ -0,0 +1,166 @@
+package main
+
+import (
+	"expvar"
+	"math/bits"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Latency histograms: the time from the sender handing an envelope to its
+// transport (Envelope.send_time_ns) to the receiver decoding it, per event
+// category. Averages hide the stalls that break real time, so latencies go
+// into log-linear buckets in the manner of HDR histograms: 16 buckets per
+// power of two of microseconds, for percentiles within about 6% up to
+// hours. The histograms cover the whole run. They are served under
+// /debug/vars as latency, with the traffic statistics (-stats, /stats), and
+// only mean something when the sender's and receiver's clocks agree (NTP,
+// PTP, or the same host); envelopes that arrive before they were sent
+// count as negative, the sign of a clock offset.
+const (
+	latencySubBits  = 4 // 16 buckets per power of two
+	latencySub      = 1 << latencySubBits
+	latencyMaxShift = 40 // about 12 days, in microseconds
+	latencyBuckets  = 2*latencySub + latencyMaxShift*latencySub
+)
+
+type latencyHistogram struct {
+	counts   [latencyBuckets]uint64
+	count    uint64
+	sum      float64 // microseconds
+	max      uint64
+	negative uint64
+}
+
+// latencyBucket maps a latency in microseconds to its bucket: exact below
+// 2*latencySub, then latencySub buckets per power of two.
+func latencyBucket(us uint64) int {
+	if us < 2*latencySub {
+		return int(us)
+	}
+	shift := bits.Len64(us) - latencySubBits - 1
+	if shift > latencyMaxShift {
+		return latencyBuckets - 1
+	}
+	return 2*latencySub + (shift-1)*latencySub + int(us>>shift) - latencySub
+}
+
+// latencyValue is the middle of bucket i, in microseconds.
+func latencyValue(i int) float64 {
+	if i < 2*latencySub {
+		return float64(i)
+	}
+	j := i - 2*latencySub
+	shift := j/latencySub + 1
+	low := uint64(j%latencySub+latencySub) << shift
+	return float64(low) + float64(uint64(1)<<shift)/2
+}
+
+func (h *latencyHistogram) record(d time.Duration) {
+	if d < 0 {
+		h.negative++
+		return
+	}
+	us := uint64(d / time.Microsecond)
+	h.counts[latencyBucket(us)]++
+	h.count++
+	h.sum += float64(us)
+	h.max = max(h.max, us)
+}
+
+// quantile returns the latency below which a fraction q of envelopes
+// arrived, in microseconds.
+func (h *latencyHistogram) quantile(q float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+	rank := uint64(q*float64(h.count-1)) + 1
+	var seen uint64
+	for i, n := range h.counts {
+		seen += n
+		if seen >= rank {
+			return min(latencyValue(i), float64(h.max))
+		}
+	}
+	return float64(h.max)
+}
+
+// latencyStats is one row of the latency table, in milliseconds.
+type latencyStats struct {
+	Category string  `json:"category"`
+	Count    uint64  `json:"count"`
+	Mean     float64 `json:"mean_ms"`
+	P50      float64 `json:"p50_ms"`
+	P90      float64 `json:"p90_ms"`
+	P99      float64 `json:"p99_ms"`
+	P999     float64 `json:"p999_ms"`
+	Max      float64 `json:"max_ms"`
+	Negative uint64  `json:"negative"` // arrived before they were sent
+}
+
+type latencyTracker struct {
+	mu    sync.Mutex
+	hists map[string]*latencyHistogram // per category
+}
+
+var latencies = &latencyTracker{hists: make(map[string]*latencyHistogram)}
+
+func init() {
+	expvar.Publish("latency", expvar.Func(func() any { return latencies.snapshot() }))
+}
+
+// record adds an envelope decoded at received, if the sender stamped it.
+func (t *latencyTracker) record(env *trackspb.Envelope, received time.Time) {
+	sent := env.GetSendTimeNs()
+	if sent == 0 {
+		return
+	}
+	d := received.Sub(time.Unix(0, sent))
+	cat := eventCategory(env)
+	t.mu.Lock()
+	h := t.hists[cat]
+	if h == nil {
+		h = &latencyHistogram{}
+		t.hists[cat] = h
+	}
+	h.record(d)
+	t.mu.Unlock()
+}
+
+// snapshot returns the statistics per category, in category order.
+func (t *latencyTracker) snapshot() []latencyStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rows := []latencyStats{}
+	for cat, h := range t.hists {
+		r := latencyStats{
+			Category: cat,
+			Count:    h.count,
+			P50:      h.quantile(0.5) / 1000,
+			P90:      h.quantile(0.9) / 1000,
+			P99:      h.quantile(0.99) / 1000,
+			P999:     h.quantile(0.999) / 1000,
+			Max:      float64(h.max) / 1000,
+			Negative: h.negative,
+		}
+		if h.count > 0 {
+			r.Mean = h.sum / float64(h.count) / 1000
+		}
+		rows = append(rows, r)
+	}
+	order := make(map[string]int)
+	for i, c := range categoryNames {
+		order[c] = i
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		oi, oj := order[rows[i].Category], order[rows[j].Category]
+		if oi != oj {
+			return oi < oj
+		}
+		return rows[i].Category < rows[j].Category
+	})
+	return rows
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Lead-sheet export (-lead-sheet). Lays the chord progression out in bars
+// using the beat grid: ChordPro for .cho/.chopro/.chordpro/.pro files, a
+// plain-text chart otherwise.
+const (
+	leadSheetBarsPerLine = 4
+	beatMergeWindow      = 0.05 // beat and downbeat within this are one beat
+)
+
+// bar is one measure: the chord shown on each beat, "" where the previous
+// chord continues.
+type bar struct {
+	start  float64
+	chords []string
+}
+
+// beatGrid returns the track's beat times, with downbeats folded in.
+func beatGrid(d *trackData) (beats []float64, downs []float64) {
+	for _, p := range d.series["beat"] {
+		beats = append(beats, p.t)
+	}
+	for _, p := range d.series["downbeat"] {
+		downs = append(downs, p.t)
+	}
+	return mergeBeats(beats, downs), downs
+}
+
+// mergeBeats sorts beats and downbeats into one grid.
+func mergeBeats(beats, downs []float64) []float64 {
+	all := append(append([]float64(nil), beats...), downs...)
+	sort.Float64s(all)
+	merged := all[:0]
+	for _, t := range all {
+		if len(merged) == 0 || t-merged[len(merged)-1] > beatMergeWindow {
+			merged = append(merged, t)
+		}
+	}
+	return merged
+}
+
+// buildBars groups beats into bars at each downbeat (beats before the first
+// one form a pickup bar), or into fours when the sender reported no
+// downbeats, and places every chord change on its nearest beat.
+func buildBars(d *trackData) []bar {
+	beats, downs := beatGrid(d)
+	if len(beats) == 0 {
+		return nil
+	}
+
+	isDown := make([]bool, len(beats))
+	for _, t := range downs {
+		isDown[nearestIndex(beats, t)] = true
+	}
+	if len(downs) == 0 {
+		for i := range isDown {
+			isDown[i] = i%4 == 0
+		}
+	}
+
+	onBeat := make([]string, len(beats))
+	for _, c := range d.chords {
+		onBeat[nearestIndex(beats, c.t)] = c.name
+	}
+
+	var bars []bar
+	shown := ""
+	for i, t := range beats {
+		if i == 0 || isDown[i] {
+			bars = append(bars, bar{start: t})
+		}
+		b := &bars[len(bars)-1]
+		c := onBeat[i]
+		if c == shown {
+			c = ""
+		} else if c != "" {
+			shown = c
+		}
+		b.chords = append(b.chords, c)
+	}
+	return bars
+}
+
+func nearestIndex(ts []float64, t float64) int {
+	i := sort.SearchFloat64s(ts, t)
+	if i == len(ts) || (i > 0 && t-ts[i-1] < ts[i]-t) {
+		i--
+	}
+	return i
+}
+
+// mainKey is the key held for the longest part of the track.
+func mainKey(d *trackData) string {
+	return heldLongest(d.keys, d.duration())
+}
+
+// heldLongest is the label in force for the longest time up to end.
+func heldLongest(labels []label, end float64) string {
+	held := make(map[string]float64)
+	for i, l := range labels {
+		until := end
+		if i+1 < len(labels) {
+			until = labels[i+1].t
+		}
+		held[l.name] += until - l.t
+	}
+	best := ""
+	for k, dur := range held {
+		if best == "" || dur > held[best] || (dur == held[best] && k < best) {
+			best = k
+		}
+	}
+	return best
+}
+
+// shortKey renders "A minor" as "Am" and "C major" as "C".
+func shortKey(key string) string {
+	tonic, scale, _ := strings.Cut(key, " ")
+	scale, _, _ = strings.Cut(scale, " ") // drop the key code
+	if scale == "minor" {
+		return tonic + "m"
+	}
+	return tonic
+}
+
+// timeSignature is the track's mainMeter or, when there is none, the most
+// common number of beats per bar over 4.
+func timeSignature(d *trackData, bars []bar) string {
+	if m := mainMeter(d); m != "" {
+		return m
+	}
+	return fmt.Sprintf("%d/4", meter(bars))
+}
+
+// meter is the most common number of beats per bar, ignoring the pickup.
+func meter(bars []bar) int {
+	counts := make(map[int]int)
+	for i, b := range bars {
+		if i > 0 || len(bars) == 1 {
+			counts[len(b.chords)]++
+		}
+	}
+	best := 4
+	for n, c := range counts {
+		if c > counts[best] || (c == counts[best] && n < best) {
+			best = n
+		}
+	}
+	return best
+}
+
+// medianTempo is the median tempo.change, or 0 when none was reported.
+func medianTempo(d *trackData) float64 {
+	var bpms []float64
+	for _, p := range d.series["tempo.change"] {
+		bpms = append(bpms, p.v)
+	}
+	if len(bpms) == 0 {
+		return 0
+	}
+	sort.Float64s(bpms)
+	return bpms[len(bpms)/2]
+}
+
+func isChordProPath(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".cho", ".chopro", ".chordpro", ".pro":
+		return true
+	}
+	return false
+}
+
+func writeLeadSheet(path string, d *trackData) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	bars := buildBars(d)
+	if isChordProPath(path) {
+		writeChordPro(w, d, bars)
+	} else {
+		writeTextChart(w, d, bars)
+	}
+	return flushClose(w, f)
+}
+
+func writeChordPro(w *bufio.Writer, d *trackData, bars []bar) {
+	fmt.Fprintf(w, "{title: %s}\n", d.meta.displayTitle(d.start))
+	if d.meta.Artist != "" {
+		fmt.Fprintf(w, "{artist: %s}\n", d.meta.Artist)
+	}
+	if d.meta.Album != "" {
+		fmt.Fprintf(w, "{album: %s}\n", d.meta.Album)
+	}
+	if k := mainKey(d); k != "" {
+		fmt.Fprintf(w, "{key: %s}\n", shortKey(k))
+	}
+	if bpm := medianTempo(d); bpm > 0 {
+		fmt.Fprintf(w, "{tempo: %.0f}\n", bpm)
+	}
+	n := meter(bars)
+	fmt.Fprintf(w, "{time: %s}\n\n", timeSignature(d, bars))
+	if len(bars) == 0 {
+		fmt.Fprintln(w, "{comment: No beats were detected}")
+		return
+	}
+
+	fmt.Fprintf(w, "{start_of_grid: %dx%d}\n", leadSheetBarsPerLine, n)
+	shown := ""
+	for i := 0; i < len(bars); i += leadSheetBarsPerLine {
+		line := bars[i:min(i+leadSheetBarsPerLine, len(bars))]
+		fmt.Fprint(w, "|")
+		for k, b := range line {
+			for j, c := range b.chords {
+				// Name the sounding chord at the start of every line.
+				if c == "" && k == 0 && j == 0 && shown != "" {
+					c = shown
+				}
+				if c == "" {
+					c = "."
+				} else {
+					shown = c
+				}
+				fmt.Fprintf(w, " %s", c)
+			}
+			fmt.Fprint(w, " |")
+		}
+		fmt.Fprintf(w, " %s\n", formatClock(line[0].start))
+	}
+	fmt.Fprintln(w, "{end_of_grid}")
+}
+
+func writeTextChart(w *bufio.Writer, d *trackData, bars []bar) {
+	if d.meta.Title != "" {
+		fmt.Fprintln(w, d.meta.heading(d.start))
+	} else {
+		fmt.Fprintln(w, filepath.Base(d.start.GetFilename()))
+	}
+	var info []string
+	if k := mainKey(d); k != "" {
+		info = append(info, "Key: "+k)
+	}
+	if bpm := medianTempo(d); bpm > 0 {
+		info = append(info, fmt.Sprintf("Tempo: %.0f BPM", bpm))
+	}
+	info = append(info, "Time: "+timeSignature(d, bars))
+	fmt.Fprintf(w, "%s\n\n", strings.Join(info, "   "))
+	if len(bars) == 0 {
+		fmt.Fprintln(w, "No beats were detected.")
+		return
+	}
+
+	width := 2
+	for _, b := range bars {
+		for _, c := range b.chords {
+			width = max(width, len(c)+1)
+		}
+	}
+	digits := int(math.Log10(float64(len(bars)))) + 1
+	shown := ""
+	for i := 0; i < len(bars); i += leadSheetBarsPerLine {
+		line := bars[i:min(i+leadSheetBarsPerLine, len(bars))]
+		fmt.Fprintf(w, "%*d %s |", digits, i+1, formatClock(line[0].start))
+		for k, b := range line {
+			for j, c := range b.chords {
+				if c == "" && k == 0 && j == 0 && shown != "" {
+					c = shown
+				}
+				if c == "" {
+					c = "."
+				} else {
+					shown = c
+				}
+				fmt.Fprintf(w, " %-*s", width, c)
+			}
+			fmt.Fprint(w, "|")
+		}
+		fmt.Fprintln(w)
+	}
+}
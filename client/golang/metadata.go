@@ -0,0 +1,195 @@
+package main
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"github.com/dhowden/tag"
+)
+
+// Track metadata (-track-metadata, -track-tags). The sender announces a
+// track by its filename only; with these flags the receiver looks up the
+// artist, title and album when a track is announced and attaches them to
+// the track, for summaries, now-playing outputs and exports. Two sources,
+// in order of precedence:
+//
+//   - a local database, -track-metadata FILE: JSON, an object keyed by
+//     filename ({"song.mp3": {"artist": "...", "title": "...", "album":
+//     "..."}}), or CSV with a header row naming filename, artist, title and
+//     album columns. Keys match the full filename as the sender reports it,
+//     or its base name.
+//   - the file's own tags, -track-tags: ID3 (MP3), MP4/M4A, FLAC and Ogg
+//     Vorbis comments, when the receiver can open the file under the same
+//     path as the sender (the same machine, or a shared mount).
+//
+// Lookups happen once per filename and are cached; they start on
+// track.prepare, so the tags of the next track are usually read before it
+// begins.
+type trackMeta struct {
+	Artist string `json:"artist"`
+	Title  string `json:"title"`
+	Album  string `json:"album"`
+}
+
+type metadataLookup struct {
+	db   map[string]trackMeta
+	tags bool
+
+	mu    sync.Mutex
+	cache map[string]trackMeta
+}
+
+// trackMetadata is the configured lookup, or nil when tracks are known by
+// filename only.
+var trackMetadata *metadataLookup
+
+func newMetadataLookup(dbPath string, tags bool) (*metadataLookup, error) {
+	m := &metadataLookup{tags: tags, cache: make(map[string]trackMeta)}
+	if dbPath == "" {
+		return m, nil
+	}
+	var err error
+	if strings.EqualFold(filepath.Ext(dbPath), ".csv") {
+		m.db, err = loadMetadataCSV(dbPath)
+	} else {
+		m.db, err = loadMetadataJSON(dbPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dbPath, err)
+	}
+	return m, nil
+}
+
+func loadMetadataJSON(path string) (map[string]trackMeta, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	db := make(map[string]trackMeta)
+	if err := json.Unmarshal(b, &db); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func loadMetadataCSV(path string) (map[string]trackMeta, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("no header row")
+	}
+	col := map[string]int{"filename": -1, "artist": -1, "title": -1, "album": -1}
+	for i, name := range rows[0] {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := col[name]; ok {
+			col[name] = i
+		}
+	}
+	if col["filename"] < 0 {
+		return nil, errors.New("no filename column in the header row")
+	}
+	get := func(row []string, name string) string {
+		if i := col[name]; i >= 0 && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	db := make(map[string]trackMeta)
+	for _, row := range rows[1:] {
+		if name := get(row, "filename"); name != "" {
+			db[name] = trackMeta{Artist: get(row, "artist"), Title: get(row, "title"), Album: get(row, "album")}
+		}
+	}
+	return db, nil
+}
+
+// lookupTrackMeta returns what is known about the announced file; fields
+// nobody knows are empty.
+func lookupTrackMeta(filename string) trackMeta {
+	m := trackMetadata
+	if m == nil || filename == "" {
+		return trackMeta{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if meta, ok := m.cache[filename]; ok {
+		return meta
+	}
+	meta, ok := m.db[filename]
+	if !ok {
+		meta = m.db[filepath.Base(filename)]
+	}
+	if m.tags && (meta.Artist == "" || meta.Title == "" || meta.Album == "") {
+		tags := readTags(filename)
+		meta.Artist = cmp.Or(meta.Artist, tags.Artist)
+		meta.Title = cmp.Or(meta.Title, tags.Title)
+		meta.Album = cmp.Or(meta.Album, tags.Album)
+	}
+	m.cache[filename] = meta
+	return meta
+}
+
+// readTags reads the file's embedded tags. A file that is not on this
+// machine, or has no tags, is not an error worth reporting.
+func readTags(path string) trackMeta {
+	f, err := os.Open(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "metadata: %v\n", err)
+		}
+		return trackMeta{}
+	}
+	defer f.Close()
+	t, err := tag.ReadFrom(f)
+	if err != nil {
+		if err != tag.ErrNoTagsFound {
+			fmt.Fprintf(os.Stderr, "metadata: %s: %v\n", path, err)
+		}
+		return trackMeta{}
+	}
+	return trackMeta{
+		Artist: strings.TrimSpace(cmp.Or(t.Artist(), t.AlbumArtist())),
+		Title:  strings.TrimSpace(t.Title()),
+		Album:  strings.TrimSpace(t.Album()),
+	}
+}
+
+// prefetchTrackMeta starts looking up the track a track.prepare announces.
+func prefetchTrackMeta(env *trackspb.Envelope) {
+	if p := env.GetTrackPrepare(); p != nil && trackMetadata != nil {
+		go lookupTrackMeta(p.GetFilename())
+	}
+}
+
+// displayTitle is the track's title, falling back to its filename without
+// directory or extension.
+func (m trackMeta) displayTitle(start *trackspb.TrackStart) string {
+	return cmp.Or(m.Title, trackTitle(start))
+}
+
+// heading is "Artist - Title" when the artist is known, otherwise the
+// title.
+func (m trackMeta) heading(start *trackspb.TrackStart) string {
+	if m.Artist == "" {
+		return m.displayTitle(start)
+	}
+	return m.Artist + " - " + m.displayTitle(start)
+}
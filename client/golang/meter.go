@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Meter estimation (-derive=meter.change, and the time signature of
+// -lead-sheet and -midi-file exports). The beats between consecutive
+// downbeats are counted over the last meterWindow; the most common count is
+// the number of beats per bar once at least meterMinBars bars were measured
+// and meterMinShare of them agree. Onsets on the thirds of the beat, more
+// than on its half, make the meter compound: two beats per bar are then 6/8
+// rather than 2/4. Six, nine or twelve beats per bar are taken as a compound
+// meter counted in eighths.
+const (
+	meterWindow    = 32.0 // seconds of beats the deriver measures
+	meterMinBars   = 4
+	meterMinShare  = 0.6
+	meterPhase     = 0.07 // tolerance around 1/3, 1/2 and 2/3 of a beat
+	meterMinThirds = 4    // onsets needed on each third for a compound meter
+)
+
+type meterDeriver struct {
+	streams map[string]*meterState
+}
+
+type meterState struct {
+	beats, downs, onsets []float64
+	meter                string // last reported
+}
+
+func (d *meterDeriver) derive(env *trackspb.Envelope) []*trackspb.Envelope {
+	stream := env.GetStreamId()
+	switch env.Event.(type) {
+	case *trackspb.Envelope_TrackStart, *trackspb.Envelope_TimelineReset:
+		delete(d.streams, stream)
+		return nil
+	case *trackspb.Envelope_Beat, *trackspb.Envelope_Downbeat, *trackspb.Envelope_Onset:
+	default:
+		return nil
+	}
+	st := d.streams[stream]
+	if st == nil {
+		st = &meterState{}
+		d.streams[stream] = st
+	}
+	t := env.GetTimestamp()
+	switch env.Event.(type) {
+	case *trackspb.Envelope_Beat:
+		st.beats = appendRecentTimes(st.beats, t, meterWindow)
+	case *trackspb.Envelope_Onset:
+		st.onsets = appendRecentTimes(st.onsets, t, meterWindow)
+	case *trackspb.Envelope_Downbeat:
+		st.downs = appendRecentTimes(st.downs, t, meterWindow)
+		if m := estimateMeter(st.beats, st.downs, st.onsets); m != nil && m.GetMeter() != st.meter {
+			st.meter = m.GetMeter()
+			return []*trackspb.Envelope{derivedEnvelope(env, m)}
+		}
+	}
+	return nil
+}
+
+// estimateMeter returns the meter of the bars between downs, or nil if
+// there are too few of them or they disagree.
+func estimateMeter(beats, downs, onsets []float64) *trackspb.MeterChange {
+	grid := mergeBeats(beats, downs)
+	downs = append([]float64(nil), downs...)
+	sort.Float64s(downs)
+	counts := make(map[int]int)
+	bars := 0
+	for i := 1; i < len(downs); i++ {
+		lo := sort.SearchFloat64s(grid, downs[i-1]-beatMergeWindow)
+		hi := sort.SearchFloat64s(grid, downs[i]-beatMergeWindow)
+		if n := hi - lo; n >= 1 && n <= 16 {
+			counts[n]++
+			bars++
+		}
+	}
+	if bars < meterMinBars {
+		return nil
+	}
+	best := 0
+	for n, c := range counts {
+		if best == 0 || c > counts[best] || (c == counts[best] && n < best) {
+			best = n
+		}
+	}
+	share := float64(counts[best]) / float64(bars)
+	if share < meterMinShare {
+		return nil
+	}
+	m := &trackspb.MeterChange{Beats: int32(best), Confidence: float64(int(share*100)) / 100}
+	switch {
+	case best <= 4 && compoundBeats(grid, onsets):
+		m.Numerator, m.Denominator = int32(3*best), 8
+	case best >= 6 && best%3 == 0:
+		m.Numerator, m.Denominator = int32(best), 8
+	default:
+		m.Numerator, m.Denominator = int32(best), 4
+	}
+	m.Meter = fmt.Sprintf("%d/%d", m.Numerator, m.Denominator)
+	return m
+}
+
+// compoundBeats reports whether the onsets within beats fall on its thirds
+// rather than its half.
+func compoundBeats(grid, onsets []float64) bool {
+	onsets = append([]float64(nil), onsets...)
+	sort.Float64s(onsets)
+	var first, second, half int
+	for i := 1; i < len(grid); i++ {
+		b0, iv := grid[i-1], grid[i]-grid[i-1]
+		if iv < 0.2 || iv > 2 {
+			continue
+		}
+		for j := sort.SearchFloat64s(onsets, b0); j < len(onsets) && onsets[j] < grid[i]; j++ {
+			phase := (onsets[j] - b0) / iv
+			switch {
+			case phase > 1.0/3-meterPhase && phase < 1.0/3+meterPhase:
+				first++
+			case phase > 2.0/3-meterPhase && phase < 2.0/3+meterPhase:
+				second++
+			case phase > 0.5-meterPhase && phase < 0.5+meterPhase:
+				half++
+			}
+		}
+	}
+	return first >= meterMinThirds && second >= meterMinThirds && first+second > 2*half
+}
+
+// mainMeter is the meter.change held for the longest part of the track or,
+// when none was derived, the meter estimated over the whole track; "" if
+// neither is known.
+func mainMeter(d *trackData) string {
+	if len(d.meters) > 0 {
+		return heldLongest(d.meters, d.duration())
+	}
+	if m := estimateMeter(eventTimes(d, "beat"), eventTimes(d, "downbeat"), eventTimes(d, "onset")); m != nil {
+		return m.GetMeter()
+	}
+	return ""
+}
+
+// parseMeter splits "6/8" into 6 and 8.
+func parseMeter(s string) (num, den int, ok bool) {
+	a, b, found := strings.Cut(s, "/")
+	num, err1 := strconv.Atoi(a)
+	den, err2 := strconv.Atoi(b)
+	if !found || err1 != nil || err2 != nil || num < 1 || den < 1 {
+		return 0, 0, false
+	}
+	return num, den, true
+}
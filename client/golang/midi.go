@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Live MIDI output (-midi). Follows the detected melody (or pitch) with
+// note on/off messages, bending the sounding note to track the frequency
+// between semitones. Messages are written as raw MIDI bytes to a device,
+// so no MIDI library or cgo is needed: on Linux, `modprobe snd-virmidi`
+// provides virtual ports (/dev/snd/midiC*D*) that show up to synthesizers
+// like any other MIDI port. The device "rtpmidi" sends over the network
+// session instead (-rtpmidi).
+const (
+	midiVelocity = 100
+	// A new note starts once the frequency is this many semitones away
+	// from the sounding one; smaller moves are pitch bends.
+	midiRetrigger = 0.75
+)
+
+// freqToNote returns the fractional MIDI note number of f (A4 = 440 Hz = 69).
+func freqToNote(f float64) float64 {
+	return 69 + 12*math.Log2(f/440)
+}
+
+// openMIDIPort opens a raw MIDI device, or the RTP-MIDI session for
+// "rtpmidi".
+func openMIDIPort(path string, rtp *rtpMIDISession) (io.WriteCloser, error) {
+	if path == "rtpmidi" {
+		if rtp == nil {
+			return nil, fmt.Errorf("rtpmidi needs an -rtpmidi session")
+		}
+		return rtp.port(), nil
+	}
+	return os.OpenFile(path, os.O_WRONLY, 0)
+}
+
+type midiOutput struct {
+	w         io.WriteCloser
+	channel   byte
+	source    string // melody or pitch
+	minConf   float64
+	bendRange float64 // semitones for a full pitch-bend swing
+
+	note  int // sounding note, or -1
+	bend  int // last sent, or -1 before the first
+	bytes []byte
+}
+
+func newMIDIOutput(path string, rtp *rtpMIDISession, channel int, source string, minConf, bendRange float64) (*midiOutput, error) {
+	if channel < 1 || channel > 16 {
+		return nil, fmt.Errorf("channel must be 1-16")
+	}
+	if source != "melody" && source != "pitch" {
+		return nil, fmt.Errorf("unknown source %q (want melody or pitch)", source)
+	}
+	if bendRange <= 0 {
+		return nil, fmt.Errorf("bend range must be positive")
+	}
+	w, err := openMIDIPort(path, rtp)
+	if err != nil {
+		return nil, err
+	}
+	m := &midiOutput{
+		w:         w,
+		channel:   byte(channel - 1),
+		source:    source,
+		minConf:   minConf,
+		bendRange: bendRange,
+		note:      -1,
+		bend:      -1,
+	}
+	// Start from a centred bend in case the synth was left bent.
+	m.pitchBend(8192)
+	m.flush()
+	return m, nil
+}
+
+func (m *midiOutput) handle(env *trackspb.Envelope) {
+	switch e := env.Event.(type) {
+	case *trackspb.Envelope_Melody:
+		if m.source == "melody" {
+			m.follow(e.Melody.GetFrequency())
+		}
+	case *trackspb.Envelope_Pitch:
+		if m.source == "pitch" {
+			f := e.Pitch.GetFrequency()
+			if e.Pitch.GetConfidence() < m.minConf {
+				f = 0
+			}
+			m.follow(f)
+		}
+	case *trackspb.Envelope_SilenceStart, *trackspb.Envelope_TrackEnd, *trackspb.Envelope_TrackAbort:
+		m.noteOff()
+	}
+	m.flush()
+}
+
+// follow moves the output to frequency f; f <= 0 (unvoiced) ends the note.
+func (m *midiOutput) follow(f float64) {
+	if f <= 0 {
+		m.noteOff()
+		return
+	}
+	n := freqToNote(f)
+	if n < 0 || n > 127 {
+		m.noteOff()
+		return
+	}
+	if m.note < 0 || math.Abs(n-float64(m.note)) >= midiRetrigger {
+		m.noteOff()
+		m.note = int(math.Round(n))
+		m.pitchBend(m.bendFor(n))
+		m.bytes = append(m.bytes, 0x90|m.channel, byte(m.note), midiVelocity)
+		return
+	}
+	m.pitchBend(m.bendFor(n))
+}
+
+func (m *midiOutput) bendFor(n float64) int {
+	v := 8192 + int(math.Round((n-float64(m.note))/m.bendRange*8192))
+	return min(max(v, 0), 16383)
+}
+
+func (m *midiOutput) pitchBend(v int) {
+	if v == m.bend {
+		return
+	}
+	m.bend = v
+	m.bytes = append(m.bytes, 0xe0|m.channel, byte(v&0x7f), byte(v>>7))
+}
+
+func (m *midiOutput) noteOff() {
+	if m.note < 0 {
+		return
+	}
+	m.bytes = append(m.bytes, 0x80|m.channel, byte(m.note), 0)
+	m.note = -1
+}
+
+func (m *midiOutput) flush() {
+	if len(m.bytes) == 0 {
+		return
+	}
+	if _, err := m.w.Write(m.bytes); err != nil {
+		fmt.Fprintf(os.Stderr, "midi: %v\n", err)
+	}
+	m.bytes = m.bytes[:0]
+}
+
+// close releases the sounding note and the device.
+func (m *midiOutput) close() {
+	m.noteOff()
+	m.pitchBend(8192)
+	m.flush()
+	m.w.Close()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+func TestMIDIOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "midi")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := newMIDIOutput(path, nil, 1, "melody", 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	melody := func(f float64) {
+		m.handle(&trackspb.Envelope{Event: &trackspb.Envelope_Melody{Melody: &trackspb.Melody{Frequency: f}}})
+	}
+	melody(440)
+	melody(440)
+	melody(0)
+	m.close()
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0xe0, 0x00, 0x40, // centred bend on opening, in case the synth was left bent
+		0x90, 69, midiVelocity, // A4, already centred
+		0x80, 69, 0, // unvoiced
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("sent % x, want % x", got, want)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/tracks"
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// MIDI Time Code output (-mtc). While a track plays, SMPTE timecode for the
+// interpolated track position is sent as MTC quarter-frame messages, so
+// video and lighting gear that chases timecode follows the analyzed track.
+// Eight quarter frames, sent four per frame, carry one complete timecode;
+// each sequence is stamped with the position when its first piece is sent.
+// A full-frame SysEx message locates receivers when a track starts, after
+// a seek and when playback stops, after which quarter frames pause, as
+// from a stopped tape machine. Like -midi, raw MIDI bytes are written to a
+// device file or the RTP-MIDI session.
+
+// mtcRate is one of the four SMPTE frame rates MTC can carry.
+type mtcRate struct {
+	name string
+	fps  float64 // frames per second of real time
+	nom  int     // frames per timecode second
+	code byte    // rate bits of the hours byte
+	drop bool    // drop-frame numbering
+}
+
+var mtcRates = []mtcRate{
+	{"24", 24, 24, 0, false},
+	{"25", 25, 25, 1, false},
+	{"29.97df", 30000.0 / 1001, 30, 2, true},
+	{"30", 30, 30, 3, false},
+}
+
+func parseMTCRate(s string) (mtcRate, error) {
+	for _, r := range mtcRates {
+		if r.name == s {
+			return r, nil
+		}
+	}
+	return mtcRate{}, fmt.Errorf("unknown frame rate %q (want 24, 25, 29.97df or 30)", s)
+}
+
+// timecode is an SMPTE time: hours, minutes, seconds and frames.
+type timecode struct {
+	h, m, s, f int
+}
+
+func (tc timecode) String() string {
+	return fmt.Sprintf("%02d:%02d:%02d:%02d", tc.h, tc.m, tc.s, tc.f)
+}
+
+// toTimecode converts seconds to timecode at rate r, wrapping at 24 hours.
+// Drop-frame timecode skips frame numbers 0 and 1 at the start of every
+// minute except each tenth, so its numbers keep up with the clock.
+func toTimecode(sec float64, r mtcRate) timecode {
+	n := int(math.Floor(max(sec, 0)*r.fps + 1e-6))
+	if r.drop {
+		const perTen = 17982 // frames in ten minutes at 29.97
+		const perMin = 1798  // frames in a dropping minute
+		d, m := n/perTen, n%perTen
+		n += 18 * d
+		if m > 1 {
+			n += 2 * ((m - 2) / perMin)
+		}
+	}
+	n %= 24 * 3600 * r.nom
+	return timecode{n / (3600 * r.nom), n / (60 * r.nom) % 60, n / r.nom % 60, n % r.nom}
+}
+
+type mtcOutput struct {
+	w     io.WriteCloser
+	rate  mtcRate
+	start float64 // timecode of track position 0, seconds
+	pos   *tracks.PositionEstimator
+
+	mu      sync.Mutex
+	locate  bool // send a full frame before the next quarter frames
+	running bool // quarter frames are being sent
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newMTCOutput(path string, rtp *rtpMIDISession, rate string, start time.Duration) (*mtcOutput, error) {
+	r, err := parseMTCRate(rate)
+	if err != nil {
+		return nil, err
+	}
+	if start < 0 || start >= 24*time.Hour {
+		return nil, fmt.Errorf("start must be between 0 and 24h")
+	}
+	w, err := openMIDIPort(path, rtp)
+	if err != nil {
+		return nil, err
+	}
+	m := &mtcOutput{
+		w:     w,
+		rate:  r,
+		start: start.Seconds(),
+		pos:   tracks.NewPositionEstimator(),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go m.run()
+	return m, nil
+}
+
+func (m *mtcOutput) handle(env *trackspb.Envelope, received time.Time) {
+	m.pos.Observe(env, received)
+	if env.GetTrackStart() != nil || env.GetTimelineReset() != nil {
+		m.mu.Lock()
+		m.locate = true
+		m.mu.Unlock()
+	}
+}
+
+// run sends quarter frames on a fixed schedule while the position advances.
+func (m *mtcOutput) run() {
+	defer close(m.done)
+	quarter := time.Duration(float64(time.Second) / (4 * m.rate.fps))
+	next := time.Now()
+	var last float64 // position at the previous sequence
+	var tc timecode
+	for piece := 0; ; piece = (piece + 1) % 8 {
+		select {
+		case <-m.stop:
+			return
+		case <-time.After(time.Until(next)):
+		}
+		next = next.Add(quarter)
+		if now := time.Now(); now.Sub(next) > 4*quarter {
+			// Fell behind (suspended, overloaded): don't try to catch up.
+			next = now
+		}
+
+		if piece == 0 {
+			now := time.Now()
+			p := m.pos.Position(now)
+			playing := m.pos.Playing() && p != last
+			m.mu.Lock()
+			// A sequence covers two frames; anything else is a seek.
+			seek := math.Abs(p-last-2/m.rate.fps) > 2/m.rate.fps
+			locate := m.locate || !m.running || seek
+			m.locate = false
+			if m.running && !playing {
+				// Stopped: park receivers on the final position.
+				m.write(m.fullFrame(toTimecode(m.start+p, m.rate)))
+			}
+			m.running = playing
+			m.mu.Unlock()
+			last = p
+			if !playing {
+				piece = 7 // look again at the next quarter frame
+				continue
+			}
+			tc = toTimecode(m.start+p, m.rate)
+			if locate {
+				m.write(m.fullFrame(tc))
+			}
+		}
+		m.write([]byte{0xf1, m.quarterFrame(piece, tc)})
+	}
+}
+
+// quarterFrame returns the data byte of piece 0-7 of tc.
+func (m *mtcOutput) quarterFrame(piece int, tc timecode) byte {
+	var v int
+	switch piece {
+	case 0:
+		v = tc.f & 0xf
+	case 1:
+		v = tc.f >> 4
+	case 2:
+		v = tc.s & 0xf
+	case 3:
+		v = tc.s >> 4
+	case 4:
+		v = tc.m & 0xf
+	case 5:
+		v = tc.m >> 4
+	case 6:
+		v = tc.h & 0xf
+	case 7:
+		v = tc.h>>4 | int(m.rate.code)<<1
+	}
+	return byte(piece<<4 | v)
+}
+
+// fullFrame returns the full-frame SysEx message locating tc.
+func (m *mtcOutput) fullFrame(tc timecode) []byte {
+	return []byte{0xf0, 0x7f, 0x7f, 0x01, 0x01, m.rate.code<<5 | byte(tc.h), byte(tc.m), byte(tc.s), byte(tc.f), 0xf7}
+}
+
+func (m *mtcOutput) write(b []byte) {
+	if _, err := m.w.Write(b); err != nil {
+		fmt.Fprintf(os.Stderr, "mtc: %v\n", err)
+	}
+}
+
+func (m *mtcOutput) close() {
+	close(m.stop)
+	<-m.done
+	if m.running {
+		m.write(m.fullFrame(toTimecode(m.start+m.pos.Position(time.Now()), m.rate)))
+	}
+	m.w.Close()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// Noise floor (the track summary's "noise_floor", and the HTML report): the
+// loudness frames inside the track's silences, less noiseFloorEdge at either
+// end of each so fades and decays stay out, are what the recording sounds
+// like with nothing playing. Their median is the floor. A track whose floor
+// is above noiseFloorThreshold is flagged.
+const (
+	noiseFloorEdge      = 0.25 // seconds
+	noiseFloorMinFrames = 3
+)
+
+// noiseFloorThreshold is the floor above which a track is flagged, set by
+// -noise-floor-max.
+var noiseFloorThreshold = -60.0
+
+type noiseFloor struct {
+	Level    float64 `json:"level"` // median, dB or LUFS
+	P10      float64 `json:"p10"`
+	P90      float64 `json:"p90"`
+	Silences int     `json:"silences"` // measured
+	Seconds  float64 `json:"seconds"`  // of silence measured
+	Frames   int     `json:"frames"`
+	Exceeded bool    `json:"exceeded"` // Level above the threshold
+}
+
+// trackNoiseFloor measures d's noise floor, or returns nil when its
+// silences hold fewer than noiseFloorMinFrames loudness frames.
+func trackNoiseFloor(d *trackData) *noiseFloor {
+	frames := append([]point(nil), d.series["loudness"]...)
+	sort.SliceStable(frames, func(i, j int) bool { return frames[i].t < frames[j].t })
+	var levels []float64
+	nf := &noiseFloor{}
+	for _, r := range silenceRegions(d) {
+		from, to := r.start, r.end
+		if to-from > 2*noiseFloorEdge {
+			from, to = from+noiseFloorEdge, to-noiseFloorEdge
+		}
+		lo := sort.Search(len(frames), func(i int) bool { return frames[i].t >= from })
+		hi := sort.Search(len(frames), func(i int) bool { return frames[i].t > to })
+		if hi <= lo {
+			continue
+		}
+		for _, p := range frames[lo:hi] {
+			levels = append(levels, p.v)
+		}
+		nf.Silences++
+		nf.Seconds += to - from
+	}
+	if len(levels) < noiseFloorMinFrames {
+		return nil
+	}
+	sort.Float64s(levels)
+	at := func(q float64) float64 { return round1(levels[int(math.Round(q*float64(len(levels)-1)))]) }
+	nf.Level, nf.P10, nf.P90 = at(0.5), at(0.1), at(0.9)
+	nf.Seconds = round3(nf.Seconds)
+	nf.Frames = len(levels)
+	nf.Exceeded = nf.Level > noiseFloorThreshold
+	return nf
+}
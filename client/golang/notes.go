@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Note names for pitch and melody frequencies, relative to the most recent
+// Tuning event of the stream instead of a fixed A4 = 440 Hz.
+const defaultA4 = 440.0
+
+var noteNames = [12]string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+
+// tuningTracker holds the current A4 reference per stream. It is updated
+// from the main loop and read by formatters on other goroutines.
+type tuningTracker struct {
+	mu  sync.Mutex
+	ref map[string]float64
+}
+
+var tuning = &tuningTracker{ref: make(map[string]float64)}
+
+// observe updates the stream's reference from tuning events and resets it
+// on track.start. Tuning.frequency is accepted both as the reference itself
+// (e.g. 442) and as a deviation from 440 Hz (e.g. +2); anything else is
+// ignored.
+func (t *tuningTracker) observe(env *trackspb.Envelope) {
+	stream := env.GetStreamId()
+	switch e := env.Event.(type) {
+	case *trackspb.Envelope_TrackStart:
+		t.mu.Lock()
+		delete(t.ref, stream)
+		t.mu.Unlock()
+	case *trackspb.Envelope_Tuning:
+		f := e.Tuning.GetFrequency()
+		switch {
+		case f > 400 && f < 480:
+		case f > -40 && f < 40:
+			f += defaultA4
+		default:
+			return
+		}
+		t.mu.Lock()
+		t.ref[stream] = f
+		t.mu.Unlock()
+	}
+}
+
+func (t *tuningTracker) reference(stream string) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if f, ok := t.ref[stream]; ok {
+		return f
+	}
+	return defaultA4
+}
+
+// note is a frequency named as the nearest equal-tempered note.
+type note struct {
+	Name      string  `json:"name"`  // e.g. "A4", "C#5"
+	Cents     float64 `json:"cents"` // deviation from Name, -50 to +50
+	Reference float64 `json:"a4"`    // A4 reference used, Hz
+}
+
+func (n note) String() string {
+	return fmt.Sprintf("%s%+.0fc", n.Name, n.Cents)
+}
+
+// nameNote names freq relative to the A4 reference ref. It fails for
+// unvoiced (non-positive) frequencies and those outside the MIDI range.
+func nameNote(freq, ref float64) (note, bool) {
+	if freq <= 0 {
+		return note{}, false
+	}
+	n := 69 + 12*math.Log2(freq/ref)
+	nearest := math.Round(n)
+	if nearest < 0 || nearest > 127 {
+		return note{}, false
+	}
+	i := int(nearest)
+	cents := math.Round((n-nearest)*1000) / 10
+	return note{noteNames[i%12] + fmt.Sprint(i/12-1), cents, ref}, true
+}
+
+// envelopeNotes names the frequencies of pitch, melody and pitch.change
+// events; from is only set for pitch.change.
+func envelopeNotes(env *trackspb.Envelope) (to, from *note) {
+	ref := tuning.reference(env.GetStreamId())
+	name := func(f float64) *note {
+		if n, ok := nameNote(f, ref); ok {
+			return &n
+		}
+		return nil
+	}
+	switch e := env.Event.(type) {
+	case *trackspb.Envelope_Pitch:
+		return name(e.Pitch.GetFrequency()), nil
+	case *trackspb.Envelope_Melody:
+		return name(e.Melody.GetFrequency()), nil
+	case *trackspb.Envelope_PitchChange:
+		return name(e.PitchChange.GetToHz()), name(e.PitchChange.GetFromHz())
+	}
+	return nil, nil
+}
+
+// noteSuffix renders envelopeNotes for console lines, e.g. " note=A4-12c",
+// adding the reference when it is not 440 Hz.
+func noteSuffix(env *trackspb.Envelope) string {
+	to, from := envelopeNotes(env)
+	s := ""
+	if from != nil {
+		s += " from_note=" + from.String()
+	}
+	if to != nil {
+		s += " note=" + to.String()
+	}
+	if n := firstNote(to, from); n != nil && n.Reference != defaultA4 {
+		s += fmt.Sprintf(" a4=%.1fHz", n.Reference)
+	}
+	return s
+}
+
+func firstNote(notes ...*note) *note {
+	for _, n := range notes {
+		if n != nil {
+			return n
+		}
+	}
+	return nil
+}
+
+// appendNoteJSON adds "note" (and "from_note" for pitch.change) to the JSON
+// object b rendered from env.
+func appendNoteJSON(b []byte, env *trackspb.Envelope) []byte {
+	to, from := envelopeNotes(env)
+	if to == nil && from == nil {
+		return b
+	}
+	if len(b) == 0 || b[len(b)-1] != '}' {
+		return b
+	}
+	b = b[:len(b)-1]
+	for _, f := range []struct {
+		key string
+		n   *note
+	}{{"from_note", from}, {"note", to}} {
+		if f.n == nil {
+			continue
+		}
+		if len(b) > 1 {
+			b = append(b, ',')
+		}
+		b = append(b, fmt.Sprintf("%q:{\"name\":%q,\"cents\":%g,\"a4\":%g}", f.key, f.n.Name, f.n.Cents, f.n.Reference)...)
+	}
+	return append(b, '}')
+}
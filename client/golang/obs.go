@@ -0,0 +1,353 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"github.com/gorilla/websocket"
+)
+
+// OBS integration (-obs). Switches scenes and shows, hides or toggles
+// sources through obs-websocket (protocol v5, built into OBS 28+) when
+// configured events arrive. Requests are made from a single goroutine so
+// the receive loop never waits on OBS.
+const (
+	obsQueue          = 64
+	obsRequestTimeout = 5 * time.Second
+)
+
+// obs-websocket v5 opcodes
+const (
+	obsOpHello           = 0
+	obsOpIdentify        = 1
+	obsOpIdentified      = 2
+	obsOpRequest         = 6
+	obsOpRequestResponse = 7
+)
+
+// obsAction is a scene or source change. kind is scene, show, hide or
+// toggle; source is empty for scene.
+type obsAction struct {
+	kind   string
+	scene  string
+	source string
+}
+
+func (a obsAction) String() string {
+	if a.source == "" {
+		return a.kind + ":" + a.scene
+	}
+	return a.kind + ":" + a.scene + "/" + a.source
+}
+
+// obsRule runs action when an event of the given name or category arrives.
+type obsRule struct {
+	event  string
+	action obsAction
+	last   time.Time
+}
+
+// parseOBSRules parses -obs-on, e.g.
+// "track.start=scene:Live,silence.start=scene:BRB,downbeat=toggle:Live/Strobe".
+func parseOBSRules(spec string) ([]*obsRule, error) {
+	var rules []*obsRule
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		event, action, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid OBS rule %q (want event=action)", item)
+		}
+		if !isCategory(event) && !isEventName(event) {
+			return nil, fmt.Errorf("unknown event or category %q", event)
+		}
+		kind, target, ok := strings.Cut(action, ":")
+		if !ok || target == "" {
+			return nil, fmt.Errorf("invalid OBS action %q (want scene:<scene> or show|hide|toggle:<scene>/<source>)", action)
+		}
+		a := obsAction{kind: kind}
+		switch kind {
+		case "scene":
+			a.scene = target
+		case "show", "hide", "toggle":
+			a.scene, a.source, ok = strings.Cut(target, "/")
+			if !ok || a.scene == "" || a.source == "" {
+				return nil, fmt.Errorf("invalid OBS action %q (want %s:<scene>/<source>)", action, kind)
+			}
+		default:
+			return nil, fmt.Errorf("unknown OBS action %q (want scene, show, hide or toggle)", kind)
+		}
+		rules = append(rules, &obsRule{event: event, action: a})
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("no OBS rules given (use -obs-on)")
+	}
+	return rules, nil
+}
+
+type obsClient struct {
+	url      string
+	password string
+	rules    []*obsRule
+	cooldown time.Duration
+	actions  chan obsAction
+	dropped  atomic.Int64
+	done     chan struct{}
+
+	conn    *websocket.Conn
+	nextID  int
+	itemIDs map[string]int // "scene/source" -> sceneItemId
+}
+
+func newOBSClient(url, password string, rules []*obsRule, cooldown time.Duration) *obsClient {
+	c := &obsClient{
+		url:      url,
+		password: password,
+		rules:    rules,
+		cooldown: cooldown,
+		actions:  make(chan obsAction, obsQueue),
+		done:     make(chan struct{}),
+		itemIDs:  make(map[string]int),
+	}
+	go c.run()
+	return c
+}
+
+// handle queues the actions of every rule matching env. Rules that fired
+// less than cooldown ago are skipped, so bursts of boundaries don't make
+// the stream flicker.
+func (c *obsClient) handle(env *trackspb.Envelope) {
+	name, category := eventName(env), eventCategory(env)
+	now := time.Now()
+	for _, r := range c.rules {
+		if r.event != name && r.event != category {
+			continue
+		}
+		if !r.last.IsZero() && now.Sub(r.last) < c.cooldown {
+			continue
+		}
+		r.last = now
+		select {
+		case c.actions <- r.action:
+		default:
+			c.dropped.Add(1)
+			fmt.Fprintf(os.Stderr, "obs: queue full, dropping %s\n", r.action)
+		}
+	}
+}
+
+func (c *obsClient) status() sinkStatus {
+	return sinkStatus{Name: "obs", Policy: "drop-newest", Depth: len(c.actions), Capacity: cap(c.actions), Dropped: int(c.dropped.Load())}
+}
+
+// close runs the remaining queued actions and disconnects.
+func (c *obsClient) close() {
+	close(c.actions)
+	<-c.done
+}
+
+func (c *obsClient) run() {
+	defer close(c.done)
+	defer c.disconnect()
+	if err := c.connect(); err != nil {
+		fmt.Fprintf(os.Stderr, "obs: %v\n", err)
+	}
+	for a := range c.actions {
+		if err := c.do(a); err != nil {
+			fmt.Fprintf(os.Stderr, "obs: %s: %v\n", a, err)
+		}
+	}
+}
+
+func (c *obsClient) do(a obsAction) error {
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			return err
+		}
+	}
+	if a.kind == "scene" {
+		_, err := c.request("SetCurrentProgramScene", map[string]any{"sceneName": a.scene})
+		return err
+	}
+
+	id, err := c.sceneItemID(a.scene, a.source)
+	if err != nil {
+		return err
+	}
+	enabled := a.kind == "show"
+	if a.kind == "toggle" {
+		resp, err := c.request("GetSceneItemEnabled", map[string]any{"sceneName": a.scene, "sceneItemId": id})
+		if err != nil {
+			return err
+		}
+		var cur struct {
+			SceneItemEnabled bool `json:"sceneItemEnabled"`
+		}
+		if err := json.Unmarshal(resp, &cur); err != nil {
+			return err
+		}
+		enabled = !cur.SceneItemEnabled
+	}
+	_, err = c.request("SetSceneItemEnabled", map[string]any{
+		"sceneName":        a.scene,
+		"sceneItemId":      id,
+		"sceneItemEnabled": enabled,
+	})
+	return err
+}
+
+func (c *obsClient) sceneItemID(scene, source string) (int, error) {
+	key := scene + "/" + source
+	if id, ok := c.itemIDs[key]; ok {
+		return id, nil
+	}
+	resp, err := c.request("GetSceneItemId", map[string]any{"sceneName": scene, "sourceName": source})
+	if err != nil {
+		return 0, err
+	}
+	var item struct {
+		SceneItemID int `json:"sceneItemId"`
+	}
+	if err := json.Unmarshal(resp, &item); err != nil {
+		return 0, err
+	}
+	c.itemIDs[key] = item.SceneItemID
+	return item.SceneItemID, nil
+}
+
+type obsMessage struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d"`
+}
+
+func (c *obsClient) connect() error {
+	conn, _, err := websocket.DefaultDialer.Dial(c.url, nil)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	conn.SetReadDeadline(time.Now().Add(obsRequestTimeout))
+
+	var hello struct {
+		Authentication *struct {
+			Challenge string `json:"challenge"`
+			Salt      string `json:"salt"`
+		} `json:"authentication"`
+	}
+	if err := c.expect(obsOpHello, &hello); err != nil {
+		c.disconnect()
+		return err
+	}
+
+	// Subscribe to no events: the receiver only makes requests.
+	identify := map[string]any{"rpcVersion": 1, "eventSubscriptions": 0}
+	if auth := hello.Authentication; auth != nil {
+		if c.password == "" {
+			c.disconnect()
+			return fmt.Errorf("%s requires a password (-obs-password)", c.url)
+		}
+		secret := obsHash(c.password + auth.Salt)
+		identify["authentication"] = obsHash(secret + auth.Challenge)
+	}
+	if err := c.send(obsOpIdentify, identify); err != nil {
+		c.disconnect()
+		return err
+	}
+	if err := c.expect(obsOpIdentified, nil); err != nil {
+		c.disconnect()
+		return fmt.Errorf("identify failed (wrong password?): %w", err)
+	}
+	conn.SetReadDeadline(time.Time{})
+	c.itemIDs = make(map[string]int)
+	return nil
+}
+
+func obsHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func (c *obsClient) disconnect() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+func (c *obsClient) send(op int, d any) error {
+	c.conn.SetWriteDeadline(time.Now().Add(obsRequestTimeout))
+	return c.conn.WriteJSON(map[string]any{"op": op, "d": d})
+}
+
+func (c *obsClient) expect(op int, d any) error {
+	var m obsMessage
+	if err := c.conn.ReadJSON(&m); err != nil {
+		return err
+	}
+	if m.Op != op {
+		return fmt.Errorf("unexpected opcode %d (want %d)", m.Op, op)
+	}
+	if d == nil {
+		return nil
+	}
+	return json.Unmarshal(m.D, d)
+}
+
+// request sends a request and waits for its response, returning the
+// responseData. Connection errors drop the connection so the next action
+// reconnects.
+func (c *obsClient) request(typ string, data map[string]any) (json.RawMessage, error) {
+	c.nextID++
+	id := strconv.Itoa(c.nextID)
+	err := c.send(obsOpRequest, map[string]any{"requestType": typ, "requestId": id, "requestData": data})
+	if err != nil {
+		c.disconnect()
+		return nil, err
+	}
+
+	c.conn.SetReadDeadline(time.Now().Add(obsRequestTimeout))
+	defer func() {
+		if c.conn != nil {
+			c.conn.SetReadDeadline(time.Time{})
+		}
+	}()
+	for {
+		var m obsMessage
+		if err := c.conn.ReadJSON(&m); err != nil {
+			c.disconnect()
+			return nil, err
+		}
+		if m.Op != obsOpRequestResponse {
+			continue
+		}
+		var resp struct {
+			RequestID     string `json:"requestId"`
+			RequestStatus struct {
+				Result  bool   `json:"result"`
+				Code    int    `json:"code"`
+				Comment string `json:"comment"`
+			} `json:"requestStatus"`
+			ResponseData json.RawMessage `json:"responseData"`
+		}
+		if err := json.Unmarshal(m.D, &resp); err != nil {
+			return nil, err
+		}
+		if resp.RequestID != id {
+			continue
+		}
+		if !resp.RequestStatus.Result {
+			return nil, fmt.Errorf("%s failed (code %d): %s", typ, resp.RequestStatus.Code, resp.RequestStatus.Comment)
+		}
+		return resp.ResponseData, nil
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// eventWriter appends envelopes to one output file.
+type eventWriter interface {
+	write(env *trackspb.Envelope, received time.Time) error
+	close() error
+}
+
+func outputFormatFor(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".json", ".ndjson":
+		return "jsonl"
+	case ".csv":
+		return "csv"
+	case ".trk":
+		return "trk"
+	}
+	return ""
+}
+
+func newEventWriter(format, path string) (eventWriter, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	if format == "trk" {
+		return newTrkWriter(path)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := newEventWriterTo(format, f)
+	if err != nil {
+		f.Close()
+	}
+	return w, err
+}
+
+// newEventWriterTo writes envelopes to f, which the writer closes.
+func newEventWriterTo(format string, f *os.File) (eventWriter, error) {
+	bw := bufio.NewWriter(f)
+	switch format {
+	case "jsonl":
+		return &jsonlWriter{f: f, w: bw}, nil
+	case "csv":
+		cw := csv.NewWriter(bw)
+		cw.Write([]string{"timestamp", "stream_id", "event", "value", "fields"})
+		return &csvWriter{f: f, w: bw, csv: cw}, nil
+	case "trk":
+		return startTrk(f), nil
+	}
+	return nil, fmt.Errorf("unknown output format %q (want jsonl, csv or trk)", format)
+}
+
+type jsonlWriter struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+var jsonlOptions = protojson.MarshalOptions{UseProtoNames: true}
+
+func (j *jsonlWriter) write(env *trackspb.Envelope, _ time.Time) error {
+	b, err := jsonlOptions.Marshal(env)
+	if err != nil {
+		return err
+	}
+	// protojson randomizes whitespace; compact it for stable output
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err != nil {
+		return err
+	}
+	out := appendNoteJSON(buf.Bytes(), env)
+	_, err = j.w.Write(append(out, '\n'))
+	return err
+}
+
+func (j *jsonlWriter) close() error { return flushClose(j.w, j.f) }
+
+type csvWriter struct {
+	f   *os.File
+	w   *bufio.Writer
+	csv *csv.Writer
+}
+
+func (c *csvWriter) write(env *trackspb.Envelope, _ time.Time) error {
+	value := ""
+	if v, ok := eventValue(env); ok {
+		value = strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return c.csv.Write([]string{
+		strconv.FormatFloat(env.GetTimestamp(), 'f', 3, 64),
+		env.GetStreamId(),
+		eventName(env),
+		value,
+		eventFields(env),
+	})
+}
+
+func (c *csvWriter) close() error {
+	c.csv.Flush()
+	return flushClose(c.w, c.f)
+}
+
+func flushClose(w *bufio.Writer, f *os.File) error {
+	err := w.Flush()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// eventFields renders the event payload as space-separated name=value pairs,
+// with repeated values joined by ';' and note names added for pitch events.
+func eventFields(env *trackspb.Envelope) string {
+	fd := env.ProtoReflect().WhichOneof(envelopeEventOneof)
+	if fd == nil {
+		return ""
+	}
+	msg := env.ProtoReflect().Get(fd).Message()
+	fields := msg.Descriptor().Fields()
+	var parts []string
+	for i := 0; i < fields.Len(); i++ {
+		f := fields.Get(i)
+		if !msg.Has(f) {
+			continue
+		}
+		v := msg.Get(f)
+		if f.IsList() {
+			l := v.List()
+			vals := make([]string, l.Len())
+			for i := range vals {
+				vals[i] = fmt.Sprint(l.Get(i).Interface())
+			}
+			parts = append(parts, string(f.Name())+"="+strings.Join(vals, ";"))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s=%v", f.Name(), v.Interface()))
+		}
+	}
+	to, from := envelopeNotes(env)
+	if from != nil {
+		parts = append(parts, "from_note="+from.String())
+	}
+	if to != nil {
+		parts = append(parts, "note="+to.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// trackOutput writes each track to its own file, named by expanding a
+// template when the track starts:
+//
+//	{date}            local date the track started, 2006-01-02
+//	{start_time}      local time the track started, 150405
+//	{track_filename}  TrackStart filename without directory or extension
+//	{artist}          artist from -track-metadata or -track-tags, or empty
+//	{title}           title from -track-metadata or -track-tags, or
+//	                  {track_filename}
+//	{album}           album from -track-metadata or -track-tags, or empty
+//	{duration}        TrackStart duration in whole seconds
+//	{sample_rate}     TrackStart sample rate
+//	{channels}        TrackStart channel count
+//	{stream}          stream id, or "default"
+//	{index}           1-based track number of its stream in this run
+//
+// Each stream has its own file, so decks or rooms merged into one receiver
+// don't cut each other's tracks short. Envelopes that arrive while a stream
+// has no file open (such as track.prepare) are held back, up to
+// trackPendingMax of them with their own receive times, and written once
+// its next track.start opens one; those from before the stream's first
+// track are of no track and go nowhere. A file closes on its stream's
+// track.end or track.abort, or when the stream's next track starts.
+type trackOutput struct {
+	template string
+	format   string
+
+	files   map[string]*trackFile // by stream id
+	sampler *outSampler           // -out-sample, or nil
+}
+
+// trackPendingMax is how many envelopes a stream holds back between
+// tracks; older ones are dropped.
+const trackPendingMax = 256
+
+// trackFile is the file of one stream's current track.
+type trackFile struct {
+	w       eventWriter // nil between tracks
+	path    string
+	index   int
+	pending []queuedEvent
+}
+
+func newTrackOutput(template, format string, sampler *outSampler) (*trackOutput, error) {
+	if format == "" {
+		format = outputFormatFor(template)
+	}
+	switch format {
+	case "jsonl", "csv", "trk":
+	case "":
+		return nil, fmt.Errorf("cannot infer output format from %q; use -out-format", template)
+	default:
+		return nil, fmt.Errorf("unknown output format %q (want jsonl, csv or trk)", format)
+	}
+	return &trackOutput{template: template, format: format, files: map[string]*trackFile{}, sampler: sampler}, nil
+}
+
+func (o *trackOutput) handle(env *trackspb.Envelope, received time.Time) error {
+	if o.sampler == nil {
+		return o.store(env, received)
+	}
+	for _, e := range o.sampler.push(env) {
+		if err := o.store(e, received); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *trackOutput) store(env *trackspb.Envelope, received time.Time) error {
+	stream := env.GetStreamId()
+	f := o.files[stream]
+	if start := env.GetTrackStart(); start != nil {
+		if f == nil {
+			f = &trackFile{}
+			o.files[stream] = f
+		}
+		if err := f.close(); err != nil {
+			return err
+		}
+		f.index++
+		f.path = expandTemplate(o.template, start, stream, received, f.index)
+		pending := f.pending
+		f.pending = nil
+		w, err := newEventWriter(o.format, f.path)
+		if err != nil {
+			return err
+		}
+		f.w = w
+		for _, p := range pending {
+			if err := f.w.write(p.env, p.received); err != nil {
+				return err
+			}
+		}
+	}
+	if f == nil {
+		return nil
+	}
+
+	if f.w == nil {
+		if len(f.pending) == trackPendingMax {
+			f.pending = append(f.pending[:0], f.pending[1:]...)
+		}
+		f.pending = append(f.pending, queuedEvent{env: env, received: received})
+		return nil
+	}
+	if err := f.w.write(env, received); err != nil {
+		return err
+	}
+
+	switch env.Event.(type) {
+	case *trackspb.Envelope_TrackEnd, *trackspb.Envelope_TrackAbort:
+		return f.close()
+	}
+	return nil
+}
+
+// close closes the file of every stream.
+func (o *trackOutput) close() error {
+	var errs []error
+	for _, f := range o.files {
+		errs = append(errs, f.close())
+	}
+	return errors.Join(errs...)
+}
+
+func (f *trackFile) close() error {
+	if f.w == nil {
+		return nil
+	}
+	err := f.w.close()
+	f.w = nil
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.path, err)
+	}
+	return nil
+}
+
+func expandTemplate(tmpl string, start *trackspb.TrackStart, stream string, started time.Time, index int) string {
+	name := trackTitle(start)
+	meta := lookupTrackMeta(start.GetFilename())
+	if stream == "" {
+		stream = "default"
+	}
+	r := strings.NewReplacer(
+		"{date}", started.Format("2006-01-02"),
+		"{start_time}", started.Format("150405"),
+		"{track_filename}", sanitizePathPart(name),
+		"{artist}", sanitizePathPart(meta.Artist),
+		"{title}", sanitizePathPart(meta.displayTitle(start)),
+		"{album}", sanitizePathPart(meta.Album),
+		"{duration}", strconv.Itoa(int(start.GetDuration())),
+		"{sample_rate}", strconv.Itoa(int(start.GetSampleRate())),
+		"{channels}", strconv.Itoa(int(start.GetChannels())),
+		"{stream}", sanitizePathPart(stream),
+		"{index}", strconv.Itoa(index),
+	)
+	return r.Replace(tmpl)
+}
+
+// trackTitle is the TrackStart filename without directory or extension.
+func trackTitle(start *trackspb.TrackStart) string {
+	name := filepath.Base(start.GetFilename())
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// sanitizePathPart keeps template values from introducing directories.
+func sanitizePathPart(s string) string {
+	s = strings.NewReplacer("/", "_", "\\", "_").Replace(s)
+	if s == "" || s == "." || s == ".." {
+		return "_"
+	}
+	return s
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/tracks"
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// csvEvents returns the event column of a -out CSV file.
+func csvEvents(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	for _, row := range rows[1:] {
+		events = append(events, row[2])
+	}
+	return events
+}
+
+func TestTrackOutputStreams(t *testing.T) {
+	dir := t.TempDir()
+	o, err := newTrackOutput(filepath.Join(dir, "{stream}-{index}.csv"), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := tracks.Epoch
+	send := func(stream string, ev any) {
+		t.Helper()
+		env := &trackspb.Envelope{StreamId: stream}
+		switch e := ev.(type) {
+		case *trackspb.TrackPrepare:
+			env.Event = &trackspb.Envelope_TrackPrepare{TrackPrepare: e}
+		case *trackspb.TrackStart:
+			env.Event = &trackspb.Envelope_TrackStart{TrackStart: e}
+		case *trackspb.TrackEnd:
+			env.Event = &trackspb.Envelope_TrackEnd{TrackEnd: e}
+		case *trackspb.Beat:
+			env.Event = &trackspb.Envelope_Beat{Beat: e}
+		}
+		at = at.Add(time.Second)
+		if err := o.handle(env, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	send("a", &trackspb.Beat{}) // before any track of a: not written
+	send("a", &trackspb.TrackStart{Filename: "one.wav"})
+	send("a", &trackspb.Beat{})
+	send("b", &trackspb.TrackStart{Filename: "two.wav"})
+	send("a", &trackspb.Beat{}) // still a's first track
+	send("b", &trackspb.Beat{})
+	send("a", &trackspb.TrackEnd{})
+	send("a", &trackspb.TrackPrepare{Filename: "three.wav"})
+	send("a", &trackspb.TrackStart{Filename: "three.wav"})
+	send("b", &trackspb.TrackEnd{})
+	if err := o.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string][]string{
+		"a-1.csv": {"track.start", "beat", "beat", "track.end"},
+		"b-1.csv": {"track.start", "beat", "track.end"},
+		"a-2.csv": {"track.prepare", "track.start"},
+	} {
+		if got := csvEvents(t, filepath.Join(dir, name)); !slices.Equal(got, want) {
+			t.Errorf("%s has %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestTrackOutputPendingCap(t *testing.T) {
+	dir := t.TempDir()
+	o, err := newTrackOutput(filepath.Join(dir, "{index}.csv"), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.handle(&trackspb.Envelope{Event: &trackspb.Envelope_TrackStart{TrackStart: &trackspb.TrackStart{}}}, tracks.Epoch)
+	o.handle(&trackspb.Envelope{Event: &trackspb.Envelope_TrackEnd{TrackEnd: &trackspb.TrackEnd{}}}, tracks.Epoch)
+	for range 2 * trackPendingMax {
+		o.handle(&trackspb.Envelope{Event: &trackspb.Envelope_Beat{Beat: &trackspb.Beat{}}}, tracks.Epoch)
+	}
+	if n := len(o.files[""].pending); n != trackPendingMax {
+		t.Errorf("%d envelopes held back between tracks, want at most %d", n, trackPendingMax)
+	}
+	o.handle(&trackspb.Envelope{Event: &trackspb.Envelope_TrackStart{TrackStart: &trackspb.TrackStart{}}}, tracks.Epoch)
+	o.close()
+	if got := csvEvents(t, filepath.Join(dir, "2.csv")); len(got) != trackPendingMax+1 {
+		t.Errorf("second track's file has %d events, want %d", len(got), trackPendingMax+1)
+	}
+}
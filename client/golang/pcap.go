@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Packet capture (-pcap FILE): every datagram as it arrives, before FEC,
+// fragment reassembly or decoding, in the classic pcap format that
+// Wireshark and tcpdump read. The receiver only sees UDP payloads, so each
+// is written with a synthesized IPv4 and UDP header (link type RAW) from
+// its sender's address to the group and the port it arrived on, which is enough for
+// Wireshark to apply a dissector by port. Packets from the zmq and shm
+// transports have no addresses; they are written from 0.0.0.0.
+const (
+	pcapMagic    = 0xa1b2c3d4 // microsecond timestamps
+	pcapSnapLen  = 65535
+	pcapLinkRaw  = 101 // LINKTYPE_RAW: packets begin with an IP header
+	pcapIPHeader = 20
+	pcapUDP      = 8
+)
+
+type pcapWriter struct {
+	mu      sync.Mutex
+	f       *os.File
+	w       *bufio.Writer
+	dst     *net.UDPAddr
+	packets int
+	err     error // first write error; capture stops after it
+	closed  bool
+}
+
+// newPCAPWriter creates path and writes the file header. group and port
+// are what the packets are addressed to.
+func newPCAPWriter(path, group string, port int) (*pcapWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	dst := &net.UDPAddr{IP: net.ParseIP(group), Port: port}
+	p := &pcapWriter{f: f, w: bufio.NewWriterSize(f, 64<<10), dst: dst}
+	var h [24]byte
+	binary.LittleEndian.PutUint32(h[0:], pcapMagic)
+	binary.LittleEndian.PutUint16(h[4:], 2)
+	binary.LittleEndian.PutUint16(h[6:], 4)
+	binary.LittleEndian.PutUint32(h[16:], pcapSnapLen)
+	binary.LittleEndian.PutUint32(h[20:], pcapLinkRaw)
+	if _, err := p.w.Write(h[:]); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// write records pkt, received at t from src ("ip:port", or a transport's
+// label).
+func (p *pcapWriter) write(pkt []byte, src string, t time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil || p.closed {
+		return
+	}
+	dstPort := p.dst.Port
+	if sender, port, ok := sourcePort(src); ok {
+		src = sender
+		if n, err := strconv.Atoi(port); err == nil {
+			dstPort = n
+		}
+	}
+	srcIP, srcPort := net.IPv4zero.To4(), 0
+	if addr, err := netip.ParseAddrPort(src); err == nil && addr.Addr().Unmap().Is4() {
+		ip4 := addr.Addr().Unmap().As4()
+		srcIP, srcPort = ip4[:], int(addr.Port())
+	}
+	dstIP := net.IPv4zero.To4()
+	if ip := p.dst.IP.To4(); ip != nil {
+		dstIP = ip
+	}
+
+	caplen := min(len(pkt), pcapSnapLen-pcapIPHeader-pcapUDP)
+	total := pcapIPHeader + pcapUDP + len(pkt)
+	var rec [16 + pcapIPHeader + pcapUDP]byte
+	binary.LittleEndian.PutUint32(rec[0:], uint32(t.Unix()))
+	binary.LittleEndian.PutUint32(rec[4:], uint32(t.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(rec[8:], uint32(pcapIPHeader+pcapUDP+caplen))
+	binary.LittleEndian.PutUint32(rec[12:], uint32(total))
+
+	ip := rec[16 : 16+pcapIPHeader]
+	ip[0] = 0x45 // version 4, 5-word header
+	binary.BigEndian.PutUint16(ip[2:], uint16(min(total, 0xffff)))
+	ip[8] = 1  // TTL
+	ip[9] = 17 // UDP
+	copy(ip[12:16], srcIP)
+	copy(ip[16:20], dstIP)
+	binary.BigEndian.PutUint16(ip[10:], ipChecksum(ip))
+
+	udp := rec[16+pcapIPHeader:]
+	binary.BigEndian.PutUint16(udp[0:], uint16(srcPort))
+	binary.BigEndian.PutUint16(udp[2:], uint16(dstPort))
+	binary.BigEndian.PutUint16(udp[4:], uint16(min(pcapUDP+len(pkt), 0xffff)))
+	// A zero UDP checksum means none was computed, which IPv4 allows.
+
+	if _, err := p.w.Write(rec[:]); err != nil {
+		p.err = err
+		return
+	}
+	if _, err := p.w.Write(pkt[:caplen]); err != nil {
+		p.err = err
+		return
+	}
+	p.packets++
+}
+
+func ipChecksum(h []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(h); i += 2 {
+		sum += uint32(h[i])<<8 | uint32(h[i+1])
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+// close flushes the capture and reports how many packets it holds. It is
+// safe to call more than once, and from the signal handler while packets
+// are still arriving.
+func (p *pcapWriter) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	err := p.err
+	if ferr := p.w.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := p.f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("pcap: %w", err)
+	}
+	fmt.Printf("Captured %d packet(s) to %s.\n", p.packets, p.f.Name())
+	return nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Curve plots (-plot): at the end of each track, the chosen scalar events
+// (-plot-curves) are drawn as stacked line charts over the track's
+// duration, one panel each, to a PNG or SVG file named by the -plot
+// template, for attaching to tickets or putting in documents. The panels
+// are the ones of the HTML report (-report); events that change at moments
+// rather than per frame, like tempo.change, are drawn as steps. Events the
+// track has none of are left out.
+const (
+	defaultPlotCurves = "loudness,tempo.change,novelty,energy"
+	plotTitleHeight   = 22
+)
+
+// parsePlotCurves checks a comma-separated list of event names.
+func parsePlotCurves(spec string) ([]string, error) {
+	var curves []string
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !isEventName(name) {
+			return nil, fmt.Errorf("unknown event %q", name)
+		}
+		curves = append(curves, name)
+	}
+	if len(curves) == 0 {
+		return nil, fmt.Errorf("no events given")
+	}
+	return curves, nil
+}
+
+type plotPanel struct {
+	title string
+	pts   []point
+	step  bool
+}
+
+func plotPanels(d *trackData, curves []string) []plotPanel {
+	var panels []plotPanel
+	for _, name := range curves {
+		if pts := d.series[name]; len(pts) > 0 {
+			panels = append(panels, plotPanel{title: name, pts: pts, step: !continuousEvents[name]})
+		}
+	}
+	return panels
+}
+
+// writePlot writes the track's curves as SVG for .svg paths, PNG otherwise.
+func writePlot(path string, d *trackData, curves []string) error {
+	panels := plotPanels(d, curves)
+	if len(panels) == 0 {
+		return fmt.Errorf("no %s events in track; enable them on the sender", strings.Join(curves, ", "))
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	title := d.meta.heading(d.start)
+	if strings.EqualFold(filepath.Ext(path), ".svg") {
+		_, err = w.WriteString(plotSVG(title, panels, d.duration()))
+	} else {
+		err = png.Encode(w, plotImage(title, panels, d.duration()))
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return flushClose(w, f)
+}
+
+func plotSVG(title string, panels []plotPanel, dur float64) string {
+	panelHeight := plotTitleHeight + plotHeight
+	height := plotTitleHeight + len(panels)*panelHeight
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`+"\n",
+		plotWidth, height, plotWidth, height)
+	b.WriteString(`<style>text { font: 11px sans-serif; fill: #555; } .title { font-size: 13px; fill: #222; }</style>` + "\n")
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/>`+"\n", plotWidth, height)
+	fmt.Fprintf(&b, `<text class="title" x="%d" y="16">%s</text>`+"\n", plotMargin, template.HTMLEscapeString(title))
+	for i, p := range panels {
+		y := plotTitleHeight + i*panelHeight
+		fmt.Fprintf(&b, `<g transform="translate(0,%d)"><text class="title" x="%d" y="16">%s</text>`, y, plotMargin, template.HTMLEscapeString(p.title))
+		fmt.Fprintf(&b, `<g transform="translate(0,%d)">%s</g></g>`+"\n", plotTitleHeight, svgLineChart(p.pts, dur, p.step))
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+var (
+	plotText       = color.RGBA{0x55, 0x55, 0x55, 255}
+	plotTitleColor = color.RGBA{0x22, 0x22, 0x22, 255}
+	plotBackground = color.RGBA{0xf7, 0xf7, 0xf7, 255}
+)
+
+// plotImage draws the same layout as plotSVG.
+func plotImage(title string, panels []plotPanel, dur float64) image.Image {
+	panelHeight := plotTitleHeight + plotHeight
+	img := image.NewRGBA(image.Rect(0, 0, plotWidth, plotTitleHeight+len(panels)*panelHeight))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	drawPlotText(img, title, plotMargin, 16, plotTitleColor, false)
+	line := hexColor(reportPalette[0])
+	for i, p := range panels {
+		top := plotTitleHeight + i*panelHeight
+		drawPlotText(img, p.title, plotMargin, top+16, plotTitleColor, false)
+		top += plotTitleHeight
+
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, pt := range p.pts {
+			lo, hi = math.Min(lo, pt.v), math.Max(hi, pt.v)
+		}
+		if hi-lo < 1e-9 {
+			lo, hi = lo-1, hi+1
+		}
+		end := dur
+		if end <= 0 {
+			end = p.pts[len(p.pts)-1].t
+		}
+		if end <= 0 {
+			end = 1
+		}
+		w, h := float64(plotWidth-2*plotMargin), float64(plotHeight-plotMargin)
+		x := func(t float64) float64 { return plotMargin + w*math.Max(0, math.Min(1, t/end)) }
+		y := func(v float64) float64 { return float64(top+plotMargin/2) + h*(1-(v-lo)/(hi-lo)) }
+
+		draw.Draw(img, image.Rect(plotMargin, top+plotMargin/2, plotMargin+int(w), top+plotMargin/2+int(h)),
+			image.NewUniform(plotBackground), image.Point{}, draw.Src)
+		drawPlotText(img, strconv.FormatFloat(hi, 'g', 3, 64), 2, int(y(hi))+4, plotText, false)
+		drawPlotText(img, strconv.FormatFloat(lo, 'g', 3, 64), 2, int(y(lo)), plotText, false)
+		for j := 0; j <= 4; j++ {
+			drawPlotText(img, formatClock(end*float64(j)/4), plotMargin+int(w)*j/4, top+plotHeight-4, plotText, true)
+		}
+
+		px, py := x(p.pts[0].t), y(p.pts[0].v)
+		for _, pt := range p.pts[1:] {
+			nx, ny := x(pt.t), y(pt.v)
+			if p.step {
+				drawPlotLine(img, px, py, nx, py, line)
+				px = nx
+			}
+			drawPlotLine(img, px, py, nx, ny, line)
+			px, py = nx, ny
+		}
+		if p.step {
+			drawPlotLine(img, px, py, x(end), py, line)
+		}
+	}
+	return img
+}
+
+// drawPlotLine draws a line two pixels thick.
+func drawPlotLine(img *image.RGBA, x0, y0, x1, y1 float64, c color.RGBA) {
+	n := int(math.Max(math.Abs(x1-x0), math.Abs(y1-y0))) + 1
+	for i := 0; i <= n; i++ {
+		f := float64(i) / float64(n)
+		x, y := int(x0+(x1-x0)*f), int(y0+(y1-y0)*f)
+		img.SetRGBA(x, y, c)
+		img.SetRGBA(x, y+1, c)
+	}
+}
+
+// drawPlotText writes s with its baseline at y, starting at x or, if
+// centered, centered on it.
+func drawPlotText(img *image.RGBA, s string, x, y int, c color.RGBA, centered bool) {
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(c), Face: basicfont.Face7x13}
+	if centered {
+		x -= d.MeasureString(s).Round() / 2
+	}
+	d.Dot = fixed.P(x, y)
+	d.DrawString(s)
+}
+
+// hexColor parses "#rrggbb".
+func hexColor(s string) color.RGBA {
+	v, _ := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"google.golang.org/protobuf/proto"
+)
+
+// WASM plugins (-plugin FILE.wasm): event processors compiled to
+// WebAssembly from any language that targets it, run in a sandbox (wazero)
+// with no access to files or the network. A plugin sees every envelope in
+// its serialized protobuf form and, like a -script, can drop it, replace
+// it or add envelopes. The ABI:
+//
+//	export memory
+//	export alloc(size i32) -> i32          buffer for an envelope of size bytes
+//	export on_event(ptr i32, len i32) -> i32
+//	                                       0 keeps the envelope, 1 drops it
+//	import tracks.emit(ptr i32, len i32)   adds a serialized envelope
+//
+// The receiver writes each envelope to a buffer from alloc and calls
+// on_event; the buffer is the plugin's from then on. Envelopes passed to
+// emit during on_event are handled after the one being processed (to
+// replace it, emit the new one and return 1); an empty stream id takes
+// that envelope's. WASI preview 1 is provided for standard output, clocks
+// and randomness, and _initialize is run for reactor modules.
+const (
+	pluginMemoryPages = 1024 // 64 MiB
+	pluginCallTimeout = time.Second
+)
+
+type wasmPlugin struct {
+	name     string
+	rt       wazero.Runtime
+	mod      api.Module
+	alloc    api.Function
+	onEvent  api.Function
+	emitted  []*trackspb.Envelope
+	cur      *trackspb.Envelope
+	disabled bool
+}
+
+func newWASMPlugin(path string) (*wasmPlugin, error) {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	p := &wasmPlugin{name: filepath.Base(path)}
+	p.rt = wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(pluginMemoryPages).
+		WithCloseOnContextDone(true))
+	fail := func(err error) (*wasmPlugin, error) {
+		p.rt.Close(ctx)
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	wasi_snapshot_preview1.MustInstantiate(ctx, p.rt)
+	if _, err := p.rt.NewHostModuleBuilder("tracks").
+		NewFunctionBuilder().WithFunc(p.emit).Export("emit").
+		Instantiate(ctx); err != nil {
+		return fail(err)
+	}
+	compiled, err := p.rt.CompileModule(ctx, code)
+	if err != nil {
+		return fail(err)
+	}
+	p.mod, err = p.rt.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().
+		WithName(p.name).
+		WithStdout(os.Stdout).
+		WithStderr(os.Stderr).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithStartFunctions("_initialize"))
+	if err != nil {
+		return fail(err)
+	}
+	p.alloc = p.mod.ExportedFunction("alloc")
+	p.onEvent = p.mod.ExportedFunction("on_event")
+	if p.alloc == nil || p.onEvent == nil || p.mod.Memory() == nil {
+		return fail(errors.New("does not export memory, alloc and on_event"))
+	}
+	return p, nil
+}
+
+func (p *wasmPlugin) process(env *trackspb.Envelope) []*trackspb.Envelope {
+	keep := []*trackspb.Envelope{env}
+	if p.disabled {
+		return keep
+	}
+	p.cur, p.emitted = env, nil
+	drop, err := p.call(env)
+	if err != nil {
+		// A trap or timeout leaves the plugin's state unknown.
+		fmt.Fprintf(os.Stderr, "plugin %s: %s: %v; plugin disabled\n", p.name, eventName(env), err)
+		p.disabled = true
+		return keep
+	}
+	if drop && eventCategory(env) != "transport" {
+		keep = nil
+	}
+	return append(keep, p.emitted...)
+}
+
+func (p *wasmPlugin) call(env *trackspb.Envelope) (drop bool, err error) {
+	b, err := proto.Marshal(env)
+	if err != nil {
+		return false, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pluginCallTimeout)
+	defer cancel()
+	res, err := p.alloc.Call(ctx, uint64(len(b)))
+	if err != nil {
+		return false, fmt.Errorf("alloc: %w", err)
+	}
+	ptr := uint32(res[0])
+	if !p.mod.Memory().Write(ptr, b) {
+		return false, fmt.Errorf("alloc returned %#x, outside memory", ptr)
+	}
+	res, err = p.onEvent.Call(ctx, uint64(ptr), uint64(len(b)))
+	if err != nil {
+		return false, fmt.Errorf("on_event: %w", err)
+	}
+	return uint32(res[0]) == 1, nil
+}
+
+// emit is the plugin's tracks.emit import.
+func (p *wasmPlugin) emit(ctx context.Context, m api.Module, ptr, size uint32) {
+	b, ok := m.Memory().Read(ptr, size)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "plugin %s: emit: buffer outside memory\n", p.name)
+		return
+	}
+	env := &trackspb.Envelope{}
+	if err := proto.Unmarshal(b, env); err != nil || env.Event == nil {
+		fmt.Fprintf(os.Stderr, "plugin %s: emit: not an envelope with an event\n", p.name)
+		return
+	}
+	if env.GetStreamId() == "" && p.cur != nil {
+		env.StreamId = p.cur.GetStreamId()
+	}
+	p.emitted = append(p.emitted, env)
+}
+
+func (p *wasmPlugin) close() {
+	p.rt.Close(context.Background())
+}
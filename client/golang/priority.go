@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// priority is the delivery class of an event. When the receive queue is
+// full, lower classes are dropped first; high-priority events are never
+// dropped.
+type priority int
+
+const (
+	priorityLow priority = iota
+	priorityNormal
+	priorityHigh
+	numPriorities
+)
+
+var priorityNames = [numPriorities]string{"low", "normal", "high"}
+
+func (p priority) String() string { return priorityNames[p] }
+
+func parsePriority(s string) (priority, error) {
+	for p, name := range priorityNames {
+		if s == name {
+			return priority(p), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown priority %q (want low, normal or high)", s)
+}
+
+// priorityMap assigns a class to each event. Defaults: transport and rhythm
+// events are high, continuous frame features are low, everything else is
+// normal. Overrides match an event name or a whole category; event names win.
+type priorityMap struct {
+	byName     map[string]priority
+	byCategory map[string]priority
+}
+
+func defaultPriorityMap() *priorityMap {
+	return &priorityMap{
+		byName: make(map[string]priority),
+		byCategory: map[string]priority{
+			"transport": priorityHigh,
+			"rhythm":    priorityHigh,
+		},
+	}
+}
+
+// parseOverrides applies a comma-separated list of name=class pairs,
+// e.g. "chord.change=high,spectral=low".
+func (m *priorityMap) parseOverrides(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, item := range strings.Split(spec, ",") {
+		name, class, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			return fmt.Errorf("invalid priority override %q (want name=class)", item)
+		}
+		p, err := parsePriority(class)
+		if err != nil {
+			return err
+		}
+		if isCategory(name) {
+			m.byCategory[name] = p
+		} else if isEventName(name) {
+			m.byName[name] = p
+		} else {
+			return fmt.Errorf("unknown event or category %q", name)
+		}
+	}
+	return nil
+}
+
+func (m *priorityMap) classify(env *trackspb.Envelope) priority {
+	name := eventName(env)
+	if p, ok := m.byName[name]; ok {
+		return p
+	}
+	if p, ok := m.byCategory[eventCategory(env)]; ok {
+		return p
+	}
+	if continuousEvents[name] {
+		return priorityLow
+	}
+	return priorityNormal
+}
+
+func isCategory(s string) bool {
+	for _, c := range categoryNames {
+		if c != "" && c == s {
+			return true
+		}
+	}
+	return false
+}
+
+func isEventName(s string) bool {
+	for _, n := range eventNames {
+		if n == s {
+			return true
+		}
+	}
+	return false
+}
+
+type queuedEvent struct {
+	env      *trackspb.Envelope
+	prio     priority
+	received time.Time
+}
+
+// queuePolicy is what a full eventQueue does with another event.
+type queuePolicy int
+
+const (
+	policyPriority   queuePolicy = iota // evict by priority class, as below
+	policyBlock                         // wait for room
+	policyDropOldest                    // evict the oldest event
+	policyDropNewest                    // drop the incoming event
+)
+
+var queuePolicyNames = []string{"priority", "block", "drop-oldest", "drop-newest"}
+
+func (p queuePolicy) String() string { return queuePolicyNames[p] }
+
+func parseQueuePolicy(s string) (queuePolicy, error) {
+	for p, name := range queuePolicyNames {
+		if s == name {
+			return queuePolicy(p), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown policy %q (want %s)", s, strings.Join(queuePolicyNames, ", "))
+}
+
+// eventQueue decouples the socket reader from event handling so a slow
+// handler never stalls reception. When it holds limit events, pushing evicts
+// the oldest event of the lowest class present, as long as that class is not
+// above the incoming event's; otherwise the incoming event is dropped.
+// High-priority events are never dropped: the queue grows past its limit
+// instead. Sink queues (see sinks.go) may use another policy.
+type eventQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	items   []queuedEvent
+	limit   int
+	policy  queuePolicy
+	closed  bool
+	dropped [numPriorities]int
+}
+
+func newEventQueue(limit int) *eventQueue {
+	q := &eventQueue{limit: limit}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *eventQueue) push(env *trackspb.Envelope, prio priority) {
+	q.pushAt(env, prio, time.Time{})
+}
+
+// pushAt queues env with the time it was received.
+func (q *eventQueue) pushAt(env *trackspb.Envelope, prio priority, received time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) >= q.limit {
+		switch q.policy {
+		case policyPriority:
+			victim := -1
+			for i, it := range q.items {
+				if it.prio == priorityHigh {
+					continue
+				}
+				if victim < 0 || it.prio < q.items[victim].prio {
+					victim = i
+				}
+			}
+			switch {
+			case victim >= 0 && q.items[victim].prio <= prio:
+				q.dropped[q.items[victim].prio]++
+				q.items = append(q.items[:victim], q.items[victim+1:]...)
+			case prio != priorityHigh:
+				q.dropped[prio]++
+				return
+			}
+		case policyBlock:
+			for len(q.items) >= q.limit && !q.closed {
+				q.cond.Wait()
+			}
+		case policyDropOldest:
+			q.dropped[q.items[0].prio]++
+			q.items[0] = queuedEvent{}
+			q.items = q.items[1:]
+		case policyDropNewest:
+			q.dropped[prio]++
+			return
+		}
+	}
+	q.items = append(q.items, queuedEvent{env, prio, received})
+	q.cond.Signal()
+}
+
+// pop blocks until an event is available. It returns nil once the queue is
+// closed and drained.
+func (q *eventQueue) pop() *trackspb.Envelope {
+	env, _ := q.popAt()
+	return env
+}
+
+// popAt is pop, also returning the time given to pushAt.
+func (q *eventQueue) popAt() (*trackspb.Envelope, time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.items) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.items) == 0 {
+		return nil, time.Time{}
+	}
+	it := q.items[0]
+	q.items[0] = queuedEvent{}
+	q.items = q.items[1:]
+	if q.policy == policyBlock {
+		q.cond.Signal() // a blocked push waits on the same condition
+	}
+	return it.env, it.received
+}
+
+func (q *eventQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+func (q *eventQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+func (q *eventQueue) droppedCounts() [numPriorities]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
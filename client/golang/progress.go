@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/tracks"
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"golang.org/x/term"
+)
+
+// Progress bar (-progress). While a track plays, the last line of the
+// terminal shows its position, a bar, the remaining time and the file name,
+// redrawn ten times a second from the interpolated position. Event lines
+// scroll above it, and track.position lines are left out since the bar
+// shows the same thing.
+const progressInterval = 100 * time.Millisecond
+
+type progressBar struct {
+	mu     sync.Mutex
+	out    *os.File
+	pos    *tracks.PositionEstimator
+	name   string
+	active bool // a track is playing
+	drawn  bool // the bar is on screen
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// progressEnabled resolves the -progress mode: on, off, or auto for a
+// terminal on stdout.
+func progressEnabled(mode string) (bool, error) {
+	switch mode {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	case "auto":
+		return term.IsTerminal(int(os.Stdout.Fd())), nil
+	}
+	return false, fmt.Errorf("unknown mode %q (want auto, on or off)", mode)
+}
+
+func newProgressBar(out *os.File) *progressBar {
+	b := &progressBar{
+		out:  out,
+		pos:  tracks.NewPositionEstimator(),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+func (b *progressBar) run() {
+	defer close(b.done)
+	tick := time.NewTicker(progressInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-tick.C:
+			b.mu.Lock()
+			b.draw()
+			b.mu.Unlock()
+		}
+	}
+}
+
+// observe follows the track's position. The bar is removed when a track
+// ends, so end-of-track output starts on a clean line.
+func (b *progressBar) observe(env *trackspb.Envelope, received time.Time) {
+	b.pos.Observe(env, received)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch e := env.Event.(type) {
+	case *trackspb.Envelope_TrackStart:
+		b.name = filepath.Base(e.TrackStart.GetFilename())
+		b.active = true
+	case *trackspb.Envelope_TrackEnd, *trackspb.Envelope_TrackAbort:
+		b.clear()
+		b.active = false
+	}
+}
+
+// println prints a line above the bar.
+func (b *progressBar) println(line string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clear()
+	fmt.Fprintln(b.out, line)
+	b.draw()
+}
+
+func (b *progressBar) close() {
+	close(b.stop)
+	<-b.done
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clear()
+	b.active = false
+}
+
+func (b *progressBar) clear() {
+	if b.drawn {
+		fmt.Fprint(b.out, "\r\033[K")
+		b.drawn = false
+	}
+}
+
+func (b *progressBar) draw() {
+	if !b.active {
+		return
+	}
+	width := 80
+	if w, _, err := term.GetSize(int(b.out.Fd())); err == nil && w > 0 {
+		width = w
+	}
+	fmt.Fprint(b.out, "\r\033[K"+renderProgress(b.pos.Position(time.Now()), b.pos.Duration(), b.name, width-1))
+	b.drawn = true
+}
+
+// renderProgress lays out the status line in at most width columns:
+// " 1:23 [=======>      ] 3:45  -2:22  song.mp3". Without a known
+// duration only the elapsed time and name are shown.
+func renderProgress(pos, dur float64, name string, width int) string {
+	if dur <= 0 {
+		return truncate(fmt.Sprintf(" %s  %s", shortClock(pos), name), width)
+	}
+	left := fmt.Sprintf(" %s [", shortClock(pos))
+	right := fmt.Sprintf("] %s  -%s", shortClock(dur), shortClock(math.Ceil(max(dur-pos, 0))))
+	tail := "  " + name
+	barWidth := width - len(left) - len(right) - len(tail)
+	if barWidth < 10 {
+		tail = ""
+		barWidth = width - len(left) - len(right)
+	}
+	if barWidth < 5 {
+		return truncate(fmt.Sprintf(" %s / %s", shortClock(pos), shortClock(dur)), width)
+	}
+	filled := int(float64(barWidth) * min(pos/dur, 1))
+	bar := strings.Repeat("=", filled)
+	if filled < barWidth {
+		bar += ">" + strings.Repeat(" ", barWidth-filled-1)
+	}
+	return truncate(left+bar+right+tail, width)
+}
+
+// shortClock renders seconds as m:ss, or h:mm:ss from an hour.
+func shortClock(sec float64) string {
+	s := int(sec)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if width <= 0 {
+		return ""
+	}
+	if len(r) > width {
+		return string(r[:width])
+	}
+	return s
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/tracks"
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"google.golang.org/protobuf/proto"
+)
+
+// The publish subcommand (tracks-recv-go publish) is a mock sender: it
+// plays a scenario (a YAML script of events at times, see
+// tracks/scenario.go) to a multicast group as the C++ sender would, or
+// writes it to a recording, to reproduce sequences like a track aborted
+// halfway or overlapping track starts against a running receiver.
+
+func runPublish(args []string) {
+	fs := flag.NewFlagSet("publish", flag.ExitOnError)
+	group := fs.String("multicast-group", "239.255.0.1", "Multicast group to send to")
+	port := fs.Int("port", 5000, "UDP port")
+	speed := fs.Float64("speed", 1, "Playback speed: 2 sends twice as fast, 0 as fast as possible")
+	loop := fs.Bool("loop", false, "Play the scenario over and over")
+	stream := fs.String("stream-id", "", "Stream id of every event, replacing the scenario's")
+	outPath := fs.String("o", "", "Write the scenario to this file (.trk, .jsonl or .csv) instead of sending it")
+	quiet := fs.Bool("quiet", false, "Do not print the events as they are sent")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s publish [flags] scenario.yaml|name\n\nBuilt-in scenarios: %s\n\n",
+			os.Args[0], strings.Join(tracks.ScenarioNames(), ", "))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *speed < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -speed must not be negative\n")
+		os.Exit(1)
+	}
+
+	// A name without a file of that name is a built-in scenario.
+	rec, err := tracks.LoadScenario(fs.Arg(0))
+	if os.IsNotExist(err) && !strings.ContainsAny(fs.Arg(0), `/\.`) {
+		rec, err = tracks.Scenario(fs.Arg(0))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *stream != "" {
+		rec.OnStream(*stream)
+	}
+
+	if *outPath != "" {
+		if err := writeScenario(rec, *outPath); err != nil {
+			fmt.Fprintf(os.Stderr, "publish: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote %d events of %s to %s\n", len(rec.Records), rec.Name, *outPath)
+		return
+	}
+
+	ip := net.ParseIP(*group)
+	if ip == nil || ip.To4() == nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid multicast group %q\n", *group)
+		os.Exit(1)
+	}
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: ip, Port: *port})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+	length := rec.End().Sub(tracks.Epoch)
+	fmt.Printf("Publishing %s (%d events, %s) to %s:%d\n", rec.Name, len(rec.Records), length.Round(time.Millisecond), *group, *port)
+	for {
+		if err := publishScenario(conn, rec, *speed, *quiet); err != nil {
+			fmt.Fprintf(os.Stderr, "publish: %v\n", err)
+			os.Exit(1)
+		}
+		if !*loop {
+			return
+		}
+	}
+}
+
+// publishScenario sends every event of rec at its time, stamped with the
+// time it is sent, as the sender stamps Envelope.send_time_ns.
+func publishScenario(conn *net.UDPConn, rec *tracks.Recording, speed float64, quiet bool) error {
+	start := time.Now()
+	for _, r := range rec.Records {
+		if speed > 0 {
+			due := start.Add(time.Duration(float64(r.Received.Sub(tracks.Epoch)) / speed))
+			time.Sleep(time.Until(due))
+		}
+		env := proto.Clone(r.Envelope).(*trackspb.Envelope)
+		env.SendTimeNs = time.Now().UnixNano()
+		b, err := proto.Marshal(env)
+		if err != nil {
+			return err
+		}
+		if _, err := conn.Write(b); err != nil {
+			return err
+		}
+		if !quiet {
+			fmt.Println(formatEvent(env))
+		}
+	}
+	return nil
+}
+
+// writeScenario writes rec to path, in the format of its extension, with
+// the scenario's receive times.
+func writeScenario(rec *tracks.Recording, path string) error {
+	format := outputFormatFor(path)
+	if format == "" {
+		return fmt.Errorf("%s: unknown output format (want .trk, .jsonl or .csv)", path)
+	}
+	w, err := newEventWriter(format, path)
+	if err != nil {
+		return err
+	}
+	for _, r := range rec.Records {
+		if err := w.write(r.Envelope, r.Received); err != nil {
+			w.close()
+			return err
+		}
+	}
+	return w.close()
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Quantized exports (-quantize=beat or bar): when a track ends, the times
+// of the -quantize-events are moved to the nearest beat, or downbeat, of
+// the track's own beat grid, before the track reaches reports, labels,
+// lead sheets and the other per-track exports. Events that land on the same
+// grid point are merged, keeping the last, so a chord that changes twice
+// within a beat shows as the chord it settled on. Events further than a
+// typical grid spacing from any grid point, as in an intro or breakdown
+// without beats, stay where they are. Live outputs (-out, the
+// web dashboard, ...) keep the times as received.
+const defaultQuantizeEvents = "onset,chord.change,segment.boundary"
+
+type quantizer struct {
+	bars   bool
+	events []string
+}
+
+func newQuantizer(unit, events string) (*quantizer, error) {
+	q := &quantizer{}
+	switch unit {
+	case "beat":
+	case "bar":
+		q.bars = true
+	default:
+		return nil, fmt.Errorf("unknown grid %q (want beat or bar)", unit)
+	}
+	for _, name := range strings.Split(events, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !isEventName(name) {
+			return nil, fmt.Errorf("unknown event %q", name)
+		}
+		if name == "beat" || name == "downbeat" {
+			return nil, fmt.Errorf("%s makes the grid and cannot be quantized", name)
+		}
+		q.events = append(q.events, name)
+	}
+	if len(q.events) == 0 {
+		return nil, fmt.Errorf("no events given (use -quantize-events)")
+	}
+	return q, nil
+}
+
+// grid returns the track's beat or downbeat times, falling back to beats
+// when a bar grid is wanted but the track has no downbeats.
+func (q *quantizer) grid(d *trackData) []float64 {
+	var beats, downbeats []float64
+	for _, p := range d.series["beat"] {
+		beats = append(beats, p.t)
+	}
+	for _, p := range d.series["downbeat"] {
+		downbeats = append(downbeats, p.t)
+	}
+	name := d.start.GetFilename()
+	switch {
+	case q.bars && len(downbeats) > 0:
+		return downbeats
+	case len(beats) == 0:
+		fmt.Fprintf(os.Stderr, "quantize: %s: no beats; times left as received\n", name)
+		return nil
+	case q.bars:
+		fmt.Fprintf(os.Stderr, "quantize: %s: no downbeats; snapping to beats\n", name)
+	}
+	return beats
+}
+
+// apply snaps the track's event times to its grid.
+func (q *quantizer) apply(d *trackData) {
+	grid := q.grid(d)
+	if len(grid) == 0 {
+		return
+	}
+	sort.Float64s(grid)
+	spacing := gridSpacing(grid)
+	snap := func(t float64) float64 { return snapTime(t, grid, spacing) }
+	for _, name := range q.events {
+		switch name {
+		case "key.change":
+			d.keys = snapLabels(d.keys, snap)
+		case "chord.change":
+			d.chords = snapLabels(d.chords, snap)
+		case "roman.numeral":
+			d.numerals = snapLabels(d.numerals, snap)
+		}
+		if pts := d.series[name]; len(pts) > 0 {
+			out := pts[:0]
+			for _, p := range pts {
+				p.t = snap(p.t)
+				if n := len(out); n > 0 && out[n-1].t == p.t {
+					out[n-1] = p
+					continue
+				}
+				out = append(out, p)
+			}
+			d.series[name] = out
+		}
+		if ts := d.marks[name]; len(ts) > 0 {
+			out := ts[:0]
+			for _, t := range ts {
+				t = snap(t)
+				if n := len(out); n > 0 && out[n-1] == t {
+					continue
+				}
+				out = append(out, t)
+			}
+			d.marks[name] = out
+		}
+	}
+}
+
+func snapLabels(labels []label, snap func(float64) float64) []label {
+	out := labels[:0]
+	for _, l := range labels {
+		l.t = snap(l.t)
+		if n := len(out); n > 0 && out[n-1].t == l.t {
+			out[n-1] = l
+			continue
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+// gridSpacing is the median distance between grid points, or +Inf for a
+// single point.
+func gridSpacing(grid []float64) float64 {
+	if len(grid) < 2 {
+		return math.Inf(1)
+	}
+	gaps := make([]float64, len(grid)-1)
+	for i := range gaps {
+		gaps[i] = grid[i+1] - grid[i]
+	}
+	sort.Float64s(gaps)
+	return gaps[len(gaps)/2]
+}
+
+// snapTime returns the grid time nearest t, or t if that is further away
+// than maxDist; grid is sorted.
+func snapTime(t float64, grid []float64, maxDist float64) float64 {
+	i := sort.SearchFloat64s(grid, t)
+	nearest := grid[min(i, len(grid)-1)]
+	if i > 0 && (i == len(grid) || t-grid[i-1] <= grid[i]-t) {
+		nearest = grid[i-1]
+	}
+	if math.Abs(nearest-t) > maxDist {
+		return t
+	}
+	return nearest
+}
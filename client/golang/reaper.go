@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// writeReaperCSV writes annotations in the CSV layout of REAPER's
+// Region/Marker Manager (Import...): instants become markers (M1, M2, ...)
+// and regions become regions (R1, R2, ...), times as m:ss.mmm.
+func writeReaperCSV(path string, d *trackData, layers map[string]bool) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	c := csv.NewWriter(w)
+	c.Write([]string{"#", "Name", "Start", "End", "Length"})
+	markers, regions := 0, 0
+	for _, a := range trackAnnotations(d, layers) {
+		if a.end > a.start {
+			regions++
+			c.Write([]string{fmt.Sprintf("R%d", regions), a.text, formatClock(a.start), formatClock(a.end), formatClock(a.end - a.start)})
+		} else {
+			markers++
+			c.Write([]string{fmt.Sprintf("M%d", markers), a.text, formatClock(a.start), "", ""})
+		}
+	}
+	c.Flush()
+	if err := c.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return flushClose(w, f)
+}
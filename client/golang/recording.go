@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"google.golang.org/protobuf/proto"
+)
+
+// Recording format (.trk): the 8-byte magic "TRKREC2\x00" followed by one
+// record per envelope, each a 16-byte header — receive time as int64 Unix
+// nanoseconds, payload length as uint32, and the CRC-32C of the first 12
+// header bytes and the payload as uint32, all little-endian — and then the
+// serialized Envelope. Lengths from trkReserved up mark the other blocks,
+// which are header-sized:
+//
+//	sync     "TRKSYNC\x00", 0xffffffff, CRC   at the start and every trkSyncEvery
+//	trailer  record count, 0xfffffffe, CRC    when the recording is closed
+//
+// A recording cut short, as by a crash or a full disk, is readable up to its
+// last complete record, and a reader that meets a damaged record skips to
+// the next sync marker. Writing to a recording without a trailer appends to
+// it, after a sync marker, rather than replacing it. Recordings in the first
+// version ("TRKREC1\x00", headers without the CRC) are still read.
+const (
+	trkMagic     = "TRKREC2\x00"
+	trkMagicV1   = "TRKREC1\x00"
+	trkHeader    = 16
+	trkSyncEvery = time.Second
+	trkMaxRecord = 1 << 20
+	trkReserved  = 0xffffff00
+	trkSyncLen   = 0xffffffff
+	trkTrailer   = 0xfffffffe
+)
+
+var trkCRC = crc32.MakeTable(crc32.Castagnoli)
+
+// trkSync is the sync marker; being constant, it can be searched for.
+var trkSync = trkBlock([]byte("TRKSYNC\x00"), trkSyncLen)
+
+// trkBlock lays out a header-sized block of the given first 8 bytes and
+// length.
+func trkBlock(first []byte, length uint32) []byte {
+	b := make([]byte, trkHeader)
+	copy(b, first)
+	binary.LittleEndian.PutUint32(b[8:12], length)
+	binary.LittleEndian.PutUint32(b[12:16], crc32.Checksum(b[:12], trkCRC))
+	return b
+}
+
+type trkWriter struct {
+	f        *os.File
+	w        *bufio.Writer
+	records  uint64
+	lastSync time.Time
+}
+
+// newTrkWriter starts a recording at path, or resumes it when path holds
+// one that was never closed.
+func newTrkWriter(path string) (*trkWriter, error) {
+	if unfinishedRecording(path) {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "output: appending to the unfinished recording %s\n", path)
+		t := &trkWriter{f: f, w: bufio.NewWriter(f)}
+		t.w.Write(trkSync)
+		return t, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return startTrk(f), nil
+}
+
+// startTrk starts a new recording in f.
+func startTrk(f *os.File) *trkWriter {
+	t := &trkWriter{f: f, w: bufio.NewWriter(f)}
+	t.w.WriteString(trkMagic)
+	t.w.Write(trkSync)
+	return t
+}
+
+// unfinishedRecording reports whether path is a recording without a
+// trailer.
+func unfinishedRecording(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, len(trkMagic))
+	if _, err := io.ReadFull(f, magic); err != nil || string(magic) != trkMagic {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil || info.Size() < int64(len(trkMagic)+trkHeader) {
+		return true
+	}
+	tail := make([]byte, trkHeader)
+	if _, err := f.ReadAt(tail, info.Size()-trkHeader); err != nil {
+		return true
+	}
+	return !bytes.Equal(tail, trkBlock(tail[:8], trkTrailer))
+}
+
+func (t *trkWriter) write(env *trackspb.Envelope, received time.Time) error {
+	b, err := proto.Marshal(env)
+	if err != nil {
+		return err
+	}
+	if len(b) > trkMaxRecord {
+		return fmt.Errorf("%s envelope of %d bytes is too large to record", eventName(env), len(b))
+	}
+	// Flushing with every sync marker bounds what a crash can lose.
+	if received.Sub(t.lastSync) >= trkSyncEvery {
+		if !t.lastSync.IsZero() {
+			t.w.Write(trkSync)
+		}
+		t.lastSync = received
+		if err := t.w.Flush(); err != nil {
+			return err
+		}
+	}
+	var hdr [trkHeader]byte
+	binary.LittleEndian.PutUint64(hdr[0:8], uint64(received.UnixNano()))
+	binary.LittleEndian.PutUint32(hdr[8:12], uint32(len(b)))
+	crc := crc32.Update(crc32.Checksum(hdr[:12], trkCRC), trkCRC, b)
+	binary.LittleEndian.PutUint32(hdr[12:16], crc)
+	t.w.Write(hdr[:])
+	_, err = t.w.Write(b)
+	t.records++
+	return err
+}
+
+// close ends the recording with its trailer.
+func (t *trkWriter) close() error {
+	var count [8]byte
+	binary.LittleEndian.PutUint64(count[:], t.records)
+	t.w.Write(trkBlock(count[:], trkTrailer))
+	return flushClose(t.w, t.f)
+}
+
+// trkReader reads the records of a recording, in either version.
+type trkReader struct {
+	name    string
+	r       *bufio.Reader
+	v1      bool
+	buf     []byte
+	records int
+
+	skipped   int  // bytes of damaged records passed over
+	truncated bool // the recording ends within a record
+	finished  bool // the recording ends with a trailer
+}
+
+var errNotRecording = errors.New("not a .trk recording (record with -out-format trk)")
+
+func newTrkReader(r io.Reader, name string) (*trkReader, error) {
+	t := &trkReader{name: name, r: bufio.NewReaderSize(r, trkHeader+trkMaxRecord)}
+	magic := make([]byte, len(trkMagic))
+	if _, err := io.ReadFull(t.r, magic); err != nil {
+		return nil, errNotRecording
+	}
+	switch string(magic) {
+	case trkMagic:
+	case trkMagicV1:
+		t.v1 = true
+	default:
+		return nil, errNotRecording
+	}
+	return t, nil
+}
+
+// next returns the next record's receive time and envelope, which is only
+// valid until the following call, or io.EOF after the last complete one.
+func (t *trkReader) next() (time.Time, []byte, error) {
+	if t.v1 {
+		return t.nextV1()
+	}
+	for {
+		hdr, err := t.r.Peek(trkHeader)
+		if err != nil {
+			return t.end(len(hdr), err)
+		}
+		n := binary.LittleEndian.Uint32(hdr[8:12])
+		sum := binary.LittleEndian.Uint32(hdr[12:16])
+		if n >= trkReserved {
+			if sum != crc32.Checksum(hdr[:12], trkCRC) {
+				t.resync()
+				continue
+			}
+			t.finished = n == trkTrailer
+			t.r.Discard(trkHeader)
+			continue
+		}
+		if n > trkMaxRecord {
+			t.resync()
+			continue
+		}
+		rec, err := t.r.Peek(trkHeader + int(n))
+		if err != nil {
+			if bytes.Contains(rec[1:], trkSync) {
+				t.resync()
+				continue
+			}
+			return t.end(len(rec), err)
+		}
+		payload := rec[trkHeader:]
+		if sum != crc32.Update(crc32.Checksum(rec[:12], trkCRC), trkCRC, payload) {
+			t.resync()
+			continue
+		}
+		t.buf = append(t.buf[:0], payload...)
+		received := time.Unix(0, int64(binary.LittleEndian.Uint64(rec[0:8])))
+		t.r.Discard(len(rec))
+		t.records++
+		t.finished = false
+		return received, t.buf, nil
+	}
+}
+
+// resync skips the damaged data at the read position up to the next sync
+// marker, or to the end.
+func (t *trkReader) resync() {
+	t.r.Discard(1)
+	t.skipped++
+	for {
+		buf, err := t.r.Peek(t.r.Size())
+		if i := bytes.Index(buf, trkSync); i >= 0 {
+			t.r.Discard(i)
+			t.skipped += i
+			return
+		}
+		if err != nil {
+			t.r.Discard(len(buf))
+			t.skipped += len(buf)
+			return
+		}
+		// Keep what could be the start of a marker.
+		n := len(buf) - (len(trkSync) - 1)
+		t.r.Discard(n)
+		t.skipped += n
+	}
+}
+
+// end ends reading with rest bytes left over, which a complete recording
+// doesn't have.
+func (t *trkReader) end(rest int, err error) (time.Time, []byte, error) {
+	if err != io.EOF {
+		return time.Time{}, nil, fmt.Errorf("%s: %w", t.name, err)
+	}
+	if rest > 0 {
+		t.truncated = true
+		t.r.Discard(rest)
+	}
+	return time.Time{}, nil, io.EOF
+}
+
+func (t *trkReader) nextV1() (time.Time, []byte, error) {
+	var hdr [12]byte
+	n, err := io.ReadFull(t.r, hdr[:])
+	if err == nil {
+		size := int(binary.LittleEndian.Uint32(hdr[8:12]))
+		if size > trkMaxRecord {
+			// No record is this large, so the length is damaged, and
+			// without sync markers there is nothing to resume from.
+			rest, _ := io.Copy(io.Discard, t.r)
+			t.skipped += n + int(rest)
+			return time.Time{}, nil, io.EOF
+		}
+		if cap(t.buf) < size {
+			t.buf = make([]byte, size)
+		}
+		t.buf = t.buf[:size]
+		var m int
+		m, err = io.ReadFull(t.r, t.buf)
+		n += m
+	}
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		return t.end(n, io.EOF)
+	}
+	if err != nil {
+		return time.Time{}, nil, fmt.Errorf("%s: %w", t.name, err)
+	}
+	t.records++
+	return time.Unix(0, int64(binary.LittleEndian.Uint64(hdr[0:8]))), t.buf, nil
+}
+
+// damage describes what was wrong with the recording once it has been read,
+// or returns "".
+func (t *trkReader) damage() string {
+	switch {
+	case t.skipped > 0 && t.truncated:
+		return fmt.Sprintf("skipped %d damaged bytes; the last record is truncated", t.skipped)
+	case t.skipped > 0:
+		return fmt.Sprintf("skipped %d damaged bytes", t.skipped)
+	case t.truncated:
+		return "the last record is truncated"
+	case !t.v1 && !t.finished:
+		return "the recording was not closed and may be incomplete"
+	}
+	return ""
+}
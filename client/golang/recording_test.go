@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/tracks"
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"google.golang.org/protobuf/proto"
+)
+
+// recordBeats writes n beats received every 250 ms from start to the
+// recording w, which therefore has a sync marker every 4 of them.
+func recordBeats(t *testing.T, w *trkWriter, start time.Time, from, n int) {
+	t.Helper()
+	for i := from; i < from+n; i++ {
+		env := &trackspb.Envelope{Timestamp: float64(i), Event: &trackspb.Envelope_Beat{Beat: &trackspb.Beat{Confidence: 0.5}}}
+		if err := w.write(env, start.Add(time.Duration(i)*250*time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readRecording returns the timestamps of the beats in the recording b and
+// what the reader found wrong with it.
+func readRecording(t *testing.T, b []byte) ([]float64, string) {
+	t.Helper()
+	r, err := newTrkReader(bytes.NewReader(b), "test.trk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []float64
+	for {
+		received, payload, err := r.next()
+		if err == io.EOF {
+			return got, r.damage()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		env := &trackspb.Envelope{}
+		if err := proto.Unmarshal(payload, env); err != nil {
+			t.Fatalf("record %d: %v", len(got), err)
+		}
+		if want := tracks.Epoch.Add(time.Duration(env.GetTimestamp()*250) * time.Millisecond); !received.Equal(want) {
+			t.Errorf("beat %g received %v, want %v", env.GetTimestamp(), received, want)
+		}
+		got = append(got, env.GetTimestamp())
+	}
+}
+
+func writeRecording(t *testing.T, n int) []byte {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.trk")
+	w, err := newTrkWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordBeats(t, w, tracks.Epoch, 0, n)
+	if err := w.close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// seq returns from, from+1, ... up to but not including to.
+func seq(from, to int) []float64 {
+	var s []float64
+	for i := from; i < to; i++ {
+		s = append(s, float64(i))
+	}
+	return s
+}
+
+func TestRecordingRoundTrip(t *testing.T) {
+	b := writeRecording(t, 12)
+	if got, damage := readRecording(t, b); !slices.Equal(got, seq(0, 12)) || damage != "" {
+		t.Errorf("read beats %v (%q), want 0-11 undamaged", got, damage)
+	}
+}
+
+func TestRecordingTruncated(t *testing.T) {
+	b := writeRecording(t, 12)
+	// Cut into the last beat, past the trailer.
+	b = b[:len(b)-trkHeader-3]
+	got, damage := readRecording(t, b)
+	if !slices.Equal(got, seq(0, 11)) || damage != "the last record is truncated" {
+		t.Errorf("read beats %v (%q), want 0-10 and a truncated last record", got, damage)
+	}
+}
+
+func TestRecordingResync(t *testing.T) {
+	b := writeRecording(t, 12)
+	// Flip a byte of beat 5, between the sync markers before beats 4 and 8.
+	markers := bytes.Count(b, trkSync)
+	if markers != 3 {
+		t.Fatalf("%d sync markers, want 3 (before beats 0, 4 and 8)", markers)
+	}
+	second := bytes.Index(b, trkSync) + len(trkSync)
+	second += bytes.Index(b[second:], trkSync) + len(trkSync)
+	record := len(bytes.Split(b, trkSync)[2]) / 4 // beats 4-7 are the same size
+	b[second+record+trkHeader+1] ^= 0x40
+
+	got, damage := readRecording(t, b)
+	want := append(seq(0, 5), seq(8, 12)...)
+	if !slices.Equal(got, want) || !strings.HasPrefix(damage, "skipped ") {
+		t.Errorf("read beats %v (%q), want %v with damaged bytes skipped", got, damage, want)
+	}
+}
+
+func TestRecordingAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.trk")
+	w, err := newTrkWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordBeats(t, w, tracks.Epoch, 0, 6)
+	// A crash: what was written reaches the file, the trailer doesn't.
+	if err := w.w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	w.f.Close()
+	if !unfinishedRecording(path) {
+		t.Fatal("recording without a trailer not unfinished")
+	}
+	b, _ := os.ReadFile(path)
+	if got, damage := readRecording(t, b); !slices.Equal(got, seq(0, 6)) || damage != "the recording was not closed and may be incomplete" {
+		t.Errorf("unfinished recording: beats %v (%q)", got, damage)
+	}
+
+	w, err = newTrkWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordBeats(t, w, tracks.Epoch, 6, 6)
+	if err := w.close(); err != nil {
+		t.Fatal(err)
+	}
+	if unfinishedRecording(path) {
+		t.Error("closed recording unfinished")
+	}
+	b, _ = os.ReadFile(path)
+	if got, damage := readRecording(t, b); !slices.Equal(got, seq(0, 12)) || damage != "" {
+		t.Errorf("appended recording: beats %v (%q), want 0-11 undamaged", got, damage)
+	}
+}
+
+func TestRecordingV1DamagedLength(t *testing.T) {
+	var b []byte
+	b = append(b, trkMagicV1...)
+	for _, size := range []uint32{0, 0xfffffff0} {
+		b = binary.LittleEndian.AppendUint64(b, uint64(tracks.Epoch.UnixNano()))
+		b = binary.LittleEndian.AppendUint32(b, size)
+	}
+	b = append(b, "the rest"...)
+	r, err := newTrkReader(bytes.NewReader(b), "v1.trk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := r.next(); err != nil {
+		t.Fatalf("first record: %v", err)
+	}
+	if _, _, err := r.next(); err != io.EOF {
+		t.Fatalf("record of 0xfffffff0 bytes: %v, want io.EOF", err)
+	}
+	if damage := r.damage(); damage != "skipped 20 damaged bytes" {
+		t.Errorf("damage %q, want 20 bytes skipped", damage)
+	}
+}
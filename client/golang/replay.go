@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/tracks"
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"google.golang.org/protobuf/proto"
+)
+
+// Replay (-transport=replay): .trk recordings (see recording.go) played back
+// as if they were arriving live, paced by the receive times they were
+// recorded with. Everything downstream of the transport sees them as
+// usual, so a recording can feed the web dashboard's WebSocket, the TCP
+// stream server, webhooks and outputs without a sender or a multicast
+// network. Files play one after another; each starts as soon as the
+// previous one ends.
+//
+// With -replay-clock=virtual the replay doesn't wait: it sets a virtual
+// clock to each record's receive time instead, shifted so each file starts
+// where the previous one ended, and the receiver stamps envelopes with that
+// clock. A recording then plays as fast as it can be handled, and what
+// follows receive times — beat grids, positions, exports, summaries, alerts
+// and the names of -out files — comes out the same on every run.
+type replaySource struct {
+	paths   []string
+	speed   float64              // 0 plays as fast as the receiver keeps up
+	virtual *tracks.VirtualClock // nil on the wall clock
+
+	f     *os.File
+	r     *trkReader
+	next  int       // index into paths of the file to open next
+	first time.Time // receive time of the current file's first record
+	start time.Time // time the current file started playing
+
+	stop      chan struct{}
+	closeOnce sync.Once
+	finished  bool // every file played to the end
+}
+
+// newReplaySource checks that every file is a recording and opens the
+// first.
+func newReplaySource(spec string, speed float64, virtual *tracks.VirtualClock) (*replaySource, error) {
+	var paths []string
+	for _, p := range strings.Split(spec, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no recording given")
+	}
+	if speed < 0 {
+		return nil, fmt.Errorf("speed must not be negative")
+	}
+	for _, p := range paths {
+		if err := checkRecording(p); err != nil {
+			return nil, err
+		}
+	}
+	s := &replaySource{paths: paths, speed: speed, virtual: virtual, stop: make(chan struct{})}
+	if err := s.openNext(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func checkRecording(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := newTrkReader(f, path); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+func (s *replaySource) openNext() error {
+	if s.f != nil {
+		s.f.Close()
+		s.f = nil
+	}
+	f, err := os.Open(s.paths[s.next])
+	if err != nil {
+		return err
+	}
+	s.next++
+	r, err := newTrkReader(f, f.Name())
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %w", f.Name(), err)
+	}
+	s.f, s.r = f, r
+	s.first = time.Time{}
+	return nil
+}
+
+// ReadPacket waits until the next record is due and returns its envelope.
+// After the last record of the last file it reports net.ErrClosed, as when
+// the source is closed.
+func (s *replaySource) ReadPacket() ([]byte, string, error) {
+	for {
+		select {
+		case <-s.stop:
+			s.f.Close()
+			return nil, "", net.ErrClosed
+		default:
+		}
+		received, payload, err := s.r.next()
+		if err == io.EOF {
+			// A recording cut short, as by killing the receiver that
+			// wrote it, plays up to its last complete record.
+			if d := s.r.damage(); d != "" {
+				fmt.Fprintf(os.Stderr, "replay: %s: %s\n", s.f.Name(), d)
+			}
+		}
+		if err == io.EOF && s.next < len(s.paths) {
+			if err := s.openNext(); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+		if err == io.EOF {
+			s.f.Close()
+			s.finished = true
+			return nil, "", net.ErrClosed
+		}
+		if err != nil {
+			s.f.Close()
+			return nil, "", err
+		}
+
+		if s.first.IsZero() {
+			s.first, s.start = received, time.Now()
+			if s.virtual != nil {
+				// A virtual clock not set yet starts at the recording.
+				if s.start = s.virtual.Now(); s.start.IsZero() {
+					s.start = received
+				}
+			}
+		}
+		if s.virtual != nil {
+			s.virtual.Set(s.start.Add(received.Sub(s.first)))
+		} else if s.speed > 0 {
+			due := s.start.Add(time.Duration(float64(received.Sub(s.first)) / s.speed))
+			if wait := time.Until(due); wait > 0 {
+				t := time.NewTimer(wait)
+				select {
+				case <-s.stop:
+					t.Stop()
+					s.f.Close()
+					return nil, "", net.ErrClosed
+				case <-t.C:
+				}
+			}
+		}
+		return stripSendTime(payload), s.f.Name(), nil
+	}
+}
+
+// stripSendTime drops the recorded Envelope.send_time_ns, which would
+// count the time since the recording as latency.
+func stripSendTime(payload []byte) []byte {
+	env := &trackspb.Envelope{}
+	if proto.Unmarshal(payload, env) != nil || env.GetSendTimeNs() == 0 {
+		return payload
+	}
+	env.SendTimeNs = 0
+	if b, err := proto.Marshal(env); err == nil {
+		return b
+	}
+	return payload
+}
+
+// Close stops playback. The file is closed by the read it interrupts, so
+// Close is safe to call while ReadPacket waits.
+func (s *replaySource) Close() error {
+	s.closeOnce.Do(func() { close(s.stop) })
+	return nil
+}
+
+// done reports whether every recording was played to the end, rather than
+// playback being stopped.
+func (s *replaySource) done() bool {
+	return s.finished
+}
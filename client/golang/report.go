@@ -0,0 +1,317 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// HTML report (-report): a self-contained page per track with SVG plots of
+// loudness, tempo, key, structure, quality events and hum, readable without
+// any TRACKS tooling.
+
+const (
+	plotWidth  = 900
+	plotHeight = 160
+	plotMargin = 40
+	bandHeight = 36
+)
+
+var qualityEventNames = []string{"click", "discontinuity", "noise.burst", "saturation", "hum"}
+
+var reportPalette = []string{
+	"#4e79a7", "#f28e2b", "#e15759", "#76b7b2", "#59a14f",
+	"#edc948", "#b07aa1", "#ff9da7", "#9c755f", "#bab0ac",
+}
+
+type reportSection struct {
+	Title string
+	Plot  template.HTML
+	Empty string
+}
+
+type reportQuality struct {
+	t      float64
+	Time   string
+	Event  string
+	Detail string
+}
+
+type reportHum struct {
+	Time       string
+	Frequency  string
+	Detail     string
+	Confidence string
+}
+
+type reportPage struct {
+	Title    string
+	Facts    [][2]string
+	Sections []reportSection
+	Quality  []reportQuality
+	Hum      []reportHum
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} — TRACKS report</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em auto; max-width: 960px; color: #222; }
+h1 { font-size: 1.4em; }
+h2 { font-size: 1.1em; margin-top: 2em; }
+table { border-collapse: collapse; }
+td, th { padding: 2px 12px 2px 0; text-align: left; }
+.empty { color: #888; font-style: italic; }
+svg text { font-size: 11px; fill: #555; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<table>{{range .Facts}}<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>{{end}}</table>
+{{range .Sections}}
+<h2>{{.Title}}</h2>
+{{if .Plot}}{{.Plot}}{{else}}<p class="empty">{{.Empty}}</p>{{end}}
+{{end}}
+<h2>Quality events</h2>
+{{if .Quality}}<table><tr><th>Time</th><th>Event</th><th>Detail</th></tr>
+{{range .Quality}}<tr><td>{{.Time}}</td><td>{{.Event}}</td><td>{{.Detail}}</td></tr>
+{{end}}</table>{{else}}<p class="empty">No quality events detected.</p>{{end}}
+{{if .Hum}}<h2>Persistent hum</h2>
+<table><tr><th>Time</th><th>Frequency</th><th>Source</th><th>Confidence</th></tr>
+{{range .Hum}}<tr><td>{{.Time}}</td><td>{{.Frequency}}</td><td>{{.Detail}}</td><td>{{.Confidence}}</td></tr>
+{{end}}</table>{{end}}
+</body>
+</html>
+`))
+
+func writeReport(path string, d *trackData) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := reportTemplate.Execute(f, buildReport(d)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func buildReport(d *trackData) *reportPage {
+	dur := d.duration()
+	title := filepath.Base(d.start.GetFilename())
+	if d.meta.Title != "" {
+		title = d.meta.heading(d.start)
+	} else if title == "." || title == "" {
+		title = "Untitled track"
+	}
+
+	status := "completed"
+	if d.aborted {
+		status = "aborted (" + d.abortReason + ")"
+	}
+	total := 0
+	for _, n := range d.counts {
+		total += n
+	}
+	p := &reportPage{
+		Title: title,
+		Facts: [][2]string{
+			{"File", d.start.GetFilename()},
+			{"Duration", formatClock(dur)},
+			{"Format", fmt.Sprintf("%d Hz, %d ch", d.start.GetSampleRate(), d.start.GetChannels())},
+			{"Received", d.started.Format("2006-01-02 15:04:05")},
+			{"Status", status},
+			{"Events", fmt.Sprint(total)},
+		},
+	}
+	if d.meta.Artist != "" {
+		p.Facts = append(p.Facts, [2]string{"Artist", d.meta.Artist})
+	}
+	if d.meta.Album != "" {
+		p.Facts = append(p.Facts, [2]string{"Album", d.meta.Album})
+	}
+	if d.stream != "" {
+		p.Facts = append(p.Facts, [2]string{"Stream", d.stream})
+	}
+	if nf := trackNoiseFloor(d); nf != nil {
+		floor := fmt.Sprintf("%.1f dB, over %.1fs of silence", nf.Level, nf.Seconds)
+		if nf.Exceeded {
+			floor += fmt.Sprintf(" — above %g dB", noiseFloorThreshold)
+		}
+		p.Facts = append(p.Facts, [2]string{"Noise floor", floor})
+	}
+
+	loud := reportSection{Title: "Loudness", Empty: "No loudness events (enable with -e loudness)."}
+	if pts := d.series["loudness"]; len(pts) > 0 {
+		loud.Plot = svgLineChart(pts, dur, false)
+	} else if pts := d.series["energy"]; len(pts) > 0 {
+		loud.Title = "Energy"
+		loud.Plot = svgLineChart(pts, dur, false)
+	}
+
+	bpm := reportSection{Title: "Tempo (BPM)", Empty: "No tempo.change events."}
+	if pts := d.series["tempo.change"]; len(pts) > 0 {
+		bpm.Plot = svgLineChart(pts, dur, true)
+	}
+
+	key := reportSection{Title: "Key", Empty: "No key.change events."}
+	if len(d.keys) > 0 {
+		key.Plot = svgLabelBands(d.keys, dur)
+	}
+
+	seg := reportSection{Title: "Segments", Empty: "No segment.boundary events."}
+	if bounds := d.marks["segment.boundary"]; len(bounds) > 0 {
+		labels := []label{{0, "1"}}
+		for i, t := range bounds {
+			if t > 0 {
+				labels = append(labels, label{t, fmt.Sprint(i + 2)})
+			}
+		}
+		seg.Plot = svgLabelBands(labels, dur)
+	}
+
+	structure := reportSection{Title: "Structure", Empty: "Too few section.change or segment.boundary events to label sections."}
+	if sections := trackStructure(d); len(sections) > 0 {
+		var labels []label
+		for _, s := range sections {
+			labels = append(labels, label{s.start, s.text()})
+		}
+		structure.Plot = svgLabelBands(labels, dur)
+	}
+
+	p.Sections = []reportSection{loud, bpm, key, seg, structure}
+
+	for _, name := range qualityEventNames {
+		for _, t := range d.marks[name] {
+			p.Quality = append(p.Quality, reportQuality{t: t, Time: formatClock(t), Event: name})
+		}
+		for _, pt := range d.series[name] {
+			detail := fmt.Sprintf("%.3fs", pt.v)
+			if name == "hum" {
+				detail = fmt.Sprintf("%.1f Hz", pt.v)
+			}
+			p.Quality = append(p.Quality, reportQuality{t: pt.t, Time: formatClock(pt.t), Event: name, Detail: detail})
+		}
+	}
+	sort.SliceStable(p.Quality, func(i, j int) bool { return p.Quality[i].t < p.Quality[j].t })
+
+	if hum := trackHum(d); hum != nil {
+		for _, s := range hum.Segments {
+			p.Hum = append(p.Hum, reportHum{
+				Time:       formatClock(s.Start) + "–" + formatClock(s.End),
+				Frequency:  fmt.Sprintf("%.1f Hz", s.Frequency),
+				Detail:     s.describe(),
+				Confidence: fmt.Sprintf("%.0f%%", s.Confidence*100),
+			})
+		}
+	}
+	return p
+}
+
+// formatClock renders seconds as m:ss.mmm.
+func formatClock(sec float64) string {
+	if sec < 0 {
+		return "-" + formatClock(-sec)
+	}
+	m := int(sec) / 60
+	return fmt.Sprintf("%d:%06.3f", m, sec-float64(m*60))
+}
+
+func svgLineChart(pts []point, dur float64, step bool) template.HTML {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, p := range pts {
+		lo = math.Min(lo, p.v)
+		hi = math.Max(hi, p.v)
+	}
+	if hi-lo < 1e-9 {
+		lo, hi = lo-1, hi+1
+	}
+	if dur <= 0 {
+		dur = pts[len(pts)-1].t
+	}
+	if dur <= 0 {
+		dur = 1
+	}
+	w, h := float64(plotWidth-2*plotMargin), float64(plotHeight-2*plotMargin/2)
+	x := func(t float64) float64 { return plotMargin + w*math.Max(0, math.Min(1, t/dur)) }
+	y := func(v float64) float64 { return plotMargin/2 + h*(1-(v-lo)/(hi-lo)) }
+
+	var path strings.Builder
+	for i, p := range pts {
+		cmd := "L"
+		if i == 0 {
+			cmd = "M"
+		} else if step {
+			fmt.Fprintf(&path, "L%.1f,%.1f ", x(p.t), y(pts[i-1].v))
+		}
+		fmt.Fprintf(&path, "%s%.1f,%.1f ", cmd, x(p.t), y(p.v))
+	}
+	if step {
+		fmt.Fprintf(&path, "L%.1f,%.1f", x(dur), y(pts[len(pts)-1].v))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`,
+		plotWidth, plotHeight, plotWidth, plotHeight)
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.0f" height="%.0f" fill="#f7f7f7"/>`, plotMargin, plotMargin/2, w, h)
+	fmt.Fprintf(&b, `<text x="2" y="%.0f">%.3g</text><text x="2" y="%.0f">%.3g</text>`, y(hi)+4, hi, y(lo), lo)
+	svgTimeAxis(&b, dur, plotHeight-4)
+	fmt.Fprintf(&b, `<path d="%s" fill="none" stroke="%s" stroke-width="1.5"/></svg>`, path.String(), reportPalette[0])
+	return template.HTML(b.String())
+}
+
+// svgLabelBands draws consecutive labelled regions, each lasting until the
+// next label (or the end of the track), colored by label.
+func svgLabelBands(labels []label, dur float64) template.HTML {
+	if dur <= 0 {
+		dur = labels[len(labels)-1].t + 1
+	}
+	w := float64(plotWidth - 2*plotMargin)
+	x := func(t float64) float64 { return plotMargin + w*math.Max(0, math.Min(1, t/dur)) }
+	colors := make(map[string]string)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`,
+		plotWidth, bandHeight+20, plotWidth, bandHeight+20)
+	for i, l := range labels {
+		end := dur
+		if i+1 < len(labels) {
+			end = labels[i+1].t
+		}
+		c, ok := colors[l.name]
+		if !ok {
+			c = reportPalette[len(colors)%len(reportPalette)]
+			colors[l.name] = c
+		}
+		x0, x1 := x(l.t), x(end)
+		fmt.Fprintf(&b, `<rect x="%.1f" y="0" width="%.1f" height="%d" fill="%s"><title>%s @ %s</title></rect>`,
+			x0, math.Max(x1-x0, 1), bandHeight, c, template.HTMLEscapeString(l.name), formatClock(l.t))
+		if x1-x0 > 30 {
+			fmt.Fprintf(&b, `<text x="%.1f" y="%d" style="fill:#fff">%s</text>`,
+				x0+4, bandHeight/2+4, template.HTMLEscapeString(l.name))
+		}
+	}
+	svgTimeAxis(&b, dur, bandHeight+16)
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+func svgTimeAxis(b *strings.Builder, dur float64, y int) {
+	w := float64(plotWidth - 2*plotMargin)
+	for i := 0; i <= 4; i++ {
+		t := dur * float64(i) / 4
+		fmt.Fprintf(b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`,
+			plotMargin+w*float64(i)/4, y, formatClock(t))
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Rhythmic band activity (the track summary's "rhythm_bands", and
+// GET /api/rhythm for the dashboard): which frequency regions the onsets
+// happen in. For every onset, each band's energy in the band frame after it
+// is compared with the last frame at least bandOnsetLead before it; the
+// band takes part in the onset when it rose by bandRise dB or more. A
+// band's activity is the share of onsets it took part in, so a kick-driven
+// track is active in its low bands and a hi-hat pattern in its high ones.
+// Onsets without frames within bandOnsetGap either side are left out.
+const (
+	bandRise       = 3.0  // dB
+	bandOnsetLead  = 0.04 // seconds
+	bandOnsetGap   = 0.25 // seconds
+	rhythmWindow   = 60.0 // seconds of timeline served by /api/rhythm
+	rhythmBinWidth = 2.0  // seconds per timeline column
+)
+
+// bandProfile is the activity of each band over a set of onsets, low bands
+// first. Low, Mid and High average the lower, middle and upper thirds.
+type bandProfile struct {
+	Bands    string    `json:"bands"` // bands.mel, bands.bark or bands.erb
+	Onsets   int       `json:"onsets"`
+	Activity []float64 `json:"activity"`
+	Low      float64   `json:"low"`
+	Mid      float64   `json:"mid"`
+	High     float64   `json:"high"`
+}
+
+// onsetBands returns the onsets that fall between band frames, with the
+// bands that rose across each. frames and onsets are sorted.
+func onsetBands(frames []frame, onsets []float64) (times []float64, rose [][]bool) {
+	if len(frames) == 0 {
+		return nil, nil
+	}
+	dim := len(frames[0].v)
+	for _, t := range onsets {
+		after := sort.Search(len(frames), func(i int) bool { return frames[i].t >= t })
+		before := sort.Search(len(frames), func(i int) bool { return frames[i].t > t-bandOnsetLead }) - 1
+		if after == len(frames) || before < 0 ||
+			frames[after].t-t > bandOnsetGap || t-frames[before].t > bandOnsetGap ||
+			len(frames[after].v) != dim || len(frames[before].v) != dim {
+			continue
+		}
+		r := make([]bool, dim)
+		for k := range dim {
+			r[k] = bandDB(frames[after].v[k])-bandDB(frames[before].v[k]) >= bandRise
+		}
+		times = append(times, t)
+		rose = append(rose, r)
+	}
+	return times, rose
+}
+
+func bandDB(x float32) float64 {
+	return 10 * math.Log10(max(float64(x), 1e-12))
+}
+
+// newBandProfile sums rose into a profile, or returns nil without onsets.
+func newBandProfile(bands string, rose [][]bool) *bandProfile {
+	if len(rose) == 0 {
+		return nil
+	}
+	p := &bandProfile{Bands: bands, Onsets: len(rose), Activity: bandActivity(rose)}
+	n := len(p.Activity)
+	third := func(i int) float64 {
+		lo, hi := i*n/3, (i+1)*n/3
+		if hi <= lo {
+			return 0
+		}
+		sum := 0.0
+		for _, a := range p.Activity[lo:hi] {
+			sum += a
+		}
+		return math.Round(sum/float64(hi-lo)*1000) / 1000
+	}
+	p.Low, p.Mid, p.High = third(0), third(1), third(2)
+	return p
+}
+
+func bandActivity(rose [][]bool) []float64 {
+	out := make([]float64, len(rose[0]))
+	for _, r := range rose {
+		for k, up := range r {
+			if up {
+				out[k]++
+			}
+		}
+	}
+	for k := range out {
+		out[k] = math.Round(out[k]/float64(len(rose))*1000) / 1000
+	}
+	return out
+}
+
+// trackBandProfile is the profile of a finished track, over the first of
+// bands.mel, bands.bark and bands.erb it has.
+func trackBandProfile(d *trackData) *bandProfile {
+	for _, bands := range heatmapBands {
+		if frames := d.features[bands]; len(frames) > 0 {
+			onsets := eventTimes(d, "onset")
+			sort.Float64s(onsets)
+			_, rose := onsetBands(frames, onsets)
+			return newBandProfile(bands, rose)
+		}
+	}
+	return nil
+}
+
+// rhythmTracker follows the band frames and onsets of the stream whose
+// track started last, as tonalTracker does for chroma.
+type rhythmTracker struct {
+	mu     sync.Mutex
+	stream string
+	bands  string // the first band event seen
+	frames []frame
+	onsets []float64
+}
+
+func (r *rhythmTracker) observe(env *trackspb.Envelope) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if env.GetTrackStart() != nil {
+		r.stream, r.bands, r.frames, r.onsets = env.GetStreamId(), "", nil, nil
+		return
+	}
+	if env.GetStreamId() != r.stream {
+		return
+	}
+	var values []float32
+	switch e := env.Event.(type) {
+	case *trackspb.Envelope_Onset:
+		r.onsets = append(r.onsets, env.GetTimestamp())
+		return
+	case *trackspb.Envelope_BandsMel:
+		values = e.BandsMel.GetValues()
+	case *trackspb.Envelope_BandsBark:
+		values = e.BandsBark.GetValues()
+	case *trackspb.Envelope_BandsErb:
+		values = e.BandsErb.GetValues()
+	default:
+		return
+	}
+	name := eventName(env)
+	if r.bands == "" {
+		r.bands = name
+	}
+	if name == r.bands && len(values) > 0 {
+		r.frames = append(r.frames, frame{env.GetTimestamp(), values})
+	}
+}
+
+// bandTimeline is the activity of the last rhythmWindow in columns of
+// rhythmBinWidth seconds, oldest first; columns without onsets are null.
+type bandTimeline struct {
+	Start   float64     `json:"start"` // track time of the first column
+	Bin     float64     `json:"bin"`
+	Columns [][]float64 `json:"columns"`
+}
+
+// rhythmView is the GET /api/rhythm payload.
+type rhythmView struct {
+	Stream   string        `json:"stream,omitempty"`
+	Profile  *bandProfile  `json:"profile"` // the track so far
+	Timeline *bandTimeline `json:"timeline"`
+}
+
+func (r *rhythmTracker) view() rhythmView {
+	r.mu.Lock()
+	frames, onsets := r.frames, append([]float64(nil), r.onsets...)
+	v := rhythmView{Stream: r.stream}
+	bands := r.bands
+	r.mu.Unlock()
+
+	sort.Float64s(onsets)
+	times, rose := onsetBands(frames, onsets)
+	v.Profile = newBandProfile(bands, rose)
+	if v.Profile == nil {
+		return v
+	}
+	end := frames[len(frames)-1].t
+	n := int(rhythmWindow / rhythmBinWidth)
+	start := math.Max(0, math.Floor(end/rhythmBinWidth)*rhythmBinWidth-float64(n-1)*rhythmBinWidth)
+	tl := &bandTimeline{Start: start, Bin: rhythmBinWidth, Columns: make([][]float64, n)}
+	for i := range n {
+		lo := sort.SearchFloat64s(times, start+float64(i)*rhythmBinWidth)
+		hi := sort.SearchFloat64s(times, start+float64(i+1)*rhythmBinWidth)
+		if hi > lo {
+			tl.Columns[i] = bandActivity(rose[lo:hi])
+		}
+	}
+	v.Timeline = tl
+	return v
+}
+
+func (w *webServer) handleRhythm(rw http.ResponseWriter, _ *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(w.rhythm.view())
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Roman-numeral analysis (-derive=roman.numeral): labels each chord.change
+// with its function in the stream's current key.
+
+// Scale degrees by semitones above the tonic. Degrees outside the key take
+// an accidental relative to the major scale (bII, #IV, ...); in minor keys
+// the natural-minor degrees III, VI and VII are written without one.
+var (
+	majorDegrees = [12]string{"I", "bII", "II", "bIII", "III", "IV", "#IV", "V", "bVI", "VI", "bVII", "VII"}
+	minorDegrees = [12]string{"I", "bII", "II", "III", "#III", "IV", "#IV", "V", "VI", "#VI", "VII", "#VII"}
+)
+
+type romanDeriver struct {
+	keys map[string]*trackspb.KeyChange // current key per stream
+}
+
+func (r *romanDeriver) derive(env *trackspb.Envelope) []*trackspb.Envelope {
+	stream := env.GetStreamId()
+	switch e := env.Event.(type) {
+	case *trackspb.Envelope_TrackStart:
+		delete(r.keys, stream)
+	case *trackspb.Envelope_KeyChange:
+		r.keys[stream] = e.KeyChange
+	case *trackspb.Envelope_ChordChange:
+		key := r.keys[stream]
+		if key == nil {
+			return nil
+		}
+		numeral, ok := romanNumeral(e.ChordChange.GetChord(), key.GetKey(), key.GetScale())
+		if !ok {
+			return nil
+		}
+		return []*trackspb.Envelope{derivedEnvelope(env, &trackspb.RomanNumeral{
+			Numeral: numeral,
+			Chord:   e.ChordChange.GetChord(),
+			Key:     key.GetKey() + " " + key.GetScale(),
+		})}
+	}
+	return nil
+}
+
+// splitChord splits a chord name such as "F#m7" into its root and quality.
+func splitChord(chord string) (root, quality string) {
+	n := 1
+	if len(chord) > 1 && (chord[1] == '#' || chord[1] == 'b') {
+		n = 2
+	}
+	if len(chord) < n {
+		return "", ""
+	}
+	return chord[:n], chord[n:]
+}
+
+// romanNumeral names chord's function in the given key: upper case for
+// major and augmented chords, lower case for minor and diminished ones,
+// keeping seventh and other extensions ("V7", "ii7", "viio").
+func romanNumeral(chord, key, scale string) (string, bool) {
+	root, quality := splitChord(chord)
+	rootPC, ok := pitchClasses[root]
+	if !ok {
+		return "", false
+	}
+	tonicPC, ok := pitchClasses[key]
+	if !ok {
+		return "", false
+	}
+	degrees := &majorDegrees
+	if scale == "minor" {
+		degrees = &minorDegrees
+	}
+	numeral := degrees[(rootPC-tonicPC+12)%12]
+
+	var suffix string
+	lower := false
+	switch {
+	case strings.HasPrefix(quality, "dim"):
+		lower, suffix = true, "o"+strings.TrimPrefix(quality, "dim")
+	case strings.HasPrefix(quality, "aug"):
+		suffix = "+" + strings.TrimPrefix(quality, "aug")
+	case strings.HasPrefix(quality, "maj"):
+		suffix = quality
+	case strings.HasPrefix(quality, "min"):
+		lower, suffix = true, strings.TrimPrefix(quality, "min")
+	case strings.HasPrefix(quality, "m"):
+		lower, suffix = true, strings.TrimPrefix(quality, "m")
+	default:
+		suffix = quality
+	}
+	if lower {
+		// Keep the accidental as is: "bvi", not "Bvi".
+		acc := strings.TrimRight(numeral, "IV")
+		numeral = acc + strings.ToLower(strings.TrimPrefix(numeral, acc))
+	}
+	return numeral + suffix, true
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RTP-MIDI network session (-rtpmidi), the AppleMIDI protocol macOS and iOS
+// speak natively, so -midi and -mtc can reach devices on the LAN without
+// MIDI hardware: pass "rtpmidi" as their device. The receiver listens on a
+// control port and the data port after it, accepts every invitation, and
+// answers clock synchronisation. It can also invite peers itself
+// (-rtpmidi-invite), keeping their clocks in sync. MIDI is sent to every
+// peer as RTP packets without a recovery journal, which suits a LAN.
+const (
+	rtpMIDIVersion     = 2
+	rtpMIDIPayloadType = 0x61
+	rtpMIDISync        = 10 * time.Second // clock sync interval towards invited peers
+	rtpMIDITimeout     = 90 * time.Second // peers silent this long are dropped
+	rtpMIDIMaxList     = 4095             // longest MIDI command list in one packet
+)
+
+// AppleMIDI session commands.
+const (
+	appleMIDIInvite   = "IN"
+	appleMIDIAccept   = "OK"
+	appleMIDIReject   = "NO"
+	appleMIDIBye      = "BY"
+	appleMIDISync     = "CK"
+	appleMIDIFeedback = "RS"
+)
+
+type rtpMIDISession struct {
+	name    string
+	ssrc    uint32
+	start   time.Time
+	control *net.UDPConn
+	data    *net.UDPConn
+	invites []*net.UDPAddr
+
+	mu    sync.Mutex
+	peers map[uint32]*rtpMIDIPeer
+	seq   uint16
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+type rtpMIDIPeer struct {
+	name     string
+	control  *net.UDPAddr
+	data     *net.UDPAddr // nil until the data port accepted
+	token    uint32
+	invited  bool // we invited it, so we keep its clock in sync
+	lastSeen time.Time
+}
+
+// newRTPMIDISession listens on addr (the control port) and the port after
+// it, and starts inviting the peers in invites, a comma-separated list of
+// host:port control addresses.
+func newRTPMIDISession(addr, name, invites string) (*rtpMIDISession, error) {
+	caddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if caddr.Port == 0 || caddr.Port == 65535 {
+		return nil, fmt.Errorf("need a control port from 1 to 65534")
+	}
+	s := &rtpMIDISession{
+		name:  name,
+		ssrc:  rand.Uint32(),
+		start: time.Now(),
+		peers: make(map[uint32]*rtpMIDIPeer),
+		stop:  make(chan struct{}),
+	}
+	for _, p := range strings.Split(invites, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		a, err := net.ResolveUDPAddr("udp", p)
+		if err != nil {
+			return nil, fmt.Errorf("invite %s: %v", p, err)
+		}
+		s.invites = append(s.invites, a)
+	}
+	if s.control, err = net.ListenUDP("udp", caddr); err != nil {
+		return nil, err
+	}
+	daddr := *caddr
+	daddr.Port++
+	if s.data, err = net.ListenUDP("udp", &daddr); err != nil {
+		s.control.Close()
+		return nil, err
+	}
+	s.wg.Add(3)
+	go s.read(s.control, false)
+	go s.read(s.data, true)
+	go s.maintain()
+	return s, nil
+}
+
+// now is the session clock in 100 µs units, as used by AppleMIDI clock
+// sync and RTP-MIDI timestamps.
+func (s *rtpMIDISession) now() uint64 {
+	return uint64(time.Since(s.start) / (100 * time.Microsecond))
+}
+
+// command builds an AppleMIDI session packet: invitation, acceptance,
+// rejection or bye.
+func (s *rtpMIDISession) command(cmd string, token uint32, withName bool) []byte {
+	b := make([]byte, 0, 16+len(s.name)+1)
+	b = append(b, 0xff, 0xff, cmd[0], cmd[1])
+	b = binary.BigEndian.AppendUint32(b, rtpMIDIVersion)
+	b = binary.BigEndian.AppendUint32(b, token)
+	b = binary.BigEndian.AppendUint32(b, s.ssrc)
+	if withName {
+		b = append(b, s.name...)
+		b = append(b, 0)
+	}
+	return b
+}
+
+func (s *rtpMIDISession) sync(count byte, ts [3]uint64) []byte {
+	b := make([]byte, 0, 36)
+	b = append(b, 0xff, 0xff, 'C', 'K')
+	b = binary.BigEndian.AppendUint32(b, s.ssrc)
+	b = append(b, count, 0, 0, 0)
+	for _, t := range ts {
+		b = binary.BigEndian.AppendUint64(b, t)
+	}
+	return b
+}
+
+func (s *rtpMIDISession) read(conn *net.UDPConn, data bool) {
+	defer s.wg.Done()
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-s.stop:
+			default:
+				fmt.Fprintf(os.Stderr, "rtpmidi: %v\n", err)
+			}
+			return
+		}
+		// MIDI sent to us, and anything else that isn't a session packet,
+		// is ignored.
+		if n < 8 || buf[0] != 0xff || buf[1] != 0xff {
+			continue
+		}
+		s.handle(conn, data, string(buf[2:4]), buf[4:n], from)
+	}
+}
+
+func (s *rtpMIDISession) handle(conn *net.UDPConn, data bool, cmd string, b []byte, from *net.UDPAddr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch cmd {
+	case appleMIDIInvite, appleMIDIAccept, appleMIDIReject, appleMIDIBye:
+		if len(b) < 12 {
+			return
+		}
+		token, ssrc := binary.BigEndian.Uint32(b[4:]), binary.BigEndian.Uint32(b[8:])
+		name := string(bytes.TrimRight(b[12:], "\x00"))
+		p := s.peers[ssrc]
+		switch cmd {
+		case appleMIDIInvite:
+			if p == nil {
+				p = &rtpMIDIPeer{token: token}
+				s.peers[ssrc] = p
+			}
+			if name != "" {
+				p.name = name
+			}
+			p.lastSeen = time.Now()
+			if data {
+				p.data = from
+				fmt.Fprintf(os.Stderr, "rtpmidi: session with %q (%s)\n", p.name, from.IP)
+			} else {
+				p.control = from
+			}
+			conn.WriteToUDP(s.command(appleMIDIAccept, token, true), from)
+		case appleMIDIAccept:
+			// Our invitation was accepted: the control port first, then the
+			// data port, which is the next port up.
+			inv := s.invitedBy(from, data)
+			if inv == nil {
+				return
+			}
+			p = &rtpMIDIPeer{name: name, control: inv, token: token, invited: true, lastSeen: time.Now()}
+			s.peers[ssrc] = p
+			if !data {
+				s.data.WriteToUDP(s.command(appleMIDIInvite, token, true), dataAddr(inv))
+				return
+			}
+			p.data = from
+			fmt.Fprintf(os.Stderr, "rtpmidi: session with %q (%s)\n", p.name, from.IP)
+			s.data.WriteToUDP(s.sync(0, [3]uint64{s.now()}), from)
+		case appleMIDIReject:
+			fmt.Fprintf(os.Stderr, "rtpmidi: %s declined the invitation\n", from)
+		case appleMIDIBye:
+			if p != nil {
+				fmt.Fprintf(os.Stderr, "rtpmidi: %q left the session\n", p.name)
+				delete(s.peers, ssrc)
+			}
+		}
+	case appleMIDISync:
+		if !data || len(b) < 32 {
+			return
+		}
+		ssrc, count := binary.BigEndian.Uint32(b), b[4]
+		ts := [3]uint64{binary.BigEndian.Uint64(b[8:]), binary.BigEndian.Uint64(b[16:]), binary.BigEndian.Uint64(b[24:])}
+		if p := s.peers[ssrc]; p != nil {
+			p.lastSeen = time.Now()
+		}
+		switch count {
+		case 0:
+			ts[1] = s.now()
+			s.data.WriteToUDP(s.sync(1, ts), from)
+		case 1:
+			ts[2] = s.now()
+			s.data.WriteToUDP(s.sync(2, ts), from)
+		}
+	case appleMIDIFeedback:
+		if len(b) >= 4 {
+			if p := s.peers[binary.BigEndian.Uint32(b)]; p != nil {
+				p.lastSeen = time.Now()
+			}
+		}
+	}
+}
+
+// invitedBy returns the -rtpmidi-invite control address a reply from from
+// belongs to, or nil.
+func (s *rtpMIDISession) invitedBy(from *net.UDPAddr, data bool) *net.UDPAddr {
+	for _, a := range s.invites {
+		want := a
+		if data {
+			want = dataAddr(a)
+		}
+		if want.Port == from.Port && (want.IP.IsUnspecified() || want.IP.Equal(from.IP)) {
+			return a
+		}
+	}
+	return nil
+}
+
+func dataAddr(control *net.UDPAddr) *net.UDPAddr {
+	a := *control
+	a.Port++
+	return &a
+}
+
+// maintain invites missing peers, keeps invited peers' clocks in sync and
+// drops peers that went away without saying goodbye.
+func (s *rtpMIDISession) maintain() {
+	defer s.wg.Done()
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	var lastSync time.Time
+	for n := 0; ; n++ {
+		s.mu.Lock()
+		now := time.Now()
+		for ssrc, p := range s.peers {
+			if now.Sub(p.lastSeen) > rtpMIDITimeout {
+				fmt.Fprintf(os.Stderr, "rtpmidi: lost %q\n", p.name)
+				delete(s.peers, ssrc)
+			}
+		}
+		if now.Sub(lastSync) >= rtpMIDISync {
+			lastSync = now
+			for _, p := range s.peers {
+				if p.invited && p.data != nil {
+					s.data.WriteToUDP(s.sync(0, [3]uint64{s.now()}), p.data)
+				}
+			}
+		}
+		// Invitations go out every five seconds until accepted.
+		if n%5 == 0 {
+			for _, a := range s.invites {
+				if !s.joined(a) {
+					s.control.WriteToUDP(s.command(appleMIDIInvite, rand.Uint32(), true), a)
+				}
+			}
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-s.stop:
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+func (s *rtpMIDISession) joined(control *net.UDPAddr) bool {
+	for _, p := range s.peers {
+		if p.invited && p.control == control && p.data != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Write sends MIDI bytes — complete messages, as written by the MIDI
+// outputs — to every peer. Delivery is best effort, so it never fails.
+func (s *rtpMIDISession) Write(b []byte) (int, error) {
+	msgs := splitMIDI(b)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(msgs) > 0 {
+		var list []byte
+		for len(msgs) > 0 {
+			size := len(msgs[0])
+			if len(list) > 0 {
+				size++ // delta time
+			}
+			if len(list) > 0 && len(list)+size > rtpMIDIMaxList {
+				break
+			}
+			if len(list) > 0 {
+				list = append(list, 0)
+			}
+			list = append(list, msgs[0]...)
+			msgs = msgs[1:]
+		}
+		s.send(list)
+	}
+	return len(b), nil
+}
+
+// send transmits one RTP-MIDI packet carrying the command list.
+func (s *rtpMIDISession) send(list []byte) {
+	s.seq++
+	pkt := make([]byte, 0, 14+len(list))
+	pkt = append(pkt, 0x80, rtpMIDIPayloadType)
+	pkt = binary.BigEndian.AppendUint16(pkt, s.seq)
+	pkt = binary.BigEndian.AppendUint32(pkt, uint32(s.now()))
+	pkt = binary.BigEndian.AppendUint32(pkt, s.ssrc)
+	if len(list) <= 15 {
+		pkt = append(pkt, byte(len(list)))
+	} else {
+		pkt = append(pkt, 0x80|byte(len(list)>>8), byte(len(list)))
+	}
+	pkt = append(pkt, list...)
+	for _, p := range s.peers {
+		if p.data != nil {
+			s.data.WriteToUDP(pkt, p.data)
+		}
+	}
+}
+
+// splitMIDI cuts a byte stream of complete MIDI messages without running
+// status into messages.
+func splitMIDI(b []byte) [][]byte {
+	var out [][]byte
+	for len(b) > 0 {
+		n := 1
+		switch st := b[0]; {
+		case st == 0xf0:
+			n = bytes.IndexByte(b, 0xf7) + 1
+			if n == 0 {
+				n = len(b)
+			}
+		case st == 0xf1 || st == 0xf3 || st&0xe0 == 0xc0:
+			n = 2
+		case st == 0xf2 || (st >= 0x80 && st < 0xf0):
+			n = 3
+		}
+		n = min(n, len(b))
+		out = append(out, b[:n])
+		b = b[n:]
+	}
+	return out
+}
+
+// port returns a MIDI port writing to the session, for -midi=rtpmidi and
+// -mtc=rtpmidi. Closing the port leaves the session open.
+func (s *rtpMIDISession) port() *rtpMIDIPort {
+	return &rtpMIDIPort{s}
+}
+
+type rtpMIDIPort struct{ s *rtpMIDISession }
+
+func (p *rtpMIDIPort) Write(b []byte) (int, error) { return p.s.Write(b) }
+func (p *rtpMIDIPort) Close() error                { return nil }
+
+// close says goodbye to every peer and stops the session.
+func (s *rtpMIDISession) close() {
+	s.mu.Lock()
+	for _, p := range s.peers {
+		if p.control != nil {
+			s.control.WriteToUDP(s.command(appleMIDIBye, p.token, false), p.control)
+		}
+	}
+	s.mu.Unlock()
+	close(s.stop)
+	s.control.Close()
+	s.data.Close()
+	s.wg.Wait()
+}
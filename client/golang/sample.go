@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Output sampling (-out-sample). Stored tracks are mostly frame features:
+// with --all the sender emits dozens of spectral, band and tonal vectors
+// every 100 ms, which dwarf the beats, chords and keys that carry the
+// music. Rules thin out chosen events or categories before they reach the
+// -out files, per stream and in stream time, while everything else is
+// stored at full rate:
+//
+//	spectral=1s        keep the first event of each second
+//	bands=mean:2s      store the mean of each 2 seconds instead
+//
+// A mean averages every floating-point field of the event, and vectors
+// element by element; other fields are taken from the first event of the
+// window, whose timestamp it carries. Windows still open when a track ends
+// are stored before track.end.
+type sampleRule struct {
+	every float64 // seconds of stream time
+	mean  bool
+}
+
+type sampleKey struct {
+	stream, event string
+}
+
+type sampleWindow struct {
+	start float64
+	acc   *trackspb.Envelope // first envelope, then sums (mean)
+	n     int
+}
+
+type outSampler struct {
+	byName     map[string]sampleRule
+	byCategory map[string]sampleRule
+	windows    map[sampleKey]*sampleWindow
+	order      []sampleKey // windows in the order they opened, for flushing
+}
+
+// parseSampleRules parses a comma-separated list of name=rule pairs, e.g.
+// "spectral=1s,bands=mean:2s". Names are events or categories; event names
+// take precedence.
+func parseSampleRules(spec string) (*outSampler, error) {
+	s := &outSampler{
+		byName:     make(map[string]sampleRule),
+		byCategory: make(map[string]sampleRule),
+		windows:    make(map[sampleKey]*sampleWindow),
+	}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, rule, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rule %q (want name=interval or name=mean:interval)", item)
+		}
+		var r sampleRule
+		if iv, ok := strings.CutPrefix(rule, "mean:"); ok {
+			r.mean, rule = true, iv
+		}
+		d, err := time.ParseDuration(rule)
+		if err != nil {
+			// A bare number is seconds, as for the stream server's interval.
+			f, ferr := strconv.ParseFloat(rule, 64)
+			if ferr != nil {
+				return nil, fmt.Errorf("invalid interval %q in %q", rule, item)
+			}
+			d = time.Duration(f * float64(time.Second))
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("interval in %q must be positive", item)
+		}
+		r.every = d.Seconds()
+		switch {
+		case name == "transport" || isTransportEvent(name):
+			return nil, fmt.Errorf("transport events cannot be sampled")
+		case isCategory(name):
+			s.byCategory[name] = r
+		case isEventName(name):
+			s.byName[name] = r
+		default:
+			return nil, fmt.Errorf("unknown event or category %q", name)
+		}
+	}
+	return s, nil
+}
+
+func isTransportEvent(name string) bool {
+	for f, n := range eventNames {
+		if n == name {
+			return categoryNames[f/10] == "transport"
+		}
+	}
+	return false
+}
+
+func (s *outSampler) rule(env *trackspb.Envelope) (sampleRule, bool) {
+	if r, ok := s.byName[eventName(env)]; ok {
+		return r, true
+	}
+	r, ok := s.byCategory[eventCategory(env)]
+	return r, ok
+}
+
+// push returns the envelopes to store for env: env itself, nothing while a
+// window fills, or a window's result.
+func (s *outSampler) push(env *trackspb.Envelope) []*trackspb.Envelope {
+	stream := env.GetStreamId()
+	switch env.Event.(type) {
+	case *trackspb.Envelope_TrackStart, *trackspb.Envelope_TrackEnd, *trackspb.Envelope_TrackAbort:
+		return append(s.flush(stream), env)
+	}
+	r, ok := s.rule(env)
+	if !ok {
+		return []*trackspb.Envelope{env}
+	}
+
+	key := sampleKey{stream, eventName(env)}
+	ts := env.GetTimestamp()
+	w := s.windows[key]
+	var out []*trackspb.Envelope
+	if w != nil && (ts >= w.start+r.every || ts < w.start) {
+		if w.acc != nil {
+			out = append(out, w.result())
+		}
+		w = nil
+	}
+	if w == nil {
+		w = &sampleWindow{start: ts}
+		if _, open := s.windows[key]; !open {
+			s.order = append(s.order, key)
+		}
+		s.windows[key] = w
+		if !r.mean {
+			// Decimation: the first event of the window is stored, the
+			// rest of it dropped.
+			return append(out, env)
+		}
+	}
+	if !r.mean {
+		return out
+	}
+	if w.acc == nil {
+		w.acc = proto.Clone(env).(*trackspb.Envelope)
+	} else {
+		addNumbers(eventMessage(w.acc), eventMessage(env))
+	}
+	w.n++
+	return out
+}
+
+// flush returns the means still being collected for stream, and forgets
+// its windows.
+func (s *outSampler) flush(stream string) []*trackspb.Envelope {
+	var out []*trackspb.Envelope
+	keep := s.order[:0]
+	for _, key := range s.order {
+		if key.stream != stream {
+			keep = append(keep, key)
+			continue
+		}
+		if w := s.windows[key]; w.acc != nil {
+			out = append(out, w.result())
+		}
+		delete(s.windows, key)
+	}
+	s.order = keep
+	sort.SliceStable(out, func(i, j int) bool { return out[i].GetTimestamp() < out[j].GetTimestamp() })
+	return out
+}
+
+func (w *sampleWindow) result() *trackspb.Envelope {
+	if w.n > 1 {
+		scaleNumbers(eventMessage(w.acc), 1/float64(w.n))
+	}
+	return w.acc
+}
+
+// eventMessage returns the event inside the envelope's oneof.
+func eventMessage(env *trackspb.Envelope) protoreflect.Message {
+	m := env.ProtoReflect()
+	fd := m.WhichOneof(envelopeEventOneof)
+	if fd == nil || fd.Message() == nil {
+		return nil
+	}
+	return m.Get(fd).Message()
+}
+
+func isFloatField(fd protoreflect.FieldDescriptor) bool {
+	return fd.Kind() == protoreflect.FloatKind || fd.Kind() == protoreflect.DoubleKind
+}
+
+func floatValue(fd protoreflect.FieldDescriptor, f float64) protoreflect.Value {
+	if fd.Kind() == protoreflect.FloatKind {
+		return protoreflect.ValueOfFloat32(float32(f))
+	}
+	return protoreflect.ValueOfFloat64(f)
+}
+
+// addNumbers adds the floating-point fields of m into acc; vectors are
+// added element by element, up to the shorter length.
+func addNumbers(acc, m protoreflect.Message) {
+	if acc == nil || m == nil {
+		return
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if !isFloatField(fd) || fd.IsMap() {
+			return true
+		}
+		if fd.IsList() {
+			// A field missing from the first event has no vector to add
+			// to.
+			if !acc.Has(fd) {
+				return true
+			}
+			dst, src := acc.Mutable(fd).List(), v.List()
+			for i := 0; i < min(dst.Len(), src.Len()); i++ {
+				dst.Set(i, floatValue(fd, dst.Get(i).Float()+src.Get(i).Float()))
+			}
+			return true
+		}
+		acc.Set(fd, floatValue(fd, acc.Get(fd).Float()+v.Float()))
+		return true
+	})
+}
+
+func scaleNumbers(m protoreflect.Message, k float64) {
+	if m == nil {
+		return
+	}
+	// Fields are collected first; setting them while ranging is not safe.
+	var fields []protoreflect.FieldDescriptor
+	m.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		if isFloatField(fd) && !fd.IsMap() {
+			fields = append(fields, fd)
+		}
+		return true
+	})
+	for _, fd := range fields {
+		if fd.IsList() {
+			l := m.Mutable(fd).List()
+			for i := 0; i < l.Len(); i++ {
+				l.Set(i, floatValue(fd, l.Get(i).Float()*k))
+			}
+			continue
+		}
+		m.Set(fd, floatValue(fd, m.Get(fd).Float()*k))
+	}
+}
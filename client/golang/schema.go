@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Sender schemas. Senders send their tracks.proto as a schema envelope (a
+// FileDescriptorSet) every --schema-interval, and -web serves this
+// receiver's at /api/schema. An event added to tracks.proto after this
+// receiver was built decodes as an envelope without an event, its fields
+// left as unknown fields; with the sender's schema it gets its name, its
+// category and its fields back, so it is shown, filtered and counted like
+// any other. Schemas are kept per stream, and streams without one of their
+// own use the latest received.
+type schemaRegistry struct {
+	mu      sync.Mutex
+	streams map[string]*senderSchema
+	latest  *senderSchema
+}
+
+type senderSchema struct {
+	raw      []byte // the descriptor set, to skip unchanged repeats
+	envelope protoreflect.MessageDescriptor
+}
+
+var schemas = &schemaRegistry{streams: make(map[string]*senderSchema)}
+
+// observe takes the schema from a schema envelope.
+func (r *schemaRegistry) observe(env *trackspb.Envelope) {
+	raw := env.GetSchema().GetDescriptorSet()
+	if raw == nil {
+		return
+	}
+	stream := env.GetStreamId()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s := r.streams[stream]; s != nil && bytes.Equal(s.raw, raw) {
+		r.latest = s
+		return
+	}
+	desc, err := envelopeDescriptor(raw)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "schema: stream %q: %v\n", stream, err)
+		return
+	}
+	s := &senderSchema{raw: append([]byte(nil), raw...), envelope: desc}
+	r.streams[stream] = s
+	r.latest = s
+}
+
+// envelopeDescriptor finds tracks.Envelope in a serialized FileDescriptorSet.
+func envelopeDescriptor(raw []byte) (protoreflect.MessageDescriptor, error) {
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(raw, set); err != nil {
+		return nil, err
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, err
+	}
+	d, err := files.FindDescriptorByName("tracks.Envelope")
+	if err != nil {
+		return nil, err
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok || md.Oneofs().ByName("event") == nil {
+		return nil, fmt.Errorf("tracks.Envelope has no event oneof")
+	}
+	return md, nil
+}
+
+func (r *schemaRegistry) lookup(stream string) *senderSchema {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s := r.streams[stream]; s != nil {
+		return s
+	}
+	return r.latest
+}
+
+// unknownEvent returns the sender's field of env's event when this receiver
+// doesn't know it, or nil.
+func (r *schemaRegistry) unknownEvent(env *trackspb.Envelope) (protoreflect.FieldDescriptor, *senderSchema) {
+	unknown := env.ProtoReflect().GetUnknown()
+	if env.Event != nil || len(unknown) == 0 {
+		return nil, nil
+	}
+	s := r.lookup(env.GetStreamId())
+	if s == nil {
+		return nil, nil
+	}
+	var event protoreflect.FieldDescriptor
+	for b := unknown; len(b) > 0; {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			break
+		}
+		m := protowire.ConsumeFieldValue(num, typ, b[n:])
+		if m < 0 {
+			break
+		}
+		// The last member of the oneof wins, as in protobuf.
+		if fd := s.envelope.Fields().ByNumber(num); fd != nil && fd.ContainingOneof() != nil && fd.ContainingOneof().Name() == "event" {
+			event = fd
+		}
+		b = b[n+m:]
+	}
+	if event == nil {
+		return nil, nil
+	}
+	return event, s
+}
+
+// schemaEventName names an event by its field in the sender's schema, as
+// events are named: key_change is key.change.
+func schemaEventName(fd protoreflect.FieldDescriptor) string {
+	return strings.ReplaceAll(string(fd.Name()), "_", ".")
+}
+
+// describe renders the fields of an event unknown to this receiver, e.g.
+// "from=120 to=128", decoded with the sender's schema.
+func (r *schemaRegistry) describe(env *trackspb.Envelope) (string, string, bool) {
+	fd, s := r.unknownEvent(env)
+	if fd == nil {
+		return "", "", false
+	}
+	msg := dynamicpb.NewMessage(s.envelope)
+	if err := proto.Unmarshal(env.ProtoReflect().GetUnknown(), msg); err != nil || fd.Message() == nil {
+		return schemaEventName(fd), "", true
+	}
+	return schemaEventName(fd), formatDynamic(msg.Get(fd).Message()), true
+}
+
+// formatDynamic renders a message's set fields as name=value, in field
+// order.
+func formatDynamic(m protoreflect.Message) string {
+	var fields []protoreflect.FieldDescriptor
+	m.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		fields = append(fields, fd)
+		return true
+	})
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Number() < fields[j].Number() })
+	parts := make([]string, len(fields))
+	for i, fd := range fields {
+		v := m.Get(fd)
+		var s string
+		switch {
+		case fd.IsList():
+			l := v.List()
+			items := make([]string, l.Len())
+			for j := range items {
+				items[j] = formatDynamicValue(fd, l.Get(j))
+			}
+			s = "[" + strings.Join(items, " ") + "]"
+		case fd.IsMap():
+			s = fmt.Sprintf("{%d entries}", v.Map().Len())
+		default:
+			s = formatDynamicValue(fd, v)
+		}
+		parts[i] = string(fd.Name()) + "=" + s
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatDynamicValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch fd.Kind() {
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return strconv.FormatFloat(v.Float(), 'g', 6, 64)
+	case protoreflect.StringKind:
+		return v.String()
+	case protoreflect.BytesKind:
+		return fmt.Sprintf("%dB", len(v.Bytes()))
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return strconv.Itoa(int(v.Enum()))
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return "{" + formatDynamic(v.Message()) + "}"
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// ownSchema is this receiver's tracks.proto as a serialized
+// FileDescriptorSet, as senders send it.
+func ownSchema() ([]byte, error) {
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(trackspb.File_tracks_proto)},
+	}
+	return proto.Marshal(set)
+}
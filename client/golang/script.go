@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	lua "github.com/yuin/gopher-lua"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Scripting (-script FILE.lua): a Lua script sees every envelope before it
+// is handled and can drop it, change it, or emit events of its own, for
+// custom filtering and aggregation without recompiling the receiver. The
+// script defines
+//
+//	function on_event(env) ... end
+//
+// env is the envelope in its protobuf JSON form with proto field names,
+// plus the event's name: {timestamp = 1.5, stream_id = "deckA",
+// event = "beat", beat = {confidence = 0.8}}. on_event returns nil or true
+// to keep the envelope, false to drop it, or a table to replace it. emit(t)
+// adds an envelope in the same form, timestamped and labelled like the one
+// being handled unless t says otherwise. Globals persist between calls.
+//
+// Transport events (track.start, track.end, ...) are shown to the script
+// but always kept unchanged, since the receiver's own track handling
+// depends on them.
+type scriptHook struct {
+	L       *lua.LState
+	onEvent lua.LValue
+	emitted []*trackspb.Envelope
+	cur     *trackspb.Envelope // being handled, for emit's defaults
+}
+
+var scriptJSON = protojson.MarshalOptions{UseProtoNames: true}
+
+func newScriptHook(path string) (*scriptHook, error) {
+	s := &scriptHook{L: lua.NewState()}
+	s.L.SetGlobal("emit", s.L.NewFunction(s.emit))
+	if err := s.L.DoFile(path); err != nil {
+		s.L.Close()
+		return nil, err
+	}
+	s.onEvent = s.L.GetGlobal("on_event")
+	if s.onEvent.Type() != lua.LTFunction {
+		s.L.Close()
+		return nil, fmt.Errorf("%s: no on_event(env) function", path)
+	}
+	return s, nil
+}
+
+// process runs env through the script and returns what replaces it: env
+// itself, a changed copy, or nothing, followed by any emitted envelopes.
+func (s *scriptHook) process(env *trackspb.Envelope) []*trackspb.Envelope {
+	s.cur, s.emitted = env, nil
+	keep := []*trackspb.Envelope{env}
+
+	tbl, err := s.toTable(env)
+	if err == nil {
+		err = s.L.CallByParam(lua.P{Fn: s.onEvent, NRet: 1, Protect: true}, tbl)
+	}
+	if err != nil {
+		if apiErr, ok := err.(*lua.ApiError); ok {
+			err = fmt.Errorf("%s", apiErr.Object) // without the traceback
+		}
+		fmt.Fprintf(os.Stderr, "script: %s: %v\n", eventName(env), err)
+		return keep
+	}
+	ret := s.L.Get(-1)
+	s.L.Pop(1)
+
+	if eventCategory(env) != "transport" {
+		switch v := ret.(type) {
+		case lua.LBool:
+			if !v {
+				keep = nil
+			}
+		case *lua.LTable:
+			changed, err := s.fromTable(v, env)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "script: on_event(%s) returned %v\n", eventName(env), err)
+			} else {
+				keep = []*trackspb.Envelope{changed}
+			}
+		}
+	}
+	return append(keep, s.emitted...)
+}
+
+func (s *scriptHook) emit(L *lua.LState) int {
+	env, err := s.fromTable(L.CheckTable(1), s.cur)
+	if err != nil {
+		L.RaiseError("emit: %v", err)
+		return 0
+	}
+	s.emitted = append(s.emitted, env)
+	return 0
+}
+
+func (s *scriptHook) toTable(env *trackspb.Envelope) (lua.LValue, error) {
+	b, err := scriptJSON.Marshal(env)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	if _, ok := m["timestamp"]; !ok {
+		m["timestamp"] = 0.0
+	}
+	m["event"] = eventName(env)
+	return toLua(s.L, m), nil
+}
+
+// fromTable builds an envelope from a script's table. The timestamp and
+// stream id default to those of from.
+func (s *scriptHook) fromTable(t *lua.LTable, from *trackspb.Envelope) (*trackspb.Envelope, error) {
+	m, ok := fromLua(t).(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("a list, not an envelope")
+	}
+	delete(m, "event")
+	if _, ok := m["timestamp"]; !ok {
+		m["timestamp"] = from.GetTimestamp()
+	}
+	if _, ok := m["stream_id"]; !ok && from.GetStreamId() != "" {
+		m["stream_id"] = from.GetStreamId()
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	env := &trackspb.Envelope{}
+	if err := protojson.Unmarshal(b, env); err != nil {
+		return nil, err
+	}
+	if env.Event == nil {
+		return nil, fmt.Errorf("an envelope without an event")
+	}
+	return env, nil
+}
+
+func (s *scriptHook) close() {
+	s.L.Close()
+}
+
+func toLua(L *lua.LState, v any) lua.LValue {
+	switch v := v.(type) {
+	case map[string]any:
+		t := L.NewTable()
+		for k, e := range v {
+			t.RawSetString(k, toLua(L, e))
+		}
+		return t
+	case []any:
+		t := L.NewTable()
+		for _, e := range v {
+			t.Append(toLua(L, e))
+		}
+		return t
+	case string:
+		return lua.LString(v)
+	case float64:
+		return lua.LNumber(v)
+	case bool:
+		return lua.LBool(v)
+	}
+	return lua.LNil
+}
+
+// fromLua converts a Lua value for JSON encoding. Tables with a sequence
+// part are lists, others (including empty ones, as for track.end) objects.
+func fromLua(v lua.LValue) any {
+	switch v := v.(type) {
+	case *lua.LTable:
+		if n := v.Len(); n > 0 {
+			list := make([]any, 0, n)
+			for i := 1; i <= n; i++ {
+				list = append(list, fromLua(v.RawGetInt(i)))
+			}
+			return list
+		}
+		m := make(map[string]any)
+		v.ForEach(func(k, e lua.LValue) {
+			if ks, ok := k.(lua.LString); ok {
+				m[string(ks)] = fromLua(e)
+			}
+		})
+		return m
+	case lua.LString:
+		return string(v)
+	case lua.LNumber:
+		return float64(v)
+	case lua.LBool:
+		return bool(v)
+	}
+	return nil
+}
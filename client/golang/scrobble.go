@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Scrobbling (-scrobble): finished tracks are submitted as listens to
+// Last.fm or ListenBrainz, with the artist, title and album from
+// -track-metadata or -track-tags. Both services follow the same rules for
+// what counts as a listen, applied here to the track's duration and how far
+// it got: a track must be longer than 30 seconds and have played for half
+// its duration or four minutes, whichever comes first. A track that reached
+// track.end played in full; one aborted or cut short by the receiver
+// stopping, up to its last event. Tracks
+// without a known artist are left out, since a filename is not a listen
+// either service could match. Submissions are sent in the background, one
+// at a time; failures are printed.
+const (
+	scrobbleMinDuration = 30.0
+	scrobbleMaxPlayed   = 240.0
+	scrobbleTimeout     = 10 * time.Second
+	scrobbleQueue       = 32
+
+	lastfmAPI              = "https://ws.audioscrobbler.com/2.0/"
+	defaultListenBrainzAPI = "https://api.listenbrainz.org"
+)
+
+type scrobbleConfig struct {
+	services        string // comma-separated: lastfm, listenbrainz
+	lastfmKey       string
+	lastfmSecret    string
+	lastfmSession   string
+	listenBrainzURL string
+	listenBrainzKey string
+}
+
+// scrobble is one listen to submit.
+type scrobble struct {
+	meta     trackMeta
+	started  time.Time
+	duration float64
+}
+
+type scrobbler struct {
+	cfg          scrobbleConfig
+	lastfm       bool
+	listenBrainz bool
+	client       *http.Client
+	dropped      atomic.Int64
+
+	queue chan scrobble
+	done  chan struct{}
+}
+
+func newScrobbler(cfg scrobbleConfig) (*scrobbler, error) {
+	s := &scrobbler{
+		cfg:    cfg,
+		client: &http.Client{Timeout: scrobbleTimeout},
+		queue:  make(chan scrobble, scrobbleQueue),
+		done:   make(chan struct{}),
+	}
+	for _, name := range strings.Split(cfg.services, ",") {
+		switch strings.TrimSpace(name) {
+		case "lastfm":
+			if cfg.lastfmKey == "" || cfg.lastfmSecret == "" || cfg.lastfmSession == "" {
+				return nil, fmt.Errorf("lastfm needs -lastfm-key, -lastfm-secret and -lastfm-session")
+			}
+			s.lastfm = true
+		case "listenbrainz":
+			if cfg.listenBrainzKey == "" {
+				return nil, fmt.Errorf("listenbrainz needs -listenbrainz-token")
+			}
+			u, err := url.Parse(cfg.listenBrainzURL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("invalid -listenbrainz-url %q", cfg.listenBrainzURL)
+			}
+			s.listenBrainz = true
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown service %q (want lastfm or listenbrainz)", name)
+		}
+	}
+	if !s.lastfm && !s.listenBrainz {
+		return nil, fmt.Errorf("no service given")
+	}
+	if trackMetadata == nil {
+		fmt.Fprintln(os.Stderr, "scrobble: without -track-metadata or -track-tags no track has an artist; nothing will be scrobbled")
+	}
+	go s.run()
+	return s, nil
+}
+
+// trackPlayed is how much of the track was heard, in seconds.
+func trackPlayed(d *trackData) float64 {
+	if d.counts["track.end"] == 0 {
+		return d.end
+	}
+	return d.duration()
+}
+
+// countsAsListen reports whether d counts as a listen.
+func countsAsListen(d *trackData) bool {
+	dur := d.duration()
+	return dur > scrobbleMinDuration && trackPlayed(d) >= min(dur/2, scrobbleMaxPlayed)
+}
+
+// add queues a finished track if it counts as a listen.
+func (s *scrobbler) add(d *trackData) {
+	if !countsAsListen(d) {
+		return
+	}
+	if d.meta.Artist == "" {
+		if trackMetadata != nil {
+			fmt.Fprintf(os.Stderr, "scrobble: %s: no artist known; not scrobbled\n", d.start.GetFilename())
+		}
+		return
+	}
+	sc := scrobble{meta: d.meta, started: d.started, duration: d.duration()}
+	sc.meta.Title = d.meta.displayTitle(d.start)
+	select {
+	case s.queue <- sc:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+func (s *scrobbler) status() sinkStatus {
+	return sinkStatus{Name: "scrobble", Policy: "drop-newest", Depth: len(s.queue), Capacity: cap(s.queue), Dropped: int(s.dropped.Load())}
+}
+
+func (s *scrobbler) run() {
+	defer close(s.done)
+	for sc := range s.queue {
+		if s.lastfm {
+			if err := s.submitLastfm(sc); err != nil {
+				fmt.Fprintf(os.Stderr, "scrobble: last.fm: %s - %s: %v\n", sc.meta.Artist, sc.meta.Title, err)
+			}
+		}
+		if s.listenBrainz {
+			if err := s.submitListenBrainz(sc); err != nil {
+				fmt.Fprintf(os.Stderr, "scrobble: listenbrainz: %s - %s: %v\n", sc.meta.Artist, sc.meta.Title, err)
+			}
+		}
+	}
+}
+
+// submitLastfm calls track.scrobble, signed with the API secret.
+func (s *scrobbler) submitLastfm(sc scrobble) error {
+	v := url.Values{
+		"method":    {"track.scrobble"},
+		"api_key":   {s.cfg.lastfmKey},
+		"sk":        {s.cfg.lastfmSession},
+		"artist":    {sc.meta.Artist},
+		"track":     {sc.meta.Title},
+		"timestamp": {strconv.FormatInt(sc.started.Unix(), 10)},
+		"duration":  {strconv.Itoa(int(sc.duration))},
+	}
+	if sc.meta.Album != "" {
+		v.Set("album", sc.meta.Album)
+	}
+	v.Set("api_sig", lastfmSignature(v, s.cfg.lastfmSecret))
+	v.Set("format", "json")
+
+	resp, err := s.client.PostForm(lastfmAPI, v)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Error     int    `json:"error"`
+		Message   string `json:"message"`
+		Scrobbles struct {
+			Attr struct {
+				Ignored int `json:"ignored"`
+			} `json:"@attr"`
+		} `json:"scrobbles"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	if result.Error != 0 {
+		return fmt.Errorf("error %d: %s", result.Error, result.Message)
+	}
+	if result.Scrobbles.Attr.Ignored > 0 {
+		return fmt.Errorf("ignored by Last.fm")
+	}
+	return nil
+}
+
+// lastfmSignature is the md5 of the parameters, sorted by name and
+// concatenated as namevalue, followed by the secret.
+func lastfmSignature(v url.Values, secret string) string {
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteString(v.Get(k))
+	}
+	b.WriteString(secret)
+	sum := md5.Sum([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+type listenBrainzListen struct {
+	ListenType string                    `json:"listen_type"`
+	Payload    []listenBrainzPayloadItem `json:"payload"`
+}
+
+type listenBrainzPayloadItem struct {
+	ListenedAt    int64                     `json:"listened_at"`
+	TrackMetadata listenBrainzTrackMetadata `json:"track_metadata"`
+}
+
+type listenBrainzTrackMetadata struct {
+	ArtistName     string         `json:"artist_name"`
+	TrackName      string         `json:"track_name"`
+	ReleaseName    string         `json:"release_name,omitempty"`
+	AdditionalInfo map[string]any `json:"additional_info"`
+}
+
+func (s *scrobbler) submitListenBrainz(sc scrobble) error {
+	body, err := json.Marshal(listenBrainzListen{
+		ListenType: "single",
+		Payload: []listenBrainzPayloadItem{{
+			ListenedAt: sc.started.Unix(),
+			TrackMetadata: listenBrainzTrackMetadata{
+				ArtistName:  sc.meta.Artist,
+				TrackName:   sc.meta.Title,
+				ReleaseName: sc.meta.Album,
+				AdditionalInfo: map[string]any{
+					"duration_ms":       int(sc.duration * 1000),
+					"submission_client": "tracks",
+				},
+			},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(s.cfg.listenBrainzURL, "/")+"/1/submit-listens", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+s.cfg.listenBrainzKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&e) == nil && e.Error != "" {
+			return fmt.Errorf("server returned %s: %s", resp.Status, e.Error)
+		}
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	return nil
+}
+
+// close submits the listens still queued and stops.
+func (s *scrobbler) close() {
+	close(s.queue)
+	<-s.done
+	if n := s.dropped.Load(); n > 0 {
+		fmt.Fprintf(os.Stderr, "scrobble: %d listens dropped (queue full)\n", n)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"math"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Section changes (-derive=section.change). The novelty stream is smoothed
+// with a centred moving average, and a local maximum of the smoothed curve
+// becomes a section change when it stands out from the preceding
+// sectionWindow of novelty by more than the threshold, in standard
+// deviations. Peaks are only known once the frames after them have
+// arrived, so section changes are reported about a kernel late, stamped at
+// the peak. The first sectionWarmup of a track only builds up statistics.
+const (
+	sectionWindow = 30.0 // seconds of smoothed novelty for the local statistics
+	sectionWarmup = 10.0 // seconds of history needed before judging peaks
+)
+
+type sectionDeriver struct {
+	cfg     *deriveConfig
+	streams map[string]*sectionState
+}
+
+type sectionState struct {
+	raw    []point // recent novelty frames
+	smooth []point // smoothed novelty, a half kernel behind raw
+	last   float64 // time of the last section change
+}
+
+func newSectionDeriver(cfg *deriveConfig) *sectionDeriver {
+	return &sectionDeriver{cfg: cfg, streams: make(map[string]*sectionState)}
+}
+
+func (s *sectionDeriver) derive(env *trackspb.Envelope) []*trackspb.Envelope {
+	stream := env.GetStreamId()
+	switch e := env.Event.(type) {
+	case *trackspb.Envelope_TrackStart, *trackspb.Envelope_TimelineReset:
+		delete(s.streams, stream)
+	case *trackspb.Envelope_Novelty:
+		st := s.streams[stream]
+		if st == nil {
+			st = &sectionState{last: math.Inf(-1)}
+			s.streams[stream] = st
+		}
+		if p, conf, ok := st.push(point{env.GetTimestamp(), e.Novelty.GetValue()}, s.cfg); ok {
+			return []*trackspb.Envelope{derivedEnvelopeAt(env, p.t, &trackspb.SectionChange{
+				Confidence: conf,
+				Novelty:    p.v,
+			})}
+		}
+	}
+	return nil
+}
+
+// push adds a novelty frame and reports a newly confirmed peak.
+func (st *sectionState) push(p point, cfg *deriveConfig) (point, float64, bool) {
+	h := max(cfg.sectionKernel/2, 1)
+	st.raw = append(st.raw, p)
+	if len(st.raw) > 2*h+1 {
+		st.raw = st.raw[len(st.raw)-(2*h+1):]
+	}
+	if len(st.raw) < 2*h+1 {
+		return point{}, 0, false
+	}
+	sum := 0.0
+	for _, r := range st.raw {
+		sum += r.v
+	}
+	st.smooth = append(st.smooth, point{st.raw[h].t, sum / float64(len(st.raw))})
+
+	// Keep the statistics window plus the frames after the candidate.
+	for len(st.smooth) > 2*h+1 && st.smooth[0].t < st.smooth[len(st.smooth)-1].t-sectionWindow {
+		st.smooth = st.smooth[1:]
+	}
+
+	c := len(st.smooth) - 1 - h
+	if c < h {
+		return point{}, 0, false
+	}
+	peak := st.smooth[c]
+	for i := c - h; i <= c+h; i++ {
+		// Ties resolve to the first frame of a plateau.
+		if (i < c && st.smooth[i].v >= peak.v) || (i > c && st.smooth[i].v > peak.v) {
+			return point{}, 0, false
+		}
+	}
+	if peak.t-st.smooth[0].t < sectionWarmup || peak.t-st.last < cfg.sectionMinGap {
+		return point{}, 0, false
+	}
+
+	var mean, sq float64
+	n := float64(c + 1)
+	for _, q := range st.smooth[:c+1] {
+		mean += q.v
+	}
+	mean /= n
+	for _, q := range st.smooth[:c+1] {
+		sq += (q.v - mean) * (q.v - mean)
+	}
+	std := math.Sqrt(sq / n)
+	if std == 0 {
+		return point{}, 0, false
+	}
+	z := (peak.v - mean) / std
+	if z <= cfg.sectionThreshold {
+		return point{}, 0, false
+	}
+	st.last = peak.t
+	// 0 at the threshold, approaching 1 for peaks far above it.
+	return peak, 1 - cfg.sectionThreshold/z, true
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync/atomic"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Serial output (-serial), for microcontrollers on a USB serial port: each
+// event matching -serial-on is written as a 7-byte frame, small enough to
+// parse on an Arduino without a protobuf library:
+//
+//	byte 0     0xA5, start of frame
+//	byte 1     event, its field number in Envelope (beat 20, downbeat 22, ...)
+//	bytes 2-5  the event's value as a little-endian float32 (energy, onset
+//	           strength, bpm, ...), 0 for events without one
+//	byte 6     XOR of bytes 1-5
+//
+// The port is set to raw 8N1 at -serial-baud. Frames are written in the
+// background; if the port cannot keep up, new frames are dropped rather
+// than delaying the beats after them.
+const (
+	serialSync     = 0xA5
+	serialFrameLen = 7
+	serialQueue    = 256
+)
+
+type serialSink struct {
+	port    io.WriteCloser
+	rules   []eventRule
+	dropped atomic.Int64
+
+	queue chan [serialFrameLen]byte
+	done  chan struct{}
+}
+
+func newSerialSink(path string, baud int, on string) (*serialSink, error) {
+	rules, err := parseEventRules(on)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("no events given (use -serial-on)")
+	}
+	port, err := openSerialPort(path, baud)
+	if err != nil {
+		return nil, err
+	}
+	s := &serialSink{
+		port:  port,
+		rules: rules,
+		queue: make(chan [serialFrameLen]byte, serialQueue),
+		done:  make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// serialFrame encodes env as a frame.
+func serialFrame(env *trackspb.Envelope) [serialFrameLen]byte {
+	var f [serialFrameLen]byte
+	f[0] = serialSync
+	f[1] = byte(eventField(env))
+	v, _ := eventValue(env)
+	binary.LittleEndian.PutUint32(f[2:6], math.Float32bits(float32(v)))
+	for _, b := range f[1:6] {
+		f[6] ^= b
+	}
+	return f
+}
+
+func (s *serialSink) handle(env *trackspb.Envelope) {
+	for _, r := range s.rules {
+		if r.matches(env) {
+			select {
+			case s.queue <- serialFrame(env):
+			default:
+				s.dropped.Add(1)
+			}
+			return
+		}
+	}
+}
+
+func (s *serialSink) status() sinkStatus {
+	return sinkStatus{Name: "serial", Policy: "drop-newest", Depth: len(s.queue), Capacity: cap(s.queue), Dropped: int(s.dropped.Load())}
+}
+
+func (s *serialSink) run() {
+	defer close(s.done)
+	failing := false
+	for f := range s.queue {
+		_, err := s.port.Write(f[:])
+		// Report a failing port once, not once per beat.
+		if err != nil && !failing {
+			fmt.Fprintf(os.Stderr, "serial: %v\n", err)
+		}
+		failing = err != nil
+	}
+}
+
+// close writes the frames still queued and closes the port.
+func (s *serialSink) close() {
+	close(s.queue)
+	<-s.done
+	s.port.Close()
+	if n := s.dropped.Load(); n > 0 {
+		fmt.Fprintf(os.Stderr, "serial: %d frames dropped (port too slow)\n", n)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"io"
+)
+
+func openSerialPort(path string, baud int) (io.WriteCloser, error) {
+	return nil, errors.New("serial output is only supported on Linux")
+}
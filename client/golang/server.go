@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"google.golang.org/protobuf/encoding/protodelim"
+)
+
+// Stream server protocol (-serve). A client connects over TCP and sends one
+// line:
+//
+//	SUBSCRIBE <events> [<event>>=<min> ...] [interval=<seconds>] [stream=<id>] [token=<token>]
+//
+// <events> is a comma-separated list of event names and categories, or "*"
+// for everything. Each <event>>=<min> drops events of that type whose main
+// value (see eventValue) is below <min>; interval drops events arriving less
+// than the given number of seconds (stream time) after the previous event of
+// the same type; stream keeps only envelopes with that stream id. Transport
+// events are always delivered for the selected stream. token is the client's
+// access token, when the server requires one (see auth.go).
+//
+// The server answers "OK\n" or "ERR <reason>\n". After OK it streams
+// varint-length-delimited Envelopes until either side closes.
+const (
+	serverHandshakeTimeout = 10 * time.Second
+	serverWriteTimeout     = 5 * time.Second
+	serverClientQueue      = 256
+)
+
+type subscription struct {
+	all        bool
+	events     map[string]bool
+	categories map[string]bool
+	thresholds map[string]float64
+	interval   float64
+	stream     string
+	token      string
+	last       map[string]float64
+}
+
+func parseSubscription(line string) (*subscription, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || !strings.EqualFold(fields[0], "SUBSCRIBE") {
+		return nil, fmt.Errorf("expected SUBSCRIBE <events> [options]")
+	}
+
+	sub := &subscription{
+		events:     make(map[string]bool),
+		categories: make(map[string]bool),
+		thresholds: make(map[string]float64),
+		last:       make(map[string]float64),
+	}
+	for _, name := range strings.Split(fields[1], ",") {
+		switch {
+		case name == "*":
+			sub.all = true
+		case isCategory(name):
+			sub.categories[name] = true
+		case isEventName(name):
+			sub.events[name] = true
+		default:
+			return nil, fmt.Errorf("unknown event or category %q", name)
+		}
+	}
+
+	for _, opt := range fields[2:] {
+		if name, val, ok := strings.Cut(opt, ">="); ok {
+			if !isEventName(name) {
+				return nil, fmt.Errorf("unknown event %q in threshold", name)
+			}
+			min, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid threshold %q", opt)
+			}
+			sub.thresholds[name] = min
+			continue
+		}
+		if val, ok := strings.CutPrefix(opt, "interval="); ok {
+			iv, err := strconv.ParseFloat(val, 64)
+			if err != nil || iv < 0 {
+				return nil, fmt.Errorf("invalid interval %q", val)
+			}
+			sub.interval = iv
+			continue
+		}
+		if val, ok := strings.CutPrefix(opt, "stream="); ok {
+			sub.stream = val
+			continue
+		}
+		if val, ok := strings.CutPrefix(opt, "token="); ok {
+			sub.token = val
+			continue
+		}
+		return nil, fmt.Errorf("unknown option %q", opt)
+	}
+	return sub, nil
+}
+
+// matches reports whether env should be sent to the subscriber. It records
+// delivery times, so it must be called once per event.
+func (s *subscription) matches(env *trackspb.Envelope) bool {
+	if s.stream != "" && env.GetStreamId() != s.stream {
+		return false
+	}
+	if eventCategory(env) == "transport" {
+		return true
+	}
+	name := eventName(env)
+	if !s.all && !s.events[name] && !s.categories[eventCategory(env)] {
+		return false
+	}
+	if min, ok := s.thresholds[name]; ok {
+		if v, ok := eventValue(env); ok && v < min {
+			return false
+		}
+	}
+	if s.interval > 0 {
+		key := env.GetStreamId() + "/" + name
+		ts := env.GetTimestamp()
+		if last, ok := s.last[key]; ok && ts >= last && ts-last < s.interval {
+			return false
+		}
+		s.last[key] = ts
+	}
+	return true
+}
+
+type streamClient struct {
+	conn  net.Conn
+	sub   *subscription
+	grant *grant
+	queue *eventQueue
+	feed  *feedStats
+}
+
+// streamServer relays received events to TCP subscribers, each with its own
+// filter and priority-aware send queue so a slow client never holds up the
+// others.
+type streamServer struct {
+	ln    net.Listener
+	prios *priorityMap
+	auth  *authenticator
+
+	mu      sync.Mutex
+	clients map[*streamClient]struct{}
+	wg      sync.WaitGroup
+}
+
+func newStreamServer(addr string, prios *priorityMap, auth *authenticator) (*streamServer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("serve: %w", err)
+	}
+	s := &streamServer{ln: ln, prios: prios, auth: auth, clients: make(map[*streamClient]struct{})}
+	go s.acceptLoop()
+	return s, nil
+}
+
+func (s *streamServer) acceptLoop() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+func (s *streamServer) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(serverHandshakeTimeout))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return
+	}
+	sub, err := parseSubscription(line)
+	if err != nil {
+		fmt.Fprintf(conn, "ERR %v\n", err)
+		return
+	}
+	g, err := s.auth.authorize(sub.token, conn.RemoteAddr().String())
+	if err != nil {
+		fmt.Fprintf(conn, "ERR %v\n", err)
+		return
+	}
+	if sub.stream != "" && !g.allows(sub.stream) {
+		fmt.Fprintf(conn, "ERR stream %q not allowed for this token\n", sub.stream)
+		return
+	}
+	release, err := s.auth.acquire(g)
+	if err != nil {
+		fmt.Fprintf(conn, "ERR %v\n", err)
+		return
+	}
+	defer release()
+	conn.SetReadDeadline(time.Time{})
+	if _, err := conn.Write([]byte("OK\n")); err != nil {
+		return
+	}
+
+	q := newEventQueue(serverClientQueue)
+	c := &streamClient{conn: conn, sub: sub, grant: g, queue: q,
+		feed: newFeedStats("serve", conn.RemoteAddr().String(), g, subscriptionFilter(line), q)}
+	s.mu.Lock()
+	s.clients[c] = struct{}{}
+	s.mu.Unlock()
+	defer s.remove(c)
+
+	w := bufio.NewWriter(conn)
+	for env := c.queue.pop(); env != nil; env = c.queue.pop() {
+		conn.SetWriteDeadline(time.Now().Add(serverWriteTimeout))
+		n, err := protodelim.MarshalTo(w, env)
+		if err != nil {
+			return
+		}
+		if err := w.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "serve: dropping client %s: %v\n", conn.RemoteAddr(), err)
+			return
+		}
+		c.feed.count(n, time.Now())
+	}
+}
+
+func (s *streamServer) remove(c *streamClient) {
+	s.mu.Lock()
+	delete(s.clients, c)
+	s.mu.Unlock()
+	c.queue.close()
+}
+
+// publish queues env for every subscriber whose filter matches.
+func (s *streamServer) publish(env *trackspb.Envelope) {
+	prio := s.prios.classify(env)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		if c.grant.allows(env.GetStreamId()) && c.sub.matches(env) {
+			c.queue.push(env, prio)
+		}
+	}
+}
+
+func (s *streamServer) clientStatuses(now time.Time) []clientStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var st []clientStatus
+	for c := range s.clients {
+		st = append(st, c.feed.status(now))
+	}
+	return st
+}
+
+// close stops accepting clients and lets connected ones drain their queues,
+// waiting at most serverWriteTimeout before cutting them off.
+func (s *streamServer) close() {
+	s.ln.Close()
+	s.mu.Lock()
+	for c := range s.clients {
+		c.queue.close()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(serverWriteTimeout):
+		s.mu.Lock()
+		for c := range s.clients {
+			c.conn.Close()
+		}
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,156 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Shared-memory ring written by the sender with --transport shm. The object
+// lives in /dev/shm and starts with a 64-byte header:
+//
+//	bytes 0-7    magic "TRKSHM01"
+//	bytes 8-15   ring capacity in bytes (little-endian)
+//	bytes 16-23  total bytes written (little-endian, atomically published)
+//
+// Records follow as [u32 length][payload], each padded to 8 bytes. A length
+// of 0xFFFFFFFF marks a wrap back to the start of the data area. There is a
+// single writer and any number of readers; readers that fall more than half
+// the ring behind skip ahead and count a drop.
+const (
+	shmMagic       = "TRKSHM01"
+	shmHeaderLen   = 64
+	shmOffCapacity = 8
+	shmOffWritePos = 16
+	shmWrap        = 0xFFFFFFFF
+
+	// Polls without sleeping before backing off to shmIdleSleep.
+	shmSpinPolls = 200
+	shmIdleSleep = 50 * time.Microsecond
+)
+
+type shmSource struct {
+	label    string
+	mem      []byte
+	data     []byte
+	capacity uint64
+	pos      uint64
+	buf      []byte
+	dropped  int
+	closed   atomic.Bool
+}
+
+func newSHMSource(name string) (*shmSource, error) {
+	path := filepath.Join("/dev/shm", strings.TrimPrefix(name, "/"))
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("shm open: %w", err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("shm stat: %w", err)
+	}
+	if fi.Size() < shmHeaderLen {
+		return nil, fmt.Errorf("shm %s: too small (%d bytes)", name, fi.Size())
+	}
+	mem, err := syscall.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("shm mmap: %w", err)
+	}
+
+	capacity := binary.LittleEndian.Uint64(mem[shmOffCapacity:])
+	if string(mem[:8]) != shmMagic || capacity == 0 || uint64(len(mem)) < shmHeaderLen+capacity {
+		syscall.Munmap(mem)
+		return nil, fmt.Errorf("shm %s: not a TRACKS ring", name)
+	}
+
+	s := &shmSource{
+		label:    "shm:" + name,
+		mem:      mem,
+		data:     mem[shmHeaderLen : shmHeaderLen+capacity],
+		capacity: capacity,
+	}
+	// Join at the current write position, like joining a multicast group
+	// mid-stream.
+	s.pos = s.writePos()
+	return s, nil
+}
+
+func (s *shmSource) writePos() uint64 {
+	return atomic.LoadUint64((*uint64)(unsafe.Pointer(&s.mem[shmOffWritePos])))
+}
+
+func (s *shmSource) ReadPacket() ([]byte, string, error) {
+	idle := 0
+	for {
+		if s.closed.Load() {
+			if s.mem != nil {
+				syscall.Munmap(s.mem)
+				s.mem, s.data = nil, nil
+			}
+			return nil, "", net.ErrClosed
+		}
+
+		wp := s.writePos()
+		switch {
+		case wp < s.pos:
+			s.pos = 0 // sender restarted and reset the ring
+		case wp-s.pos > s.capacity/2:
+			s.dropped++
+			s.pos = wp
+		}
+		if s.pos == wp {
+			idle++
+			if idle < shmSpinPolls {
+				runtime.Gosched()
+			} else {
+				time.Sleep(shmIdleSleep)
+			}
+			continue
+		}
+		idle = 0
+
+		off := s.pos % s.capacity
+		n := uint64(binary.LittleEndian.Uint32(s.data[off:]))
+		if n == shmWrap {
+			s.pos += s.capacity - off
+			continue
+		}
+		if off+4+n > s.capacity {
+			s.dropped++
+			s.pos = s.writePos() // torn or corrupt record: resync
+			continue
+		}
+
+		start := s.pos
+		s.buf = append(s.buf[:0], s.data[off+4:off+4+n]...)
+		s.pos += (4 + n + 7) &^ 7
+
+		// The writer may have lapped us while we copied.
+		if s.writePos()-start > s.capacity/2 {
+			s.dropped++
+			s.pos = s.writePos()
+			continue
+		}
+		return s.buf, s.label, nil
+	}
+}
+
+// Close stops the reader; the mapping is released by the next ReadPacket so
+// an in-flight read never touches unmapped memory.
+func (s *shmSource) Close() error {
+	s.closed.Store(true)
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+type shmSource struct {
+	dropped int
+}
+
+func newSHMSource(name string) (*shmSource, error) {
+	return nil, errors.New("the shared-memory transport is only supported on Linux")
+}
+
+func (s *shmSource) ReadPacket() ([]byte, string, error) {
+	return nil, "", errors.New("shared memory unavailable")
+}
+
+func (s *shmSource) Close() error { return nil }
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Sinks that write to files or devices (-out, -now-playing, -midi) each run
+// on a goroutine of their own behind a queue of -sink-queue events, so a
+// slow disk or device holds up only that sink. What happens when a sink's
+// queue is full is its -sink-policy:
+//
+//	block        wait for room (the default; nothing is lost, but event
+//	             handling stalls until the sink catches up)
+//	priority     drop by priority class, like the receive queue
+//	drop-oldest  drop the oldest queued event
+//	drop-newest  drop the incoming event
+//
+// Reception never waits for a sink: it has its own queue (-queue), which
+// drops by priority if handling falls behind. The network sinks (-webhook,
+// -obs) already queue and drop on their own. The depth and drops of every
+// sink are reported with the traffic statistics (-stats, /stats).
+const defaultSinkQueue = 1024
+
+var sinkNames = []string{"out", "now-playing", "midi"}
+
+// sinkStatus is one row of the sink table in the stats.
+type sinkStatus struct {
+	Name     string `json:"name"`
+	Policy   string `json:"policy"`
+	Depth    int    `json:"depth"`
+	Capacity int    `json:"capacity"`
+	Dropped  int    `json:"dropped"`
+}
+
+// sinkReporter is a sink with a queue of its own.
+type sinkReporter interface {
+	status() sinkStatus
+}
+
+// sinkPolicies holds -sink-policy: a policy for all sinks and overrides
+// per sink.
+type sinkPolicies struct {
+	all  queuePolicy
+	sink map[string]queuePolicy
+}
+
+// parseSinkPolicies parses "policy" or "sink=policy,...", or both, e.g.
+// "drop-oldest,out=block".
+func parseSinkPolicies(spec string) (sinkPolicies, error) {
+	p := sinkPolicies{all: policyBlock, sink: make(map[string]queuePolicy)}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, policy, ok := strings.Cut(item, "=")
+		if !ok {
+			policy = name
+		}
+		pol, err := parseQueuePolicy(strings.TrimSpace(policy))
+		if err != nil {
+			return p, err
+		}
+		if !ok {
+			p.all = pol
+			continue
+		}
+		name = strings.TrimSpace(name)
+		found := false
+		for _, n := range sinkNames {
+			found = found || n == name
+		}
+		if !found {
+			return p, fmt.Errorf("unknown sink %q (want %s)", name, strings.Join(sinkNames, ", "))
+		}
+		p.sink[name] = pol
+	}
+	return p, nil
+}
+
+func (p sinkPolicies) get(name string) queuePolicy {
+	if pol, ok := p.sink[name]; ok {
+		return pol
+	}
+	return p.all
+}
+
+type sink struct {
+	name   string
+	queue  *eventQueue
+	prios  *priorityMap
+	handle func(env *trackspb.Envelope, received time.Time)
+	done   chan struct{}
+}
+
+func newSink(name string, size int, policy queuePolicy, prios *priorityMap, handle func(*trackspb.Envelope, time.Time)) *sink {
+	q := newEventQueue(size)
+	q.policy = policy
+	s := &sink{name: name, queue: q, prios: prios, handle: handle, done: make(chan struct{})}
+	go s.run()
+	return s
+}
+
+func (s *sink) run() {
+	defer close(s.done)
+	for {
+		env, received := s.queue.popAt()
+		if env == nil {
+			return
+		}
+		s.handle(env, received)
+	}
+}
+
+func (s *sink) push(env *trackspb.Envelope, received time.Time) {
+	s.queue.pushAt(env, s.prios.classify(env), received)
+}
+
+func (s *sink) dropped() int {
+	n := 0
+	for _, d := range s.queue.droppedCounts() {
+		n += d
+	}
+	return n
+}
+
+func (s *sink) status() sinkStatus {
+	return sinkStatus{
+		Name:     s.name,
+		Policy:   s.queue.policy.String(),
+		Depth:    s.queue.len(),
+		Capacity: s.queue.limit,
+		Dropped:  s.dropped(),
+	}
+}
+
+// close lets the sink handle what is queued, then stops it. The sink's
+// output itself is closed by the caller.
+func (s *sink) close() {
+	s.queue.close()
+	<-s.done
+	if n := s.dropped(); n > 0 {
+		fmt.Fprintf(os.Stderr, "%s: %d event(s) dropped (sink queue full)\n", s.name, n)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Standard MIDI File export (-midi-file). Each finished track becomes a
+// format 1 file with a tempo map from tempo.change, time signatures from
+// the track's meter (see meter.go), the melody quantized to sixteenth
+// notes, and a click track from beat and downbeat events, so the analysis
+// can be lined up against the audio in a DAW.
+const (
+	smfPPQ       = 480
+	smfQuantum   = smfPPQ / 4 // sixteenth note
+	smfClickLen  = smfPPQ / 8
+	smfClickBeat = 77 // GM low wood block
+	smfClickDown = 76 // GM high wood block
+	smfDrums     = 9  // GM percussion channel
+)
+
+type smfEvent struct {
+	tick int
+	data []byte
+}
+
+// tempoMap converts stream seconds to ticks through piecewise-constant
+// tempo segments.
+type tempoMap struct {
+	times []float64 // segment start, seconds
+	ticks []float64 // segment start, ticks
+	bpms  []float64
+}
+
+func newTempoMap(changes []point) *tempoMap {
+	m := &tempoMap{times: []float64{0}, ticks: []float64{0}, bpms: []float64{120}}
+	for i, c := range changes {
+		if c.v <= 0 {
+			continue
+		}
+		if i == 0 || c.t <= 0 {
+			// The first tempo also applies before it was detected.
+			m.bpms[0] = c.v
+			continue
+		}
+		last := len(m.times) - 1
+		m.ticks = append(m.ticks, m.ticks[last]+(c.t-m.times[last])*m.bpms[last]/60*smfPPQ)
+		m.times = append(m.times, c.t)
+		m.bpms = append(m.bpms, c.v)
+	}
+	return m
+}
+
+func (m *tempoMap) tick(t float64) int {
+	i := sort.SearchFloat64s(m.times, t)
+	if i == len(m.times) || m.times[i] > t {
+		i--
+	}
+	i = max(i, 0)
+	return int(math.Round(m.ticks[i] + (t-m.times[i])*m.bpms[i]/60*smfPPQ))
+}
+
+func quantize(tick int) int {
+	return (tick + smfQuantum/2) / smfQuantum * smfQuantum
+}
+
+func writeSMF(path string, d *trackData) error {
+	changes := d.series["tempo.change"]
+	if q := smfQuartersPerBeat(d); q != 1 {
+		// MIDI tempos count quarter notes; tempo.change counts beats.
+		scaled := make([]point, len(changes))
+		for i, c := range changes {
+			scaled[i] = point{c.t, c.v * q}
+		}
+		changes = scaled
+	}
+	tempo := newTempoMap(changes)
+
+	title := filepath.Base(d.start.GetFilename())
+	if d.meta.Title != "" {
+		title = d.meta.heading(d.start)
+	}
+	conductor := []smfEvent{{0, smfMeta(0x03, []byte(title))}}
+	conductor = append(conductor, smfTimeSignatures(d, tempo)...)
+	for i, start := range tempo.ticks {
+		us := uint32(math.Round(60e6 / tempo.bpms[i]))
+		conductor = append(conductor, smfEvent{int(start), smfMeta(0x51, []byte{byte(us >> 16), byte(us >> 8), byte(us)})})
+	}
+
+	tracks := [][]smfEvent{
+		conductor,
+		append([]smfEvent{{0, smfMeta(0x03, []byte("Melody"))}}, smfMelody(d.series["melody"], d.end, tempo)...),
+		append([]smfEvent{{0, smfMeta(0x03, []byte("Click"))}}, smfClick(d, tempo)...),
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	hdr := []byte{'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 1, 0, byte(len(tracks)), smfPPQ >> 8, smfPPQ & 0xff}
+	w.Write(hdr)
+	for _, tr := range tracks {
+		w.Write(smfTrackChunk(tr))
+	}
+	return flushClose(w, f)
+}
+
+// smfTimeSignatures places each meter.change, the first at the start of
+// the file, or the track's mainMeter, or 4/4.
+func smfTimeSignatures(d *trackData, tempo *tempoMap) []smfEvent {
+	meters := d.meters
+	if len(meters) == 0 {
+		meters = []label{{0, mainMeter(d)}}
+	}
+	var events []smfEvent
+	for i, m := range meters {
+		num, den, ok := parseMeter(m.name)
+		if !ok {
+			num, den = 4, 4
+		}
+		tick := 0
+		if i > 0 {
+			tick = quantize(tempo.tick(m.t))
+		}
+		// Metronome clicks per quarter note (24) or, in compound meters, per
+		// dotted quarter.
+		clocks := byte(24)
+		if den == 8 && num%3 == 0 {
+			clocks = 36
+		}
+		events = append(events, smfEvent{tick, smfMeta(0x58, []byte{byte(num), byte(bits.Len(uint(den)) - 1), clocks, 8})})
+	}
+	return events
+}
+
+// smfQuartersPerBeat is how many quarter notes a tracked beat spans: 1.5
+// when a compound meter is tracked in dotted quarters, 0.5 when it is
+// tracked in eighths, 1 otherwise.
+func smfQuartersPerBeat(d *trackData) float64 {
+	num, den, ok := parseMeter(mainMeter(d))
+	m := estimateMeter(eventTimes(d, "beat"), eventTimes(d, "downbeat"), nil)
+	if !ok || den != 8 || m == nil {
+		return 1
+	}
+	switch int(m.GetBeats()) {
+	case num / 3:
+		return 1.5
+	case num:
+		return 0.5
+	}
+	return 1
+}
+
+// smfMelody segments melody frames into notes: consecutive voiced frames on
+// the same semitone form one note, which is then snapped to the grid.
+func smfMelody(frames []point, end float64, tempo *tempoMap) []smfEvent {
+	var events []smfEvent
+	note, from := -1, 0.0
+	emit := func(to float64) {
+		if note < 0 {
+			return
+		}
+		on, off := quantize(tempo.tick(from)), quantize(tempo.tick(to))
+		if off > on {
+			events = append(events,
+				smfEvent{on, []byte{0x90, byte(note), midiVelocity}},
+				smfEvent{off, []byte{0x80, byte(note), 0}})
+		}
+		note = -1
+	}
+	for _, p := range frames {
+		n := -1
+		if p.v > 0 {
+			if f := math.Round(freqToNote(p.v)); f >= 0 && f <= 127 {
+				n = int(f)
+			}
+		}
+		if n != note {
+			emit(p.t)
+			if n >= 0 {
+				note, from = n, p.t
+			}
+		}
+	}
+	emit(end)
+	return events
+}
+
+func smfClick(d *trackData, tempo *tempoMap) []smfEvent {
+	down := make(map[int]bool)
+	for _, p := range d.series["downbeat"] {
+		down[tempo.tick(p.t)] = true
+	}
+	var events []smfEvent
+	click := func(tick, key int) {
+		events = append(events,
+			smfEvent{tick, []byte{0x90 | smfDrums, byte(key), midiVelocity}},
+			smfEvent{tick + smfClickLen, []byte{0x80 | smfDrums, byte(key), 0}})
+	}
+	for _, p := range d.series["beat"] {
+		// A downbeat is also reported as a beat; click it once, accented.
+		if tick := tempo.tick(p.t); !down[tick] {
+			click(tick, smfClickBeat)
+		}
+	}
+	for tick := range down {
+		click(tick, smfClickDown)
+	}
+	return events
+}
+
+func smfMeta(typ byte, data []byte) []byte {
+	b := append([]byte{0xff, typ}, smfVarLen(len(data))...)
+	return append(b, data...)
+}
+
+// smfVarLen encodes n as a MIDI variable-length quantity (big-endian
+// 7-bit groups, unlike protobuf varints).
+func smfVarLen(n int) []byte {
+	b := []byte{byte(n & 0x7f)}
+	for n >>= 7; n > 0; n >>= 7 {
+		b = append([]byte{byte(n&0x7f) | 0x80}, b...)
+	}
+	return b
+}
+
+func smfTrackChunk(events []smfEvent) []byte {
+	// Note-offs sort before note-ons at the same tick so repeated notes
+	// retrigger cleanly.
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].tick != events[j].tick {
+			return events[i].tick < events[j].tick
+		}
+		return events[i].data[0]&0xf0 == 0x80 && events[j].data[0]&0xf0 != 0x80
+	})
+	var body []byte
+	last := 0
+	for _, e := range events {
+		body = append(body, smfVarLen(e.tick-last)...)
+		body = append(body, e.data...)
+		last = e.tick
+	}
+	body = append(body, 0, 0xff, 0x2f, 0) // end of track
+	chunk := []byte{'M', 'T', 'r', 'k', 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(chunk[4:], uint32(len(body)))
+	return append(chunk, body...)
+}
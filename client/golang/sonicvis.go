@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Sonic Visualiser export (-sv). Writes one layer file (.svl) per layer —
+// beats as time instants; chords, keys, segments and structure as regions — which
+// Sonic Visualiser opens with File → Import Annotation Layer.
+var svLayers = []string{"beats", "chords", "keys", "segments", "structure"}
+
+// svLayerPath inserts the layer name into an -sv path: at {layer} if the
+// template has it, otherwise before the extension.
+func svLayerPath(path, layer string) string {
+	if strings.Contains(path, "{layer}") {
+		return strings.ReplaceAll(path, "{layer}", layer)
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + layer + ext
+}
+
+func writeSonicVisualiser(path string, d *trackData) ([]string, error) {
+	sr := int(d.start.GetSampleRate())
+	if sr <= 0 {
+		sr = 44100
+	}
+	dur := d.duration()
+
+	var written []string
+	for _, layer := range svLayers {
+		var regions []annotation
+		instants := false
+		switch layer {
+		case "beats":
+			for _, b := range beatPositions(d) {
+				text := ""
+				if b.pos > 0 {
+					text = fmt.Sprint(b.pos)
+				}
+				regions = append(regions, annotation{b.t, b.t, layer, text})
+			}
+			instants = true
+		case "chords":
+			regions = chordRegions(d)
+		case "keys":
+			regions = labelRegions(d.keys, dur, layer)
+		case "segments":
+			regions = segmentRegions(d)
+		case "structure":
+			regions = structureRegions(d)
+		}
+
+		p := svLayerPath(path, layer)
+		if err := writeSVL(p, layer, sr, dur, regions, instants); err != nil {
+			return written, err
+		}
+		written = append(written, p)
+	}
+	return written, nil
+}
+
+func writeSVL(path, name string, sr int, dur float64, items []annotation, instants bool) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	frame := func(t float64) int { return int(math.Round(t * float64(sr))) }
+	attr := func(s string) string {
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(s))
+		return strings.ReplaceAll(b.String(), `"`, "&quot;")
+	}
+
+	fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(w, `<!DOCTYPE sonic-visualiser>`)
+	fmt.Fprintln(w, `<sv>`)
+	fmt.Fprintln(w, `  <data>`)
+	if instants {
+		fmt.Fprintf(w, "    <model id=\"1\" name=\"%s\" sampleRate=\"%d\" start=\"0\" end=\"%d\" type=\"sparse\" dimensions=\"1\" resolution=\"1\" notifyOnAdd=\"true\" dataset=\"0\" />\n",
+			attr(name), sr, frame(dur))
+		fmt.Fprintln(w, `    <dataset id="0" dimensions="1">`)
+		for _, it := range items {
+			fmt.Fprintf(w, "      <point frame=\"%d\" label=\"%s\" />\n", frame(it.start), attr(it.text))
+		}
+	} else {
+		fmt.Fprintf(w, "    <model id=\"1\" name=\"%s\" sampleRate=\"%d\" start=\"0\" end=\"%d\" type=\"sparse\" dimensions=\"3\" resolution=\"1\" notifyOnAdd=\"true\" dataset=\"0\" subtype=\"region\" valueQuantization=\"0\" minimum=\"0\" maximum=\"%d\" units=\"\" />\n",
+			attr(name), sr, frame(dur), max(len(items)-1, 0))
+		fmt.Fprintln(w, `    <dataset id="0" dimensions="3">`)
+		for i, it := range items {
+			fmt.Fprintf(w, "      <point frame=\"%d\" value=\"%d\" duration=\"%d\" label=\"%s\" />\n",
+				frame(it.start), i, frame(it.end)-frame(it.start), attr(it.text))
+		}
+	}
+	fmt.Fprintln(w, `    </dataset>`)
+	fmt.Fprintln(w, `  </data>`)
+	fmt.Fprintln(w, `  <display>`)
+	layerType := "regions"
+	if instants {
+		layerType = "timeinstants"
+	}
+	fmt.Fprintf(w, "    <layer id=\"2\" type=\"%s\" name=\"%s\" model=\"1\" />\n", layerType, attr(name))
+	fmt.Fprintln(w, `  </display>`)
+	fmt.Fprintln(w, `</sv>`)
+	return flushClose(w, f)
+}
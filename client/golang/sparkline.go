@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"golang.org/x/term"
+)
+
+// Sparklines (-sparkline=loudness,energy,spectral.centroid): a strip chart
+// on the console in place of the event lines, one row per event,
+//
+//	loudness            -14.2 ▃▃▄▅▆▆▇█▇▆▅▅▄▃▃▂▂▃▄▅
+//	spectral.centroid    2180 ▂▂▃▂▅▇▆▄▃▂▂▁▁▂▃▄▃▃▂▂
+//
+// Every -sparkline-interval a column is added with each event's latest
+// value, and the oldest scrolls off the left. Each row is scaled to its
+// own minimum and maximum over the columns on screen. Between tracks the
+// columns are blank. On a terminal the chart is redrawn in place;
+// otherwise every update is appended.
+const (
+	sparkLevels  = "▁▂▃▄▅▆▇█"
+	sparkHistory = 1024 // columns kept, more than any terminal shows
+)
+
+type sparkSeries struct {
+	event  string
+	latest float64 // NaN until a value arrives, and between tracks
+	cols   []float64
+}
+
+type sparklineView struct {
+	out   *os.File
+	tty   bool
+	label int // width of the event name column
+
+	mu     sync.Mutex
+	series []*sparkSeries
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newSparklineView(spec string, interval time.Duration, out *os.File) (*sparklineView, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("-sparkline-interval must be positive")
+	}
+	v := &sparklineView{
+		out:  out,
+		tty:  term.IsTerminal(int(out.Fd())),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !isEventName(name) {
+			return nil, fmt.Errorf("unknown event %q", name)
+		}
+		v.series = append(v.series, &sparkSeries{event: name, latest: math.NaN()})
+		v.label = max(v.label, len(name))
+	}
+	if len(v.series) == 0 {
+		return nil, fmt.Errorf("no events given")
+	}
+	go v.run(interval)
+	return v, nil
+}
+
+// observe records the latest value of the charted events.
+func (v *sparklineView) observe(env *trackspb.Envelope) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if isTrackEnd(env) {
+		for _, s := range v.series {
+			s.latest = math.NaN()
+		}
+		return
+	}
+	name := eventName(env)
+	for _, s := range v.series {
+		if s.event == name {
+			if val, ok := eventValue(env); ok {
+				s.latest = val
+			}
+		}
+	}
+}
+
+func (v *sparklineView) run(interval time.Duration) {
+	defer close(v.done)
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	if v.tty {
+		io.WriteString(v.out, "\033[H\033[2J")
+	}
+	for {
+		select {
+		case <-v.stop:
+			return
+		case <-tick.C:
+			v.draw()
+		}
+	}
+}
+
+func (v *sparklineView) draw() {
+	width := 80
+	if w, _, err := term.GetSize(int(v.out.Fd())); err == nil && w > 0 {
+		width = w
+	}
+	v.mu.Lock()
+	var b strings.Builder
+	if v.tty {
+		b.WriteString("\033[H")
+	}
+	for _, s := range v.series {
+		s.cols = append(s.cols, s.latest)
+		if len(s.cols) > sparkHistory {
+			s.cols = s.cols[len(s.cols)-sparkHistory:]
+		}
+		value := "-"
+		if !math.IsNaN(s.latest) {
+			value = strconv.FormatFloat(s.latest, 'g', 4, 64)
+		}
+		line := fmt.Sprintf("%-*s %8s ", v.label, s.event, value)
+		b.WriteString(line)
+		b.WriteString(renderSparkline(s.cols, width-1-len(line)))
+		if v.tty {
+			b.WriteString("\033[K")
+		}
+		b.WriteString("\n")
+	}
+	v.mu.Unlock()
+	if v.tty {
+		b.WriteString("\033[J")
+	} else {
+		b.WriteString("\n")
+	}
+	io.WriteString(v.out, b.String())
+}
+
+// renderSparkline draws the last width values, scaled to their range;
+// NaN values are blank.
+func renderSparkline(vals []float64, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if len(vals) > width {
+		vals = vals[len(vals)-width:]
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, x := range vals {
+		if !math.IsNaN(x) {
+			lo, hi = min(lo, x), max(hi, x)
+		}
+	}
+	levels := []rune(sparkLevels)
+	var b strings.Builder
+	for _, x := range vals {
+		switch {
+		case math.IsNaN(x):
+			b.WriteByte(' ')
+		case hi == lo:
+			b.WriteRune(levels[len(levels)/2])
+		default:
+			i := int(math.Round((x - lo) / (hi - lo) * float64(len(levels)-1)))
+			b.WriteRune(levels[i])
+		}
+	}
+	return b.String()
+}
+
+func (v *sparklineView) close() {
+	close(v.stop)
+	<-v.done
+}
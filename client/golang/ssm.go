@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Self-similarity matrices (-ssm). Chroma and MFCC frames collected during
+// the track are averaged into equal time bins, and every pair of bins is
+// compared by cosine similarity. Repeated sections show up as bright
+// off-diagonal stripes and section changes as block edges, without
+// re-running the audio analysis.
+const ssmMinPixels = 512 // PNGs are scaled up by whole pixels to at least this size
+
+func parseSSMFeatures(spec string) ([]string, error) {
+	var out []string
+	for _, f := range strings.Split(spec, ",") {
+		f = strings.TrimSpace(f)
+		switch f {
+		case "":
+		case "chroma", "mfcc":
+			out = append(out, f)
+		default:
+			return nil, fmt.Errorf("unknown feature %q (want chroma or mfcc)", f)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no features given")
+	}
+	return out, nil
+}
+
+// featureBins averages the given features of d into at most n equal time
+// bins; see averageFeatures. Bins without frames are nil.
+func featureBins(d *trackData, features []string, n int) (times []float64, bins [][]float64) {
+	dur := d.duration()
+	frames := 0
+	for _, f := range features {
+		frames = max(frames, len(d.features[f]))
+	}
+	n = min(n, frames)
+	if n == 0 || dur <= 0 {
+		return nil, nil
+	}
+	edges := make([]float64, n+1)
+	for i := range edges {
+		edges[i] = dur * float64(i) / float64(n)
+	}
+	return edges[:n], averageFeatures(d, features, edges)
+}
+
+// averageFeatures averages the given features of d over the spans between
+// consecutive edges; frames outside them count towards the first or last
+// span. Each feature is normalized — MFCCs without the energy coefficient
+// and standardized per coefficient — and the per-feature parts are
+// concatenated with unit total length, so dot products are cosine
+// similarities. Spans missing any feature the track has are nil.
+func averageFeatures(d *trackData, features []string, edges []float64) [][]float64 {
+	n := len(edges) - 1
+	if n <= 0 {
+		return nil
+	}
+	var used []string
+	for _, f := range features {
+		if len(d.features[f]) > 0 {
+			used = append(used, f)
+		}
+	}
+	out := make([][]float64, n)
+	weight := 1 / math.Sqrt(float64(len(used)))
+	for _, f := range used {
+		vecs := normalizedFeature(d.features[f], f == "mfcc")
+		dim := len(vecs[0].v)
+		sums := make([][]float64, n)
+		for _, fr := range vecs {
+			if len(fr.v) != dim {
+				continue
+			}
+			i := sort.Search(len(edges), func(i int) bool { return edges[i] > fr.t }) - 1
+			i = min(max(i, 0), n-1)
+			if sums[i] == nil {
+				sums[i] = make([]float64, dim)
+			}
+			for k, x := range fr.v {
+				sums[i][k] += x
+			}
+		}
+		for i, s := range sums {
+			norm := 0.0
+			for _, x := range s {
+				norm += x * x
+			}
+			if norm == 0 {
+				continue
+			}
+			norm = math.Sqrt(norm)
+			for k := range s {
+				s[k] *= weight / norm
+			}
+			out[i] = append(out[i], s...)
+		}
+	}
+
+	// A span missing one of the features can't be compared with the others.
+	want := 0
+	for _, v := range out {
+		want = max(want, len(v))
+	}
+	for i, v := range out {
+		if len(v) != want {
+			out[i] = nil
+		}
+	}
+	return out
+}
+
+type vecFrame struct {
+	t float64
+	v []float64
+}
+
+// normalizedFeature converts frames to float64; for MFCCs it drops the
+// first (energy) coefficient and standardizes each remaining one over the
+// track, so loudness doesn't dominate timbre.
+func normalizedFeature(frames []frame, mfcc bool) []vecFrame {
+	out := make([]vecFrame, 0, len(frames))
+	for _, fr := range frames {
+		v := make([]float64, len(fr.v))
+		for i, x := range fr.v {
+			v[i] = float64(x)
+		}
+		if mfcc && len(v) > 1 {
+			v = v[1:]
+		}
+		out = append(out, vecFrame{fr.t, v})
+	}
+	if !mfcc || len(out) == 0 {
+		return out
+	}
+	dim := len(out[0].v)
+	for k := 0; k < dim; k++ {
+		var mean, sq float64
+		n := 0.0
+		for _, fr := range out {
+			if k < len(fr.v) {
+				mean += fr.v[k]
+				n++
+			}
+		}
+		mean /= n
+		for _, fr := range out {
+			if k < len(fr.v) {
+				sq += (fr.v[k] - mean) * (fr.v[k] - mean)
+			}
+		}
+		std := math.Sqrt(sq / n)
+		for _, fr := range out {
+			if k < len(fr.v) {
+				fr.v[k] -= mean
+				if std > 0 {
+					fr.v[k] /= std
+				}
+			}
+		}
+	}
+	return out
+}
+
+// similarityMatrix compares every pair of bins; negative similarities and
+// pairs involving an empty bin are 0.
+func similarityMatrix(bins [][]float64) [][]float64 {
+	m := make([][]float64, len(bins))
+	for i := range m {
+		m[i] = make([]float64, len(bins))
+	}
+	for i, a := range bins {
+		for j := i; j < len(bins); j++ {
+			b := bins[j]
+			if a == nil || b == nil {
+				continue
+			}
+			dot := 0.0
+			for k := range a {
+				dot += a[k] * b[k]
+			}
+			m[i][j] = max(dot, 0)
+			m[j][i] = m[i][j]
+		}
+	}
+	return m
+}
+
+// writeSSM writes the matrix as PNG or, for .csv paths, as CSV with bin
+// start times in the first row and column.
+func writeSSM(path string, d *trackData, features []string, size int) error {
+	times, bins := featureBins(d, features, size)
+	if len(bins) == 0 {
+		return fmt.Errorf("no %s frames in track; enable them on the sender", strings.Join(features, " or "))
+	}
+	m := similarityMatrix(bins)
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		c := csv.NewWriter(w)
+		row := []string{"time"}
+		for _, t := range times {
+			row = append(row, strconv.FormatFloat(t, 'f', 3, 64))
+		}
+		c.Write(row)
+		for i, r := range m {
+			row = []string{strconv.FormatFloat(times[i], 'f', 3, 64)}
+			for _, v := range r {
+				row = append(row, strconv.FormatFloat(v, 'f', 4, 64))
+			}
+			c.Write(row)
+		}
+		c.Flush()
+		err = c.Error()
+	} else {
+		err = png.Encode(w, ssmImage(m))
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return flushClose(w, f)
+}
+
+// ssmImage renders the matrix with time running right and down from the
+// top-left corner.
+func ssmImage(m [][]float64) image.Image {
+	n := len(m)
+	scale := max((ssmMinPixels+n-1)/n, 1)
+	img := image.NewRGBA(image.Rect(0, 0, n*scale, n*scale))
+	for i, row := range m {
+		for j, v := range row {
+			c := viridis(v)
+			for y := i * scale; y < (i+1)*scale; y++ {
+				for x := j * scale; x < (j+1)*scale; x++ {
+					img.SetRGBA(x, y, c)
+				}
+			}
+		}
+	}
+	return img
+}
+
+var viridisStops = []color.RGBA{
+	{68, 1, 84, 255}, {59, 82, 139, 255}, {33, 145, 140, 255}, {94, 201, 98, 255}, {253, 231, 37, 255},
+}
+
+// viridis maps 0..1 onto an approximation of the viridis colour map.
+func viridis(v float64) color.RGBA {
+	v = min(max(v, 0), 1) * float64(len(viridisStops)-1)
+	i := min(int(v), len(viridisStops)-2)
+	f := v - float64(i)
+	a, b := viridisStops[i], viridisStops[i+1]
+	lerp := func(x, y uint8) uint8 { return uint8(float64(x) + (float64(y)-float64(x))*f + 0.5) }
+	return color.RGBA{lerp(a.R, b.R), lerp(a.G, b.G), lerp(a.B, b.B), 255}
+}
@@ -0,0 +1,57 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+)
+
+// listenSSM opens a socket for group:port that only receives traffic from
+// the given sources, joining each (source, group) channel with
+// IP_ADD_SOURCE_MEMBERSHIP (IGMPv3) on the interface with address ifaddr.
+func listenSSM(group net.IP, port int, sources []net.IP, ifaddr net.IP) (*net.UDPConn, error) {
+	lc := net.ListenConfig{Control: func(_, _ string, rc syscall.RawConn) error {
+		var serr error
+		err := rc.Control(func(fd uintptr) {
+			serr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+		})
+		if err != nil {
+			return err
+		}
+		return serr
+	}}
+	// Bound to the group, the socket sees no other traffic to the port.
+	pc, err := lc.ListenPacket(context.Background(), "udp4", net.JoinHostPort(group.String(), strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+	conn := pc.(*net.UDPConn)
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	for _, src := range sources {
+		// struct ip_mreq_source: multiaddr, interface, sourceaddr.
+		var mreq [12]byte
+		copy(mreq[0:4], group.To4())
+		copy(mreq[4:8], ifaddr.To4())
+		copy(mreq[8:12], src.To4())
+		var serr error
+		err := rc.Control(func(fd uintptr) {
+			serr = syscall.SetsockoptString(int(fd), syscall.IPPROTO_IP, syscall.IP_ADD_SOURCE_MEMBERSHIP, string(mreq[:]))
+		})
+		if err == nil {
+			err = serr
+		}
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("join (%s, %s): %w", src, group, err)
+		}
+	}
+	return conn, nil
+}
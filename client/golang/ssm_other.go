@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+func listenSSM(group net.IP, port int, sources []net.IP, ifaddr net.IP) (*net.UDPConn, error) {
+	return nil, errors.New("source-specific multicast is only supported on Linux")
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/tracks"
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// trackInfo is the TrackStart metadata of the current track, with its
+// title, artist and album from -track-metadata or -track-tags.
+type trackInfo struct {
+	Filename   string  `json:"filename"`
+	Title      string  `json:"title"`
+	Artist     string  `json:"artist,omitempty"`
+	Album      string  `json:"album,omitempty"`
+	Duration   float64 `json:"duration"`
+	SampleRate int32   `json:"sample_rate"`
+	Channels   int32   `json:"channels"`
+	Stream     string  `json:"stream,omitempty"`
+}
+
+// liveState is the latest known musical state of a stream, served by the
+// web dashboard at /api/state.
+type liveState struct {
+	Track     *trackInfo `json:"track"`
+	Playing   bool       `json:"playing"`
+	Position  float64    `json:"position"` // interpolated to the time of the request
+	BPM       float64    `json:"bpm,omitempty"`
+	Key       string     `json:"key,omitempty"`
+	Scale     string     `json:"scale,omitempty"`
+	KeyCode   string     `json:"key_code,omitempty"` // in -key-notation
+	Chord     string     `json:"chord,omitempty"`
+	Loudness  *float64   `json:"loudness,omitempty"`
+	Energy    *float64   `json:"energy,omitempty"`
+	Chroma    []float32  `json:"chroma,omitempty"`
+	LastBeat  float64    `json:"last_beat,omitempty"`
+	Beat      *beatState `json:"beat,omitempty"` // at the time of the request
+	Silent    bool       `json:"silent"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Streams   []string   `json:"streams,omitempty"` // every stream seen, when there are several
+}
+
+// beatState is where the track is in the beat grid, from tracks.BeatClock;
+// times are seconds.
+type beatState struct {
+	BPM          float64  `json:"bpm"`
+	Phase        float64  `json:"phase"` // 0 on the beat, towards 1 before the next
+	NextBeat     float64  `json:"next_beat"`
+	BarPhase     *float64 `json:"bar_phase,omitempty"` // from downbeats, when known
+	BeatInBar    int      `json:"beat_in_bar,omitempty"`
+	BeatsPerBar  int      `json:"beats_per_bar,omitempty"`
+	Meter        string   `json:"meter,omitempty"` // with -derive=meter.change
+	NextDownbeat *float64 `json:"next_downbeat,omitempty"`
+}
+
+// stateTracker keeps the state of each stream, and its own beat clock, so
+// merged decks or rooms don't mix their tracks, tempos and keys. Without a
+// stream named, the state is that of the stream whose track started last.
+type stateTracker struct {
+	mu      sync.Mutex
+	streams map[string]*streamState // by stream id
+	current string
+}
+
+type streamState struct {
+	state liveState
+	beat  *tracks.BeatClock
+}
+
+func newStateTracker() *stateTracker {
+	return &stateTracker{streams: make(map[string]*streamState)}
+}
+
+func (s *stateTracker) update(env *trackspb.Envelope, received time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream := env.GetStreamId()
+	ss := s.streams[stream]
+	if ss == nil {
+		ss = &streamState{beat: tracks.NewBeatClock()}
+		s.streams[stream] = ss
+		if len(s.streams) == 1 {
+			s.current = stream
+		}
+	}
+	ss.beat.Observe(env, received)
+	st := &ss.state
+	st.UpdatedAt = received
+
+	switch e := env.Event.(type) {
+	case *trackspb.Envelope_TrackStart:
+		v := e.TrackStart
+		meta := lookupTrackMeta(v.GetFilename())
+		*st = liveState{
+			Track: &trackInfo{
+				Filename:   v.GetFilename(),
+				Title:      meta.displayTitle(v),
+				Artist:     meta.Artist,
+				Album:      meta.Album,
+				Duration:   v.GetDuration(),
+				SampleRate: v.GetSampleRate(),
+				Channels:   v.GetChannels(),
+				Stream:     stream,
+			},
+			Playing:   true,
+			UpdatedAt: received,
+		}
+		s.current = stream
+	case *trackspb.Envelope_TrackEnd, *trackspb.Envelope_TrackAbort:
+		st.Playing = false
+	case *trackspb.Envelope_TempoChange:
+		st.BPM = e.TempoChange.GetBpm()
+	case *trackspb.Envelope_Beat:
+		st.LastBeat = env.GetTimestamp()
+	case *trackspb.Envelope_KeyChange:
+		st.Key = e.KeyChange.GetKey()
+		st.Scale = e.KeyChange.GetScale()
+		st.KeyCode = keyCode(st.Key, st.Scale)
+	case *trackspb.Envelope_ChordChange:
+		st.Chord = e.ChordChange.GetChord()
+	case *trackspb.Envelope_Chroma:
+		st.Chroma = append([]float32(nil), e.Chroma.GetValues()...)
+	case *trackspb.Envelope_Loudness:
+		v := e.Loudness.GetValue()
+		st.Loudness = &v
+	case *trackspb.Envelope_Energy:
+		v := e.Energy.GetValue()
+		st.Energy = &v
+	case *trackspb.Envelope_SilenceStart:
+		st.Silent = true
+	case *trackspb.Envelope_SilenceEnd:
+		st.Silent = false
+	}
+}
+
+// snapshot returns the state of the current stream.
+func (s *stateTracker) snapshot() liveState {
+	st, _ := s.streamSnapshot("")
+	return st
+}
+
+// streamSnapshot returns the state of stream, or of the current stream if
+// stream is empty, and whether there is one.
+func (s *stateTracker) streamSnapshot(stream string) (liveState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stream == "" {
+		stream = s.current
+	}
+	ss := s.streams[stream]
+	if ss == nil {
+		return liveState{}, stream == s.current
+	}
+	st := ss.state
+	now := time.Now()
+	st.Position = ss.beat.Position().Position(now)
+	if p := ss.beat.At(now); p.Valid {
+		b := &beatState{BPM: round3(p.BPM), Phase: round3(p.Beat), NextBeat: round3(p.NextBeat.Seconds())}
+		if p.BarValid {
+			bar, next := round3(p.Bar), round3(p.NextBar.Seconds())
+			b.BarPhase, b.BeatInBar, b.BeatsPerBar, b.NextDownbeat = &bar, p.BeatInBar, p.BeatsPerBar, &next
+			b.Meter = p.Meter
+		}
+		st.Beat = b
+	}
+	if st.Track != nil {
+		t := *st.Track
+		st.Track = &t
+	}
+	st.Chroma = append([]float32(nil), st.Chroma...)
+	if len(s.streams) > 1 {
+		st.Streams = slices.Sorted(maps.Keys(s.streams))
+	}
+	return st, true
+}
+
+// round3 rounds to milliseconds, or thousandths of a beat.
+func round3(x float64) float64 {
+	return math.Round(x*1000) / 1000
+}
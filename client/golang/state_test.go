@@ -0,0 +1,49 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/davesmith10/tracks/client/golang/tracks"
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// trackFilename is the filename of st's track, or "" without one.
+func trackFilename(st liveState) string {
+	if st.Track == nil {
+		return ""
+	}
+	return st.Track.Filename
+}
+
+func TestStateStreams(t *testing.T) {
+	s := newStateTracker()
+	if st, ok := s.streamSnapshot(""); !ok || st.Track != nil {
+		t.Errorf("state before any event = %+v, %v; want no track", st, ok)
+	}
+	start := func(stream, file string) {
+		s.update(&trackspb.Envelope{StreamId: stream, Event: &trackspb.Envelope_TrackStart{TrackStart: &trackspb.TrackStart{Filename: file}}}, tracks.Epoch)
+	}
+	tempo := func(stream string, bpm float64) {
+		s.update(&trackspb.Envelope{StreamId: stream, Event: &trackspb.Envelope_TempoChange{TempoChange: &trackspb.TempoChange{Bpm: bpm}}}, tracks.Epoch)
+	}
+	start("deckA", "a.wav")
+	tempo("deckA", 124)
+	start("deckB", "b.wav")
+	tempo("deckB", 90)
+	tempo("deckA", 125)
+
+	if st := s.snapshot(); trackFilename(st) != "b.wav" || st.BPM != 90 {
+		t.Errorf("current state: %s at %g BPM, want deckB's b.wav at 90", trackFilename(st), st.BPM)
+	}
+	st, ok := s.streamSnapshot("deckA")
+	if !ok || trackFilename(st) != "a.wav" || st.BPM != 125 || !st.Playing {
+		t.Errorf("deckA: %s at %g BPM, playing %v; want a.wav at 125, playing", trackFilename(st), st.BPM, st.Playing)
+	}
+	if !slices.Equal(st.Streams, []string{"deckA", "deckB"}) {
+		t.Errorf("streams %q, want deckA and deckB", st.Streams)
+	}
+	if _, ok := s.streamSnapshot("deckC"); ok {
+		t.Error("state of a stream never seen")
+	}
+}
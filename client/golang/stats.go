@@ -0,0 +1,332 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"golang.org/x/term"
+)
+
+// Live traffic statistics: events and bytes per second for every event
+// type and every source, to see what dominates a stream. Envelopes are
+// counted as they are decoded, before the queue, so the figures show what
+// arrives even when the receiver falls behind. Rates are averaged over the
+// last statsWindow complete seconds. They are served as JSON at /stats by
+// the web dashboard (-web) and shown on the console with -stats, along
+// with the state of the sink queues (see sinks.go), the clients of -serve
+// and -web (see clientstats.go) and the latency per event category (see
+// latency.go).
+const statsWindow = 5
+
+type statsKey struct {
+	event, source, stream string
+}
+
+type statsCount struct {
+	events, bytes int
+}
+
+type liveStats struct {
+	mu      sync.Mutex
+	buckets [statsWindow + 1]map[statsKey]statsCount // ring; cur is being filled
+	cur     int
+	filled  int // complete buckets, up to statsWindow
+	total   statsCount
+	started time.Time
+	sinks   []sinkReporter
+	servers []clientReporter
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newLiveStats() *liveStats {
+	s := &liveStats{started: time.Now(), stop: make(chan struct{}), done: make(chan struct{})}
+	for i := range s.buckets {
+		s.buckets[i] = make(map[statsKey]statsCount)
+	}
+	go s.run()
+	return s
+}
+
+func (s *liveStats) run() {
+	defer close(s.done)
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-tick.C:
+			s.mu.Lock()
+			s.cur = (s.cur + 1) % len(s.buckets)
+			clear(s.buckets[s.cur])
+			s.filled = min(s.filled+1, statsWindow)
+			s.mu.Unlock()
+		}
+	}
+}
+
+// record counts a decoded envelope of size bytes from source.
+func (s *liveStats) record(env *trackspb.Envelope, size int, source string) {
+	k := statsKey{eventName(env), source, env.GetStreamId()}
+	s.mu.Lock()
+	c := s.buckets[s.cur][k]
+	c.events++
+	c.bytes += size
+	s.buckets[s.cur][k] = c
+	s.total.events++
+	s.total.bytes += size
+	s.mu.Unlock()
+}
+
+// watchSinks adds sinks to the snapshots.
+func (s *liveStats) watchSinks(sinks ...sinkReporter) {
+	s.mu.Lock()
+	s.sinks = append(s.sinks, sinks...)
+	s.mu.Unlock()
+}
+
+func (s *liveStats) close() {
+	close(s.stop)
+	<-s.done
+}
+
+// statsRate is one row of a stats snapshot.
+type statsRate struct {
+	Event       string  `json:"event,omitempty"`
+	Source      string  `json:"source,omitempty"`
+	Stream      string  `json:"stream,omitempty"`
+	EventsPerS  float64 `json:"events_per_sec"`
+	BytesPerSec float64 `json:"bytes_per_sec"`
+}
+
+type statsSnapshot struct {
+	Window      int            `json:"window"` // seconds averaged
+	EventsPerS  float64        `json:"events_per_sec"`
+	BytesPerSec float64        `json:"bytes_per_sec"`
+	TotalEvents int            `json:"total_events"`
+	TotalBytes  int            `json:"total_bytes"`
+	Uptime      float64        `json:"uptime"`
+	Types       []statsRate    `json:"types"`
+	Sources     []statsRate    `json:"sources"`
+	Sinks       []sinkStatus   `json:"sinks"`
+	Clients     []clientStatus `json:"clients"` // see clientstats.go
+	Latency     []latencyStats `json:"latency"` // whole run, see latency.go
+}
+
+func (s *liveStats) snapshot() statsSnapshot {
+	s.mu.Lock()
+	sinks := s.sinks
+	s.mu.Unlock()
+	// Sinks have locks of their own.
+	statuses := []sinkStatus{}
+	for _, k := range sinks {
+		statuses = append(statuses, k.status())
+	}
+	clients := s.clientStatuses(time.Now())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := statsSnapshot{
+		Sinks:       statuses,
+		Clients:     clients,
+		Latency:     latencies.snapshot(),
+		Window:      s.filled,
+		TotalEvents: s.total.events,
+		TotalBytes:  s.total.bytes,
+		Uptime:      time.Since(s.started).Seconds(),
+		Types:       []statsRate{},
+		Sources:     []statsRate{},
+	}
+	if s.filled == 0 {
+		return snap
+	}
+	types := make(map[string]*statsRate)
+	sources := make(map[[2]string]*statsRate)
+	for i := 1; i <= s.filled; i++ {
+		b := s.buckets[(s.cur-i+len(s.buckets))%len(s.buckets)]
+		for k, c := range b {
+			t := types[k.event]
+			if t == nil {
+				t = &statsRate{Event: k.event}
+				types[k.event] = t
+			}
+			src := sources[[2]string{k.source, k.stream}]
+			if src == nil {
+				src = &statsRate{Source: k.source, Stream: k.stream}
+				sources[[2]string{k.source, k.stream}] = src
+			}
+			for _, r := range []*statsRate{t, src} {
+				r.EventsPerS += float64(c.events)
+				r.BytesPerSec += float64(c.bytes)
+			}
+			snap.EventsPerS += float64(c.events)
+			snap.BytesPerSec += float64(c.bytes)
+		}
+	}
+	n := float64(s.filled)
+	snap.EventsPerS /= n
+	snap.BytesPerSec /= n
+	for _, t := range types {
+		t.EventsPerS /= n
+		t.BytesPerSec /= n
+		snap.Types = append(snap.Types, *t)
+	}
+	for _, src := range sources {
+		src.EventsPerS /= n
+		src.BytesPerSec /= n
+		snap.Sources = append(snap.Sources, *src)
+	}
+	sort.Slice(snap.Types, func(i, j int) bool {
+		a, b := snap.Types[i], snap.Types[j]
+		if a.BytesPerSec != b.BytesPerSec {
+			return a.BytesPerSec > b.BytesPerSec
+		}
+		return a.Event < b.Event
+	})
+	sort.Slice(snap.Sources, func(i, j int) bool {
+		a, b := snap.Sources[i], snap.Sources[j]
+		if a.BytesPerSec != b.BytesPerSec {
+			return a.BytesPerSec > b.BytesPerSec
+		}
+		return a.Source+a.Stream < b.Source+b.Stream
+	})
+	return snap
+}
+
+// statsView redraws the stats on the console every second (-stats), in
+// place of the event lines. On a terminal the screen is cleared for each
+// update; otherwise every update is appended.
+type statsView struct {
+	stats *liveStats
+	out   *os.File
+	top   int
+	tty   bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newStatsView(stats *liveStats, out *os.File, top int) *statsView {
+	v := &statsView{
+		stats: stats,
+		out:   out,
+		top:   top,
+		tty:   term.IsTerminal(int(out.Fd())),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go v.run()
+	return v
+}
+
+func (v *statsView) run() {
+	defer close(v.done)
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-v.stop:
+			return
+		case <-tick.C:
+			var b strings.Builder
+			if v.tty {
+				b.WriteString("\033[H\033[2J")
+			}
+			writeStats(&b, v.stats.snapshot(), v.top)
+			if !v.tty {
+				b.WriteString("\n")
+			}
+			io.WriteString(v.out, b.String())
+		}
+	}
+}
+
+func (v *statsView) close() {
+	close(v.stop)
+	<-v.done
+}
+
+// writeStats renders a snapshot as tables of the top event types and
+// sources, busiest first.
+func writeStats(w io.Writer, s statsSnapshot, top int) {
+	fmt.Fprintf(w, "%.1f events/s, %s/s (%ds average); %d events, %s in %s\n\n",
+		s.EventsPerS, formatBytes(s.BytesPerSec), s.Window, s.TotalEvents, formatBytes(float64(s.TotalBytes)), shortClock(s.Uptime))
+
+	fmt.Fprintf(w, "%-22s %10s %10s %6s\n", "EVENT", "EVENTS/S", "BYTES/S", "SHARE")
+	for i, r := range s.Types {
+		if i == top {
+			fmt.Fprintf(w, "... %d more\n", len(s.Types)-top)
+			break
+		}
+		share := 0.0
+		if s.BytesPerSec > 0 {
+			share = 100 * r.BytesPerSec / s.BytesPerSec
+		}
+		fmt.Fprintf(w, "%-22s %10.1f %10s %5.0f%%\n", r.Event, r.EventsPerS, formatBytes(r.BytesPerSec), share)
+	}
+
+	fmt.Fprintf(w, "\n%-22s %-16s %10s %10s\n", "SOURCE", "STREAM", "EVENTS/S", "BYTES/S")
+	for i, r := range s.Sources {
+		if i == top {
+			fmt.Fprintf(w, "... %d more\n", len(s.Sources)-top)
+			break
+		}
+		stream := r.Stream
+		if stream == "" {
+			stream = "-"
+		}
+		fmt.Fprintf(w, "%-22s %-16s %10.1f %10s\n", r.Source, stream, r.EventsPerS, formatBytes(r.BytesPerSec))
+	}
+
+	if len(s.Latency) > 0 {
+		fmt.Fprintf(w, "\n%-22s %10s %8s %8s %8s %8s %8s\n", "LATENCY (ms)", "COUNT", "P50", "P90", "P99", "P99.9", "MAX")
+		for _, l := range s.Latency {
+			fmt.Fprintf(w, "%-22s %10d %8.2f %8.2f %8.2f %8.2f %8.2f\n", l.Category, l.Count, l.P50, l.P90, l.P99, l.P999, l.Max)
+			if l.Negative > 0 {
+				fmt.Fprintf(w, "%-22s %d arrived before they were sent; check the clocks\n", "", l.Negative)
+			}
+		}
+	}
+
+	if len(s.Sinks) > 0 {
+		fmt.Fprintf(w, "\n%-22s %-12s %10s %10s\n", "SINK", "POLICY", "QUEUED", "DROPPED")
+		for _, k := range s.Sinks {
+			fmt.Fprintf(w, "%-22s %-12s %10s %10d\n", k.Name, k.Policy, fmt.Sprintf("%d/%d", k.Depth, k.Capacity), k.Dropped)
+		}
+	}
+
+	if len(s.Clients) > 0 {
+		fmt.Fprintf(w, "\n%-22s %-24s %10s %10s %10s %10s\n", "CLIENT", "FILTER", "EVENTS/S", "BYTES/S", "QUEUED", "DROPPED")
+		for i, c := range s.Clients {
+			if i == top {
+				fmt.Fprintf(w, "... %d more\n", len(s.Clients)-top)
+				break
+			}
+			slow := ""
+			if c.Slow {
+				slow = "  slow"
+			}
+			fmt.Fprintf(w, "%-22s %-24s %10.1f %10s %10s %10d%s\n", c.Server+" "+c.Address, truncate(c.Filter, 24), c.EventsPerS,
+				formatBytes(c.BytesPerSec), fmt.Sprintf("%d/%d", c.Depth, c.Capacity), c.Dropped, slow)
+		}
+	}
+}
+
+// formatBytes renders a byte count as B, kB or MB.
+func formatBytes(n float64) string {
+	switch {
+	case n >= 1e6:
+		return fmt.Sprintf("%.1f MB", n/1e6)
+	case n >= 1e3:
+		return fmt.Sprintf("%.1f kB", n/1e3)
+	}
+	return fmt.Sprintf("%.0f B", n)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/tracks"
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"golang.org/x/term"
+)
+
+// Status lines (-status): a few lines per stream, updated in place, in
+// place of the event lines,
+//
+//	deckA  song.mp3  1:23 / 4:05
+//	  tempo     128.0 BPM  bar 12, beat 3/4
+//	  key       A minor (8A)
+//	  loudness  -14.2 dB  peak -1.3 dB
+//	  onset     1:22.970  strength 0.53
+//
+// Events only update the state; the lines are redrawn at most once every
+// -status-interval, and only when something changed, so a slow terminal
+// or link never falls behind the stream. The tempo and bar follow a
+// BeatClock per stream. On a terminal the lines are redrawn in place;
+// otherwise every update is appended.
+type statusView struct {
+	out *os.File
+	tty bool
+
+	mu      sync.Mutex
+	streams []*streamStatus // in order of first event
+	dirty   bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type streamStatus struct {
+	id       string
+	clock    *tracks.BeatClock
+	name     string
+	playing  bool
+	key      string
+	loudness string
+	peak     string
+	onset    string
+	downs    int // since the track started
+}
+
+func newStatusView(interval time.Duration, out *os.File) (*statusView, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("-status-interval must be positive")
+	}
+	v := &statusView{
+		out:  out,
+		tty:  term.IsTerminal(int(out.Fd())),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go v.run(interval)
+	return v, nil
+}
+
+func (v *statusView) stream(id string) *streamStatus {
+	for _, s := range v.streams {
+		if s.id == id {
+			return s
+		}
+	}
+	s := &streamStatus{id: id, clock: tracks.NewBeatClock()}
+	v.streams = append(v.streams, s)
+	return s
+}
+
+// observe updates the status of env's stream.
+func (v *statusView) observe(env *trackspb.Envelope, received time.Time) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s := v.stream(env.GetStreamId())
+	s.clock.Observe(env, received)
+	switch e := env.Event.(type) {
+	case *trackspb.Envelope_TrackStart:
+		*s = streamStatus{id: s.id, clock: s.clock, playing: true,
+			name: filepath.Base(e.TrackStart.GetFilename())}
+	case *trackspb.Envelope_TrackEnd, *trackspb.Envelope_TrackAbort:
+		s.playing = false
+	case *trackspb.Envelope_TimelineReset:
+		s.downs = 0
+	case *trackspb.Envelope_Downbeat:
+		s.downs++
+	case *trackspb.Envelope_TempoChange, *trackspb.Envelope_MeterChange:
+	case *trackspb.Envelope_KeyChange:
+		k := e.KeyChange
+		s.key = k.GetKey() + " " + k.GetScale()
+		if code := keyCode(k.GetKey(), k.GetScale()); code != "" {
+			s.key += " (" + code + ")"
+		}
+	case *trackspb.Envelope_Loudness:
+		s.loudness = fmt.Sprintf("%.1f dB", e.Loudness.GetValue())
+	case *trackspb.Envelope_LoudnessPeak:
+		s.peak = fmt.Sprintf("%.1f dB", e.LoudnessPeak.GetValue())
+	case *trackspb.Envelope_Onset:
+		s.onset = fmt.Sprintf("%s  strength %.2f", formatClock(env.GetTimestamp()), e.Onset.GetStrength())
+	default:
+		return
+	}
+	v.dirty = true
+}
+
+func (v *statusView) run(interval time.Duration) {
+	defer close(v.done)
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	if v.tty {
+		io.WriteString(v.out, "\033[H\033[2J")
+	}
+	for {
+		select {
+		case <-v.stop:
+			return
+		case <-tick.C:
+			v.draw(time.Now())
+		}
+	}
+}
+
+// draw renders the status lines at now. While a track plays its position
+// and beat move on, so it is always redrawn; otherwise only after an
+// event changed something.
+func (v *statusView) draw(now time.Time) {
+	width := 80
+	if w, _, err := term.GetSize(int(v.out.Fd())); err == nil && w > 0 {
+		width = w
+	}
+	v.mu.Lock()
+	changed := v.dirty
+	for _, s := range v.streams {
+		changed = changed || s.playing && v.tty
+	}
+	if !changed {
+		v.mu.Unlock()
+		return
+	}
+	v.dirty = false
+	var b strings.Builder
+	if v.tty {
+		b.WriteString("\033[H")
+	}
+	for _, s := range v.streams {
+		for _, line := range s.lines(now) {
+			b.WriteString(truncate(line, width-1))
+			if v.tty {
+				b.WriteString("\033[K")
+			}
+			b.WriteString("\n")
+		}
+	}
+	v.mu.Unlock()
+	if v.tty {
+		b.WriteString("\033[J")
+	} else {
+		b.WriteString("\n")
+	}
+	io.WriteString(v.out, b.String())
+}
+
+// lines lays out the stream's status; values not seen yet are shown as -.
+func (s *streamStatus) lines(now time.Time) []string {
+	orDash := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+	head := s.name
+	if head == "" {
+		head = "waiting for a track"
+	}
+	if s.id != "" {
+		head = s.id + "  " + head
+	}
+	pos := s.clock.Position()
+	switch {
+	case s.name == "":
+	case !s.playing:
+		head += "  ended"
+	case pos.Duration() > 0:
+		head += fmt.Sprintf("  %s / %s", shortClock(pos.Position(now)), shortClock(pos.Duration()))
+	default:
+		head += "  " + shortClock(pos.Position(now))
+	}
+
+	tempo := "-"
+	if p := s.clock.At(now); p.Valid && s.playing {
+		tempo = fmt.Sprintf("%.1f BPM", p.BPM)
+		if p.BarValid && s.downs > 0 {
+			tempo += fmt.Sprintf("  bar %d, beat %d/%d", s.downs, p.BeatInBar, p.BeatsPerBar)
+		}
+	}
+	loudness := orDash(s.loudness)
+	if s.peak != "" {
+		loudness += "  peak " + s.peak
+	}
+	return []string{
+		head,
+		"  tempo     " + tempo,
+		"  key       " + orDash(s.key),
+		"  loudness  " + loudness,
+		"  onset     " + orDash(s.onset),
+	}
+}
+
+func (v *statusView) close() {
+	close(v.stop)
+	<-v.done
+}
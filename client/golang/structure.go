@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Section labeling (the "structure" layer). The track is cut at its
+// section.change events — or its segment.boundary events when there are none
+// — and the resulting sections are compared by their average chroma and
+// MFCC. Sections similar enough to an earlier one share its letter (A, B,
+// C, ...), and heuristics name their likely function: the loudest repeated
+// section is the chorus, the most repeated other one the verse, the first
+// and last sections otherwise the intro and outro, and unrepeated sections
+// in between bridges.
+
+// structureSimilarity is the cosine similarity above which two sections get
+// the same letter, set by -structure-similarity.
+var structureSimilarity = 0.9
+
+// structureMinSection is the shortest section kept; closer cuts are merged
+// into the section before them.
+const structureMinSection = 4.0
+
+// structureSection is one labelled section of a track.
+type structureSection struct {
+	start, end float64
+	letter     string
+	role       string // "intro", "verse", "chorus", "bridge", "outro" or ""
+	loudness   float64
+	hasLevel   bool
+}
+
+func (s structureSection) text() string {
+	if s.role == "" {
+		return s.letter
+	}
+	return s.letter + " (" + s.role + ")"
+}
+
+// trackStructure labels the sections of d. Tracks with fewer than two
+// sections have no structure.
+func trackStructure(d *trackData) []structureSection {
+	dur := d.duration()
+	cuts := d.marks["segment.boundary"]
+	if pts := d.series["section.change"]; len(pts) > 0 {
+		cuts = nil
+		for _, p := range pts {
+			cuts = append(cuts, p.t)
+		}
+	}
+	edges := []float64{0}
+	for _, t := range cuts {
+		if t-edges[len(edges)-1] >= structureMinSection && dur-t >= structureMinSection {
+			edges = append(edges, t)
+		}
+	}
+	edges = append(edges, dur)
+	if len(edges) < 3 {
+		return nil
+	}
+
+	vecs := averageFeatures(d, []string{"chroma", "mfcc"}, edges)
+	level := d.series["loudness"]
+	if len(level) == 0 {
+		level = d.series["energy"]
+	}
+
+	sections := make([]structureSection, len(edges)-1)
+	labels := make([]int, len(sections))
+	next := 0
+	for i := range sections {
+		s := &sections[i]
+		s.start, s.end = edges[i], edges[i+1]
+		s.loudness, s.hasLevel = meanBetween(level, s.start, s.end)
+
+		// Single linkage: take the label of the most similar earlier section.
+		best, bestSim := -1, structureSimilarity
+		for j := 0; j < i && vecs != nil && vecs[i] != nil; j++ {
+			if vecs[j] == nil {
+				continue
+			}
+			sim := 0.0
+			for k := range vecs[i] {
+				sim += vecs[i][k] * vecs[j][k]
+			}
+			if sim >= bestSim {
+				best, bestSim = j, sim
+			}
+		}
+		if best >= 0 {
+			labels[i] = labels[best]
+		} else {
+			labels[i] = next
+			next++
+		}
+		s.letter = sectionLetter(labels[i])
+	}
+	assignRoles(sections, labels, next)
+	return sections
+}
+
+// assignRoles applies the function heuristics described above.
+func assignRoles(sections []structureSection, labels []int, n int) {
+	count := make([]int, n)
+	level := make([]float64, n)
+	leveled := make([]int, n)
+	for i, s := range sections {
+		count[labels[i]]++
+		if s.hasLevel {
+			level[labels[i]] += s.loudness
+			leveled[labels[i]]++
+		}
+	}
+
+	chorus, verse := -1, -1
+	for l := 0; l < n; l++ {
+		if count[l] < 2 || leveled[l] == 0 {
+			continue
+		}
+		if chorus < 0 || level[l]/float64(leveled[l]) > level[chorus]/float64(leveled[chorus]) {
+			chorus = l
+		}
+	}
+	// The verse is the repeated section heard most often between the first
+	// and last sections, so a returning intro isn't mistaken for one.
+	inner := make([]int, n)
+	for i := 1; i < len(sections)-1; i++ {
+		inner[labels[i]]++
+	}
+	for l := 0; l < n; l++ {
+		if count[l] >= 2 && l != chorus && inner[l] > 0 && (verse < 0 || inner[l] > inner[verse]) {
+			verse = l
+		}
+	}
+	// A lone repeated section is only a chorus if it is louder than the rest.
+	if chorus >= 0 && verse < 0 {
+		rest, restN := 0.0, 0
+		for i, s := range sections {
+			if labels[i] != chorus && s.hasLevel {
+				rest += s.loudness
+				restN++
+			}
+		}
+		if restN > 0 && rest/float64(restN) >= level[chorus]/float64(leveled[chorus]) {
+			chorus, verse = -1, chorus
+		}
+	}
+
+	for i := range sections {
+		s := &sections[i]
+		switch l := labels[i]; {
+		case l == chorus:
+			s.role = "chorus"
+		case l == verse:
+			s.role = "verse"
+		case i == 0:
+			s.role = "intro"
+		case i == len(sections)-1:
+			s.role = "outro"
+		case count[l] >= 2:
+		default:
+			s.role = "bridge"
+		}
+	}
+}
+
+// sectionLetter names label i: A to Z, then AA, AB, ...
+func sectionLetter(i int) string {
+	s := string(rune('A' + i%26))
+	for i >= 26 {
+		i = i/26 - 1
+		s = string(rune('A'+i%26)) + s
+	}
+	return s
+}
+
+// meanBetween averages the points in [from, to).
+func meanBetween(pts []point, from, to float64) (float64, bool) {
+	sum, n := 0.0, 0
+	for _, p := range pts {
+		if p.t >= from && p.t < to {
+			sum += p.v
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
+
+func structureRegions(d *trackData) []annotation {
+	var out []annotation
+	for _, s := range trackStructure(d) {
+		out = append(out, annotation{s.start, s.end, "structure", s.text()})
+	}
+	return out
+}
+
+// formatStructure renders the structure as one line per section for the
+// end-of-track summary.
+func formatStructure(sections []structureSection) string {
+	var b strings.Builder
+	b.WriteString("Structure:")
+	for _, s := range sections {
+		fmt.Fprintf(&b, "\n  %s-%s  %s", formatClock(s.start), formatClock(s.end), s.text())
+	}
+	return b.String()
+}
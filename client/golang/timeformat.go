@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/tracks"
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Line time prefixes (-time-format):
+//
+//	track  [  12.345]       the analyzer's track time in seconds (default)
+//	clock  [14:03:07.250]   the wall-clock time the event was received
+//	bars   [  12.3.50]      bar.beat.hundredths of a beat, counted from the
+//	                        track's first downbeat
+//
+// Bars follow a BeatClock per stream; until a track's first downbeat, or
+// while its beat grid is lost, lines show track time.
+
+type timeStamper struct {
+	mode    string
+	streams map[string]*barCounter // for bars
+}
+
+// barCounter numbers a stream's bars: the downbeats seen since the track
+// started, plus any bars whose downbeat the analysis missed.
+type barCounter struct {
+	clock    *tracks.BeatClock
+	downs    int
+	lastDown float64
+}
+
+func newTimeStamper(mode string) (*timeStamper, error) {
+	switch mode {
+	case "track", "clock", "bars":
+	default:
+		return nil, fmt.Errorf("unknown time format %q (want track, clock or bars)", mode)
+	}
+	return &timeStamper{mode: mode, streams: make(map[string]*barCounter)}, nil
+}
+
+// format renders env, received at now, with the chosen prefix. Envelopes
+// must be given in order, since bars are counted as they arrive.
+func (s *timeStamper) format(env *trackspb.Envelope, now time.Time) string {
+	return formatEventStamped(env, s.stamp(env, now))
+}
+
+func (s *timeStamper) stamp(env *trackspb.Envelope, now time.Time) string {
+	switch s.mode {
+	case "clock":
+		return now.Format("[15:04:05.000] ")
+	case "bars":
+		if stamp, ok := s.bars(env, now); ok {
+			return stamp
+		}
+	}
+	return trackStamp(env)
+}
+
+func (s *timeStamper) bars(env *trackspb.Envelope, now time.Time) (string, bool) {
+	stream := env.GetStreamId()
+	b := s.streams[stream]
+	switch env.Event.(type) {
+	case *trackspb.Envelope_TrackStart, *trackspb.Envelope_TimelineReset:
+		b = nil
+	}
+	if b == nil {
+		b = &barCounter{clock: tracks.NewBeatClock()}
+		s.streams[stream] = b
+	}
+	b.clock.Observe(env, now)
+	t := env.GetTimestamp()
+	if env.GetDownbeat() != nil {
+		b.downs++
+		b.lastDown = t
+	}
+	p := b.clock.AtPosition(t)
+	if b.downs == 0 || !p.Valid || !p.BarValid {
+		return "", false
+	}
+	// Bars since the last downbeat, for downbeats the analysis missed.
+	missed := int(math.Floor((t-b.lastDown)*p.BPM/60+0.25)) / p.BeatsPerBar
+	return fmt.Sprintf("[%4d.%d.%02d] ", b.downs+missed, p.BeatInBar, int(p.Beat*100)), true
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Timeline discontinuities. Within a track, timestamps only move forward,
+// give or take the reordering of the priority queue. When they jump back
+// by more than -timeline-tolerance, the analyzer was restarted or seeked
+// without announcing a new track, and everything derived from the timeline
+// so far (position estimates, the click track's beat grid, trend windows,
+// the recorded track) no longer lines up with what follows. The receiver
+// then synthesizes a timeline.reset envelope, timestamped at the new
+// position, and handles it before the envelope that revealed the jump.
+type timelineDetector struct {
+	tolerance float64            // seconds; 0 disables detection
+	latest    map[string]float64 // stream → latest timestamp in the track
+}
+
+func newTimelineDetector(tolerance float64) *timelineDetector {
+	return &timelineDetector{tolerance: tolerance, latest: make(map[string]float64)}
+}
+
+// check returns a timeline.reset envelope if env jumps back in its
+// stream's timeline, or nil.
+func (t *timelineDetector) check(env *trackspb.Envelope) *trackspb.Envelope {
+	stream := env.GetStreamId()
+	switch env.Event.(type) {
+	case *trackspb.Envelope_TrackStart, *trackspb.Envelope_TrackEnd, *trackspb.Envelope_TrackAbort:
+		delete(t.latest, stream)
+		return nil
+	case *trackspb.Envelope_TrackPrepare:
+		// Announces the next track; its timestamp belongs to neither.
+		return nil
+	}
+	if t.tolerance <= 0 {
+		return nil
+	}
+	ts := env.GetTimestamp()
+	latest, ok := t.latest[stream]
+	if !ok || ts > latest {
+		t.latest[stream] = ts
+		return nil
+	}
+	if latest-ts <= t.tolerance {
+		return nil
+	}
+	t.latest[stream] = ts
+	timelineResets.Add(1)
+	return &trackspb.Envelope{
+		Timestamp: ts,
+		StreamId:  stream,
+		Event:     &trackspb.Envelope_TimelineReset{TimelineReset: &trackspb.TimelineReset{Previous: latest}},
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Tonal views (GET /api/tonal): the chroma of the current track aggregated
+// for chroma wheels, and key strengths for keyscapes, computed by the web
+// server from the chroma events it passes on. Key strengths are the
+// correlation of a chroma vector with the Krumhansl-Kessler major and minor
+// profiles rotated to each of the 24 keys. The keyscape (as in Sapp's
+// keyscapes) is a triangle: the track is cut into ?bins= equal time bins
+// (default 32), row 0 has the strongest key of each bin, row 1 of each pair
+// of neighbouring bins, and so on up to the single window over the whole
+// track. With several streams, the views follow the stream whose track
+// started last.
+const (
+	tonalRecent         = 10.0 // seconds averaged into "recent"
+	defaultKeyscapeBins = 32
+	maxKeyscapeBins     = 128
+)
+
+var (
+	kkMajor = [12]float64{6.35, 2.23, 3.48, 2.33, 4.38, 4.09, 2.52, 5.19, 2.39, 3.66, 2.29, 2.88}
+	kkMinor = [12]float64{6.33, 2.68, 3.52, 5.38, 2.60, 3.53, 2.54, 4.75, 3.98, 2.69, 3.34, 3.17}
+)
+
+type tonalTracker struct {
+	mu     sync.Mutex
+	stream string
+	frames []frame
+	key    *trackspb.KeyChange // the sender's latest estimate
+}
+
+func (t *tonalTracker) observe(env *trackspb.Envelope) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if env.GetTrackStart() != nil {
+		t.stream, t.frames, t.key = env.GetStreamId(), nil, nil
+		return
+	}
+	if env.GetStreamId() != t.stream {
+		return
+	}
+	switch e := env.Event.(type) {
+	case *trackspb.Envelope_Chroma:
+		if len(e.Chroma.GetValues()) == 12 {
+			t.frames = append(t.frames, frame{env.GetTimestamp(), e.Chroma.GetValues()})
+		}
+	case *trackspb.Envelope_KeyChange:
+		t.key = e.KeyChange
+	}
+}
+
+// keyStrength is one key's fit; Fifths is the key's position on the circle
+// of fifths (see fifthsIndex), for colouring.
+type keyStrength struct {
+	Key      string  `json:"key"`
+	Scale    string  `json:"scale"`
+	Code     string  `json:"code,omitempty"` // in -key-notation
+	Fifths   int     `json:"fifths"`
+	Strength float64 `json:"strength"`
+}
+
+type keyscape struct {
+	Start float64          `json:"start"` // track time of the first bin
+	End   float64          `json:"end"`
+	Bins  int              `json:"bins"`
+	Rows  [][]*keyStrength `json:"rows"` // row r: windows r+1 bins wide; null where there is no chroma
+}
+
+// tonalView is the GET /api/tonal payload. Chroma vectors are in
+// pitch-class order from C and scaled so the largest value is 1.
+type tonalView struct {
+	Stream       string        `json:"stream,omitempty"`
+	PitchClasses []string      `json:"pitch_classes"`
+	FifthsOrder  []int         `json:"fifths_order"` // pitch classes clockwise around a wheel of fifths
+	Chroma       []float64     `json:"chroma"`       // latest frame
+	Recent       []float64     `json:"recent"`       // mean of the last tonalRecent seconds
+	Mean         []float64     `json:"mean"`         // mean of the track so far
+	Key          *keyStrength  `json:"key"`          // latest key.change
+	Keys         []keyStrength `json:"keys"`         // all 24 keys for Mean, strongest first
+	Keyscape     *keyscape     `json:"keyscape"`
+}
+
+func (t *tonalTracker) view(bins int) tonalView {
+	t.mu.Lock()
+	frames := t.frames
+	key := t.key
+	v := tonalView{Stream: t.stream, PitchClasses: noteNames[:]}
+	t.mu.Unlock()
+
+	for i := range 12 {
+		v.FifthsOrder = append(v.FifthsOrder, i*7%12)
+	}
+	if key != nil {
+		k := newKeyStrength(key.GetKey(), key.GetScale(), key.GetStrength())
+		v.Key = &k
+	}
+	if len(frames) == 0 {
+		return v
+	}
+	last := frames[len(frames)-1]
+	var recent, mean [12]float64
+	for _, f := range frames {
+		for i, x := range f.v {
+			mean[i] += float64(x)
+			if f.t >= last.t-tonalRecent {
+				recent[i] += float64(x)
+			}
+		}
+	}
+	var latest [12]float64
+	for i, x := range last.v {
+		latest[i] = float64(x)
+	}
+	v.Chroma, v.Recent, v.Mean = scaleChroma(latest), scaleChroma(recent), scaleChroma(mean)
+	v.Keys = keyStrengths(mean)
+	v.Keyscape = keyscapeOf(frames, bins)
+	return v
+}
+
+func newKeyStrength(key, scale string, strength float64) keyStrength {
+	i, _ := fifthsIndex(key, scale)
+	return keyStrength{Key: key, Scale: scale, Code: keyCode(key, scale), Fifths: i, Strength: strength}
+}
+
+func scaleChroma(c [12]float64) []float64 {
+	top := 0.0
+	for _, x := range c {
+		top = max(top, x)
+	}
+	out := make([]float64, 12)
+	for i, x := range c {
+		if top > 0 {
+			out[i] = math.Round(x/top*1000) / 1000
+		}
+	}
+	return out
+}
+
+// keyStrengths correlates c with all 24 key profiles, strongest first.
+func keyStrengths(c [12]float64) []keyStrength {
+	var out []keyStrength
+	for tonic := range 12 {
+		for _, scale := range []string{"major", "minor"} {
+			profile := kkMajor
+			if scale == "minor" {
+				profile = kkMinor
+			}
+			var rotated [12]float64
+			for i := range 12 {
+				rotated[(tonic+i)%12] = profile[i]
+			}
+			r := math.Round(correlation(c, rotated)*1000) / 1000
+			out = append(out, newKeyStrength(noteNames[tonic], scale, r))
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Strength > out[j].Strength })
+	return out
+}
+
+func correlation(a, b [12]float64) float64 {
+	var ma, mb float64
+	for i := range 12 {
+		ma += a[i] / 12
+		mb += b[i] / 12
+	}
+	var num, da, db float64
+	for i := range 12 {
+		num += (a[i] - ma) * (b[i] - mb)
+		da += (a[i] - ma) * (a[i] - ma)
+		db += (b[i] - mb) * (b[i] - mb)
+	}
+	if da == 0 || db == 0 {
+		return 0
+	}
+	return num / math.Sqrt(da*db)
+}
+
+// keyscapeOf sums the frames into n equal time bins and finds the strongest
+// key of every run of neighbouring bins.
+func keyscapeOf(frames []frame, n int) *keyscape {
+	start, end := frames[0].t, frames[len(frames)-1].t
+	if end <= start {
+		n = 1
+	}
+	n = min(n, len(frames))
+	sums := make([][12]float64, n+1) // prefix sums over bins
+	for _, f := range frames {
+		i := 0
+		if end > start {
+			i = min(int((f.t-start)/(end-start)*float64(n)), n-1)
+		}
+		for k, x := range f.v {
+			sums[i+1][k] += float64(x)
+		}
+	}
+	for i := 1; i <= n; i++ {
+		for k := range 12 {
+			sums[i][k] += sums[i-1][k]
+		}
+	}
+	ks := &keyscape{Start: start, End: end, Bins: n}
+	for w := 1; w <= n; w++ {
+		row := make([]*keyStrength, n-w+1)
+		for j := range row {
+			var c [12]float64
+			total := 0.0
+			for k := range 12 {
+				c[k] = sums[j+w][k] - sums[j][k]
+				total += c[k]
+			}
+			if total > 0 {
+				best := keyStrengths(c)[0]
+				row[j] = &best
+			}
+		}
+		ks.Rows = append(ks.Rows, row)
+	}
+	return ks
+}
+
+func (w *webServer) handleTonal(rw http.ResponseWriter, r *http.Request) {
+	bins := defaultKeyscapeBins
+	if s := r.URL.Query().Get("bins"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxKeyscapeBins {
+			http.Error(rw, "bins must be 1 to "+strconv.Itoa(maxKeyscapeBins), http.StatusBadRequest)
+			return
+		}
+		bins = n
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(w.tonal.view(bins))
+}
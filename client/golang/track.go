@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+type point struct {
+	t, v float64
+}
+
+// frame is a feature vector at a timestamp.
+type frame struct {
+	t float64
+	v []float32
+}
+
+type label struct {
+	t    float64
+	name string
+}
+
+// trackData accumulates one track's events for end-of-track reports and
+// exports.
+type trackData struct {
+	start       *trackspb.TrackStart
+	meta        trackMeta // -track-metadata, -track-tags
+	stream      string
+	started     time.Time
+	index       int
+	end         float64 // latest timestamp seen
+	aborted     bool
+	abortReason string
+	counts      map[string]int
+
+	series   map[string][]point   // event name → (timestamp, main value)
+	marks    map[string][]float64 // valueless event name → timestamps
+	keys     []label              // "A minor", "A minor (8A)" with -key-notation
+	chords   []label
+	numerals []label            // roman.numeral, when derived
+	meters   []label            // meter.change, when derived: "4/4", "6/8"
+	features map[string][]frame // "chroma", "mfcc" → frames, for structure analysis; "bands.*" for heatmaps
+	markers  []float64          // set by an operator (see companion.go)
+}
+
+func newTrackData(env *trackspb.Envelope, started time.Time, index int) *trackData {
+	return &trackData{
+		start:    env.GetTrackStart(),
+		meta:     lookupTrackMeta(env.GetTrackStart().GetFilename()),
+		stream:   env.GetStreamId(),
+		started:  started,
+		index:    index,
+		counts:   make(map[string]int),
+		series:   make(map[string][]point),
+		marks:    make(map[string][]float64),
+		features: make(map[string][]frame),
+	}
+}
+
+func (d *trackData) add(env *trackspb.Envelope) {
+	ts := env.GetTimestamp()
+	if ts > d.end {
+		d.end = ts
+	}
+	name := eventName(env)
+	d.counts[name]++
+
+	switch e := env.Event.(type) {
+	case *trackspb.Envelope_KeyChange:
+		d.keys = append(d.keys, label{ts, keyLabel(e.KeyChange.GetKey(), e.KeyChange.GetScale())})
+	case *trackspb.Envelope_ChordChange:
+		d.chords = append(d.chords, label{ts, e.ChordChange.GetChord()})
+	case *trackspb.Envelope_RomanNumeral:
+		d.numerals = append(d.numerals, label{ts, e.RomanNumeral.GetNumeral()})
+	case *trackspb.Envelope_MeterChange:
+		d.meters = append(d.meters, label{ts, e.MeterChange.GetMeter()})
+	case *trackspb.Envelope_Chroma:
+		d.features["chroma"] = append(d.features["chroma"], frame{ts, e.Chroma.GetValues()})
+	case *trackspb.Envelope_Mfcc:
+		d.features["mfcc"] = append(d.features["mfcc"], frame{ts, e.Mfcc.GetValues()})
+	case *trackspb.Envelope_BandsMel:
+		d.features[name] = append(d.features[name], frame{ts, e.BandsMel.GetValues()})
+	case *trackspb.Envelope_BandsBark:
+		d.features[name] = append(d.features[name], frame{ts, e.BandsBark.GetValues()})
+	case *trackspb.Envelope_BandsErb:
+		d.features[name] = append(d.features[name], frame{ts, e.BandsErb.GetValues()})
+	case *trackspb.Envelope_TrackAbort:
+		d.aborted = true
+		d.abortReason = e.TrackAbort.GetReason()
+	}
+
+	if v, ok := eventValue(env); ok {
+		d.series[name] = append(d.series[name], point{ts, v})
+	} else {
+		d.marks[name] = append(d.marks[name], ts)
+	}
+}
+
+// rewind forgets the events at or after t, which a restarted or seeking
+// sender is about to send again.
+func (d *trackData) rewind(t float64) {
+	for name, pts := range d.series {
+		n := len(pts)
+		for n > 0 && pts[n-1].t >= t {
+			n--
+		}
+		d.counts[name] -= len(pts) - n
+		d.series[name] = pts[:n]
+	}
+	for name, ts := range d.marks {
+		n := len(ts)
+		for n > 0 && ts[n-1] >= t {
+			n--
+		}
+		d.counts[name] -= len(ts) - n
+		d.marks[name] = ts[:n]
+	}
+	d.keys = rewindLabels(d.keys, t)
+	d.chords = rewindLabels(d.chords, t)
+	d.numerals = rewindLabels(d.numerals, t)
+	d.meters = rewindLabels(d.meters, t)
+	for name, frames := range d.features {
+		n := len(frames)
+		for n > 0 && frames[n-1].t >= t {
+			n--
+		}
+		d.features[name] = frames[:n]
+	}
+	n := len(d.markers)
+	for n > 0 && d.markers[n-1] >= t {
+		n--
+	}
+	d.markers = d.markers[:n]
+	d.end = t
+}
+
+func rewindLabels(labels []label, t float64) []label {
+	n := len(labels)
+	for n > 0 && labels[n-1].t >= t {
+		n--
+	}
+	return labels[:n]
+}
+
+// duration is the announced track length, or the latest timestamp seen when
+// the sender did not report one.
+func (d *trackData) duration() float64 {
+	if dur := d.start.GetDuration(); dur > 0 {
+		return dur
+	}
+	return d.end
+}
+
+// trackTracker follows track boundaries in the event stream and hands each
+// finished track to the registered hooks. Each stream has its own current
+// track, so a track starting on one deck doesn't cut short the one playing
+// on the other.
+type trackTracker struct {
+	cur   map[string]*trackData // by stream id
+	index map[string]int        // tracks started per stream
+	hooks []func(*trackData)
+}
+
+func (t *trackTracker) onTrackEnd(hook func(*trackData)) {
+	t.hooks = append(t.hooks, hook)
+}
+
+func (t *trackTracker) handle(env *trackspb.Envelope, received time.Time) {
+	stream := env.GetStreamId()
+	if env.GetTrackStart() != nil {
+		t.finishStream(stream)
+		if t.cur == nil {
+			t.cur, t.index = make(map[string]*trackData), make(map[string]int)
+		}
+		t.index[stream]++
+		t.cur[stream] = newTrackData(env, received, t.index[stream])
+	}
+	d := t.cur[stream]
+	if d == nil {
+		return
+	}
+	if env.GetTimelineReset() != nil {
+		d.rewind(env.GetTimestamp())
+	}
+	d.add(env)
+
+	switch env.Event.(type) {
+	case *trackspb.Envelope_TrackEnd, *trackspb.Envelope_TrackAbort:
+		t.finishStream(stream)
+	}
+}
+
+// export writes each finished track to a file named by expanding template
+// (see trackOutput), unless template is empty. name prefixes errors, and
+// what names the file in the line reporting it, if not empty.
+func (t *trackTracker) export(name, template, what string, write func(path string, d *trackData) error) {
+	if template == "" {
+		return
+	}
+	t.onTrackEnd(func(d *trackData) {
+		path := expandTemplate(template, d.start, d.stream, d.started, d.index)
+		if err := write(path, d); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			return
+		}
+		if what != "" {
+			fmt.Printf("%s written to %s\n", what, path)
+		}
+	})
+}
+
+// mark adds an operator's marker to the current track of its stream, if
+// any, and reports whether it did.
+func (t *trackTracker) mark(m operatorMarker) bool {
+	d := t.cur[m.stream]
+	if d == nil {
+		return false
+	}
+	d.markers = append(d.markers, m.t)
+	return true
+}
+
+// finish completes the current track of every stream, in stream order. A
+// track cut short without track.end or track.abort still reaches the hooks.
+func (t *trackTracker) finish() {
+	for _, stream := range slices.Sorted(maps.Keys(t.cur)) {
+		t.finishStream(stream)
+	}
+}
+
+func (t *trackTracker) finishStream(stream string) {
+	d := t.cur[stream]
+	if d == nil {
+		return
+	}
+	for _, hook := range t.hooks {
+		hook(d)
+	}
+	delete(t.cur, stream)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/tracks"
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+func TestTrackTrackerStreams(t *testing.T) {
+	tracker := &trackTracker{}
+	var done []*trackData
+	tracker.onTrackEnd(func(d *trackData) { done = append(done, d) })
+	send := func(stream string, ts float64, ev any) {
+		env := &trackspb.Envelope{StreamId: stream, Timestamp: ts}
+		switch e := ev.(type) {
+		case *trackspb.TrackStart:
+			env.Event = &trackspb.Envelope_TrackStart{TrackStart: e}
+		case *trackspb.TrackEnd:
+			env.Event = &trackspb.Envelope_TrackEnd{TrackEnd: e}
+		case *trackspb.Beat:
+			env.Event = &trackspb.Envelope_Beat{Beat: e}
+		}
+		tracker.handle(env, tracks.Epoch.Add(time.Duration(ts*float64(time.Second))))
+	}
+	send("deckA", 0, &trackspb.TrackStart{Filename: "a.wav"})
+	send("deckA", 1, &trackspb.Beat{})
+	send("deckB", 0, &trackspb.TrackStart{Filename: "b.wav"})
+	if len(done) != 0 {
+		t.Fatalf("deckB's track.start finished %d tracks, want none", len(done))
+	}
+	send("deckA", 2, &trackspb.Beat{})
+	send("deckB", 1, &trackspb.Beat{})
+	if !tracker.mark(operatorMarker{stream: "deckB", t: 1}) || tracker.mark(operatorMarker{stream: "deckC", t: 1}) {
+		t.Error("marker not added to deckB's track only")
+	}
+	send("deckA", 3, &trackspb.TrackEnd{})
+	send("deckA", 4, &trackspb.TrackStart{Filename: "c.wav"})
+	tracker.finish()
+
+	if len(done) != 3 {
+		t.Fatalf("%d tracks finished, want 3", len(done))
+	}
+	for i, want := range []struct {
+		file   string
+		stream string
+		index  int
+		beats  int
+	}{
+		{"a.wav", "deckA", 1, 2},
+		{"c.wav", "deckA", 2, 0},
+		{"b.wav", "deckB", 1, 1},
+	} {
+		d := done[i]
+		if d.start.GetFilename() != want.file || d.stream != want.stream || d.index != want.index || d.counts["beat"] != want.beats {
+			t.Errorf("track %d: %s of %s, #%d, %d beats; want %s of %s, #%d, %d beats", i, d.start.GetFilename(), d.stream, d.index, d.counts["beat"], want.file, want.stream, want.index, want.beats)
+		}
+	}
+	if len(done[2].markers) != 1 {
+		t.Errorf("deckB's track has %d markers, want 1", len(done[2].markers))
+	}
+}
+
+func TestTrackExport(t *testing.T) {
+	dir := t.TempDir()
+	tracker := &trackTracker{}
+	tracker.export("test", "", "Nothing", func(string, *trackData) error {
+		t.Error("export with an empty template called")
+		return nil
+	})
+	tracker.export("test", filepath.Join(dir, "{stream}-{index}.txt"), "Test", func(path string, d *trackData) error {
+		return os.WriteFile(path, []byte(d.start.GetFilename()), 0o644)
+	})
+	for _, stream := range []string{"a", "b"} {
+		tracker.handle(&trackspb.Envelope{StreamId: stream, Event: &trackspb.Envelope_TrackStart{TrackStart: &trackspb.TrackStart{Filename: stream + ".wav"}}}, tracks.Epoch)
+	}
+	tracker.finish()
+	for _, stream := range []string{"a", "b"} {
+		if b, err := os.ReadFile(filepath.Join(dir, stream+"-1.txt")); err != nil || string(b) != stream+".wav" {
+			t.Errorf("%s's export: %q, %v", stream, b, err)
+		}
+	}
+}
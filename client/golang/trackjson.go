@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Per-track JSON summaries (-track-summary): one file per track with its
+// tempo, key, loudness, dynamics, quality events, clipping incidents, hum
+// and structure, written when it ends.
+// Run with -continuous and a template like
+// archive/{date}/{track_filename}-{start_time}.json, they build an analysis
+// archive as tracks play. Every directory written to also gets an
+// index.jsonl with a line per track, for listing the archive without
+// opening each summary.
+
+type trackSummary struct {
+	File        string         `json:"file"`
+	Title       string         `json:"title"`
+	Artist      string         `json:"artist,omitempty"`
+	Album       string         `json:"album,omitempty"`
+	Stream      string         `json:"stream,omitempty"`
+	Received    string         `json:"received"`
+	Status      string         `json:"status"` // completed or aborted
+	AbortReason string         `json:"abort_reason,omitempty"`
+	Duration    float64        `json:"duration"`
+	SampleRate  int32          `json:"sample_rate"`
+	Channels    int32          `json:"channels"`
+	Events      int            `json:"events"`
+	EventCounts map[string]int `json:"event_counts"`
+
+	Tempo    *tempoSummary    `json:"tempo,omitempty"`
+	Groove   *grooveSummary   `json:"groove,omitempty"`
+	Key      string           `json:"key,omitempty"` // held longest
+	Keys     []summaryLabel   `json:"keys,omitempty"`
+	Loudness *valueSummary    `json:"loudness,omitempty"`
+	Dynamics *dynamicsSummary `json:"dynamics,omitempty"`     // see dynamics.go
+	Floor    *noiseFloor      `json:"noise_floor,omitempty"`  // see noisefloor.go
+	Rhythm   *bandProfile     `json:"rhythm_bands,omitempty"` // see rhythmbands.go
+	Quality  []summaryEvent   `json:"quality"`
+	Clipping []clipIncident   `json:"clipping,omitempty"` // see clipreport.go
+	Hum      *humProfile      `json:"hum,omitempty"`      // see hum.go
+	Sections []summarySection `json:"structure,omitempty"`
+	Segments []float64        `json:"segment_boundaries,omitempty"`
+	Fades    []fadeCurve      `json:"fades,omitempty"`       // see fades.go
+	Mix      []transition     `json:"transitions,omitempty"` // see transitions.go
+	Markers  []float64        `json:"markers,omitempty"`     // set by an operator
+}
+
+// valueSummary describes an event's values over the track.
+type valueSummary struct {
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	P10    float64 `json:"p10"`
+	P95    float64 `json:"p95"`
+}
+
+type tempoSummary struct {
+	valueSummary
+	Changes int     `json:"changes"`
+	Beats   int     `json:"beats"`
+	BeatBPM float64 `json:"beat_bpm,omitempty"` // from the median beat interval
+}
+
+// grooveSummary is measureGroove over the whole track.
+type grooveSummary struct {
+	Swing  float64 `json:"swing"`     // percent
+	Ratio  float64 `json:"ratio"`     // long to short eighth note
+	Offset float64 `json:"offset_ms"` // on-beat onsets from the beat; negative ahead
+	Jitter float64 `json:"jitter_ms"`
+	Onsets int32   `json:"onsets"`
+}
+
+type summaryLabel struct {
+	Time  float64 `json:"time"`
+	Label string  `json:"label"`
+}
+
+type summaryEvent struct {
+	Time  float64  `json:"time"`
+	Event string   `json:"event"`
+	Value *float64 `json:"value,omitempty"`
+}
+
+type summarySection struct {
+	Start    float64 `json:"start"`
+	End      float64 `json:"end"`
+	Label    string  `json:"label"`
+	Role     string  `json:"role,omitempty"`
+	Loudness float64 `json:"loudness,omitempty"`
+}
+
+// trackIndexEntry is one line of index.jsonl.
+type trackIndexEntry struct {
+	Summary  string  `json:"summary"` // file name, relative to the index
+	Title    string  `json:"title"`
+	Artist   string  `json:"artist,omitempty"`
+	Stream   string  `json:"stream,omitempty"`
+	Received string  `json:"received"`
+	Status   string  `json:"status"`
+	Duration float64 `json:"duration"`
+	BPM      float64 `json:"bpm,omitempty"`
+	Key      string  `json:"key,omitempty"`
+	DR       *int    `json:"dr,omitempty"`
+	Noisy    bool    `json:"noisy,omitempty"` // noise floor above -noise-floor-max
+	Quality  int     `json:"quality"`
+}
+
+func summarizeValues(pts []point) *valueSummary {
+	if len(pts) == 0 {
+		return nil
+	}
+	vs := make([]float64, len(pts))
+	sum := 0.0
+	for i, p := range pts {
+		vs[i] = p.v
+		sum += p.v
+	}
+	sort.Float64s(vs)
+	at := func(q float64) float64 { return vs[int(math.Round(q*float64(len(vs)-1)))] }
+	return &valueSummary{
+		Mean:   sum / float64(len(vs)),
+		Median: at(0.5),
+		Min:    vs[0],
+		Max:    vs[len(vs)-1],
+		P10:    at(0.1),
+		P95:    at(0.95),
+	}
+}
+
+// beatBPM is the tempo implied by the median interval between beats.
+func beatBPM(beats []float64) float64 {
+	var gaps []float64
+	for i := 1; i < len(beats); i++ {
+		if g := beats[i] - beats[i-1]; g > 0 {
+			gaps = append(gaps, g)
+		}
+	}
+	if len(gaps) == 0 {
+		return 0
+	}
+	sort.Float64s(gaps)
+	return math.Round(600/gaps[len(gaps)/2]) / 10
+}
+
+// eventTimes lists when events of a type arrived, with or without values.
+func eventTimes(d *trackData, name string) []float64 {
+	times := append([]float64(nil), d.marks[name]...)
+	for _, p := range d.series[name] {
+		times = append(times, p.t)
+	}
+	return times
+}
+
+func buildTrackSummary(d *trackData) *trackSummary {
+	s := &trackSummary{
+		File:        d.start.GetFilename(),
+		Title:       d.meta.displayTitle(d.start),
+		Artist:      d.meta.Artist,
+		Album:       d.meta.Album,
+		Stream:      d.stream,
+		Received:    d.started.Format("2006-01-02T15:04:05Z07:00"),
+		Status:      "completed",
+		Duration:    d.duration(),
+		SampleRate:  d.start.GetSampleRate(),
+		Channels:    d.start.GetChannels(),
+		EventCounts: d.counts,
+		Key:         mainKey(d),
+		Loudness:    summarizeValues(d.series["loudness"]),
+		Dynamics:    trackDynamics(d),
+		Floor:       trackNoiseFloor(d),
+		Rhythm:      trackBandProfile(d),
+		Quality:     []summaryEvent{},
+		Clipping:    clipIncidents(d),
+		Hum:         trackHum(d),
+		Segments:    eventTimes(d, "segment.boundary"),
+		Fades:       trackFades(d),
+		Mix:         trackTransitions(d),
+		Markers:     d.markers,
+	}
+	if d.aborted {
+		s.Status, s.AbortReason = "aborted", d.abortReason
+	}
+	for _, n := range d.counts {
+		s.Events += n
+	}
+
+	beats := eventTimes(d, "beat")
+	if tempo := summarizeValues(d.series["tempo.change"]); tempo != nil || len(beats) > 1 {
+		s.Tempo = &tempoSummary{Changes: len(d.series["tempo.change"]), Beats: len(beats), BeatBPM: beatBPM(beats)}
+		if tempo != nil {
+			s.Tempo.valueSummary = *tempo
+		}
+	}
+	if g := measureGroove(beats, eventTimes(d, "onset")); g != nil {
+		s.Groove = &grooveSummary{Swing: g.Swing, Ratio: g.Ratio, Offset: g.Offset, Jitter: g.Jitter, Onsets: g.Onsets}
+	}
+	for _, k := range d.keys {
+		s.Keys = append(s.Keys, summaryLabel{k.t, k.name})
+	}
+
+	for _, name := range qualityEventNames {
+		for _, t := range d.marks[name] {
+			s.Quality = append(s.Quality, summaryEvent{Time: t, Event: name})
+		}
+		for _, p := range d.series[name] {
+			v := p.v
+			s.Quality = append(s.Quality, summaryEvent{Time: p.t, Event: name, Value: &v})
+		}
+	}
+	sort.SliceStable(s.Quality, func(i, j int) bool { return s.Quality[i].Time < s.Quality[j].Time })
+
+	for _, sec := range trackStructure(d) {
+		ss := summarySection{Start: sec.start, End: sec.end, Label: sec.letter, Role: sec.role}
+		if sec.hasLevel {
+			ss.Loudness = sec.loudness
+		}
+		s.Sections = append(s.Sections, ss)
+	}
+	return s
+}
+
+// writeTrackSummary writes d's summary to path and adds it to the
+// index.jsonl beside it.
+func writeTrackSummary(path string, d *trackData) error {
+	dir := filepath.Dir(path)
+	if dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	s := buildTrackSummary(d)
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+
+	entry := trackIndexEntry{
+		Summary:  filepath.Base(path),
+		Title:    s.Title,
+		Artist:   s.Artist,
+		Stream:   s.Stream,
+		Received: s.Received,
+		Status:   s.Status,
+		Duration: s.Duration,
+		Key:      s.Key,
+		Quality:  len(s.Quality),
+	}
+	if s.Dynamics != nil {
+		entry.DR = &s.Dynamics.DR
+	}
+	if s.Floor != nil {
+		entry.Noisy = s.Floor.Exceeded
+	}
+	if s.Tempo != nil {
+		entry.BPM = s.Tempo.Median
+		if entry.BPM == 0 {
+			entry.BPM = s.Tempo.BeatBPM
+		}
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "index.jsonl"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
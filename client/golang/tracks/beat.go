@@ -0,0 +1,170 @@
+package tracks
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// BeatClock follows the beat grid of a track, so visuals can animate ahead
+// of the beat rather than react to it: it reports where in the current
+// beat and bar the track is, and how long until the next beat and
+// downbeat.
+//
+// The grid is anchored on the latest Beat, and its period follows
+// TempoChange, refined by the intervals between beats: each interval close
+// to a whole number of periods (beats the analysis missed are allowed for)
+// moves the period by Smoothing of the difference. Bars are counted from
+// the latest Downbeat, with the number of beats per bar taken from the
+// latest MeterChange (derived by receivers) or, without one, measured
+// between downbeats (4 until two have been seen). The track position
+// between events comes from a PositionEstimator. The grid is considered
+// lost MaxGap after the last beat, as in a breakdown without beats.
+//
+// A BeatClock is safe for concurrent use.
+type BeatClock struct {
+	// Smoothing is the weight of a new beat interval in the period, 0 to
+	// 1. Default 0.2.
+	Smoothing float64
+	// MaxGap is how long after the last beat the grid is still
+	// extrapolated. Default 4s.
+	MaxGap time.Duration
+
+	pos *PositionEstimator
+
+	mu          sync.Mutex
+	period      float64 // seconds per beat, 0 if unknown
+	lastBeat    float64
+	hasBeat     bool
+	lastDown    float64
+	hasDown     bool
+	beatsPerBar int
+	meter       string // from MeterChange
+}
+
+// BeatPhase is the state of the beat grid at one moment. Beat is the
+// phase within the current beat: 0 on the beat, rising towards 1 just
+// before the next one; Bar is the same for the bar. Durations are in
+// wall-clock time, at the measured playback rate.
+type BeatPhase struct {
+	Valid       bool    // a beat grid is known; the other fields are zero otherwise
+	Position    float64 // track position, seconds
+	BPM         float64 // the grid's smoothed tempo
+	Beat        float64
+	NextBeat    time.Duration
+	BarValid    bool // a downbeat has been seen; the bar fields are zero otherwise
+	Bar         float64
+	BeatInBar   int // 1 on the downbeat
+	BeatsPerBar int
+	Meter       string        // the latest MeterChange's, e.g. "6/8"; "" if none
+	NextBar     time.Duration // until the next downbeat
+}
+
+// NewBeatClock returns a beat clock with the default settings.
+func NewBeatClock() *BeatClock {
+	return &BeatClock{
+		Smoothing:   0.2,
+		MaxGap:      4 * time.Second,
+		pos:         NewPositionEstimator(),
+		beatsPerBar: 4,
+	}
+}
+
+// Observe feeds an envelope received at the given time. Beat, Downbeat,
+// TempoChange and MeterChange build the grid; TrackStart, TrackEnd, TrackAbort and
+// TimelineReset clear it. Transport events also go to the clock's
+// PositionEstimator.
+func (c *BeatClock) Observe(env *trackspb.Envelope, received time.Time) {
+	c.pos.Observe(env, received)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := env.GetTimestamp()
+	switch e := env.Event.(type) {
+	case *trackspb.Envelope_TrackStart, *trackspb.Envelope_TrackEnd,
+		*trackspb.Envelope_TrackAbort, *trackspb.Envelope_TimelineReset:
+		c.period, c.hasBeat, c.hasDown, c.beatsPerBar, c.meter = 0, false, false, 4, ""
+	case *trackspb.Envelope_TempoChange:
+		if bpm := e.TempoChange.GetBpm(); bpm > 0 {
+			c.period = 60 / bpm
+		}
+	case *trackspb.Envelope_Beat:
+		c.beat(t)
+	case *trackspb.Envelope_MeterChange:
+		if n := int(e.MeterChange.GetBeats()); n >= 1 && n <= 16 {
+			c.beatsPerBar, c.meter = n, e.MeterChange.GetMeter()
+		}
+	case *trackspb.Envelope_Downbeat:
+		if c.meter == "" && c.hasDown && c.period > 0 {
+			if n := int(math.Round((t - c.lastDown) / c.period)); n >= 2 && n <= 12 {
+				c.beatsPerBar = n
+			}
+		}
+		c.lastDown, c.hasDown = t, true
+	}
+}
+
+func (c *BeatClock) beat(t float64) {
+	if c.hasBeat && t > c.lastBeat {
+		iv := t - c.lastBeat
+		switch {
+		case c.period > 0:
+			if k := math.Round(iv / c.period); k >= 1 && k <= 4 {
+				if one := iv / k; math.Abs(one-c.period) < 0.25*c.period {
+					c.period += c.Smoothing * (one - c.period)
+				}
+			}
+		case iv > 0.2 && iv < 2: // 30 to 300 BPM
+			c.period = iv
+		}
+	}
+	c.lastBeat, c.hasBeat = t, true
+}
+
+// At returns the beat phase at time now.
+func (c *BeatClock) At(now time.Time) BeatPhase {
+	return c.phase(c.pos.Position(now), c.pos.Rate())
+}
+
+// AtPosition returns the beat phase at track position pos (seconds),
+// taking playback to run in real time.
+func (c *BeatClock) AtPosition(pos float64) BeatPhase {
+	return c.phase(pos, 1)
+}
+
+// Position returns the clock's PositionEstimator.
+func (c *BeatClock) Position() *PositionEstimator {
+	return c.pos
+}
+
+func (c *BeatClock) phase(pos, rate float64) BeatPhase {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.hasBeat || c.period <= 0 || pos-c.lastBeat > c.MaxGap.Seconds() {
+		return BeatPhase{}
+	}
+	wall := func(sec float64) time.Duration {
+		return time.Duration(sec / rate * float64(time.Second))
+	}
+	x := (pos - c.lastBeat) / c.period
+	p := BeatPhase{
+		Valid:    true,
+		Position: pos,
+		BPM:      60 / c.period,
+		Beat:     x - math.Floor(x),
+	}
+	p.NextBeat = wall((1 - p.Beat) * c.period)
+	if c.hasDown {
+		n := c.beatsPerBar
+		beats := int(math.Round((c.lastBeat-c.lastDown)/c.period)) + int(math.Floor(x))
+		i := (beats%n + n) % n
+		p.BarValid = true
+		p.BeatsPerBar = n
+		p.Meter = c.meter
+		p.BeatInBar = i + 1
+		p.Bar = (float64(i) + p.Beat) / float64(n)
+		p.NextBar = wall((float64(n-i) - p.Beat) * c.period)
+	}
+	return p
+}
@@ -0,0 +1,58 @@
+package tracks
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time to code that stamps or paces events as they are
+// received. The types in this package take times as arguments instead, so
+// they can be driven by any Clock: SystemClock in a live program, a
+// VirtualClock in tests and replays, which then run as fast as the events
+// can be handled and give the same results every time.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the wall clock.
+type SystemClock struct{}
+
+// Now returns time.Now().
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// VirtualClock is a Clock that only moves when it is told to, as a replay
+// sets it to the receive time of each recorded event. The zero value reads
+// the zero time until it is set.
+//
+// A VirtualClock is safe for concurrent use.
+type VirtualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewVirtualClock returns a virtual clock reading t.
+func NewVirtualClock(t time.Time) *VirtualClock {
+	return &VirtualClock{now: t}
+}
+
+// Now returns the time the clock was last set to.
+func (c *VirtualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set moves the clock to t, which may be earlier than the time it reads.
+func (c *VirtualClock) Set(t time.Time) {
+	c.mu.Lock()
+	c.now = t
+	c.mu.Unlock()
+}
+
+// Advance moves the clock forward by d and returns the new time.
+func (c *VirtualClock) Advance(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	return c.now
+}
@@ -0,0 +1,4 @@
+// Package tracks provides building blocks for Go programs that consume
+// TRACKS event streams, for use alongside the generated trackspb messages.
+// The receiver in the parent directory is built from the same pieces.
+package tracks
@@ -0,0 +1,179 @@
+package tracks
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// PositionEstimator interpolates the playback position of a track between
+// TrackPosition updates, which the sender only emits periodically, so user
+// interfaces can show smooth progress and keep overlays in sync.
+//
+// Between updates the position advances at the measured playback rate.
+// Each update is compared with the estimate: small errors (network jitter,
+// clock drift) are absorbed gradually over SlewTime, so the position never
+// jumps backwards, while errors larger than JumpThreshold (a seek, or a
+// pause the estimator didn't notice) are applied at once. The estimate
+// stops advancing MaxExtrapolation after the last update, so a paused or
+// stalled sender doesn't run the position away.
+//
+// A PositionEstimator is safe for concurrent use.
+type PositionEstimator struct {
+	// JumpThreshold is the largest error corrected gradually; larger
+	// errors reset the estimate. Default 1s.
+	JumpThreshold time.Duration
+	// SlewTime is how long small errors take to be absorbed. Default 2s.
+	SlewTime time.Duration
+	// MaxExtrapolation is how far past the last update the estimate keeps
+	// advancing. Default 3s.
+	MaxExtrapolation time.Duration
+
+	mu       sync.Mutex
+	duration float64
+	playing  bool
+	anchor   float64   // estimated position at anchorAt, before correction
+	anchorAt time.Time // wall-clock time of the last update
+	rate     float64   // playback seconds per wall-clock second
+	err      float64   // error still being slewed in, seconds
+	last     float64   // position of the last update
+	updated  bool      // any update since the track started
+}
+
+// NewPositionEstimator returns an estimator with the default settings.
+func NewPositionEstimator() *PositionEstimator {
+	return &PositionEstimator{
+		JumpThreshold:    time.Second,
+		SlewTime:         2 * time.Second,
+		MaxExtrapolation: 3 * time.Second,
+		rate:             1,
+	}
+}
+
+// Observe feeds an envelope received at the given time: TrackStart starts
+// a new track at position 0, TrackPosition updates the estimate, and
+// TrackEnd or TrackAbort stop it, and TimelineReset (derived by receivers
+// when timestamps jump back) seeks to its timestamp. Other events are
+// ignored.
+func (p *PositionEstimator) Observe(env *trackspb.Envelope, received time.Time) {
+	switch e := env.Event.(type) {
+	case *trackspb.Envelope_TrackStart:
+		p.Start(e.TrackStart.GetDuration(), received)
+	case *trackspb.Envelope_TrackPosition:
+		p.Update(e.TrackPosition.GetPosition(), received)
+	case *trackspb.Envelope_TrackEnd:
+		p.Stop(p.Duration(), received)
+	case *trackspb.Envelope_TrackAbort:
+		p.Stop(env.GetTimestamp(), received)
+	case *trackspb.Envelope_TimelineReset:
+		p.Seek(env.GetTimestamp(), received)
+	}
+}
+
+// Start begins a new track of the given duration (0 if unknown), playing
+// from position 0 at time at.
+func (p *PositionEstimator) Start(duration float64, at time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.duration = duration
+	p.playing = true
+	p.anchor, p.anchorAt = 0, at
+	p.rate, p.err, p.last = 1, 0, 0
+	p.updated = false
+}
+
+// Update reports that the track was at position pos (seconds) at time at.
+func (p *PositionEstimator) Update(pos float64, at time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	predicted := p.position(at)
+	if p.updated && p.playing {
+		// Measure the playback rate from consecutive updates; a smoothed
+		// rate keeps one late packet from skewing it.
+		if dt := at.Sub(p.anchorAt).Seconds(); dt > 0.05 {
+			observed := (pos - p.last) / dt
+			if observed > 0.5 && observed < 2 {
+				p.rate += 0.2 * (observed - p.rate)
+			}
+		}
+	}
+	p.playing = true
+	p.last = pos
+	p.updated = true
+
+	if math.Abs(pos-predicted) > p.JumpThreshold.Seconds() {
+		p.anchor, p.err = pos, 0
+	} else {
+		p.anchor, p.err = predicted, pos-predicted
+	}
+	p.anchorAt = at
+}
+
+// Seek moves the position to pos at time at, as when the sender restarts
+// or seeks within the track. Unlike a large Update, it does not measure the
+// playback rate across the jump.
+func (p *PositionEstimator) Seek(pos float64, at time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.playing = true
+	p.anchor, p.anchorAt, p.err, p.last = pos, at, 0, pos
+	p.updated = false
+}
+
+// Stop freezes the position at pos, as at the end of a track.
+func (p *PositionEstimator) Stop(pos float64, at time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.playing = false
+	p.anchor, p.anchorAt, p.err, p.last = pos, at, 0, pos
+}
+
+// Position returns the estimated position in seconds at time now, within
+// [0, Duration] when the duration is known.
+func (p *PositionEstimator) Position(now time.Time) float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.position(now)
+}
+
+func (p *PositionEstimator) position(now time.Time) float64 {
+	pos := p.anchor
+	if p.playing {
+		dt := now.Sub(p.anchorAt).Seconds()
+		dt = max(min(dt, p.MaxExtrapolation.Seconds()), 0)
+		pos += p.rate * dt
+		if slew := p.SlewTime.Seconds(); slew > 0 && dt < slew {
+			pos += p.err * dt / slew
+		} else {
+			pos += p.err
+		}
+	}
+	if p.duration > 0 {
+		pos = min(pos, p.duration)
+	}
+	return max(pos, 0)
+}
+
+// Duration returns the track's announced duration, or 0 if unknown.
+func (p *PositionEstimator) Duration() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.duration
+}
+
+// Playing reports whether a track has started and not yet ended.
+func (p *PositionEstimator) Playing() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.playing
+}
+
+// Rate returns the measured playback rate, 1 for real time.
+func (p *PositionEstimator) Rate() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rate
+}
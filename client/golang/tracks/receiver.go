@@ -0,0 +1,355 @@
+package tracks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"google.golang.org/protobuf/proto"
+)
+
+// Receiver hands the envelopes of a TRACKS stream to the programs
+// subscribed to it (see Subscribe). Listen returns one reading a multicast
+// group; one from NewReceiver is fed with Observe instead, from another
+// transport, a recording or a test.
+//
+// Subscribers get envelopes on buffered channels. A subscriber that falls
+// more than BufferSize envelopes behind misses the newer ones rather than
+// holding up the others; Dropped counts them.
+//
+// A Receiver is safe for concurrent use.
+type Receiver struct {
+	// Clock stamps the envelopes Listen receives. Default SystemClock.
+	Clock Clock
+	// BufferSize is the capacity of the channels of later subscriptions.
+	// Default 256.
+	BufferSize int
+
+	conn    net.PacketConn
+	done    chan struct{}
+	dropped atomic.Int64
+	invalid atomic.Int64
+
+	mu      sync.Mutex
+	subs    []*subscription
+	closed  bool
+	origins map[origin]*originState
+	starts  []func(s *Session)
+
+	pullOnce sync.Once
+	pull     <-chan TimedEvent[*trackspb.Envelope] // for Next
+}
+
+// Delivery is an envelope as a Receiver delivered it, with the context of
+// its reception. Envelopes carry no sequence number or track ID of their
+// own, so Seq and Track are counted by the receiver, separately for each
+// source and stream; a gap in Seq is therefore not a loss, but Track tells
+// tracks apart even where streams interleave.
+type Delivery struct {
+	Envelope  *trackspb.Envelope
+	Timestamp float64   // track position, seconds
+	Source    string    // sender address, "" if observed without one
+	Stream    string    // the envelope's stream_id, "" if unset
+	Received  time.Time // when it was read, by the receiver's Clock
+	Seq       uint64    // envelopes from this source and stream so far, 1 for the first
+	Track     uint64    // tracks started by this source and stream so far, 0 before the first
+	// Latency is the time from the sender's send_time_ns to Received,
+	// 0 if the sender doesn't stamp envelopes. It includes any difference
+	// between the two machines' clocks.
+	Latency time.Duration
+}
+
+// origin identifies where envelopes come from, for counting them.
+type origin struct{ source, stream string }
+
+type originState struct {
+	seq, track uint64
+	session    *Session // the track playing, nil between tracks
+
+	// Session callbacks waiting to run, in Seq order, and whether a
+	// goroutine is running them.
+	pending []func()
+	running bool
+}
+
+// ErrClosed is returned by Next once the receiver is closed and every
+// envelope received before has been returned.
+var ErrClosed = errors.New("tracks: receiver closed")
+
+// subscription is one subscriber's channel. send offers it a delivery,
+// reporting false if it is full; close closes it.
+type subscription struct {
+	ch    any // the channel, to find it by
+	send  func(d *Delivery) bool
+	close func()
+}
+
+// NewReceiver returns a receiver with the default settings, without a
+// transport.
+func NewReceiver() *Receiver {
+	return &Receiver{Clock: SystemClock{}, BufferSize: 256}
+}
+
+// Listen joins the multicast group on port and returns a receiver of the
+// envelopes that arrive there, until it is closed. It takes one envelope
+// per datagram, in the protobuf encoding: datagrams framed for FEC or
+// fragmented (sender --fec, --mtu), in another encoding, or holding an
+// envelope that fails CheckEnvelope, are counted by Invalid and passed over.
+func Listen(group string, port int) (*Receiver, error) {
+	ip := net.ParseIP(group)
+	if ip == nil || ip.To4() == nil {
+		return nil, fmt.Errorf("invalid multicast group %q", group)
+	}
+	conn, err := net.ListenMulticastUDP("udp4", nil, &net.UDPAddr{IP: ip, Port: port})
+	if err != nil {
+		return nil, err
+	}
+	return NewPacketReceiver(conn), nil
+}
+
+// NewPacketReceiver returns a receiver of the envelopes read from conn, as
+// Listen does, which closes conn when it is closed.
+func NewPacketReceiver(conn net.PacketConn) *Receiver {
+	r := NewReceiver()
+	r.conn = conn
+	r.done = make(chan struct{})
+	go r.read()
+	return r
+}
+
+func (r *Receiver) read() {
+	defer close(r.done)
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		env := &trackspb.Envelope{}
+		if proto.Unmarshal(buf[:n], env) != nil || env.Event == nil || CheckEnvelope(env) != nil {
+			r.invalid.Add(1)
+			continue
+		}
+		r.ObserveFrom(env, addr.String(), r.Clock.Now())
+	}
+}
+
+// Observe hands an envelope received at the given time to the
+// subscribers, without a source. Envelopes observed after Close are
+// ignored.
+func (r *Receiver) Observe(env *trackspb.Envelope, received time.Time) {
+	r.ObserveFrom(env, "", received)
+}
+
+// ObserveFrom is Observe for an envelope from the sender at address
+// source.
+func (r *Receiver) ObserveFrom(env *trackspb.Envelope, source string, received time.Time) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	o := origin{source, env.GetStreamId()}
+	st := r.origins[o]
+	if st == nil {
+		if r.origins == nil {
+			r.origins = make(map[origin]*originState)
+		}
+		st = &originState{}
+		r.origins[o] = st
+	}
+	st.seq++
+	var cut, started *Session
+	if env.GetTrackStart() != nil {
+		st.track++
+		cut = st.session
+	}
+	d := &Delivery{
+		Envelope:  env,
+		Timestamp: env.GetTimestamp(),
+		Source:    source,
+		Stream:    o.stream,
+		Received:  received,
+		Seq:       st.seq,
+		Track:     st.track,
+	}
+	if ns := env.GetSendTimeNs(); ns != 0 {
+		d.Latency = received.Sub(time.Unix(0, ns))
+	}
+	for _, s := range r.subs {
+		if !s.send(d) {
+			r.dropped.Add(1)
+		}
+	}
+	if env.GetTrackStart() != nil {
+		started = newSession(d)
+		st.session = started
+	}
+	session := st.session
+	switch env.Event.(type) {
+	case *trackspb.Envelope_TrackEnd, *trackspb.Envelope_TrackAbort:
+		st.session = nil
+	}
+	if cut == nil && session == nil {
+		r.mu.Unlock()
+		return
+	}
+	starts := r.starts
+	st.pending = append(st.pending, func() {
+		if cut != nil {
+			cut.cut(received)
+		}
+		if started != nil {
+			for _, f := range starts {
+				f(started)
+			}
+		}
+		if session != nil {
+			session.observe(d)
+		}
+	})
+	if st.running {
+		// The goroutine running the origin's callbacks runs these too.
+		r.mu.Unlock()
+		return
+	}
+	st.running = true
+	r.mu.Unlock()
+	r.runCallbacks(st)
+}
+
+// runCallbacks runs the session callbacks queued for an origin, one at a
+// time and in order, until none are left. They run unlocked, so they may
+// call the receiver, and envelopes another goroutine observes from the
+// same origin meanwhile have their callbacks run here, after these.
+func (r *Receiver) runCallbacks(st *originState) {
+	for {
+		r.mu.Lock()
+		if len(st.pending) == 0 {
+			st.running = false
+			r.mu.Unlock()
+			return
+		}
+		f := st.pending[0]
+		st.pending[0] = nil
+		st.pending = st.pending[1:]
+		r.mu.Unlock()
+		f()
+	}
+}
+
+// OnTrackStart registers f to be called with the Session of every track
+// that starts from now on, before the Session gets its first envelope.
+func (r *Receiver) OnTrackStart(f func(s *Session)) {
+	r.mu.Lock()
+	r.starts = append(r.starts, f)
+	r.mu.Unlock()
+}
+
+// subscribe adds a subscription, or closes it at once if the receiver is
+// closed.
+func (r *Receiver) subscribe(s *subscription) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		s.close()
+		return
+	}
+	r.subs = append(r.subs, s)
+}
+
+// unsubscribe removes and closes the subscription of channel ch, if r
+// has it.
+func (r *Receiver) unsubscribe(ch any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, s := range r.subs {
+		if s.ch == ch {
+			r.subs = append(r.subs[:i:i], r.subs[i+1:]...)
+			s.close()
+			return
+		}
+	}
+}
+
+// bufferSize returns the capacity for a new subscription's channel.
+func (r *Receiver) bufferSize() int {
+	if r.BufferSize > 0 {
+		return r.BufferSize
+	}
+	return 256
+}
+
+// Next returns the next envelope received as a Delivery, waiting for one until ctx is
+// done. The first call subscribes to every envelope, as Subscribe does, so
+// envelopes received before it are not returned; a caller that falls
+// BufferSize envelopes behind misses newer ones. After Close, Next returns
+// the envelopes still buffered, then ErrClosed.
+func (r *Receiver) Next(ctx context.Context) (*Delivery, error) {
+	r.pullOnce.Do(func() { r.pull = Subscribe[*trackspb.Envelope](r) })
+	select {
+	case ev, ok := <-r.pull:
+		if !ok {
+			return nil, ErrClosed
+		}
+		return &ev.Delivery, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// All returns an iterator over the envelopes received, from Next, which
+// ends when Next returns an error: the receiver was closed or ctx is done.
+//
+//	for d := range r.All(ctx) {
+//		...
+//	}
+func (r *Receiver) All(ctx context.Context) iter.Seq[*Delivery] {
+	return func(yield func(*Delivery) bool) {
+		for {
+			d, err := r.Next(ctx)
+			if err != nil || !yield(d) {
+				return
+			}
+		}
+	}
+}
+
+// Dropped returns the number of envelopes subscribers missed because their
+// channels were full.
+func (r *Receiver) Dropped() int64 { return r.dropped.Load() }
+
+// Invalid returns the number of datagrams Listen could not take as an
+// envelope.
+func (r *Receiver) Invalid() int64 { return r.invalid.Load() }
+
+// Close stops the receiver, closing its connection if it has one, and
+// closes the channels of its subscriptions; envelopes still buffered in
+// them can be read first.
+func (r *Receiver) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	subs := r.subs
+	r.subs = nil
+	r.mu.Unlock()
+
+	var err error
+	if r.conn != nil {
+		err = r.conn.Close()
+		<-r.done
+	}
+	for _, s := range subs {
+		s.close()
+	}
+	return err
+}
@@ -0,0 +1,131 @@
+package tracks
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// startPulling makes r's first call of Next, which subscribes it, and
+// checks that it returns at once with nothing received.
+func startPulling(t *testing.T, r *Receiver) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if d, err := r.Next(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Next on an idle receiver = %v, %v; want context.Canceled", d, err)
+	}
+}
+
+func TestNextWaits(t *testing.T) {
+	r := NewReceiver()
+	defer r.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := r.Next(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Next with nothing received = %v, want context.DeadlineExceeded", err)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		r.Observe(beatAt(1), epoch)
+	}()
+	d, err := r.Next(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Envelope.GetBeat() == nil || d.Timestamp != 1 || d.Seq != 1 || !d.Received.Equal(epoch) {
+		t.Errorf("Next = %+v, want the beat at 1 s, #1, received at %v", d, epoch)
+	}
+}
+
+func TestNextAfterClose(t *testing.T) {
+	r := NewReceiver()
+	startPulling(t, r)
+	r.Observe(beatAt(1), epoch)
+	r.Observe(beatAt(2), epoch)
+	r.Close()
+	r.Observe(beatAt(3), epoch) // ignored
+
+	for _, want := range []float64{1, 2} {
+		d, err := r.Next(context.Background())
+		if err != nil || d.Timestamp != want {
+			t.Fatalf("Next after Close = %v, %v; want the beat at %g buffered before", d, err, want)
+		}
+	}
+	for range 2 {
+		if d, err := r.Next(context.Background()); err != ErrClosed {
+			t.Errorf("Next once drained = %v, %v; want ErrClosed", d, err)
+		}
+	}
+}
+
+func TestNextUnblockedByClose(t *testing.T) {
+	r := NewReceiver()
+	startPulling(t, r)
+	errc := make(chan error)
+	go func() {
+		_, err := r.Next(context.Background())
+		errc <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	r.Close()
+	select {
+	case err := <-errc:
+		if err != ErrClosed {
+			t.Errorf("waiting Next = %v after Close, want ErrClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Next still waiting a second after Close")
+	}
+}
+
+func TestAllStopsEarly(t *testing.T) {
+	r := NewReceiver()
+	defer r.Close()
+	startPulling(t, r)
+	for i := range 5 {
+		r.Observe(beatAt(float64(i)), epoch)
+	}
+
+	var got []float64
+	for d := range r.All(context.Background()) {
+		got = append(got, d.Timestamp)
+		if len(got) == 2 {
+			break
+		}
+	}
+	if len(got) != 2 || got[0] != 0 || got[1] != 1 {
+		t.Fatalf("All until break = %v, want [0 1]", got)
+	}
+	// Breaking consumed nothing more: the rest are still there.
+	if d, err := r.Next(context.Background()); err != nil || d.Timestamp != 2 {
+		t.Errorf("Next after the break = %v, %v; want the beat at 2", d, err)
+	}
+
+	// The iterator also ends when its context is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	n := 0
+	for range r.All(ctx) {
+		n++
+	}
+	if n != 2 {
+		t.Errorf("All until cancelled yielded %d, want the 2 left", n)
+	}
+}
+
+func TestAllEndsOnClose(t *testing.T) {
+	r := NewReceiver()
+	startPulling(t, r)
+	r.Observe(beatAt(0), epoch)
+	time.AfterFunc(20*time.Millisecond, func() { r.Close() })
+	n := 0
+	for range r.All(context.Background()) {
+		n++
+	}
+	if n != 1 {
+		t.Errorf("All until Close yielded %d, want 1", n)
+	}
+}
@@ -0,0 +1,73 @@
+package tracks
+
+import (
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Epoch is the receive time of the start of the scenarios and of the
+// trackstest fixtures; each envelope is received at Epoch plus its time.
+var Epoch = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// Record is one envelope of a recording and the time it was received.
+type Record struct {
+	Received time.Time
+	Envelope *trackspb.Envelope
+}
+
+// Recording is a stream as a receiver got it, in order.
+type Recording struct {
+	Name    string
+	Records []Record
+}
+
+// Envelopes returns the recording's envelopes in order.
+func (r *Recording) Envelopes() []*trackspb.Envelope {
+	envs := make([]*trackspb.Envelope, len(r.Records))
+	for i, rec := range r.Records {
+		envs[i] = rec.Envelope
+	}
+	return envs
+}
+
+// Play hands every record to observe in order, at once. A non-nil clock is
+// set to each record's receive time before it is handed over, for code that
+// reads the time from it.
+func (r *Recording) Play(clock *VirtualClock, observe func(env *trackspb.Envelope, received time.Time)) {
+	for _, rec := range r.Records {
+		if clock != nil {
+			clock.Set(rec.Received)
+		}
+		observe(rec.Envelope, rec.Received)
+	}
+}
+
+// Until returns the part of the recording before timestamp t (seconds),
+// sharing its envelopes, to look at the state in the middle of a track.
+func (r *Recording) Until(t float64) *Recording {
+	part := &Recording{Name: r.Name}
+	for _, rec := range r.Records {
+		if rec.Envelope.Timestamp < t {
+			part.Records = append(part.Records, rec)
+		}
+	}
+	return part
+}
+
+// End is the receive time of the last record.
+func (r *Recording) End() time.Time {
+	if len(r.Records) == 0 {
+		return Epoch
+	}
+	return r.Records[len(r.Records)-1].Received
+}
+
+// OnStream sets the stream id of every envelope, as a sender run with
+// --stream-id would, and returns r.
+func (r *Recording) OnStream(id string) *Recording {
+	for _, rec := range r.Records {
+		rec.Envelope.StreamId = id
+	}
+	return r
+}
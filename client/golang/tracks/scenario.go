@@ -0,0 +1,175 @@
+package tracks
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"gopkg.in/yaml.v3"
+)
+
+// A scenario is a stream scripted in YAML, to reproduce sequences that are
+// hard to get from a real sender, such as a track aborted halfway or a
+// track.start before the last track ended:
+//
+//	name: abort-mid-track
+//	stream: studio-a               # stream id of every event; default none
+//	events:
+//	  - at: 0                      # seconds from the start, when it is sent
+//	    event: track.start
+//	    fields: {filename: a.wav, duration: 180}
+//	  - at: 0
+//	    every: 0.5                 # repeated every 0.5 s ...
+//	    until: 12.5                # ... up to, but not including, 12.5 s
+//	    event: beat
+//	    fields: {confidence: 0.9}
+//	  - at: 12.5
+//	    timestamp: 11              # its timestamp, if not at: a late packet
+//	    event: track.abort
+//	    fields: {reason: user_interrupt}
+//
+// Event names are as receivers print them, and fields are the event
+// message's, by their proto or JSON names; a track.position without a
+// position is at its timestamp. Events are sent in the order of
+// at, and in file order at the same time.
+type scenarioFile struct {
+	Name   string          `yaml:"name"`
+	Stream string          `yaml:"stream"`
+	Events []scenarioEvent `yaml:"events"`
+}
+
+type scenarioEvent struct {
+	At        float64        `yaml:"at"`
+	Every     float64        `yaml:"every"`
+	Until     float64        `yaml:"until"`
+	Timestamp *float64       `yaml:"timestamp"`
+	Stream    *string        `yaml:"stream"`
+	Event     string         `yaml:"event"`
+	Fields    map[string]any `yaml:"fields"`
+}
+
+//go:embed scenarios/*.yaml
+var builtinScenarios embed.FS
+
+// ParseScenario builds the recording a scenario describes, each event
+// received at Epoch plus its at.
+func ParseScenario(data []byte) (*Recording, error) {
+	var sc scenarioFile
+	if err := yaml.Unmarshal(data, &sc); err != nil {
+		return nil, err
+	}
+	if len(sc.Events) == 0 {
+		return nil, fmt.Errorf("scenario has no events")
+	}
+	type sent struct {
+		at  float64
+		env *trackspb.Envelope
+	}
+	var all []sent
+	for i, ev := range sc.Events {
+		if ev.At < 0 || ev.Every < 0 {
+			return nil, fmt.Errorf("event %d (%s): at and every must not be negative", i+1, ev.Event)
+		}
+		if ev.Every > 0 && ev.Until <= ev.At {
+			return nil, fmt.Errorf("event %d (%s): every needs an until after at", i+1, ev.Event)
+		}
+		// Checked once, so a repeated event fails with its own index.
+		if _, err := scenarioEnvelope(ev); err != nil {
+			return nil, fmt.Errorf("event %d: %w", i+1, err)
+		}
+		for n := 0; ; n++ {
+			at := ev.At + float64(n)*ev.Every
+			if n > 0 && (ev.Every == 0 || at >= ev.Until-1e-9) {
+				break
+			}
+			env, _ := scenarioEnvelope(ev)
+			env.StreamId = sc.Stream
+			if ev.Stream != nil {
+				env.StreamId = *ev.Stream
+			}
+			env.Timestamp = at
+			if ev.Timestamp != nil {
+				env.Timestamp = *ev.Timestamp + (at - ev.At)
+			}
+			if p, ok := env.Event.(*trackspb.Envelope_TrackPosition); ok && ev.Fields["position"] == nil {
+				p.TrackPosition.Position = env.Timestamp
+			}
+			all = append(all, sent{at, env})
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].at < all[j].at })
+	rec := &Recording{Name: sc.Name, Records: make([]Record, len(all))}
+	for i, s := range all {
+		rec.Records[i] = Record{Received: Epoch.Add(time.Duration(s.at * float64(time.Second))), Envelope: s.env}
+	}
+	return rec, nil
+}
+
+// scenarioEnvelope makes the envelope of a scenario event.
+func scenarioEnvelope(ev scenarioEvent) (*trackspb.Envelope, error) {
+	env := &trackspb.Envelope{}
+	m := env.ProtoReflect()
+	fd := m.Descriptor().Oneofs().ByName("event").Fields().ByName(protoreflect.Name(strings.ReplaceAll(ev.Event, ".", "_")))
+	if fd == nil || fd.Kind() != protoreflect.MessageKind {
+		return nil, fmt.Errorf("unknown event %q", ev.Event)
+	}
+	msg := m.NewField(fd).Message()
+	if len(ev.Fields) > 0 {
+		b, err := json.Marshal(ev.Fields)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ev.Event, err)
+		}
+		if err := protojson.Unmarshal(b, msg.Interface()); err != nil {
+			return nil, fmt.Errorf("%s: %w", ev.Event, err)
+		}
+	}
+	m.Set(fd, protoreflect.ValueOfMessage(msg))
+	return env, nil
+}
+
+// LoadScenario builds the recording of the scenario file at path.
+func LoadScenario(path string) (*Recording, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := ParseScenario(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rec, nil
+}
+
+// Scenario builds the recording of a built-in scenario:
+//
+//	abort-mid-track           a track.abort 12.5 s into a track, then the next track
+//	overlapping-track-starts  a second track.start 8 s into a track that never ends
+func Scenario(name string) (*Recording, error) {
+	data, err := builtinScenarios.ReadFile("scenarios/" + name + ".yaml")
+	if err != nil {
+		return nil, fmt.Errorf("no scenario %q (have %s)", name, strings.Join(ScenarioNames(), ", "))
+	}
+	rec, err := ParseScenario(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return rec, nil
+}
+
+// ScenarioNames lists the built-in scenarios.
+func ScenarioNames() []string {
+	entries, _ := builtinScenarios.ReadDir("scenarios")
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = strings.TrimSuffix(e.Name(), path.Ext(e.Name()))
+	}
+	return names
+}
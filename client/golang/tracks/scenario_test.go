@@ -0,0 +1,122 @@
+package tracks
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sentAt returns the time a record was received, in seconds from Epoch.
+func sentAt(r Record) float64 { return r.Received.Sub(Epoch).Seconds() }
+
+func TestScenarioNames(t *testing.T) {
+	want := []string{"abort-mid-track", "overlapping-track-starts"}
+	if got := ScenarioNames(); !slices.Equal(got, want) {
+		t.Errorf("ScenarioNames() = %q, want %q", got, want)
+	}
+	if _, err := Scenario("no-such"); err == nil {
+		t.Error("Scenario(no-such) succeeded")
+	}
+}
+
+func TestAbortMidTrack(t *testing.T) {
+	rec, err := Scenario("abort-mid-track")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var starts, aborts, ends []Record
+	for _, r := range rec.Records {
+		switch {
+		case r.Envelope.GetTrackStart() != nil:
+			starts = append(starts, r)
+		case r.Envelope.GetTrackAbort() != nil:
+			aborts = append(aborts, r)
+		case r.Envelope.GetTrackEnd() != nil:
+			ends = append(ends, r)
+		}
+	}
+	if len(starts) != 2 || len(aborts) != 1 || len(ends) != 1 {
+		t.Fatalf("%d track.start, %d track.abort, %d track.end; want 2, 1, 1", len(starts), len(aborts), len(ends))
+	}
+	if a := aborts[0]; sentAt(a) != 12.5 || a.Envelope.Timestamp != 12.5 || a.Envelope.GetTrackAbort().Reason != "user_interrupt" {
+		t.Errorf("track.abort %v sent at %gs, want user_interrupt at 12.5s", a.Envelope, sentAt(a))
+	}
+	if s := starts[1]; sentAt(s) != 14 || s.Envelope.Timestamp != 0 || s.Envelope.GetTrackStart().Filename != "second.wav" {
+		t.Errorf("second track.start %v sent at %gs, want second.wav at 14s with timestamp 0", s.Envelope, sentAt(s))
+	}
+	if e := ends[0]; sentAt(e) != 24 || e.Envelope.Timestamp != 10 {
+		t.Errorf("track.end sent at %gs with timestamp %g, want 24s and 10", sentAt(e), e.Envelope.Timestamp)
+	}
+	// Nothing of the first track after its abort.
+	for _, r := range rec.Records {
+		if sentAt(r) > 12.5 && sentAt(r) < 14 {
+			t.Errorf("%v sent at %gs, between the tracks", r.Envelope, sentAt(r))
+		}
+	}
+}
+
+func TestOverlappingTrackStarts(t *testing.T) {
+	rec, err := Scenario("overlapping-track-starts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var startsAt []float64
+	for i, r := range rec.Records {
+		if r.Envelope.GetTrackStart() != nil {
+			startsAt = append(startsAt, sentAt(r))
+		}
+		if r.Envelope.GetTrackEnd() != nil && i != len(rec.Records)-1 {
+			t.Errorf("track.end at %gs before the last record", sentAt(r))
+		}
+		if p := r.Envelope.GetTrackPosition(); p != nil && p.Position != r.Envelope.Timestamp {
+			t.Errorf("track.position %g at timestamp %g", p.Position, r.Envelope.Timestamp)
+		}
+	}
+	if !slices.Equal(startsAt, []float64{0, 8}) {
+		t.Errorf("track.start sent at %v, want [0 8]", startsAt)
+	}
+	if got := rec.End(); !got.Equal(Epoch.Add(20 * time.Second)) {
+		t.Errorf("End() = %v, want Epoch+20s", got)
+	}
+}
+
+func TestParseScenario(t *testing.T) {
+	rec, err := ParseScenario([]byte(`
+name: t
+stream: s
+events:
+  - {at: 1, event: beat}
+  - {at: 0, every: 0.5, until: 1, event: track.position, stream: other}
+  - {at: 2, timestamp: 1.5, event: key.change, fields: {key: E, scale: minor}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range rec.Records {
+		got = append(got, strings.Join([]string{r.Envelope.StreamId, string(r.Envelope.ProtoReflect().WhichOneof(eventOneof).Name())}, "/"))
+	}
+	if want := []string{"other/track_position", "other/track_position", "s/beat", "s/key_change"}; !slices.Equal(got, want) {
+		t.Errorf("events %q, want %q", got, want)
+	}
+	if p := rec.Records[1].Envelope.GetTrackPosition().GetPosition(); p != 0.5 {
+		t.Errorf("track.position without a position at %g, want 0.5", p)
+	}
+	k := rec.Records[3]
+	if k.Envelope.Timestamp != 1.5 || sentAt(k) != 2 || k.Envelope.GetKeyChange().GetKey() != "E" {
+		t.Errorf("key.change %v sent at %gs, want E with timestamp 1.5 sent at 2s", k.Envelope, sentAt(k))
+	}
+
+	for _, bad := range []string{
+		`events: []`,
+		`events: [{at: 0, event: no.such}]`,
+		`events: [{at: -1, event: beat}]`,
+		`events: [{at: 1, every: 1, event: beat}]`,
+		`events: [{at: 0, event: beat, fields: {bpm: 1}}]`,
+	} {
+		if _, err := ParseScenario([]byte(bad)); err == nil {
+			t.Errorf("ParseScenario(%s) succeeded", bad)
+		}
+	}
+}
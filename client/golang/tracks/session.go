@@ -0,0 +1,226 @@
+package tracks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Session is one track received from one source and stream, from its
+// track.start to its track.end or track.abort, so a program can keep its
+// state per track: a Receiver hands each new Session to the OnTrackStart
+// callbacks, which register what to do with the track's events and its
+// end on it. The Session also keeps a few aggregates of the track as it
+// plays.
+//
+// A track.start from the same source and stream before the track ended
+// cuts it short: its OnAbort callbacks get a nil TrackAbort (whose
+// GetReason is ""). A track still playing when the receiver is closed ends
+// neither way.
+//
+// The callbacks for one source and stream run one at a time, in the order
+// the receiver numbered the envelopes (Delivery.Seq), even when envelopes
+// are observed from several goroutines; they run on one of those
+// goroutines and should return quickly. A Session is safe for concurrent
+// use.
+type Session struct {
+	Source  string // as in Delivery
+	Stream  string
+	Track   uint64
+	Start   *trackspb.TrackStart
+	Started time.Time // when the track.start was received
+
+	mu        sync.Mutex
+	onEvent   []func(d *Delivery)
+	onEnd     []func(end *trackspb.TrackEnd)
+	onAbort   []func(abort *trackspb.TrackAbort)
+	done      bool
+	ended     time.Time
+	events    int
+	position  float64
+	beats     int
+	firstBeat float64
+	lastBeat  float64
+	bpm       float64 // latest tempo.change
+	key       string
+	scale     string
+	loudSum   float64
+	loudN     int
+	peak      float64
+	hasPeak   bool
+}
+
+func newSession(d *Delivery) *Session {
+	return &Session{
+		Source:  d.Source,
+		Stream:  d.Stream,
+		Track:   d.Track,
+		Start:   d.Envelope.GetTrackStart(),
+		Started: d.Received,
+	}
+}
+
+// OnEvent registers f to be called with every envelope of the track, from
+// the track.start to the track.end or track.abort.
+func (s *Session) OnEvent(f func(d *Delivery)) {
+	s.mu.Lock()
+	s.onEvent = append(s.onEvent, f)
+	s.mu.Unlock()
+}
+
+// OnEnd registers f to be called when the track ends with track.end.
+func (s *Session) OnEnd(f func(end *trackspb.TrackEnd)) {
+	s.mu.Lock()
+	s.onEnd = append(s.onEnd, f)
+	s.mu.Unlock()
+}
+
+// OnAbort registers f to be called when the track is aborted with
+// track.abort, or cut short by the next track.start.
+func (s *Session) OnAbort(f func(abort *trackspb.TrackAbort)) {
+	s.mu.Lock()
+	s.onAbort = append(s.onAbort, f)
+	s.mu.Unlock()
+}
+
+// observe adds an envelope of the track and calls the callbacks for it.
+func (s *Session) observe(d *Delivery) {
+	s.mu.Lock()
+	if s.done {
+		s.mu.Unlock()
+		return
+	}
+	s.events++
+	s.position = d.Timestamp
+	var end *trackspb.TrackEnd
+	var abort *trackspb.TrackAbort
+	switch e := d.Envelope.Event.(type) {
+	case *trackspb.Envelope_Beat:
+		if s.beats == 0 {
+			s.firstBeat = d.Timestamp
+		}
+		s.beats++
+		s.lastBeat = d.Timestamp
+	case *trackspb.Envelope_TempoChange:
+		if bpm := e.TempoChange.GetBpm(); bpm > 0 {
+			s.bpm = bpm
+		}
+	case *trackspb.Envelope_KeyChange:
+		s.key, s.scale = e.KeyChange.GetKey(), e.KeyChange.GetScale()
+	case *trackspb.Envelope_Loudness:
+		s.loudSum += e.Loudness.GetValue()
+		s.loudN++
+	case *trackspb.Envelope_LoudnessPeak:
+		if v := e.LoudnessPeak.GetValue(); !s.hasPeak || v > s.peak {
+			s.peak, s.hasPeak = v, true
+		}
+	case *trackspb.Envelope_TrackEnd:
+		end = e.TrackEnd
+	case *trackspb.Envelope_TrackAbort:
+		abort = e.TrackAbort
+	}
+	if end != nil || abort != nil {
+		s.done, s.ended = true, d.Received
+	}
+	onEvent, onEnd, onAbort := s.onEvent, s.onEnd, s.onAbort
+	s.mu.Unlock()
+
+	for _, f := range onEvent {
+		f(d)
+	}
+	if end != nil {
+		for _, f := range onEnd {
+			f(end)
+		}
+	}
+	if abort != nil {
+		for _, f := range onAbort {
+			f(abort)
+		}
+	}
+}
+
+// cut ends the track without track.end or track.abort, at time at.
+func (s *Session) cut(at time.Time) {
+	s.mu.Lock()
+	if s.done {
+		s.mu.Unlock()
+		return
+	}
+	s.done, s.ended = true, at
+	onAbort := s.onAbort
+	s.mu.Unlock()
+	for _, f := range onAbort {
+		f(nil)
+	}
+}
+
+// Done reports whether the track has ended or been aborted, and when.
+func (s *Session) Done() (bool, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.done, s.ended
+}
+
+// Events returns the number of envelopes of the track so far, including
+// its track.start.
+func (s *Session) Events() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.events
+}
+
+// Position returns the timestamp of the track's latest envelope, seconds.
+func (s *Session) Position() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.position
+}
+
+// Beats returns the number of beats so far.
+func (s *Session) Beats() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.beats
+}
+
+// BPM returns the tempo of the latest tempo.change or, without one, the
+// average over the beats so far; 0 if unknown.
+func (s *Session) BPM() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bpm > 0 {
+		return s.bpm
+	}
+	if s.beats >= 2 && s.lastBeat > s.firstBeat {
+		return 60 * float64(s.beats-1) / (s.lastBeat - s.firstBeat)
+	}
+	return 0
+}
+
+// Key returns the key and scale of the latest key.change, "" if none.
+func (s *Session) Key() (key, scale string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.key, s.scale
+}
+
+// Loudness returns the mean of the track's loudness values so far, and
+// whether there were any.
+func (s *Session) Loudness() (float64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loudN == 0 {
+		return 0, false
+	}
+	return s.loudSum / float64(s.loudN), true
+}
+
+// Peak returns the highest loudness.peak so far, and whether there was
+// one.
+func (s *Session) Peak() (float64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.peak, s.hasPeak
+}
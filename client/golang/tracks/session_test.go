@@ -0,0 +1,196 @@
+package tracks
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// sessionLog is what the callbacks of one session saw.
+type sessionLog struct {
+	s       *Session
+	events  int
+	ends    int
+	aborts  []*trackspb.TrackAbort
+	aborted bool
+}
+
+// logSessions registers callbacks that log every session of r.
+func logSessions(r *Receiver) *[]*sessionLog {
+	var logs []*sessionLog
+	r.OnTrackStart(func(s *Session) {
+		l := &sessionLog{s: s}
+		logs = append(logs, l)
+		s.OnEvent(func(*Delivery) { l.events++ })
+		s.OnEnd(func(*trackspb.TrackEnd) { l.ends++ })
+		s.OnAbort(func(a *trackspb.TrackAbort) { l.aborts, l.aborted = append(l.aborts, a), true })
+	})
+	return &logs
+}
+
+func TestSessionAbort(t *testing.T) {
+	rec, err := Scenario("abort-mid-track")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewReceiver()
+	logs := logSessions(r)
+	rec.Play(nil, r.Observe)
+	if len(*logs) != 2 {
+		t.Fatalf("%d sessions, want 2", len(*logs))
+	}
+
+	first, second := (*logs)[0], (*logs)[1]
+	if first.s.Track != 1 || first.s.Start.GetFilename() != "first.wav" || !first.s.Started.Equal(Epoch) {
+		t.Errorf("first session: track %d of %q started %v", first.s.Track, first.s.Start.GetFilename(), first.s.Started)
+	}
+	if len(first.aborts) != 1 || first.aborts[0].GetReason() != "user_interrupt" || first.ends != 0 {
+		t.Errorf("first track ended %d times and aborted with %v, want one user_interrupt abort", first.ends, first.aborts)
+	}
+	if done, at := first.s.Done(); !done || !at.Equal(Epoch.Add(12500*time.Millisecond)) {
+		t.Errorf("first Done() = %v, %v; want true at 12.5 s", done, at)
+	}
+	if first.events != first.s.Events() || first.events != 1+1+1+26+12+1 {
+		t.Errorf("first track: %d events called back, Events() %d, want 42", first.events, first.s.Events())
+	}
+	if first.s.Beats() != 26 || first.s.BPM() != 124 || first.s.Position() != 12.5 {
+		t.Errorf("first track: %d beats, %g BPM, at %g s; want 26, 124, 12.5", first.s.Beats(), first.s.BPM(), first.s.Position())
+	}
+	if k, sc := first.s.Key(); k != "E" || sc != "minor" {
+		t.Errorf("first track in %s %s, want E minor", k, sc)
+	}
+
+	if second.s.Track != 2 || second.ends != 1 || second.aborted {
+		t.Errorf("second session: track %d, %d ends, aborted %v; want track 2 ended once", second.s.Track, second.ends, second.aborted)
+	}
+	if second.s.BPM() != 90 {
+		t.Errorf("second track %g BPM, want 90", second.s.BPM())
+	}
+	if _, ok := second.s.Loudness(); ok {
+		t.Error("second track has loudness without loudness events")
+	}
+}
+
+func TestSessionCutByNextStart(t *testing.T) {
+	rec, err := Scenario("overlapping-track-starts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewReceiver()
+	logs := logSessions(r)
+	var order []string
+	r.OnTrackStart(func(s *Session) {
+		order = append(order, "start "+s.Start.GetFilename())
+		s.OnAbort(func(*trackspb.TrackAbort) { order = append(order, "cut "+s.Start.GetFilename()) })
+	})
+	rec.Play(nil, r.Observe)
+	if len(*logs) != 2 {
+		t.Fatalf("%d sessions, want 2", len(*logs))
+	}
+
+	first := (*logs)[0]
+	if len(first.aborts) != 1 || first.aborts[0] != nil || first.ends != 0 {
+		t.Errorf("first track: %d ends, aborts %v; want one abort with a nil TrackAbort", first.ends, first.aborts)
+	}
+	if done, at := first.s.Done(); !done || !at.Equal(Epoch.Add(8*time.Second)) {
+		t.Errorf("first Done() = %v, %v; want true at 8 s", done, at)
+	}
+	want := []string{"start first.wav", "cut first.wav", "start second.wav"}
+	if len(order) != len(want) || order[0] != want[0] || order[1] != want[1] || order[2] != want[2] {
+		t.Errorf("callbacks %q, want %q", order, want)
+	}
+	if second := (*logs)[1]; second.ends != 1 || second.s.BPM() != 140 {
+		t.Errorf("second track: %d ends at %g BPM, want 1 at 140", second.ends, second.s.BPM())
+	}
+}
+
+func TestSessionAggregates(t *testing.T) {
+	r := NewReceiver()
+	logs := logSessions(r)
+	observe := func(at float64, ev any) {
+		env := &trackspb.Envelope{Timestamp: at}
+		switch e := ev.(type) {
+		case *trackspb.TrackStart:
+			env.Event = &trackspb.Envelope_TrackStart{TrackStart: e}
+		case *trackspb.Beat:
+			env.Event = &trackspb.Envelope_Beat{Beat: e}
+		case *trackspb.Loudness:
+			env.Event = &trackspb.Envelope_Loudness{Loudness: e}
+		case *trackspb.LoudnessPeak:
+			env.Event = &trackspb.Envelope_LoudnessPeak{LoudnessPeak: e}
+		}
+		r.Observe(env, epoch.Add(time.Duration(at*float64(time.Second))))
+	}
+	observe(0, &trackspb.Beat{}) // before any track: no session
+	observe(0, &trackspb.TrackStart{Filename: "a.wav"})
+	for i := range 3 {
+		observe(float64(i)*0.5, &trackspb.Beat{})
+	}
+	observe(1, &trackspb.Loudness{Value: -10})
+	observe(1.5, &trackspb.Loudness{Value: -20})
+	observe(1.5, &trackspb.LoudnessPeak{Value: -3})
+	observe(2, &trackspb.LoudnessPeak{Value: -1})
+	observe(2.5, &trackspb.LoudnessPeak{Value: -2})
+
+	if len(*logs) != 1 {
+		t.Fatalf("%d sessions, want 1", len(*logs))
+	}
+	s := (*logs)[0].s
+	if s.Events() != 9 || s.Beats() != 3 || s.Position() != 2.5 {
+		t.Errorf("%d events, %d beats, at %g s; want 9, 3, 2.5", s.Events(), s.Beats(), s.Position())
+	}
+	if bpm := s.BPM(); math.Abs(bpm-120) > 1e-9 {
+		t.Errorf("BPM() = %g from the beats, want 120", bpm)
+	}
+	if l, ok := s.Loudness(); !ok || l != -15 {
+		t.Errorf("Loudness() = %g, %v; want -15", l, ok)
+	}
+	if p, ok := s.Peak(); !ok || p != -1 {
+		t.Errorf("Peak() = %g, %v; want -1", p, ok)
+	}
+	// A track still playing at Close ends neither way.
+	r.Close()
+	if done, _ := s.Done(); done || (*logs)[0].ends != 0 || (*logs)[0].aborted {
+		t.Error("a track playing at Close ended")
+	}
+}
+
+func TestSessionCallbackOrder(t *testing.T) {
+	r := NewReceiver()
+	var seqs []uint64
+	r.OnTrackStart(func(s *Session) {
+		s.OnEvent(func(d *Delivery) {
+			// Unlocked: callbacks of one origin never overlap, which
+			// the race detector checks.
+			seqs = append(seqs, d.Seq)
+			if d.Seq%50 == 0 {
+				// A callback may call the receiver.
+				r.ObserveFrom(beatAt(0), "other", epoch)
+			}
+		})
+	})
+	r.ObserveFrom(&trackspb.Envelope{Event: &trackspb.Envelope_TrackStart{TrackStart: &trackspb.TrackStart{}}}, "a", epoch)
+
+	const workers, each = 8, 200
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for range each {
+				r.ObserveFrom(beatAt(1), "a", epoch)
+			}
+		})
+	}
+	wg.Wait()
+
+	if len(seqs) != 1+workers*each {
+		t.Fatalf("%d callbacks, want %d", len(seqs), 1+workers*each)
+	}
+	for i, seq := range seqs {
+		if seq != uint64(i+1) {
+			t.Fatalf("callback %d for Seq %d: out of order", i+1, seq)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package tracks
+
+import (
+	"fmt"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// TimedEvent is one event of type T taken from an envelope, with the
+// envelope and the context of its reception.
+type TimedEvent[T proto.Message] struct {
+	Event T
+	Delivery
+}
+
+// Subscribe returns a channel of the events of type T that r receives
+// from now on, e.g. Subscribe[*trackspb.Beat](r) for beats, or of every
+// envelope with T *trackspb.Envelope. The channel is closed when r is, or
+// by Unsubscribe. Subscribe panics if T is not an event of the Envelope.
+func Subscribe[T proto.Message](r *Receiver) <-chan TimedEvent[T] {
+	var zero T
+	want := zero.ProtoReflect().Descriptor()
+	all := want.FullName() == (*trackspb.Envelope)(nil).ProtoReflect().Descriptor().FullName()
+	if !all && eventField(want) == nil {
+		panic(fmt.Sprintf("tracks: Subscribe: %s is not a TRACKS event", want.FullName()))
+	}
+
+	ch := make(chan TimedEvent[T], r.bufferSize())
+	r.subscribe(&subscription{
+		ch: (<-chan TimedEvent[T])(ch),
+		send: func(d *Delivery) bool {
+			var ev T
+			if all {
+				ev = any(d.Envelope).(T)
+			} else {
+				m := d.Envelope.ProtoReflect()
+				fd := m.WhichOneof(eventOneof)
+				if fd == nil || fd.Message().FullName() != want.FullName() {
+					return true
+				}
+				ev = m.Get(fd).Message().Interface().(T)
+			}
+			select {
+			case ch <- TimedEvent[T]{Event: ev, Delivery: *d}:
+				return true
+			default:
+				return false
+			}
+		},
+		close: func() { close(ch) },
+	})
+	return ch
+}
+
+// Unsubscribe ends a subscription of r from Subscribe: ch gets no more
+// events and is closed, once those already in it are read. Unsubscribing
+// a channel twice, or after r is closed, does nothing.
+func Unsubscribe[T proto.Message](r *Receiver, ch <-chan TimedEvent[T]) {
+	r.unsubscribe(ch)
+}
+
+// eventOneof is the Envelope's oneof of events.
+var eventOneof = (*trackspb.Envelope)(nil).ProtoReflect().Descriptor().Oneofs().ByName("event")
+
+// eventField returns the field of the event oneof holding messages md, or
+// nil if there is none.
+func eventField(md protoreflect.MessageDescriptor) protoreflect.FieldDescriptor {
+	fields := eventOneof.Fields()
+	for i := range fields.Len() {
+		if fd := fields.Get(i); fd.Message().FullName() == md.FullName() {
+			return fd
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package tracks
+
+import (
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var epoch = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func beatAt(t float64) *trackspb.Envelope {
+	return &trackspb.Envelope{Timestamp: t, Event: &trackspb.Envelope_Beat{Beat: &trackspb.Beat{Confidence: 0.9}}}
+}
+
+// receive returns the next event on ch, failing the test if none comes
+// within a second.
+func receive[T proto.Message](t *testing.T, ch <-chan TimedEvent[T]) (TimedEvent[T], bool) {
+	t.Helper()
+	select {
+	case ev, ok := <-ch:
+		return ev, ok
+	case <-time.After(time.Second):
+		t.Fatal("no event within a second")
+		return TimedEvent[T]{}, false
+	}
+}
+
+func TestSubscribeLoopback(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewPacketReceiver(conn)
+	defer r.Close()
+	beats := Subscribe[*trackspb.Beat](r)
+	all := Subscribe[*trackspb.Envelope](r)
+
+	out, err := net.Dial("udp4", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	send := func(b []byte) {
+		if _, err := out.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	marshal := func(env *trackspb.Envelope) []byte {
+		b, err := proto.Marshal(env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	send(marshal(&trackspb.Envelope{Timestamp: 1, StreamId: "pad1",
+		Event: &trackspb.Envelope_KeyChange{KeyChange: &trackspb.KeyChange{Key: "A", Scale: "minor"}}}))
+	send([]byte{0x08, 0x96, 0x01, 0x12}) // doesn't parse
+	send(marshal(&trackspb.Envelope{Timestamp: math.NaN(), Event: &trackspb.Envelope_Beat{Beat: &trackspb.Beat{}}}))
+	beat := beatAt(1.5)
+	beat.StreamId = "pad1"
+	send(marshal(beat))
+
+	b, _ := receive(t, beats)
+	if b.Event.GetConfidence() != 0.9 || b.Timestamp != 1.5 || b.Stream != "pad1" {
+		t.Errorf("beat %v at %g on %q, want confidence 0.9 at 1.5 on pad1", b.Event, b.Timestamp, b.Stream)
+	}
+	if b.Source != out.LocalAddr().String() || b.Seq != 2 {
+		t.Errorf("beat from %s #%d, want from %s #2", b.Source, b.Seq, out.LocalAddr())
+	}
+	if b.Received.IsZero() {
+		t.Error("beat has no receive time")
+	}
+	for _, want := range []string{"key_change", "beat"} {
+		ev, _ := receive(t, all)
+		if got := string(ev.Event.ProtoReflect().WhichOneof(eventOneof).Name()); got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+	if n := r.Invalid(); n != 2 {
+		t.Errorf("Invalid() = %d, want 2", n)
+	}
+
+	r.Close()
+	if _, ok := receive(t, beats); ok {
+		t.Error("beats still open after Close")
+	}
+}
+
+func TestSubscribeEventType(t *testing.T) {
+	r := NewReceiver()
+	r.BufferSize = 2
+	keys := Subscribe[*trackspb.KeyChange](r)
+	beats := Subscribe[*trackspb.Beat](r)
+	for i := range 3 {
+		r.Observe(beatAt(float64(i)), epoch)
+	}
+	r.Observe(&trackspb.Envelope{Event: &trackspb.Envelope_KeyChange{KeyChange: &trackspb.KeyChange{Key: "C"}}}, epoch)
+	if len(keys) != 1 || len(beats) != 2 {
+		t.Errorf("%d key.change and %d beats buffered, want 1 and 2", len(keys), len(beats))
+	}
+	if n := r.Dropped(); n != 1 {
+		t.Errorf("Dropped() = %d, want 1", n)
+	}
+	if k, _ := receive(t, keys); k.Event.GetKey() != "C" {
+		t.Errorf("key.change %v, want C", k.Event)
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	r := NewReceiver()
+	defer r.Close()
+	gone := Subscribe[*trackspb.Beat](r)
+	kept := Subscribe[*trackspb.Beat](r)
+	r.Observe(beatAt(0), epoch)
+	Unsubscribe(r, gone)
+	r.Observe(beatAt(0.5), epoch)
+
+	if b, ok := receive(t, gone); !ok || b.Timestamp != 0 {
+		t.Errorf("first beat after unsubscribing: %v, %v; want the one buffered before", b.Event, ok)
+	}
+	if _, ok := receive(t, gone); ok {
+		t.Error("channel still open after Unsubscribe")
+	}
+	for _, want := range []float64{0, 0.5} {
+		if b, _ := receive(t, kept); b.Timestamp != want {
+			t.Errorf("other subscription got a beat at %g, want %g", b.Timestamp, want)
+		}
+	}
+	// Again, and after Close, does nothing.
+	Unsubscribe(r, gone)
+	r.Close()
+	Unsubscribe(r, kept)
+}
+
+func TestSubscribeNotAnEvent(t *testing.T) {
+	defer func() {
+		if msg, _ := recover().(string); !strings.Contains(msg, "google.protobuf.Empty is not a TRACKS event") {
+			t.Errorf("panic %q, want one naming google.protobuf.Empty", msg)
+		}
+	}()
+	Subscribe[*emptypb.Empty](NewReceiver())
+	t.Error("Subscribe did not panic")
+}
+
+func TestSubscribeAfterClose(t *testing.T) {
+	r := NewReceiver()
+	r.Close()
+	if _, ok := receive(t, Subscribe[*trackspb.Beat](r)); ok {
+		t.Error("a subscription after Close is open")
+	}
+}
@@ -0,0 +1,172 @@
+// Package trackstest provides canonical TRACKS streams and matchers for
+// testing programs that consume them: recordings (tracks.Recording) whose
+// content is known exactly, to play through an application's handling on a
+// virtual clock, and helpers to check what came out. Scripted scenarios
+// are in package tracks, which the mock sender shares.
+//
+//	rec := trackstest.Steady120()
+//	clock := tracks.NewBeatClock()
+//	rec.Until(20).Play(nil, clock.Observe)
+//	trackstest.ExpectNear(t, "BPM", clock.AtPosition(20).BPM, 120, 0.5)
+//	trackstest.ExpectCount(t, rec.Envelopes(), trackstest.Event("downbeat"), 16)
+//
+// Every call of a fixture builds a new copy, so tests may change it.
+package trackstest
+
+import (
+	"sort"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/tracks"
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// All returns every fixture.
+func All() []*tracks.Recording {
+	return []*tracks.Recording{Steady120(), KeyModulation(), QualityDefects()}
+}
+
+// recorder builds a fixture; envelopes at the same timestamp keep the order
+// they were added in.
+type recorder struct {
+	name string
+	envs []*trackspb.Envelope
+}
+
+func (r *recorder) at(t float64, env *trackspb.Envelope) {
+	env.Timestamp = t
+	r.envs = append(r.envs, env)
+}
+
+// every adds the envelopes made by f at from, from+step, ... before to.
+func (r *recorder) every(from, to, step float64, f func(t float64) *trackspb.Envelope) {
+	for i := 0; ; i++ {
+		t := from + float64(i)*step
+		if t >= to-1e-9 {
+			return
+		}
+		r.at(t, f(t))
+	}
+}
+
+// track adds the transport events of a track of the given length:
+// track.start, track.position every second and track.end.
+func (r *recorder) track(filename string, duration float64) {
+	r.at(0, &trackspb.Envelope{Event: &trackspb.Envelope_TrackStart{TrackStart: &trackspb.TrackStart{
+		Filename: filename, Duration: duration, SampleRate: 44100, Channels: 2}}})
+	r.every(1, duration, 1, func(t float64) *trackspb.Envelope {
+		return &trackspb.Envelope{Event: &trackspb.Envelope_TrackPosition{TrackPosition: &trackspb.TrackPosition{Position: t}}}
+	})
+	r.at(duration, &trackspb.Envelope{Event: &trackspb.Envelope_TrackEnd{TrackEnd: &trackspb.TrackEnd{}}})
+}
+
+// beats adds tempo.change at from, and a beat every beat of bpm from there
+// to to, with a downbeat on every beatsPerBar-th.
+func (r *recorder) beats(from, to, bpm float64, beatsPerBar int) {
+	r.at(from, &trackspb.Envelope{Event: &trackspb.Envelope_TempoChange{TempoChange: &trackspb.TempoChange{Bpm: bpm}}})
+	r.every(from, to, 60/bpm, func(float64) *trackspb.Envelope {
+		return &trackspb.Envelope{Event: &trackspb.Envelope_Beat{Beat: &trackspb.Beat{Confidence: 0.9}}}
+	})
+	r.every(from, to, 60/bpm*float64(beatsPerBar), func(float64) *trackspb.Envelope {
+		return &trackspb.Envelope{Event: &trackspb.Envelope_Downbeat{Downbeat: &trackspb.Downbeat{Confidence: 0.9}}}
+	})
+}
+
+func (r *recorder) key(t float64, key, scale string) {
+	r.at(t, &trackspb.Envelope{Event: &trackspb.Envelope_KeyChange{KeyChange: &trackspb.KeyChange{Key: key, Scale: scale, Strength: 0.85}}})
+}
+
+func (r *recorder) loudness(from, to, value float64) {
+	r.every(from, to, 0.5, func(float64) *trackspb.Envelope {
+		return &trackspb.Envelope{Event: &trackspb.Envelope_Loudness{Loudness: &trackspb.Loudness{Value: value}}}
+	})
+}
+
+// recording orders the envelopes by timestamp, keeping transport events
+// first and track.end last among equal ones, and receives each at Epoch
+// plus its timestamp.
+func (r *recorder) recording() *tracks.Recording {
+	rank := func(env *trackspb.Envelope) int {
+		switch env.Event.(type) {
+		case *trackspb.Envelope_TrackStart:
+			return 0
+		case *trackspb.Envelope_TrackEnd:
+			return 2
+		}
+		return 1
+	}
+	sort.SliceStable(r.envs, func(i, j int) bool {
+		a, b := r.envs[i], r.envs[j]
+		if a.Timestamp != b.Timestamp {
+			return a.Timestamp < b.Timestamp
+		}
+		return rank(a) < rank(b)
+	})
+	rec := &tracks.Recording{Name: r.name, Records: make([]tracks.Record, len(r.envs))}
+	for i, env := range r.envs {
+		rec.Records[i] = tracks.Record{Received: tracks.Epoch.Add(time.Duration(env.Timestamp * float64(time.Second))), Envelope: env}
+	}
+	return rec
+}
+
+// Steady120 is 32 seconds of steady 4/4 at 120 BPM in A minor, at -14 dB:
+// a tempo.change to 120, 64 beats every half second from 0, 16 downbeats
+// every 2 seconds, one key.change, loudness every half second and
+// track.position every second.
+func Steady120() *tracks.Recording {
+	r := &recorder{name: "steady-120"}
+	r.track("steady-120.wav", 32)
+	r.beats(0, 32, 120, 4)
+	r.key(0, "A", "minor")
+	r.loudness(0, 32, -14)
+	return r.recording()
+}
+
+// KeyModulation is 24 seconds at 100 BPM that modulates from C major to D
+// major at 12 seconds: key.change at 0 and 12, and a chord.change every 2.4
+// seconds (a bar) through I-V-vi-IV in each key (C G Am F C, then D A Bm G
+// D).
+func KeyModulation() *tracks.Recording {
+	r := &recorder{name: "key-modulation"}
+	r.track("key-modulation.wav", 24)
+	r.beats(0, 24, 100, 4)
+	r.key(0, "C", "major")
+	r.key(12, "D", "major")
+	chords := []string{"C", "G", "Am", "F", "C", "D", "A", "Bm", "G", "D"}
+	for i, c := range chords {
+		r.at(float64(i)*2.4, &trackspb.Envelope{Event: &trackspb.Envelope_ChordChange{ChordChange: &trackspb.ChordChange{Chord: c, Strength: 0.8}}})
+	}
+	r.loudness(0, 24, -12)
+	return r.recording()
+}
+
+// QualityDefects is 20 seconds at 128 BPM and -9 dB with one of each
+// defect a quality check should catch: 50 Hz hum from 1 second, clicks at
+// 3.2 and 3.9, saturation for 0.4 seconds at 6 with a loudness.peak of
+// +0.6 dB, a dropout (silence.start at 10, silence.end at 12.5, and no
+// beats or loudness in between), a discontinuity at 15 and a noise.burst
+// at 17.3. Its other loudness.peak values stay at -1 dB.
+func QualityDefects() *tracks.Recording {
+	r := &recorder{name: "quality-defects"}
+	r.track("quality-defects.wav", 20)
+	r.beats(0, 10, 128, 4)
+	r.beats(12.5, 20, 128, 4)
+	r.loudness(0, 10, -9)
+	r.loudness(12.5, 20, -9)
+	peak := func(t, v float64) {
+		r.at(t, &trackspb.Envelope{Event: &trackspb.Envelope_LoudnessPeak{LoudnessPeak: &trackspb.LoudnessPeak{Value: v}}})
+	}
+	for _, t := range []float64{2, 4, 8, 14, 18} {
+		peak(t, -1)
+	}
+	peak(6.1, 0.6)
+	r.at(1, &trackspb.Envelope{Event: &trackspb.Envelope_Hum{Hum: &trackspb.Hum{Frequency: 50}}})
+	r.at(3.2, &trackspb.Envelope{Event: &trackspb.Envelope_Click{Click: &trackspb.Click{}}})
+	r.at(3.9, &trackspb.Envelope{Event: &trackspb.Envelope_Click{Click: &trackspb.Click{}}})
+	r.at(6, &trackspb.Envelope{Event: &trackspb.Envelope_Saturation{Saturation: &trackspb.Saturation{Duration: 0.4}}})
+	r.at(10, &trackspb.Envelope{Event: &trackspb.Envelope_SilenceStart{SilenceStart: &trackspb.SilenceStart{}}})
+	r.at(12.5, &trackspb.Envelope{Event: &trackspb.Envelope_SilenceEnd{SilenceEnd: &trackspb.SilenceEnd{}}})
+	r.at(15, &trackspb.Envelope{Event: &trackspb.Envelope_Discontinuity{Discontinuity: &trackspb.Discontinuity{}}})
+	r.at(17.3, &trackspb.Envelope{Event: &trackspb.Envelope_NoiseBurst{NoiseBurst: &trackspb.NoiseBurst{}}})
+	return r.recording()
+}
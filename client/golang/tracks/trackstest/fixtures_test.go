@@ -0,0 +1,149 @@
+package trackstest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/tracks"
+	"github.com/davesmith10/tracks/client/golang/tracks/trackstest"
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// checkTransport checks what every fixture shares: a track.start of the
+// track's duration first, a track.end at the duration last, track.position
+// every second in between, and each envelope received at Epoch plus its
+// timestamp, in order.
+func checkTransport(t *testing.T, rec *tracks.Recording, duration float64) {
+	t.Helper()
+	envs := rec.Envelopes()
+	if len(envs) < 2 {
+		t.Fatalf("%s: %d envelopes", rec.Name, len(envs))
+	}
+	if start := envs[0].GetTrackStart(); start == nil || start.Duration != duration {
+		t.Errorf("%s: first envelope %v, want a track.start of %gs", rec.Name, envs[0], duration)
+	}
+	if last := envs[len(envs)-1]; last.GetTrackEnd() == nil || last.Timestamp != duration {
+		t.Errorf("%s: last envelope %v, want a track.end at %gs", rec.Name, last, duration)
+	}
+	trackstest.ExpectCount(t, envs, trackstest.Event("track.start", "track.end"), 2)
+	trackstest.ExpectCount(t, envs, trackstest.Event("track.position"), int(duration)-1)
+	for _, env := range trackstest.Filter(envs, trackstest.Event("track.position")) {
+		if env.GetTrackPosition().Position != env.Timestamp {
+			t.Errorf("%s: track.position %g at %gs", rec.Name, env.GetTrackPosition().Position, env.Timestamp)
+		}
+	}
+	prev := tracks.Epoch
+	for _, r := range rec.Records {
+		if want := tracks.Epoch.Add(time.Duration(r.Envelope.Timestamp * float64(time.Second))); !r.Received.Equal(want) {
+			t.Errorf("%s: %s at %gs received at %v, want %v", rec.Name, trackstest.EventName(r.Envelope), r.Envelope.Timestamp, r.Received, want)
+		}
+		if r.Received.Before(prev) {
+			t.Errorf("%s: %s at %gs out of order", rec.Name, trackstest.EventName(r.Envelope), r.Envelope.Timestamp)
+		}
+		prev = r.Received
+	}
+}
+
+// checkEvery checks that the envelopes m matches are at from, from+step,
+// ... before to, and no others.
+func checkEvery(t *testing.T, envs []*trackspb.Envelope, m trackstest.Matcher, from, to, step float64) {
+	t.Helper()
+	got := trackstest.Filter(envs, m)
+	n := 0
+	for ; from+float64(n)*step < to-1e-9; n++ {
+		if n < len(got) {
+			trackstest.ExpectNear(t, m.String()+" time", got[n].Timestamp, from+float64(n)*step, 1e-9)
+		}
+	}
+	if len(got) != n {
+		t.Errorf("got %d of %s, want %d", len(got), m, n)
+	}
+}
+
+func TestSteady120(t *testing.T) {
+	rec := trackstest.Steady120()
+	envs := rec.Envelopes()
+	checkTransport(t, rec, 32)
+
+	trackstest.ExpectCount(t, envs, trackstest.And(trackstest.Event("tempo.change"), trackstest.Field("bpm", 120)), 1)
+	trackstest.ExpectCount(t, envs, trackstest.Event("tempo.change"), 1)
+	trackstest.ExpectCount(t, envs, trackstest.Event("beat"), 64)
+	trackstest.ExpectCount(t, envs, trackstest.Event("downbeat"), 16)
+	checkEvery(t, envs, trackstest.Event("beat"), 0, 32, 0.5)
+	checkEvery(t, envs, trackstest.Event("downbeat"), 0, 32, 2)
+	trackstest.ExpectCount(t, envs, trackstest.And(trackstest.Event("key.change"), trackstest.Field("key", "A"), trackstest.Field("scale", "minor")), 1)
+	trackstest.ExpectCount(t, envs, trackstest.Event("key.change"), 1)
+	checkEvery(t, envs, trackstest.Event("loudness"), 0, 32, 0.5)
+	trackstest.ExpectNone(t, envs, trackstest.And(trackstest.Event("loudness"),
+		trackstest.Func("not -14 dB", func(env *trackspb.Envelope) bool { return env.GetLoudness().Value != -14 })))
+
+	clock := tracks.NewBeatClock()
+	rec.Until(20).Play(nil, clock.Observe)
+	trackstest.ExpectNear(t, "BPM", clock.AtPosition(20).BPM, 120, 0.5)
+}
+
+func TestKeyModulation(t *testing.T) {
+	rec := trackstest.KeyModulation()
+	envs := rec.Envelopes()
+	checkTransport(t, rec, 24)
+
+	trackstest.ExpectCount(t, envs, trackstest.And(trackstest.Event("tempo.change"), trackstest.Field("bpm", 100)), 1)
+	checkEvery(t, envs, trackstest.Event("beat"), 0, 24, 0.6)
+	checkEvery(t, envs, trackstest.Event("downbeat"), 0, 24, 2.4)
+	trackstest.ExpectCount(t, envs, trackstest.Event("key.change"), 2)
+	trackstest.ExpectSequence(t, envs,
+		trackstest.And(trackstest.Event("key.change"), trackstest.Field("key", "C"), trackstest.Field("scale", "major"), trackstest.Between(0, 0.1)),
+		trackstest.And(trackstest.Event("key.change"), trackstest.Field("key", "D"), trackstest.Field("scale", "major"), trackstest.Between(12, 12.1)))
+
+	checkEvery(t, envs, trackstest.Event("chord.change"), 0, 24, 2.4)
+	var chords []trackstest.Matcher
+	for _, c := range []string{"C", "G", "Am", "F", "C", "D", "A", "Bm", "G", "D"} {
+		chords = append(chords, trackstest.And(trackstest.Event("chord.change"), trackstest.Field("chord", c)))
+	}
+	trackstest.ExpectSequence(t, envs, chords...)
+	checkEvery(t, envs, trackstest.Event("loudness"), 0, 24, 0.5)
+}
+
+func TestQualityDefects(t *testing.T) {
+	rec := trackstest.QualityDefects()
+	envs := rec.Envelopes()
+	checkTransport(t, rec, 20)
+
+	trackstest.ExpectCount(t, envs, trackstest.And(trackstest.Event("tempo.change"), trackstest.Field("bpm", 128)), 2)
+	trackstest.ExpectNone(t, envs, trackstest.And(trackstest.Event("beat", "downbeat", "loudness"), trackstest.Between(10, 12.5)))
+	trackstest.ExpectCount(t, envs, trackstest.And(trackstest.Event("loudness"), trackstest.Field("value", -9)), trackstest.Count(envs, trackstest.Event("loudness")))
+
+	one := func(name string, at float64) {
+		t.Helper()
+		trackstest.ExpectCount(t, envs, trackstest.Event(name), 1)
+		trackstest.ExpectCount(t, envs, trackstest.And(trackstest.Event(name), trackstest.Between(at, at+1e-9)), 1)
+	}
+	one("hum", 1)
+	trackstest.ExpectCount(t, envs, trackstest.And(trackstest.Event("hum"), trackstest.Field("frequency", 50)), 1)
+	trackstest.ExpectCount(t, envs, trackstest.Event("click"), 2)
+	trackstest.ExpectSequence(t, envs,
+		trackstest.And(trackstest.Event("click"), trackstest.Between(3.2, 3.2+1e-9)),
+		trackstest.And(trackstest.Event("click"), trackstest.Between(3.9, 3.9+1e-9)))
+	one("saturation", 6)
+	trackstest.ExpectCount(t, envs, trackstest.And(trackstest.Event("saturation"), trackstest.Field("duration", 0.4)), 1)
+	one("silence.start", 10)
+	one("silence.end", 12.5)
+	one("discontinuity", 15)
+	one("noise.burst", 17.3)
+
+	peaks := trackstest.Filter(envs, trackstest.Event("loudness.peak"))
+	over := trackstest.Filter(peaks, trackstest.Func("above 0 dB", func(env *trackspb.Envelope) bool { return env.GetLoudnessPeak().Value > 0 }))
+	if len(over) != 1 || over[0].Timestamp != 6.1 || over[0].GetLoudnessPeak().Value != 0.6 {
+		t.Errorf("loudness.peak above 0 dB: %v, want one of +0.6 dB at 6.1s", over)
+	}
+	trackstest.ExpectCount(t, peaks, trackstest.Field("value", -1), len(peaks)-1)
+}
+
+func TestFixturesAreFresh(t *testing.T) {
+	for i, rec := range trackstest.All() {
+		rec.OnStream("changed")
+		if again := trackstest.All()[i]; again.Records[0].Envelope.StreamId != "" {
+			t.Errorf("%s: a change to one copy reached the next", again.Name)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package trackstest
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// EventName returns an envelope's event name as receivers print it, its
+// field name with dots for underscores (e.g. "chord.change"), or "" when
+// it has no event.
+func EventName(env *trackspb.Envelope) string {
+	m := env.ProtoReflect()
+	fd := m.WhichOneof(m.Descriptor().Oneofs().ByName("event"))
+	if fd == nil {
+		return ""
+	}
+	return strings.ReplaceAll(string(fd.Name()), "_", ".")
+}
+
+// Matcher selects envelopes; its String describes them in failure
+// messages.
+type Matcher interface {
+	Match(env *trackspb.Envelope) bool
+	String() string
+}
+
+type matcher struct {
+	desc string
+	f    func(*trackspb.Envelope) bool
+}
+
+func (m matcher) Match(env *trackspb.Envelope) bool { return m.f(env) }
+func (m matcher) String() string                    { return m.desc }
+
+// Func returns a matcher of envelopes for which f is true.
+func Func(desc string, f func(env *trackspb.Envelope) bool) Matcher {
+	return matcher{desc, f}
+}
+
+// Any matches every envelope.
+func Any() Matcher {
+	return Func("any event", func(*trackspb.Envelope) bool { return true })
+}
+
+// Event matches envelopes with any of the named events, e.g. "beat" or
+// "key.change".
+func Event(names ...string) Matcher {
+	return Func(strings.Join(names, " or "), func(env *trackspb.Envelope) bool {
+		name := EventName(env)
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Stream matches envelopes of the stream id.
+func Stream(id string) Matcher {
+	return Func(fmt.Sprintf("stream %q", id), func(env *trackspb.Envelope) bool { return env.StreamId == id })
+}
+
+// Between matches envelopes with timestamps from from up to, but not
+// including, to, in seconds.
+func Between(from, to float64) Matcher {
+	return Func(fmt.Sprintf("in [%g, %g)", from, to), func(env *trackspb.Envelope) bool {
+		return env.Timestamp >= from && env.Timestamp < to
+	})
+}
+
+// Field matches envelopes whose event has the named field (e.g. "chord"
+// for chord.change) equal to value, compared as the receivers print it.
+func Field(name string, value any) Matcher {
+	want := fmt.Sprint(value)
+	return Func(fmt.Sprintf("%s=%s", name, want), func(env *trackspb.Envelope) bool {
+		m := env.ProtoReflect()
+		fd := m.WhichOneof(m.Descriptor().Oneofs().ByName("event"))
+		if fd == nil || fd.Kind() != protoreflect.MessageKind {
+			return false
+		}
+		ev := m.Get(fd).Message()
+		f := ev.Descriptor().Fields().ByName(protoreflect.Name(name))
+		return f != nil && fmt.Sprint(ev.Get(f).Interface()) == want
+	})
+}
+
+// And matches envelopes that all of ms match.
+func And(ms ...Matcher) Matcher {
+	descs := make([]string, len(ms))
+	for i, m := range ms {
+		descs[i] = m.String()
+	}
+	return Func(strings.Join(descs, ", "), func(env *trackspb.Envelope) bool {
+		for _, m := range ms {
+			if !m.Match(env) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Filter returns the envelopes m matches, in order.
+func Filter(envs []*trackspb.Envelope, m Matcher) []*trackspb.Envelope {
+	var out []*trackspb.Envelope
+	for _, env := range envs {
+		if m.Match(env) {
+			out = append(out, env)
+		}
+	}
+	return out
+}
+
+// Count returns how many of envs m matches.
+func Count(envs []*trackspb.Envelope, m Matcher) int {
+	return len(Filter(envs, m))
+}
+
+// The Expect functions report a failure through t and carry on, as
+// t.Errorf does, and return whether the expectation held.
+
+// ExpectCount expects m to match want of envs.
+func ExpectCount(t testing.TB, envs []*trackspb.Envelope, m Matcher, want int) bool {
+	t.Helper()
+	if got := Count(envs, m); got != want {
+		t.Errorf("got %d of %s, want %d", got, m, want)
+		return false
+	}
+	return true
+}
+
+// ExpectNone expects m to match none of envs.
+func ExpectNone(t testing.TB, envs []*trackspb.Envelope, m Matcher) bool {
+	t.Helper()
+	if got := Filter(envs, m); len(got) > 0 {
+		t.Errorf("got %d of %s, want none; the first at %gs", len(got), m, got[0].Timestamp)
+		return false
+	}
+	return true
+}
+
+// ExpectSequence expects envs to hold envelopes matching ms in that order,
+// with any others before, between and after them.
+func ExpectSequence(t testing.TB, envs []*trackspb.Envelope, ms ...Matcher) bool {
+	t.Helper()
+	i := 0
+	for _, env := range envs {
+		if i < len(ms) && ms[i].Match(env) {
+			i++
+		}
+	}
+	if i < len(ms) {
+		if i == 0 {
+			t.Errorf("no %s", ms[0])
+		} else {
+			t.Errorf("no %s after %s", ms[i], ms[i-1])
+		}
+		return false
+	}
+	return true
+}
+
+// ExpectNear expects got to be within tolerance of want; what names the
+// value in the failure message.
+func ExpectNear(t testing.TB, what string, got, want, tolerance float64) bool {
+	t.Helper()
+	if math.IsNaN(got) || math.Abs(got-want) > tolerance {
+		t.Errorf("%s = %g, want %g ± %g", what, got, want, tolerance)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package trackstest_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/davesmith10/tracks/client/golang/tracks/trackstest"
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// fakeT collects the failures of the Expect functions instead of failing
+// the test.
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func key(at float64, k string) *trackspb.Envelope {
+	return &trackspb.Envelope{Timestamp: at, Event: &trackspb.Envelope_KeyChange{KeyChange: &trackspb.KeyChange{Key: k}}}
+}
+
+func TestMatchers(t *testing.T) {
+	beat := &trackspb.Envelope{Timestamp: 2, StreamId: "a", Event: &trackspb.Envelope_Beat{Beat: &trackspb.Beat{Confidence: 0.5}}}
+	none := &trackspb.Envelope{Timestamp: 2}
+	for _, tc := range []struct {
+		m    trackstest.Matcher
+		env  *trackspb.Envelope
+		want bool
+	}{
+		{trackstest.Any(), none, true},
+		{trackstest.Event("beat"), beat, true},
+		{trackstest.Event("onset", "beat"), beat, true},
+		{trackstest.Event("downbeat"), beat, false},
+		{trackstest.Event("beat"), none, false},
+		{trackstest.Stream("a"), beat, true},
+		{trackstest.Stream(""), beat, false},
+		{trackstest.Between(2, 3), beat, true},
+		{trackstest.Between(1, 2), beat, false},
+		{trackstest.Field("confidence", 0.5), beat, true},
+		{trackstest.Field("confidence", 0.6), beat, false},
+		{trackstest.Field("bpm", 0), beat, false},
+		{trackstest.Field("confidence", 0), none, false},
+		{trackstest.And(trackstest.Event("beat"), trackstest.Stream("a")), beat, true},
+		{trackstest.And(trackstest.Event("beat"), trackstest.Stream("b")), beat, false},
+		{trackstest.Func("never", func(*trackspb.Envelope) bool { return false }), beat, false},
+	} {
+		if got := tc.m.Match(tc.env); got != tc.want {
+			t.Errorf("%s matches %v: %v, want %v", tc.m, tc.env, got, tc.want)
+		}
+	}
+	if got := trackstest.EventName(&trackspb.Envelope{Event: &trackspb.Envelope_ChordChange{}}); got != "chord.change" {
+		t.Errorf("EventName = %q, want chord.change", got)
+	}
+	if got := trackstest.EventName(none); got != "" {
+		t.Errorf("EventName of no event = %q", got)
+	}
+}
+
+func TestExpectFailures(t *testing.T) {
+	envs := []*trackspb.Envelope{key(0, "C"), key(12, "D"), key(20, "C")}
+	for _, tc := range []struct {
+		name   string
+		expect func(t testing.TB) bool
+		fails  bool
+	}{
+		{"count", func(t testing.TB) bool { return trackstest.ExpectCount(t, envs, trackstest.Field("key", "C"), 2) }, false},
+		{"count wrong", func(t testing.TB) bool { return trackstest.ExpectCount(t, envs, trackstest.Field("key", "C"), 1) }, true},
+		{"none", func(t testing.TB) bool { return trackstest.ExpectNone(t, envs, trackstest.Field("key", "E")) }, false},
+		{"none found", func(t testing.TB) bool { return trackstest.ExpectNone(t, envs, trackstest.Field("key", "D")) }, true},
+		{"sequence", func(t testing.TB) bool {
+			return trackstest.ExpectSequence(t, envs, trackstest.Field("key", "C"), trackstest.Field("key", "D"), trackstest.Field("key", "C"))
+		}, false},
+		{"sequence reversed", func(t testing.TB) bool {
+			return trackstest.ExpectSequence(t, envs, trackstest.Field("key", "D"), trackstest.Field("key", "D"))
+		}, true},
+		{"sequence missing", func(t testing.TB) bool { return trackstest.ExpectSequence(t, envs, trackstest.Field("key", "E")) }, true},
+		{"near", func(t testing.TB) bool { return trackstest.ExpectNear(t, "x", 1.05, 1, 0.1) }, false},
+		{"near too far", func(t testing.TB) bool { return trackstest.ExpectNear(t, "x", 1.2, 1, 0.1) }, true},
+		{"near NaN", func(t testing.TB) bool { return trackstest.ExpectNear(t, "x", math.NaN(), 1, 0.1) }, true},
+	} {
+		ft := &fakeT{}
+		held := tc.expect(ft)
+		if held == tc.fails || (len(ft.errors) > 0) != tc.fails {
+			t.Errorf("%s: returned %v with errors %q, want failure %v", tc.name, held, ft.errors, tc.fails)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package tracks
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Envelope validation. A payload can parse and still carry numbers no
+// analyzer produces — NaN, infinities, or a timestamp, duration or position
+// beyond any track — which poison every average and threshold they reach,
+// and hang or crash code that steps through or sizes by them. Receivers
+// reject such envelopes as corrupt, like payloads that don't parse. Other
+// values out of their documented ranges (a negative duration, a chroma
+// vector of 5) are left to the code using them, which must cope.
+const maxTimestamp = 1e6 // seconds, about 11.5 days
+
+// timeFields are the fields held to maxTimestamp wherever they appear.
+var timeFields = map[protoreflect.Name]bool{"duration": true, "position": true, "previous": true, "countdown": true}
+
+// CheckEnvelope returns why env cannot be handled, or nil: the first
+// timestamp or field that is NaN or infinite, or a time beyond 10⁶
+// seconds.
+func CheckEnvelope(env *trackspb.Envelope) error {
+	if t := env.GetTimestamp(); math.IsNaN(t) || math.Abs(t) > maxTimestamp {
+		return fmt.Errorf("timestamp %g out of range", t)
+	}
+	return checkFinite(env.ProtoReflect())
+}
+
+// checkFinite returns an error naming the first floating-point field of m,
+// or of the messages in it, that is NaN or infinite, or a time field beyond
+// maxTimestamp.
+func checkFinite(m protoreflect.Message) error {
+	var err error
+	finite := func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch fd.Kind() {
+		case protoreflect.DoubleKind, protoreflect.FloatKind:
+			if f := v.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+				err = fmt.Errorf("%s is %g", fd.FullName(), f)
+				return false
+			} else if timeFields[fd.Name()] && math.Abs(f) > maxTimestamp {
+				err = fmt.Errorf("%s %g out of range", fd.FullName(), f)
+				return false
+			}
+		case protoreflect.MessageKind, protoreflect.GroupKind:
+			err = checkFinite(v.Message())
+			return err == nil
+		}
+		return true
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList():
+			l := v.List()
+			for i := range l.Len() {
+				if !finite(fd, l.Get(i)) {
+					return false
+				}
+			}
+			return true
+		case fd.IsMap():
+			ok := true
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				ok = finite(fd.MapValue(), mv)
+				return ok
+			})
+			return ok
+		}
+		return finite(fd, v)
+	})
+	return err
+}
@@ -22,17 +22,24 @@ const (
 )
 
 type Envelope struct {
-	state     protoimpl.MessageState `protogen:"open.v1"`
-	Timestamp float64                `protobuf:"fixed64,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"` // seconds from start of file
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Timestamp  float64                `protobuf:"fixed64,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`                      // seconds from start of file
+	StreamId   string                 `protobuf:"bytes,2,opt,name=stream_id,json=streamId,proto3" json:"stream_id,omitempty"`          // sender-assigned stream/deck label, empty if unset
+	SendTimeNs int64                  `protobuf:"varint,3,opt,name=send_time_ns,json=sendTimeNs,proto3" json:"send_time_ns,omitempty"` // sender wall clock at send, Unix nanoseconds; 0 if unset
 	// Types that are valid to be assigned to Event:
 	//
 	//	*Envelope_TrackStart
 	//	*Envelope_TrackEnd
 	//	*Envelope_TrackPosition
 	//	*Envelope_TrackAbort
+	//	*Envelope_TrackPrepare
+	//	*Envelope_TimelineReset
+	//	*Envelope_Schema
 	//	*Envelope_Beat
 	//	*Envelope_TempoChange
 	//	*Envelope_Downbeat
+	//	*Envelope_Groove
+	//	*Envelope_MeterChange
 	//	*Envelope_Onset
 	//	*Envelope_OnsetRate
 	//	*Envelope_Novelty
@@ -42,6 +49,7 @@ type Envelope struct {
 	//	*Envelope_Tuning
 	//	*Envelope_Dissonance
 	//	*Envelope_Inharmonicity
+	//	*Envelope_RomanNumeral
 	//	*Envelope_Pitch
 	//	*Envelope_PitchChange
 	//	*Envelope_Melody
@@ -49,6 +57,7 @@ type Envelope struct {
 	//	*Envelope_LoudnessPeak
 	//	*Envelope_Energy
 	//	*Envelope_DynamicChange
+	//	*Envelope_LoudnessTrend
 	//	*Envelope_SilenceStart
 	//	*Envelope_SilenceEnd
 	//	*Envelope_Gap
@@ -59,6 +68,8 @@ type Envelope struct {
 	//	*Envelope_SpectralRolloff
 	//	*Envelope_Mfcc
 	//	*Envelope_TimbreChange
+	//	*Envelope_BrightnessRising
+	//	*Envelope_BrightnessFalling
 	//	*Envelope_BandsMel
 	//	*Envelope_BandsBark
 	//	*Envelope_BandsErb
@@ -66,6 +77,8 @@ type Envelope struct {
 	//	*Envelope_SegmentBoundary
 	//	*Envelope_FadeIn
 	//	*Envelope_FadeOut
+	//	*Envelope_SectionChange
+	//	*Envelope_Drop
 	//	*Envelope_Click
 	//	*Envelope_Discontinuity
 	//	*Envelope_NoiseBurst
@@ -116,6 +129,20 @@ func (x *Envelope) GetTimestamp() float64 {
 	return 0
 }
 
+func (x *Envelope) GetStreamId() string {
+	if x != nil {
+		return x.StreamId
+	}
+	return ""
+}
+
+func (x *Envelope) GetSendTimeNs() int64 {
+	if x != nil {
+		return x.SendTimeNs
+	}
+	return 0
+}
+
 func (x *Envelope) GetEvent() isEnvelope_Event {
 	if x != nil {
 		return x.Event
@@ -159,6 +186,33 @@ func (x *Envelope) GetTrackAbort() *TrackAbort {
 	return nil
 }
 
+func (x *Envelope) GetTrackPrepare() *TrackPrepare {
+	if x != nil {
+		if x, ok := x.Event.(*Envelope_TrackPrepare); ok {
+			return x.TrackPrepare
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetTimelineReset() *TimelineReset {
+	if x != nil {
+		if x, ok := x.Event.(*Envelope_TimelineReset); ok {
+			return x.TimelineReset
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetSchema() *Schema {
+	if x != nil {
+		if x, ok := x.Event.(*Envelope_Schema); ok {
+			return x.Schema
+		}
+	}
+	return nil
+}
+
 func (x *Envelope) GetBeat() *Beat {
 	if x != nil {
 		if x, ok := x.Event.(*Envelope_Beat); ok {
@@ -186,6 +240,24 @@ func (x *Envelope) GetDownbeat() *Downbeat {
 	return nil
 }
 
+func (x *Envelope) GetGroove() *Groove {
+	if x != nil {
+		if x, ok := x.Event.(*Envelope_Groove); ok {
+			return x.Groove
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetMeterChange() *MeterChange {
+	if x != nil {
+		if x, ok := x.Event.(*Envelope_MeterChange); ok {
+			return x.MeterChange
+		}
+	}
+	return nil
+}
+
 func (x *Envelope) GetOnset() *Onset {
 	if x != nil {
 		if x, ok := x.Event.(*Envelope_Onset); ok {
@@ -267,6 +339,15 @@ func (x *Envelope) GetInharmonicity() *Inharmonicity {
 	return nil
 }
 
+func (x *Envelope) GetRomanNumeral() *RomanNumeral {
+	if x != nil {
+		if x, ok := x.Event.(*Envelope_RomanNumeral); ok {
+			return x.RomanNumeral
+		}
+	}
+	return nil
+}
+
 func (x *Envelope) GetPitch() *Pitch {
 	if x != nil {
 		if x, ok := x.Event.(*Envelope_Pitch); ok {
@@ -330,6 +411,15 @@ func (x *Envelope) GetDynamicChange() *DynamicChange {
 	return nil
 }
 
+func (x *Envelope) GetLoudnessTrend() *LoudnessTrend {
+	if x != nil {
+		if x, ok := x.Event.(*Envelope_LoudnessTrend); ok {
+			return x.LoudnessTrend
+		}
+	}
+	return nil
+}
+
 func (x *Envelope) GetSilenceStart() *SilenceStart {
 	if x != nil {
 		if x, ok := x.Event.(*Envelope_SilenceStart); ok {
@@ -420,6 +510,24 @@ func (x *Envelope) GetTimbreChange() *TimbreChange {
 	return nil
 }
 
+func (x *Envelope) GetBrightnessRising() *BrightnessRising {
+	if x != nil {
+		if x, ok := x.Event.(*Envelope_BrightnessRising); ok {
+			return x.BrightnessRising
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetBrightnessFalling() *BrightnessFalling {
+	if x != nil {
+		if x, ok := x.Event.(*Envelope_BrightnessFalling); ok {
+			return x.BrightnessFalling
+		}
+	}
+	return nil
+}
+
 func (x *Envelope) GetBandsMel() *BandsMel {
 	if x != nil {
 		if x, ok := x.Event.(*Envelope_BandsMel); ok {
@@ -483,6 +591,24 @@ func (x *Envelope) GetFadeOut() *FadeOut {
 	return nil
 }
 
+func (x *Envelope) GetSectionChange() *SectionChange {
+	if x != nil {
+		if x, ok := x.Event.(*Envelope_SectionChange); ok {
+			return x.SectionChange
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetDrop() *Drop {
+	if x != nil {
+		if x, ok := x.Event.(*Envelope_Drop); ok {
+			return x.Drop
+		}
+	}
+	return nil
+}
+
 func (x *Envelope) GetClick() *Click {
 	if x != nil {
 		if x, ok := x.Event.(*Envelope_Click); ok {
@@ -576,6 +702,18 @@ type Envelope_TrackAbort struct {
 	TrackAbort *TrackAbort `protobuf:"bytes,13,opt,name=track_abort,json=trackAbort,proto3,oneof"`
 }
 
+type Envelope_TrackPrepare struct {
+	TrackPrepare *TrackPrepare `protobuf:"bytes,14,opt,name=track_prepare,json=trackPrepare,proto3,oneof"`
+}
+
+type Envelope_TimelineReset struct {
+	TimelineReset *TimelineReset `protobuf:"bytes,15,opt,name=timeline_reset,json=timelineReset,proto3,oneof"` // derived by receivers
+}
+
+type Envelope_Schema struct {
+	Schema *Schema `protobuf:"bytes,16,opt,name=schema,proto3,oneof"`
+}
+
 type Envelope_Beat struct {
 	// Beat/Rhythm 20-29
 	Beat *Beat `protobuf:"bytes,20,opt,name=beat,proto3,oneof"`
@@ -589,6 +727,14 @@ type Envelope_Downbeat struct {
 	Downbeat *Downbeat `protobuf:"bytes,22,opt,name=downbeat,proto3,oneof"`
 }
 
+type Envelope_Groove struct {
+	Groove *Groove `protobuf:"bytes,23,opt,name=groove,proto3,oneof"` // derived by receivers
+}
+
+type Envelope_MeterChange struct {
+	MeterChange *MeterChange `protobuf:"bytes,24,opt,name=meter_change,json=meterChange,proto3,oneof"` // derived by receivers
+}
+
 type Envelope_Onset struct {
 	// Onset 30-39
 	Onset *Onset `protobuf:"bytes,30,opt,name=onset,proto3,oneof"`
@@ -627,6 +773,10 @@ type Envelope_Inharmonicity struct {
 	Inharmonicity *Inharmonicity `protobuf:"bytes,45,opt,name=inharmonicity,proto3,oneof"`
 }
 
+type Envelope_RomanNumeral struct {
+	RomanNumeral *RomanNumeral `protobuf:"bytes,46,opt,name=roman_numeral,json=romanNumeral,proto3,oneof"` // derived by receivers
+}
+
 type Envelope_Pitch struct {
 	// Pitch/Melody 50-59
 	Pitch *Pitch `protobuf:"bytes,50,opt,name=pitch,proto3,oneof"`
@@ -657,6 +807,10 @@ type Envelope_DynamicChange struct {
 	DynamicChange *DynamicChange `protobuf:"bytes,63,opt,name=dynamic_change,json=dynamicChange,proto3,oneof"`
 }
 
+type Envelope_LoudnessTrend struct {
+	LoudnessTrend *LoudnessTrend `protobuf:"bytes,64,opt,name=loudness_trend,json=loudnessTrend,proto3,oneof"` // derived by receivers
+}
+
 type Envelope_SilenceStart struct {
 	// Silence/Gap 70-79
 	SilenceStart *SilenceStart `protobuf:"bytes,70,opt,name=silence_start,json=silenceStart,proto3,oneof"`
@@ -699,6 +853,14 @@ type Envelope_TimbreChange struct {
 	TimbreChange *TimbreChange `protobuf:"bytes,86,opt,name=timbre_change,json=timbreChange,proto3,oneof"`
 }
 
+type Envelope_BrightnessRising struct {
+	BrightnessRising *BrightnessRising `protobuf:"bytes,87,opt,name=brightness_rising,json=brightnessRising,proto3,oneof"` // derived by receivers
+}
+
+type Envelope_BrightnessFalling struct {
+	BrightnessFalling *BrightnessFalling `protobuf:"bytes,88,opt,name=brightness_falling,json=brightnessFalling,proto3,oneof"` // derived by receivers
+}
+
 type Envelope_BandsMel struct {
 	// Bands 90-99
 	BandsMel *BandsMel `protobuf:"bytes,90,opt,name=bands_mel,json=bandsMel,proto3,oneof"`
@@ -729,6 +891,14 @@ type Envelope_FadeOut struct {
 	FadeOut *FadeOut `protobuf:"bytes,102,opt,name=fade_out,json=fadeOut,proto3,oneof"`
 }
 
+type Envelope_SectionChange struct {
+	SectionChange *SectionChange `protobuf:"bytes,103,opt,name=section_change,json=sectionChange,proto3,oneof"` // derived by receivers
+}
+
+type Envelope_Drop struct {
+	Drop *Drop `protobuf:"bytes,104,opt,name=drop,proto3,oneof"` // derived by receivers
+}
+
 type Envelope_Click struct {
 	// Quality 110-119
 	Click *Click `protobuf:"bytes,110,opt,name=click,proto3,oneof"`
@@ -771,12 +941,22 @@ func (*Envelope_TrackPosition) isEnvelope_Event() {}
 
 func (*Envelope_TrackAbort) isEnvelope_Event() {}
 
+func (*Envelope_TrackPrepare) isEnvelope_Event() {}
+
+func (*Envelope_TimelineReset) isEnvelope_Event() {}
+
+func (*Envelope_Schema) isEnvelope_Event() {}
+
 func (*Envelope_Beat) isEnvelope_Event() {}
 
 func (*Envelope_TempoChange) isEnvelope_Event() {}
 
 func (*Envelope_Downbeat) isEnvelope_Event() {}
 
+func (*Envelope_Groove) isEnvelope_Event() {}
+
+func (*Envelope_MeterChange) isEnvelope_Event() {}
+
 func (*Envelope_Onset) isEnvelope_Event() {}
 
 func (*Envelope_OnsetRate) isEnvelope_Event() {}
@@ -795,6 +975,8 @@ func (*Envelope_Dissonance) isEnvelope_Event() {}
 
 func (*Envelope_Inharmonicity) isEnvelope_Event() {}
 
+func (*Envelope_RomanNumeral) isEnvelope_Event() {}
+
 func (*Envelope_Pitch) isEnvelope_Event() {}
 
 func (*Envelope_PitchChange) isEnvelope_Event() {}
@@ -809,6 +991,8 @@ func (*Envelope_Energy) isEnvelope_Event() {}
 
 func (*Envelope_DynamicChange) isEnvelope_Event() {}
 
+func (*Envelope_LoudnessTrend) isEnvelope_Event() {}
+
 func (*Envelope_SilenceStart) isEnvelope_Event() {}
 
 func (*Envelope_SilenceEnd) isEnvelope_Event() {}
@@ -829,6 +1013,10 @@ func (*Envelope_Mfcc) isEnvelope_Event() {}
 
 func (*Envelope_TimbreChange) isEnvelope_Event() {}
 
+func (*Envelope_BrightnessRising) isEnvelope_Event() {}
+
+func (*Envelope_BrightnessFalling) isEnvelope_Event() {}
+
 func (*Envelope_BandsMel) isEnvelope_Event() {}
 
 func (*Envelope_BandsBark) isEnvelope_Event() {}
@@ -843,6 +1031,10 @@ func (*Envelope_FadeIn) isEnvelope_Event() {}
 
 func (*Envelope_FadeOut) isEnvelope_Event() {}
 
+func (*Envelope_SectionChange) isEnvelope_Event() {}
+
+func (*Envelope_Drop) isEnvelope_Event() {}
+
 func (*Envelope_Click) isEnvelope_Event() {}
 
 func (*Envelope_Discontinuity) isEnvelope_Event() {}
@@ -1051,27 +1243,28 @@ func (x *TrackAbort) GetReason() string {
 	return ""
 }
 
-type Beat struct {
+type TrackPrepare struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Confidence    float64                `protobuf:"fixed64,1,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	Countdown     float64                `protobuf:"fixed64,1,opt,name=countdown,proto3" json:"countdown,omitempty"` // seconds until track.start
+	Filename      string                 `protobuf:"bytes,2,opt,name=filename,proto3" json:"filename,omitempty"`     // canonical (absolute) file path
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Beat) Reset() {
-	*x = Beat{}
+func (x *TrackPrepare) Reset() {
+	*x = TrackPrepare{}
 	mi := &file_tracks_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Beat) String() string {
+func (x *TrackPrepare) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Beat) ProtoMessage() {}
+func (*TrackPrepare) ProtoMessage() {}
 
-func (x *Beat) ProtoReflect() protoreflect.Message {
+func (x *TrackPrepare) ProtoReflect() protoreflect.Message {
 	mi := &file_tracks_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1083,39 +1276,46 @@ func (x *Beat) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Beat.ProtoReflect.Descriptor instead.
-func (*Beat) Descriptor() ([]byte, []int) {
+// Deprecated: Use TrackPrepare.ProtoReflect.Descriptor instead.
+func (*TrackPrepare) Descriptor() ([]byte, []int) {
 	return file_tracks_proto_rawDescGZIP(), []int{5}
 }
 
-func (x *Beat) GetConfidence() float64 {
+func (x *TrackPrepare) GetCountdown() float64 {
 	if x != nil {
-		return x.Confidence
+		return x.Countdown
 	}
 	return 0
 }
 
-type TempoChange struct {
+func (x *TrackPrepare) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+type TimelineReset struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Bpm           float64                `protobuf:"fixed64,1,opt,name=bpm,proto3" json:"bpm,omitempty"`
+	Previous      float64                `protobuf:"fixed64,1,opt,name=previous,proto3" json:"previous,omitempty"` // latest timestamp before the jump
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *TempoChange) Reset() {
-	*x = TempoChange{}
+func (x *TimelineReset) Reset() {
+	*x = TimelineReset{}
 	mi := &file_tracks_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *TempoChange) String() string {
+func (x *TimelineReset) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*TempoChange) ProtoMessage() {}
+func (*TimelineReset) ProtoMessage() {}
 
-func (x *TempoChange) ProtoReflect() protoreflect.Message {
+func (x *TimelineReset) ProtoReflect() protoreflect.Message {
 	mi := &file_tracks_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1127,39 +1327,39 @@ func (x *TempoChange) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use TempoChange.ProtoReflect.Descriptor instead.
-func (*TempoChange) Descriptor() ([]byte, []int) {
+// Deprecated: Use TimelineReset.ProtoReflect.Descriptor instead.
+func (*TimelineReset) Descriptor() ([]byte, []int) {
 	return file_tracks_proto_rawDescGZIP(), []int{6}
 }
 
-func (x *TempoChange) GetBpm() float64 {
+func (x *TimelineReset) GetPrevious() float64 {
 	if x != nil {
-		return x.Bpm
+		return x.Previous
 	}
 	return 0
 }
 
-type Downbeat struct {
+type Schema struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Confidence    float64                `protobuf:"fixed64,1,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	DescriptorSet []byte                 `protobuf:"bytes,1,opt,name=descriptor_set,json=descriptorSet,proto3" json:"descriptor_set,omitempty"` // serialized google.protobuf.FileDescriptorSet of the sender's tracks.proto
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Downbeat) Reset() {
-	*x = Downbeat{}
+func (x *Schema) Reset() {
+	*x = Schema{}
 	mi := &file_tracks_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Downbeat) String() string {
+func (x *Schema) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Downbeat) ProtoMessage() {}
+func (*Schema) ProtoMessage() {}
 
-func (x *Downbeat) ProtoReflect() protoreflect.Message {
+func (x *Schema) ProtoReflect() protoreflect.Message {
 	mi := &file_tracks_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1171,39 +1371,39 @@ func (x *Downbeat) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Downbeat.ProtoReflect.Descriptor instead.
-func (*Downbeat) Descriptor() ([]byte, []int) {
+// Deprecated: Use Schema.ProtoReflect.Descriptor instead.
+func (*Schema) Descriptor() ([]byte, []int) {
 	return file_tracks_proto_rawDescGZIP(), []int{7}
 }
 
-func (x *Downbeat) GetConfidence() float64 {
+func (x *Schema) GetDescriptorSet() []byte {
 	if x != nil {
-		return x.Confidence
+		return x.DescriptorSet
 	}
-	return 0
+	return nil
 }
 
-type Onset struct {
+type Beat struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Strength      float64                `protobuf:"fixed64,1,opt,name=strength,proto3" json:"strength,omitempty"`
+	Confidence    float64                `protobuf:"fixed64,1,opt,name=confidence,proto3" json:"confidence,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Onset) Reset() {
-	*x = Onset{}
+func (x *Beat) Reset() {
+	*x = Beat{}
 	mi := &file_tracks_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Onset) String() string {
+func (x *Beat) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Onset) ProtoMessage() {}
+func (*Beat) ProtoMessage() {}
 
-func (x *Onset) ProtoReflect() protoreflect.Message {
+func (x *Beat) ProtoReflect() protoreflect.Message {
 	mi := &file_tracks_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1215,39 +1415,39 @@ func (x *Onset) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Onset.ProtoReflect.Descriptor instead.
-func (*Onset) Descriptor() ([]byte, []int) {
+// Deprecated: Use Beat.ProtoReflect.Descriptor instead.
+func (*Beat) Descriptor() ([]byte, []int) {
 	return file_tracks_proto_rawDescGZIP(), []int{8}
 }
 
-func (x *Onset) GetStrength() float64 {
+func (x *Beat) GetConfidence() float64 {
 	if x != nil {
-		return x.Strength
+		return x.Confidence
 	}
 	return 0
 }
 
-type OnsetRate struct {
+type TempoChange struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Rate          float64                `protobuf:"fixed64,1,opt,name=rate,proto3" json:"rate,omitempty"` // onsets per second
+	Bpm           float64                `protobuf:"fixed64,1,opt,name=bpm,proto3" json:"bpm,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *OnsetRate) Reset() {
-	*x = OnsetRate{}
+func (x *TempoChange) Reset() {
+	*x = TempoChange{}
 	mi := &file_tracks_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *OnsetRate) String() string {
+func (x *TempoChange) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*OnsetRate) ProtoMessage() {}
+func (*TempoChange) ProtoMessage() {}
 
-func (x *OnsetRate) ProtoReflect() protoreflect.Message {
+func (x *TempoChange) ProtoReflect() protoreflect.Message {
 	mi := &file_tracks_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1259,39 +1459,39 @@ func (x *OnsetRate) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use OnsetRate.ProtoReflect.Descriptor instead.
-func (*OnsetRate) Descriptor() ([]byte, []int) {
+// Deprecated: Use TempoChange.ProtoReflect.Descriptor instead.
+func (*TempoChange) Descriptor() ([]byte, []int) {
 	return file_tracks_proto_rawDescGZIP(), []int{9}
 }
 
-func (x *OnsetRate) GetRate() float64 {
+func (x *TempoChange) GetBpm() float64 {
 	if x != nil {
-		return x.Rate
+		return x.Bpm
 	}
 	return 0
 }
 
-type Novelty struct {
+type Downbeat struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Value         float64                `protobuf:"fixed64,1,opt,name=value,proto3" json:"value,omitempty"`
+	Confidence    float64                `protobuf:"fixed64,1,opt,name=confidence,proto3" json:"confidence,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Novelty) Reset() {
-	*x = Novelty{}
+func (x *Downbeat) Reset() {
+	*x = Downbeat{}
 	mi := &file_tracks_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Novelty) String() string {
+func (x *Downbeat) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Novelty) ProtoMessage() {}
+func (*Downbeat) ProtoMessage() {}
 
-func (x *Novelty) ProtoReflect() protoreflect.Message {
+func (x *Downbeat) ProtoReflect() protoreflect.Message {
 	mi := &file_tracks_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1303,41 +1503,43 @@ func (x *Novelty) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Novelty.ProtoReflect.Descriptor instead.
-func (*Novelty) Descriptor() ([]byte, []int) {
+// Deprecated: Use Downbeat.ProtoReflect.Descriptor instead.
+func (*Downbeat) Descriptor() ([]byte, []int) {
 	return file_tracks_proto_rawDescGZIP(), []int{10}
 }
 
-func (x *Novelty) GetValue() float64 {
+func (x *Downbeat) GetConfidence() float64 {
 	if x != nil {
-		return x.Value
+		return x.Confidence
 	}
 	return 0
 }
 
-type KeyChange struct {
+type Groove struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`     // e.g. "C"
-	Scale         string                 `protobuf:"bytes,2,opt,name=scale,proto3" json:"scale,omitempty"` // e.g. "major", "minor"
-	Strength      float64                `protobuf:"fixed64,3,opt,name=strength,proto3" json:"strength,omitempty"`
+	Swing         float64                `protobuf:"fixed64,1,opt,name=swing,proto3" json:"swing,omitempty"`   // eighth-note swing, % of the beat before the off-beat: 50 straight, 66.7 triplet
+	Ratio         float64                `protobuf:"fixed64,2,opt,name=ratio,proto3" json:"ratio,omitempty"`   // long to short eighth-note ratio: 1 straight, 2 triplet
+	Offset        float64                `protobuf:"fixed64,3,opt,name=offset,proto3" json:"offset,omitempty"` // mean offset of on-beat onsets from the beat, ms: negative ahead, positive behind
+	Jitter        float64                `protobuf:"fixed64,4,opt,name=jitter,proto3" json:"jitter,omitempty"` // standard deviation of those offsets, ms
+	Onsets        int32                  `protobuf:"varint,5,opt,name=onsets,proto3" json:"onsets,omitempty"`  // onsets measured
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *KeyChange) Reset() {
-	*x = KeyChange{}
+func (x *Groove) Reset() {
+	*x = Groove{}
 	mi := &file_tracks_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *KeyChange) String() string {
+func (x *Groove) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*KeyChange) ProtoMessage() {}
+func (*Groove) ProtoMessage() {}
 
-func (x *KeyChange) ProtoReflect() protoreflect.Message {
+func (x *Groove) ProtoReflect() protoreflect.Message {
 	mi := &file_tracks_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1349,54 +1551,71 @@ func (x *KeyChange) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use KeyChange.ProtoReflect.Descriptor instead.
-func (*KeyChange) Descriptor() ([]byte, []int) {
+// Deprecated: Use Groove.ProtoReflect.Descriptor instead.
+func (*Groove) Descriptor() ([]byte, []int) {
 	return file_tracks_proto_rawDescGZIP(), []int{11}
 }
 
-func (x *KeyChange) GetKey() string {
+func (x *Groove) GetSwing() float64 {
 	if x != nil {
-		return x.Key
+		return x.Swing
 	}
-	return ""
+	return 0
 }
 
-func (x *KeyChange) GetScale() string {
+func (x *Groove) GetRatio() float64 {
 	if x != nil {
-		return x.Scale
+		return x.Ratio
 	}
-	return ""
+	return 0
 }
 
-func (x *KeyChange) GetStrength() float64 {
+func (x *Groove) GetOffset() float64 {
 	if x != nil {
-		return x.Strength
+		return x.Offset
 	}
 	return 0
 }
 
-type ChordChange struct {
+func (x *Groove) GetJitter() float64 {
+	if x != nil {
+		return x.Jitter
+	}
+	return 0
+}
+
+func (x *Groove) GetOnsets() int32 {
+	if x != nil {
+		return x.Onsets
+	}
+	return 0
+}
+
+type MeterChange struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Chord         string                 `protobuf:"bytes,1,opt,name=chord,proto3" json:"chord,omitempty"` // e.g. "Am", "G"
-	Strength      float64                `protobuf:"fixed64,2,opt,name=strength,proto3" json:"strength,omitempty"`
+	Meter         string                 `protobuf:"bytes,1,opt,name=meter,proto3" json:"meter,omitempty"` // e.g. "4/4", "3/4", "6/8"
+	Numerator     int32                  `protobuf:"varint,2,opt,name=numerator,proto3" json:"numerator,omitempty"`
+	Denominator   int32                  `protobuf:"varint,3,opt,name=denominator,proto3" json:"denominator,omitempty"` // 4, or 8 for compound meters
+	Beats         int32                  `protobuf:"varint,4,opt,name=beats,proto3" json:"beats,omitempty"`             // beats per bar as tracked: 2 for 6/8 counted in dotted quarters
+	Confidence    float64                `protobuf:"fixed64,5,opt,name=confidence,proto3" json:"confidence,omitempty"`  // share of the measured bars with that many beats, 0.0 to 1.0
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ChordChange) Reset() {
-	*x = ChordChange{}
+func (x *MeterChange) Reset() {
+	*x = MeterChange{}
 	mi := &file_tracks_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ChordChange) String() string {
+func (x *MeterChange) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ChordChange) ProtoMessage() {}
+func (*MeterChange) ProtoMessage() {}
 
-func (x *ChordChange) ProtoReflect() protoreflect.Message {
+func (x *MeterChange) ProtoReflect() protoreflect.Message {
 	mi := &file_tracks_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1408,11 +1627,276 @@ func (x *ChordChange) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ChordChange.ProtoReflect.Descriptor instead.
-func (*ChordChange) Descriptor() ([]byte, []int) {
+// Deprecated: Use MeterChange.ProtoReflect.Descriptor instead.
+func (*MeterChange) Descriptor() ([]byte, []int) {
 	return file_tracks_proto_rawDescGZIP(), []int{12}
 }
 
+func (x *MeterChange) GetMeter() string {
+	if x != nil {
+		return x.Meter
+	}
+	return ""
+}
+
+func (x *MeterChange) GetNumerator() int32 {
+	if x != nil {
+		return x.Numerator
+	}
+	return 0
+}
+
+func (x *MeterChange) GetDenominator() int32 {
+	if x != nil {
+		return x.Denominator
+	}
+	return 0
+}
+
+func (x *MeterChange) GetBeats() int32 {
+	if x != nil {
+		return x.Beats
+	}
+	return 0
+}
+
+func (x *MeterChange) GetConfidence() float64 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+type Onset struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Strength      float64                `protobuf:"fixed64,1,opt,name=strength,proto3" json:"strength,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Onset) Reset() {
+	*x = Onset{}
+	mi := &file_tracks_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Onset) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Onset) ProtoMessage() {}
+
+func (x *Onset) ProtoReflect() protoreflect.Message {
+	mi := &file_tracks_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Onset.ProtoReflect.Descriptor instead.
+func (*Onset) Descriptor() ([]byte, []int) {
+	return file_tracks_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Onset) GetStrength() float64 {
+	if x != nil {
+		return x.Strength
+	}
+	return 0
+}
+
+type OnsetRate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Rate          float64                `protobuf:"fixed64,1,opt,name=rate,proto3" json:"rate,omitempty"` // onsets per second
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OnsetRate) Reset() {
+	*x = OnsetRate{}
+	mi := &file_tracks_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OnsetRate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OnsetRate) ProtoMessage() {}
+
+func (x *OnsetRate) ProtoReflect() protoreflect.Message {
+	mi := &file_tracks_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OnsetRate.ProtoReflect.Descriptor instead.
+func (*OnsetRate) Descriptor() ([]byte, []int) {
+	return file_tracks_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *OnsetRate) GetRate() float64 {
+	if x != nil {
+		return x.Rate
+	}
+	return 0
+}
+
+type Novelty struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         float64                `protobuf:"fixed64,1,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Novelty) Reset() {
+	*x = Novelty{}
+	mi := &file_tracks_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Novelty) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Novelty) ProtoMessage() {}
+
+func (x *Novelty) ProtoReflect() protoreflect.Message {
+	mi := &file_tracks_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Novelty.ProtoReflect.Descriptor instead.
+func (*Novelty) Descriptor() ([]byte, []int) {
+	return file_tracks_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *Novelty) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+type KeyChange struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`     // e.g. "C"
+	Scale         string                 `protobuf:"bytes,2,opt,name=scale,proto3" json:"scale,omitempty"` // e.g. "major", "minor"
+	Strength      float64                `protobuf:"fixed64,3,opt,name=strength,proto3" json:"strength,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KeyChange) Reset() {
+	*x = KeyChange{}
+	mi := &file_tracks_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KeyChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeyChange) ProtoMessage() {}
+
+func (x *KeyChange) ProtoReflect() protoreflect.Message {
+	mi := &file_tracks_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeyChange.ProtoReflect.Descriptor instead.
+func (*KeyChange) Descriptor() ([]byte, []int) {
+	return file_tracks_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *KeyChange) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *KeyChange) GetScale() string {
+	if x != nil {
+		return x.Scale
+	}
+	return ""
+}
+
+func (x *KeyChange) GetStrength() float64 {
+	if x != nil {
+		return x.Strength
+	}
+	return 0
+}
+
+type ChordChange struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Chord         string                 `protobuf:"bytes,1,opt,name=chord,proto3" json:"chord,omitempty"` // e.g. "Am", "G"
+	Strength      float64                `protobuf:"fixed64,2,opt,name=strength,proto3" json:"strength,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChordChange) Reset() {
+	*x = ChordChange{}
+	mi := &file_tracks_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChordChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChordChange) ProtoMessage() {}
+
+func (x *ChordChange) ProtoReflect() protoreflect.Message {
+	mi := &file_tracks_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChordChange.ProtoReflect.Descriptor instead.
+func (*ChordChange) Descriptor() ([]byte, []int) {
+	return file_tracks_proto_rawDescGZIP(), []int{17}
+}
+
 func (x *ChordChange) GetChord() string {
 	if x != nil {
 		return x.Chord
@@ -1436,7 +1920,7 @@ type Chroma struct {
 
 func (x *Chroma) Reset() {
 	*x = Chroma{}
-	mi := &file_tracks_proto_msgTypes[13]
+	mi := &file_tracks_proto_msgTypes[18]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1448,7 +1932,7 @@ func (x *Chroma) String() string {
 func (*Chroma) ProtoMessage() {}
 
 func (x *Chroma) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[13]
+	mi := &file_tracks_proto_msgTypes[18]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1461,7 +1945,7 @@ func (x *Chroma) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Chroma.ProtoReflect.Descriptor instead.
 func (*Chroma) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{13}
+	return file_tracks_proto_rawDescGZIP(), []int{18}
 }
 
 func (x *Chroma) GetValues() []float32 {
@@ -1480,7 +1964,7 @@ type Tuning struct {
 
 func (x *Tuning) Reset() {
 	*x = Tuning{}
-	mi := &file_tracks_proto_msgTypes[14]
+	mi := &file_tracks_proto_msgTypes[19]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1492,7 +1976,7 @@ func (x *Tuning) String() string {
 func (*Tuning) ProtoMessage() {}
 
 func (x *Tuning) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[14]
+	mi := &file_tracks_proto_msgTypes[19]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1505,7 +1989,7 @@ func (x *Tuning) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Tuning.ProtoReflect.Descriptor instead.
 func (*Tuning) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{14}
+	return file_tracks_proto_rawDescGZIP(), []int{19}
 }
 
 func (x *Tuning) GetFrequency() float64 {
@@ -1524,7 +2008,7 @@ type Dissonance struct {
 
 func (x *Dissonance) Reset() {
 	*x = Dissonance{}
-	mi := &file_tracks_proto_msgTypes[15]
+	mi := &file_tracks_proto_msgTypes[20]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1536,7 +2020,7 @@ func (x *Dissonance) String() string {
 func (*Dissonance) ProtoMessage() {}
 
 func (x *Dissonance) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[15]
+	mi := &file_tracks_proto_msgTypes[20]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1549,7 +2033,7 @@ func (x *Dissonance) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Dissonance.ProtoReflect.Descriptor instead.
 func (*Dissonance) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{15}
+	return file_tracks_proto_rawDescGZIP(), []int{20}
 }
 
 func (x *Dissonance) GetValue() float64 {
@@ -1568,7 +2052,7 @@ type Inharmonicity struct {
 
 func (x *Inharmonicity) Reset() {
 	*x = Inharmonicity{}
-	mi := &file_tracks_proto_msgTypes[16]
+	mi := &file_tracks_proto_msgTypes[21]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1580,7 +2064,7 @@ func (x *Inharmonicity) String() string {
 func (*Inharmonicity) ProtoMessage() {}
 
 func (x *Inharmonicity) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[16]
+	mi := &file_tracks_proto_msgTypes[21]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1593,7 +2077,7 @@ func (x *Inharmonicity) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Inharmonicity.ProtoReflect.Descriptor instead.
 func (*Inharmonicity) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{16}
+	return file_tracks_proto_rawDescGZIP(), []int{21}
 }
 
 func (x *Inharmonicity) GetValue() float64 {
@@ -1603,6 +2087,66 @@ func (x *Inharmonicity) GetValue() float64 {
 	return 0
 }
 
+type RomanNumeral struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Numeral       string                 `protobuf:"bytes,1,opt,name=numeral,proto3" json:"numeral,omitempty"` // e.g. "vi", "V7", "bVII"
+	Chord         string                 `protobuf:"bytes,2,opt,name=chord,proto3" json:"chord,omitempty"`     // the chord.change it labels
+	Key           string                 `protobuf:"bytes,3,opt,name=key,proto3" json:"key,omitempty"`         // key it was read in, e.g. "C major"
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RomanNumeral) Reset() {
+	*x = RomanNumeral{}
+	mi := &file_tracks_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RomanNumeral) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RomanNumeral) ProtoMessage() {}
+
+func (x *RomanNumeral) ProtoReflect() protoreflect.Message {
+	mi := &file_tracks_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RomanNumeral.ProtoReflect.Descriptor instead.
+func (*RomanNumeral) Descriptor() ([]byte, []int) {
+	return file_tracks_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *RomanNumeral) GetNumeral() string {
+	if x != nil {
+		return x.Numeral
+	}
+	return ""
+}
+
+func (x *RomanNumeral) GetChord() string {
+	if x != nil {
+		return x.Chord
+	}
+	return ""
+}
+
+func (x *RomanNumeral) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
 type Pitch struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Frequency     float64                `protobuf:"fixed64,1,opt,name=frequency,proto3" json:"frequency,omitempty"` // Hz
@@ -1613,7 +2157,7 @@ type Pitch struct {
 
 func (x *Pitch) Reset() {
 	*x = Pitch{}
-	mi := &file_tracks_proto_msgTypes[17]
+	mi := &file_tracks_proto_msgTypes[23]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1625,7 +2169,7 @@ func (x *Pitch) String() string {
 func (*Pitch) ProtoMessage() {}
 
 func (x *Pitch) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[17]
+	mi := &file_tracks_proto_msgTypes[23]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1638,7 +2182,7 @@ func (x *Pitch) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Pitch.ProtoReflect.Descriptor instead.
 func (*Pitch) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{17}
+	return file_tracks_proto_rawDescGZIP(), []int{23}
 }
 
 func (x *Pitch) GetFrequency() float64 {
@@ -1665,7 +2209,7 @@ type PitchChange struct {
 
 func (x *PitchChange) Reset() {
 	*x = PitchChange{}
-	mi := &file_tracks_proto_msgTypes[18]
+	mi := &file_tracks_proto_msgTypes[24]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1677,7 +2221,7 @@ func (x *PitchChange) String() string {
 func (*PitchChange) ProtoMessage() {}
 
 func (x *PitchChange) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[18]
+	mi := &file_tracks_proto_msgTypes[24]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1690,7 +2234,7 @@ func (x *PitchChange) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PitchChange.ProtoReflect.Descriptor instead.
 func (*PitchChange) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{18}
+	return file_tracks_proto_rawDescGZIP(), []int{24}
 }
 
 func (x *PitchChange) GetFromHz() float64 {
@@ -1716,7 +2260,7 @@ type Melody struct {
 
 func (x *Melody) Reset() {
 	*x = Melody{}
-	mi := &file_tracks_proto_msgTypes[19]
+	mi := &file_tracks_proto_msgTypes[25]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1728,7 +2272,7 @@ func (x *Melody) String() string {
 func (*Melody) ProtoMessage() {}
 
 func (x *Melody) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[19]
+	mi := &file_tracks_proto_msgTypes[25]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1741,7 +2285,7 @@ func (x *Melody) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Melody.ProtoReflect.Descriptor instead.
 func (*Melody) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{19}
+	return file_tracks_proto_rawDescGZIP(), []int{25}
 }
 
 func (x *Melody) GetFrequency() float64 {
@@ -1760,7 +2304,7 @@ type Loudness struct {
 
 func (x *Loudness) Reset() {
 	*x = Loudness{}
-	mi := &file_tracks_proto_msgTypes[20]
+	mi := &file_tracks_proto_msgTypes[26]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1772,7 +2316,7 @@ func (x *Loudness) String() string {
 func (*Loudness) ProtoMessage() {}
 
 func (x *Loudness) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[20]
+	mi := &file_tracks_proto_msgTypes[26]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1785,7 +2329,7 @@ func (x *Loudness) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Loudness.ProtoReflect.Descriptor instead.
 func (*Loudness) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{20}
+	return file_tracks_proto_rawDescGZIP(), []int{26}
 }
 
 func (x *Loudness) GetValue() float64 {
@@ -1804,7 +2348,7 @@ type LoudnessPeak struct {
 
 func (x *LoudnessPeak) Reset() {
 	*x = LoudnessPeak{}
-	mi := &file_tracks_proto_msgTypes[21]
+	mi := &file_tracks_proto_msgTypes[27]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1816,7 +2360,7 @@ func (x *LoudnessPeak) String() string {
 func (*LoudnessPeak) ProtoMessage() {}
 
 func (x *LoudnessPeak) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[21]
+	mi := &file_tracks_proto_msgTypes[27]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1829,7 +2373,7 @@ func (x *LoudnessPeak) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use LoudnessPeak.ProtoReflect.Descriptor instead.
 func (*LoudnessPeak) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{21}
+	return file_tracks_proto_rawDescGZIP(), []int{27}
 }
 
 func (x *LoudnessPeak) GetValue() float64 {
@@ -1848,7 +2392,7 @@ type Energy struct {
 
 func (x *Energy) Reset() {
 	*x = Energy{}
-	mi := &file_tracks_proto_msgTypes[22]
+	mi := &file_tracks_proto_msgTypes[28]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1860,7 +2404,7 @@ func (x *Energy) String() string {
 func (*Energy) ProtoMessage() {}
 
 func (x *Energy) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[22]
+	mi := &file_tracks_proto_msgTypes[28]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1873,7 +2417,7 @@ func (x *Energy) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Energy.ProtoReflect.Descriptor instead.
 func (*Energy) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{22}
+	return file_tracks_proto_rawDescGZIP(), []int{28}
 }
 
 func (x *Energy) GetValue() float64 {
@@ -1892,7 +2436,7 @@ type DynamicChange struct {
 
 func (x *DynamicChange) Reset() {
 	*x = DynamicChange{}
-	mi := &file_tracks_proto_msgTypes[23]
+	mi := &file_tracks_proto_msgTypes[29]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1904,7 +2448,7 @@ func (x *DynamicChange) String() string {
 func (*DynamicChange) ProtoMessage() {}
 
 func (x *DynamicChange) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[23]
+	mi := &file_tracks_proto_msgTypes[29]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1917,7 +2461,7 @@ func (x *DynamicChange) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DynamicChange.ProtoReflect.Descriptor instead.
 func (*DynamicChange) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{23}
+	return file_tracks_proto_rawDescGZIP(), []int{29}
 }
 
 func (x *DynamicChange) GetMagnitude() float64 {
@@ -1927,6 +2471,66 @@ func (x *DynamicChange) GetMagnitude() float64 {
 	return 0
 }
 
+type LoudnessTrend struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Direction     string                 `protobuf:"bytes,1,opt,name=direction,proto3" json:"direction,omitempty"` // "rising", "falling" or "steady"
+	Slope         float64                `protobuf:"fixed64,2,opt,name=slope,proto3" json:"slope,omitempty"`       // dB per second over the trend window
+	Loudness      float64                `protobuf:"fixed64,3,opt,name=loudness,proto3" json:"loudness,omitempty"` // mean loudness over the window
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LoudnessTrend) Reset() {
+	*x = LoudnessTrend{}
+	mi := &file_tracks_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LoudnessTrend) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoudnessTrend) ProtoMessage() {}
+
+func (x *LoudnessTrend) ProtoReflect() protoreflect.Message {
+	mi := &file_tracks_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoudnessTrend.ProtoReflect.Descriptor instead.
+func (*LoudnessTrend) Descriptor() ([]byte, []int) {
+	return file_tracks_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *LoudnessTrend) GetDirection() string {
+	if x != nil {
+		return x.Direction
+	}
+	return ""
+}
+
+func (x *LoudnessTrend) GetSlope() float64 {
+	if x != nil {
+		return x.Slope
+	}
+	return 0
+}
+
+func (x *LoudnessTrend) GetLoudness() float64 {
+	if x != nil {
+		return x.Loudness
+	}
+	return 0
+}
+
 type SilenceStart struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
@@ -1935,7 +2539,7 @@ type SilenceStart struct {
 
 func (x *SilenceStart) Reset() {
 	*x = SilenceStart{}
-	mi := &file_tracks_proto_msgTypes[24]
+	mi := &file_tracks_proto_msgTypes[31]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1947,7 +2551,7 @@ func (x *SilenceStart) String() string {
 func (*SilenceStart) ProtoMessage() {}
 
 func (x *SilenceStart) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[24]
+	mi := &file_tracks_proto_msgTypes[31]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1960,7 +2564,7 @@ func (x *SilenceStart) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SilenceStart.ProtoReflect.Descriptor instead.
 func (*SilenceStart) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{24}
+	return file_tracks_proto_rawDescGZIP(), []int{31}
 }
 
 type SilenceEnd struct {
@@ -1971,7 +2575,7 @@ type SilenceEnd struct {
 
 func (x *SilenceEnd) Reset() {
 	*x = SilenceEnd{}
-	mi := &file_tracks_proto_msgTypes[25]
+	mi := &file_tracks_proto_msgTypes[32]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1983,7 +2587,7 @@ func (x *SilenceEnd) String() string {
 func (*SilenceEnd) ProtoMessage() {}
 
 func (x *SilenceEnd) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[25]
+	mi := &file_tracks_proto_msgTypes[32]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1996,7 +2600,7 @@ func (x *SilenceEnd) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SilenceEnd.ProtoReflect.Descriptor instead.
 func (*SilenceEnd) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{25}
+	return file_tracks_proto_rawDescGZIP(), []int{32}
 }
 
 type Gap struct {
@@ -2008,7 +2612,7 @@ type Gap struct {
 
 func (x *Gap) Reset() {
 	*x = Gap{}
-	mi := &file_tracks_proto_msgTypes[26]
+	mi := &file_tracks_proto_msgTypes[33]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2020,7 +2624,7 @@ func (x *Gap) String() string {
 func (*Gap) ProtoMessage() {}
 
 func (x *Gap) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[26]
+	mi := &file_tracks_proto_msgTypes[33]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2033,7 +2637,7 @@ func (x *Gap) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Gap.ProtoReflect.Descriptor instead.
 func (*Gap) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{26}
+	return file_tracks_proto_rawDescGZIP(), []int{33}
 }
 
 func (x *Gap) GetDuration() float64 {
@@ -2052,7 +2656,7 @@ type SpectralCentroid struct {
 
 func (x *SpectralCentroid) Reset() {
 	*x = SpectralCentroid{}
-	mi := &file_tracks_proto_msgTypes[27]
+	mi := &file_tracks_proto_msgTypes[34]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2064,7 +2668,7 @@ func (x *SpectralCentroid) String() string {
 func (*SpectralCentroid) ProtoMessage() {}
 
 func (x *SpectralCentroid) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[27]
+	mi := &file_tracks_proto_msgTypes[34]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2077,7 +2681,7 @@ func (x *SpectralCentroid) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SpectralCentroid.ProtoReflect.Descriptor instead.
 func (*SpectralCentroid) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{27}
+	return file_tracks_proto_rawDescGZIP(), []int{34}
 }
 
 func (x *SpectralCentroid) GetValue() float64 {
@@ -2096,7 +2700,7 @@ type SpectralFlux struct {
 
 func (x *SpectralFlux) Reset() {
 	*x = SpectralFlux{}
-	mi := &file_tracks_proto_msgTypes[28]
+	mi := &file_tracks_proto_msgTypes[35]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2108,7 +2712,7 @@ func (x *SpectralFlux) String() string {
 func (*SpectralFlux) ProtoMessage() {}
 
 func (x *SpectralFlux) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[28]
+	mi := &file_tracks_proto_msgTypes[35]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2121,7 +2725,7 @@ func (x *SpectralFlux) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SpectralFlux.ProtoReflect.Descriptor instead.
 func (*SpectralFlux) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{28}
+	return file_tracks_proto_rawDescGZIP(), []int{35}
 }
 
 func (x *SpectralFlux) GetValue() float64 {
@@ -2140,7 +2744,7 @@ type SpectralComplexity struct {
 
 func (x *SpectralComplexity) Reset() {
 	*x = SpectralComplexity{}
-	mi := &file_tracks_proto_msgTypes[29]
+	mi := &file_tracks_proto_msgTypes[36]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2152,7 +2756,7 @@ func (x *SpectralComplexity) String() string {
 func (*SpectralComplexity) ProtoMessage() {}
 
 func (x *SpectralComplexity) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[29]
+	mi := &file_tracks_proto_msgTypes[36]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2165,7 +2769,7 @@ func (x *SpectralComplexity) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SpectralComplexity.ProtoReflect.Descriptor instead.
 func (*SpectralComplexity) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{29}
+	return file_tracks_proto_rawDescGZIP(), []int{36}
 }
 
 func (x *SpectralComplexity) GetValue() float64 {
@@ -2184,7 +2788,7 @@ type SpectralContrast struct {
 
 func (x *SpectralContrast) Reset() {
 	*x = SpectralContrast{}
-	mi := &file_tracks_proto_msgTypes[30]
+	mi := &file_tracks_proto_msgTypes[37]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2196,7 +2800,7 @@ func (x *SpectralContrast) String() string {
 func (*SpectralContrast) ProtoMessage() {}
 
 func (x *SpectralContrast) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[30]
+	mi := &file_tracks_proto_msgTypes[37]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2209,7 +2813,7 @@ func (x *SpectralContrast) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SpectralContrast.ProtoReflect.Descriptor instead.
 func (*SpectralContrast) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{30}
+	return file_tracks_proto_rawDescGZIP(), []int{37}
 }
 
 func (x *SpectralContrast) GetValues() []float32 {
@@ -2228,7 +2832,7 @@ type SpectralRolloff struct {
 
 func (x *SpectralRolloff) Reset() {
 	*x = SpectralRolloff{}
-	mi := &file_tracks_proto_msgTypes[31]
+	mi := &file_tracks_proto_msgTypes[38]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2240,7 +2844,7 @@ func (x *SpectralRolloff) String() string {
 func (*SpectralRolloff) ProtoMessage() {}
 
 func (x *SpectralRolloff) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[31]
+	mi := &file_tracks_proto_msgTypes[38]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2253,7 +2857,7 @@ func (x *SpectralRolloff) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SpectralRolloff.ProtoReflect.Descriptor instead.
 func (*SpectralRolloff) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{31}
+	return file_tracks_proto_rawDescGZIP(), []int{38}
 }
 
 func (x *SpectralRolloff) GetValue() float64 {
@@ -2272,7 +2876,7 @@ type Mfcc struct {
 
 func (x *Mfcc) Reset() {
 	*x = Mfcc{}
-	mi := &file_tracks_proto_msgTypes[32]
+	mi := &file_tracks_proto_msgTypes[39]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2284,7 +2888,7 @@ func (x *Mfcc) String() string {
 func (*Mfcc) ProtoMessage() {}
 
 func (x *Mfcc) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[32]
+	mi := &file_tracks_proto_msgTypes[39]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2297,7 +2901,7 @@ func (x *Mfcc) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Mfcc.ProtoReflect.Descriptor instead.
 func (*Mfcc) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{32}
+	return file_tracks_proto_rawDescGZIP(), []int{39}
 }
 
 func (x *Mfcc) GetValues() []float32 {
@@ -2316,7 +2920,7 @@ type TimbreChange struct {
 
 func (x *TimbreChange) Reset() {
 	*x = TimbreChange{}
-	mi := &file_tracks_proto_msgTypes[33]
+	mi := &file_tracks_proto_msgTypes[40]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2328,7 +2932,7 @@ func (x *TimbreChange) String() string {
 func (*TimbreChange) ProtoMessage() {}
 
 func (x *TimbreChange) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[33]
+	mi := &file_tracks_proto_msgTypes[40]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2341,7 +2945,7 @@ func (x *TimbreChange) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TimbreChange.ProtoReflect.Descriptor instead.
 func (*TimbreChange) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{33}
+	return file_tracks_proto_rawDescGZIP(), []int{40}
 }
 
 func (x *TimbreChange) GetDistance() float64 {
@@ -2351,6 +2955,110 @@ func (x *TimbreChange) GetDistance() float64 {
 	return 0
 }
 
+type BrightnessRising struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Slope         float64                `protobuf:"fixed64,1,opt,name=slope,proto3" json:"slope,omitempty"`       // spectral centroid change, Hz per second
+	Centroid      float64                `protobuf:"fixed64,2,opt,name=centroid,proto3" json:"centroid,omitempty"` // mean centroid over the trend window, Hz
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BrightnessRising) Reset() {
+	*x = BrightnessRising{}
+	mi := &file_tracks_proto_msgTypes[41]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BrightnessRising) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BrightnessRising) ProtoMessage() {}
+
+func (x *BrightnessRising) ProtoReflect() protoreflect.Message {
+	mi := &file_tracks_proto_msgTypes[41]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BrightnessRising.ProtoReflect.Descriptor instead.
+func (*BrightnessRising) Descriptor() ([]byte, []int) {
+	return file_tracks_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *BrightnessRising) GetSlope() float64 {
+	if x != nil {
+		return x.Slope
+	}
+	return 0
+}
+
+func (x *BrightnessRising) GetCentroid() float64 {
+	if x != nil {
+		return x.Centroid
+	}
+	return 0
+}
+
+type BrightnessFalling struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Slope         float64                `protobuf:"fixed64,1,opt,name=slope,proto3" json:"slope,omitempty"`       // spectral centroid change, Hz per second (negative)
+	Centroid      float64                `protobuf:"fixed64,2,opt,name=centroid,proto3" json:"centroid,omitempty"` // mean centroid over the trend window, Hz
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BrightnessFalling) Reset() {
+	*x = BrightnessFalling{}
+	mi := &file_tracks_proto_msgTypes[42]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BrightnessFalling) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BrightnessFalling) ProtoMessage() {}
+
+func (x *BrightnessFalling) ProtoReflect() protoreflect.Message {
+	mi := &file_tracks_proto_msgTypes[42]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BrightnessFalling.ProtoReflect.Descriptor instead.
+func (*BrightnessFalling) Descriptor() ([]byte, []int) {
+	return file_tracks_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *BrightnessFalling) GetSlope() float64 {
+	if x != nil {
+		return x.Slope
+	}
+	return 0
+}
+
+func (x *BrightnessFalling) GetCentroid() float64 {
+	if x != nil {
+		return x.Centroid
+	}
+	return 0
+}
+
 type BandsMel struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Values        []float32              `protobuf:"fixed32,1,rep,packed,name=values,proto3" json:"values,omitempty"`
@@ -2360,7 +3068,7 @@ type BandsMel struct {
 
 func (x *BandsMel) Reset() {
 	*x = BandsMel{}
-	mi := &file_tracks_proto_msgTypes[34]
+	mi := &file_tracks_proto_msgTypes[43]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2372,7 +3080,7 @@ func (x *BandsMel) String() string {
 func (*BandsMel) ProtoMessage() {}
 
 func (x *BandsMel) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[34]
+	mi := &file_tracks_proto_msgTypes[43]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2385,7 +3093,7 @@ func (x *BandsMel) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BandsMel.ProtoReflect.Descriptor instead.
 func (*BandsMel) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{34}
+	return file_tracks_proto_rawDescGZIP(), []int{43}
 }
 
 func (x *BandsMel) GetValues() []float32 {
@@ -2404,7 +3112,7 @@ type BandsBark struct {
 
 func (x *BandsBark) Reset() {
 	*x = BandsBark{}
-	mi := &file_tracks_proto_msgTypes[35]
+	mi := &file_tracks_proto_msgTypes[44]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2416,7 +3124,7 @@ func (x *BandsBark) String() string {
 func (*BandsBark) ProtoMessage() {}
 
 func (x *BandsBark) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[35]
+	mi := &file_tracks_proto_msgTypes[44]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2429,7 +3137,7 @@ func (x *BandsBark) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BandsBark.ProtoReflect.Descriptor instead.
 func (*BandsBark) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{35}
+	return file_tracks_proto_rawDescGZIP(), []int{44}
 }
 
 func (x *BandsBark) GetValues() []float32 {
@@ -2448,7 +3156,7 @@ type BandsErb struct {
 
 func (x *BandsErb) Reset() {
 	*x = BandsErb{}
-	mi := &file_tracks_proto_msgTypes[36]
+	mi := &file_tracks_proto_msgTypes[45]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2460,7 +3168,7 @@ func (x *BandsErb) String() string {
 func (*BandsErb) ProtoMessage() {}
 
 func (x *BandsErb) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[36]
+	mi := &file_tracks_proto_msgTypes[45]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2473,7 +3181,7 @@ func (x *BandsErb) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BandsErb.ProtoReflect.Descriptor instead.
 func (*BandsErb) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{36}
+	return file_tracks_proto_rawDescGZIP(), []int{45}
 }
 
 func (x *BandsErb) GetValues() []float32 {
@@ -2492,7 +3200,7 @@ type Hfc struct {
 
 func (x *Hfc) Reset() {
 	*x = Hfc{}
-	mi := &file_tracks_proto_msgTypes[37]
+	mi := &file_tracks_proto_msgTypes[46]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2504,7 +3212,7 @@ func (x *Hfc) String() string {
 func (*Hfc) ProtoMessage() {}
 
 func (x *Hfc) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[37]
+	mi := &file_tracks_proto_msgTypes[46]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2517,7 +3225,7 @@ func (x *Hfc) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Hfc.ProtoReflect.Descriptor instead.
 func (*Hfc) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{37}
+	return file_tracks_proto_rawDescGZIP(), []int{46}
 }
 
 func (x *Hfc) GetValue() float64 {
@@ -2535,7 +3243,7 @@ type SegmentBoundary struct {
 
 func (x *SegmentBoundary) Reset() {
 	*x = SegmentBoundary{}
-	mi := &file_tracks_proto_msgTypes[38]
+	mi := &file_tracks_proto_msgTypes[47]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2547,7 +3255,7 @@ func (x *SegmentBoundary) String() string {
 func (*SegmentBoundary) ProtoMessage() {}
 
 func (x *SegmentBoundary) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[38]
+	mi := &file_tracks_proto_msgTypes[47]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2560,7 +3268,7 @@ func (x *SegmentBoundary) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SegmentBoundary.ProtoReflect.Descriptor instead.
 func (*SegmentBoundary) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{38}
+	return file_tracks_proto_rawDescGZIP(), []int{47}
 }
 
 type FadeIn struct {
@@ -2572,7 +3280,7 @@ type FadeIn struct {
 
 func (x *FadeIn) Reset() {
 	*x = FadeIn{}
-	mi := &file_tracks_proto_msgTypes[39]
+	mi := &file_tracks_proto_msgTypes[48]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2584,7 +3292,7 @@ func (x *FadeIn) String() string {
 func (*FadeIn) ProtoMessage() {}
 
 func (x *FadeIn) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[39]
+	mi := &file_tracks_proto_msgTypes[48]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2597,7 +3305,7 @@ func (x *FadeIn) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FadeIn.ProtoReflect.Descriptor instead.
 func (*FadeIn) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{39}
+	return file_tracks_proto_rawDescGZIP(), []int{48}
 }
 
 func (x *FadeIn) GetEndTime() float64 {
@@ -2616,7 +3324,7 @@ type FadeOut struct {
 
 func (x *FadeOut) Reset() {
 	*x = FadeOut{}
-	mi := &file_tracks_proto_msgTypes[40]
+	mi := &file_tracks_proto_msgTypes[49]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2628,7 +3336,7 @@ func (x *FadeOut) String() string {
 func (*FadeOut) ProtoMessage() {}
 
 func (x *FadeOut) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[40]
+	mi := &file_tracks_proto_msgTypes[49]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2641,7 +3349,7 @@ func (x *FadeOut) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FadeOut.ProtoReflect.Descriptor instead.
 func (*FadeOut) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{40}
+	return file_tracks_proto_rawDescGZIP(), []int{49}
 }
 
 func (x *FadeOut) GetStartTime() float64 {
@@ -2651,6 +3359,118 @@ func (x *FadeOut) GetStartTime() float64 {
 	return 0
 }
 
+type SectionChange struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Confidence    float64                `protobuf:"fixed64,1,opt,name=confidence,proto3" json:"confidence,omitempty"` // 0.0 to 1.0
+	Novelty       float64                `protobuf:"fixed64,2,opt,name=novelty,proto3" json:"novelty,omitempty"`       // smoothed novelty at the peak
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SectionChange) Reset() {
+	*x = SectionChange{}
+	mi := &file_tracks_proto_msgTypes[50]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SectionChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SectionChange) ProtoMessage() {}
+
+func (x *SectionChange) ProtoReflect() protoreflect.Message {
+	mi := &file_tracks_proto_msgTypes[50]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SectionChange.ProtoReflect.Descriptor instead.
+func (*SectionChange) Descriptor() ([]byte, []int) {
+	return file_tracks_proto_rawDescGZIP(), []int{50}
+}
+
+func (x *SectionChange) GetConfidence() float64 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+func (x *SectionChange) GetNovelty() float64 {
+	if x != nil {
+		return x.Novelty
+	}
+	return 0
+}
+
+type Drop struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Confidence    float64                `protobuf:"fixed64,1,opt,name=confidence,proto3" json:"confidence,omitempty"` // 0.0 to 1.0
+	Gain          float64                `protobuf:"fixed64,2,opt,name=gain,proto3" json:"gain,omitempty"`             // level after the drop relative to before it
+	Buildup       float64                `protobuf:"fixed64,3,opt,name=buildup,proto3" json:"buildup,omitempty"`       // seconds of lower level leading up to the drop
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Drop) Reset() {
+	*x = Drop{}
+	mi := &file_tracks_proto_msgTypes[51]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Drop) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Drop) ProtoMessage() {}
+
+func (x *Drop) ProtoReflect() protoreflect.Message {
+	mi := &file_tracks_proto_msgTypes[51]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Drop.ProtoReflect.Descriptor instead.
+func (*Drop) Descriptor() ([]byte, []int) {
+	return file_tracks_proto_rawDescGZIP(), []int{51}
+}
+
+func (x *Drop) GetConfidence() float64 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+func (x *Drop) GetGain() float64 {
+	if x != nil {
+		return x.Gain
+	}
+	return 0
+}
+
+func (x *Drop) GetBuildup() float64 {
+	if x != nil {
+		return x.Buildup
+	}
+	return 0
+}
+
 type Click struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
@@ -2659,7 +3479,7 @@ type Click struct {
 
 func (x *Click) Reset() {
 	*x = Click{}
-	mi := &file_tracks_proto_msgTypes[41]
+	mi := &file_tracks_proto_msgTypes[52]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2671,7 +3491,7 @@ func (x *Click) String() string {
 func (*Click) ProtoMessage() {}
 
 func (x *Click) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[41]
+	mi := &file_tracks_proto_msgTypes[52]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2684,7 +3504,7 @@ func (x *Click) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Click.ProtoReflect.Descriptor instead.
 func (*Click) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{41}
+	return file_tracks_proto_rawDescGZIP(), []int{52}
 }
 
 type Discontinuity struct {
@@ -2695,7 +3515,7 @@ type Discontinuity struct {
 
 func (x *Discontinuity) Reset() {
 	*x = Discontinuity{}
-	mi := &file_tracks_proto_msgTypes[42]
+	mi := &file_tracks_proto_msgTypes[53]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2707,7 +3527,7 @@ func (x *Discontinuity) String() string {
 func (*Discontinuity) ProtoMessage() {}
 
 func (x *Discontinuity) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[42]
+	mi := &file_tracks_proto_msgTypes[53]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2720,7 +3540,7 @@ func (x *Discontinuity) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Discontinuity.ProtoReflect.Descriptor instead.
 func (*Discontinuity) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{42}
+	return file_tracks_proto_rawDescGZIP(), []int{53}
 }
 
 type NoiseBurst struct {
@@ -2731,7 +3551,7 @@ type NoiseBurst struct {
 
 func (x *NoiseBurst) Reset() {
 	*x = NoiseBurst{}
-	mi := &file_tracks_proto_msgTypes[43]
+	mi := &file_tracks_proto_msgTypes[54]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2743,7 +3563,7 @@ func (x *NoiseBurst) String() string {
 func (*NoiseBurst) ProtoMessage() {}
 
 func (x *NoiseBurst) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[43]
+	mi := &file_tracks_proto_msgTypes[54]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2756,7 +3576,7 @@ func (x *NoiseBurst) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use NoiseBurst.ProtoReflect.Descriptor instead.
 func (*NoiseBurst) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{43}
+	return file_tracks_proto_rawDescGZIP(), []int{54}
 }
 
 type Saturation struct {
@@ -2768,7 +3588,7 @@ type Saturation struct {
 
 func (x *Saturation) Reset() {
 	*x = Saturation{}
-	mi := &file_tracks_proto_msgTypes[44]
+	mi := &file_tracks_proto_msgTypes[55]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2780,7 +3600,7 @@ func (x *Saturation) String() string {
 func (*Saturation) ProtoMessage() {}
 
 func (x *Saturation) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[44]
+	mi := &file_tracks_proto_msgTypes[55]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2793,7 +3613,7 @@ func (x *Saturation) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Saturation.ProtoReflect.Descriptor instead.
 func (*Saturation) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{44}
+	return file_tracks_proto_rawDescGZIP(), []int{55}
 }
 
 func (x *Saturation) GetDuration() float64 {
@@ -2812,7 +3632,7 @@ type Hum struct {
 
 func (x *Hum) Reset() {
 	*x = Hum{}
-	mi := &file_tracks_proto_msgTypes[45]
+	mi := &file_tracks_proto_msgTypes[56]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2824,7 +3644,7 @@ func (x *Hum) String() string {
 func (*Hum) ProtoMessage() {}
 
 func (x *Hum) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[45]
+	mi := &file_tracks_proto_msgTypes[56]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2837,7 +3657,7 @@ func (x *Hum) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Hum.ProtoReflect.Descriptor instead.
 func (*Hum) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{45}
+	return file_tracks_proto_rawDescGZIP(), []int{56}
 }
 
 func (x *Hum) GetFrequency() float64 {
@@ -2856,7 +3676,7 @@ type EnvelopeEvent struct {
 
 func (x *EnvelopeEvent) Reset() {
 	*x = EnvelopeEvent{}
-	mi := &file_tracks_proto_msgTypes[46]
+	mi := &file_tracks_proto_msgTypes[57]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2868,7 +3688,7 @@ func (x *EnvelopeEvent) String() string {
 func (*EnvelopeEvent) ProtoMessage() {}
 
 func (x *EnvelopeEvent) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[46]
+	mi := &file_tracks_proto_msgTypes[57]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2881,7 +3701,7 @@ func (x *EnvelopeEvent) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use EnvelopeEvent.ProtoReflect.Descriptor instead.
 func (*EnvelopeEvent) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{46}
+	return file_tracks_proto_rawDescGZIP(), []int{57}
 }
 
 func (x *EnvelopeEvent) GetValue() float64 {
@@ -2900,7 +3720,7 @@ type Attack struct {
 
 func (x *Attack) Reset() {
 	*x = Attack{}
-	mi := &file_tracks_proto_msgTypes[47]
+	mi := &file_tracks_proto_msgTypes[58]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2912,7 +3732,7 @@ func (x *Attack) String() string {
 func (*Attack) ProtoMessage() {}
 
 func (x *Attack) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[47]
+	mi := &file_tracks_proto_msgTypes[58]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2925,7 +3745,7 @@ func (x *Attack) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Attack.ProtoReflect.Descriptor instead.
 func (*Attack) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{47}
+	return file_tracks_proto_rawDescGZIP(), []int{58}
 }
 
 func (x *Attack) GetLogAttackTime() float64 {
@@ -2944,7 +3764,7 @@ type Decay struct {
 
 func (x *Decay) Reset() {
 	*x = Decay{}
-	mi := &file_tracks_proto_msgTypes[48]
+	mi := &file_tracks_proto_msgTypes[59]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2956,7 +3776,7 @@ func (x *Decay) String() string {
 func (*Decay) ProtoMessage() {}
 
 func (x *Decay) ProtoReflect() protoreflect.Message {
-	mi := &file_tracks_proto_msgTypes[48]
+	mi := &file_tracks_proto_msgTypes[59]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2969,7 +3789,7 @@ func (x *Decay) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Decay.ProtoReflect.Descriptor instead.
 func (*Decay) Descriptor() ([]byte, []int) {
-	return file_tracks_proto_rawDescGZIP(), []int{48}
+	return file_tracks_proto_rawDescGZIP(), []int{59}
 }
 
 func (x *Decay) GetValue() float64 {
@@ -2983,19 +3803,27 @@ var File_tracks_proto protoreflect.FileDescriptor
 
 const file_tracks_proto_rawDesc = "" +
 	"\n" +
-	"\ftracks.proto\x12\x06tracks\"\xf4\x13\n" +
+	"\ftracks.proto\x12\x06tracks\"\xb4\x19\n" +
 	"\bEnvelope\x12\x1c\n" +
-	"\ttimestamp\x18\x01 \x01(\x01R\ttimestamp\x125\n" +
+	"\ttimestamp\x18\x01 \x01(\x01R\ttimestamp\x12\x1b\n" +
+	"\tstream_id\x18\x02 \x01(\tR\bstreamId\x12 \n" +
+	"\fsend_time_ns\x18\x03 \x01(\x03R\n" +
+	"sendTimeNs\x125\n" +
 	"\vtrack_start\x18\n" +
 	" \x01(\v2\x12.tracks.TrackStartH\x00R\n" +
 	"trackStart\x12/\n" +
 	"\ttrack_end\x18\v \x01(\v2\x10.tracks.TrackEndH\x00R\btrackEnd\x12>\n" +
 	"\x0etrack_position\x18\f \x01(\v2\x15.tracks.TrackPositionH\x00R\rtrackPosition\x125\n" +
 	"\vtrack_abort\x18\r \x01(\v2\x12.tracks.TrackAbortH\x00R\n" +
-	"trackAbort\x12\"\n" +
+	"trackAbort\x12;\n" +
+	"\rtrack_prepare\x18\x0e \x01(\v2\x14.tracks.TrackPrepareH\x00R\ftrackPrepare\x12>\n" +
+	"\x0etimeline_reset\x18\x0f \x01(\v2\x15.tracks.TimelineResetH\x00R\rtimelineReset\x12(\n" +
+	"\x06schema\x18\x10 \x01(\v2\x0e.tracks.SchemaH\x00R\x06schema\x12\"\n" +
 	"\x04beat\x18\x14 \x01(\v2\f.tracks.BeatH\x00R\x04beat\x128\n" +
 	"\ftempo_change\x18\x15 \x01(\v2\x13.tracks.TempoChangeH\x00R\vtempoChange\x12.\n" +
-	"\bdownbeat\x18\x16 \x01(\v2\x10.tracks.DownbeatH\x00R\bdownbeat\x12%\n" +
+	"\bdownbeat\x18\x16 \x01(\v2\x10.tracks.DownbeatH\x00R\bdownbeat\x12(\n" +
+	"\x06groove\x18\x17 \x01(\v2\x0e.tracks.GrooveH\x00R\x06groove\x128\n" +
+	"\fmeter_change\x18\x18 \x01(\v2\x13.tracks.MeterChangeH\x00R\vmeterChange\x12%\n" +
 	"\x05onset\x18\x1e \x01(\v2\r.tracks.OnsetH\x00R\x05onset\x122\n" +
 	"\n" +
 	"onset_rate\x18\x1f \x01(\v2\x11.tracks.OnsetRateH\x00R\tonsetRate\x12+\n" +
@@ -3008,14 +3836,16 @@ const file_tracks_proto_rawDesc = "" +
 	"\n" +
 	"dissonance\x18, \x01(\v2\x12.tracks.DissonanceH\x00R\n" +
 	"dissonance\x12=\n" +
-	"\rinharmonicity\x18- \x01(\v2\x15.tracks.InharmonicityH\x00R\rinharmonicity\x12%\n" +
+	"\rinharmonicity\x18- \x01(\v2\x15.tracks.InharmonicityH\x00R\rinharmonicity\x12;\n" +
+	"\rroman_numeral\x18. \x01(\v2\x14.tracks.RomanNumeralH\x00R\fromanNumeral\x12%\n" +
 	"\x05pitch\x182 \x01(\v2\r.tracks.PitchH\x00R\x05pitch\x128\n" +
 	"\fpitch_change\x183 \x01(\v2\x13.tracks.PitchChangeH\x00R\vpitchChange\x12(\n" +
 	"\x06melody\x184 \x01(\v2\x0e.tracks.MelodyH\x00R\x06melody\x12.\n" +
 	"\bloudness\x18< \x01(\v2\x10.tracks.LoudnessH\x00R\bloudness\x12;\n" +
 	"\rloudness_peak\x18= \x01(\v2\x14.tracks.LoudnessPeakH\x00R\floudnessPeak\x12(\n" +
 	"\x06energy\x18> \x01(\v2\x0e.tracks.EnergyH\x00R\x06energy\x12>\n" +
-	"\x0edynamic_change\x18? \x01(\v2\x15.tracks.DynamicChangeH\x00R\rdynamicChange\x12;\n" +
+	"\x0edynamic_change\x18? \x01(\v2\x15.tracks.DynamicChangeH\x00R\rdynamicChange\x12>\n" +
+	"\x0eloudness_trend\x18@ \x01(\v2\x15.tracks.LoudnessTrendH\x00R\rloudnessTrend\x12;\n" +
 	"\rsilence_start\x18F \x01(\v2\x14.tracks.SilenceStartH\x00R\fsilenceStart\x125\n" +
 	"\vsilence_end\x18G \x01(\v2\x12.tracks.SilenceEndH\x00R\n" +
 	"silenceEnd\x12\x1f\n" +
@@ -3026,7 +3856,9 @@ const file_tracks_proto_rawDesc = "" +
 	"\x11spectral_contrast\x18S \x01(\v2\x18.tracks.SpectralContrastH\x00R\x10spectralContrast\x12D\n" +
 	"\x10spectral_rolloff\x18T \x01(\v2\x17.tracks.SpectralRolloffH\x00R\x0fspectralRolloff\x12\"\n" +
 	"\x04mfcc\x18U \x01(\v2\f.tracks.MfccH\x00R\x04mfcc\x12;\n" +
-	"\rtimbre_change\x18V \x01(\v2\x14.tracks.TimbreChangeH\x00R\ftimbreChange\x12/\n" +
+	"\rtimbre_change\x18V \x01(\v2\x14.tracks.TimbreChangeH\x00R\ftimbreChange\x12G\n" +
+	"\x11brightness_rising\x18W \x01(\v2\x18.tracks.BrightnessRisingH\x00R\x10brightnessRising\x12J\n" +
+	"\x12brightness_falling\x18X \x01(\v2\x19.tracks.BrightnessFallingH\x00R\x11brightnessFalling\x12/\n" +
 	"\tbands_mel\x18Z \x01(\v2\x10.tracks.BandsMelH\x00R\bbandsMel\x122\n" +
 	"\n" +
 	"bands_bark\x18[ \x01(\v2\x11.tracks.BandsBarkH\x00R\tbandsBark\x12/\n" +
@@ -3034,7 +3866,9 @@ const file_tracks_proto_rawDesc = "" +
 	"\x03hfc\x18] \x01(\v2\v.tracks.HfcH\x00R\x03hfc\x12D\n" +
 	"\x10segment_boundary\x18d \x01(\v2\x17.tracks.SegmentBoundaryH\x00R\x0fsegmentBoundary\x12)\n" +
 	"\afade_in\x18e \x01(\v2\x0e.tracks.FadeInH\x00R\x06fadeIn\x12,\n" +
-	"\bfade_out\x18f \x01(\v2\x0f.tracks.FadeOutH\x00R\afadeOut\x12%\n" +
+	"\bfade_out\x18f \x01(\v2\x0f.tracks.FadeOutH\x00R\afadeOut\x12>\n" +
+	"\x0esection_change\x18g \x01(\v2\x15.tracks.SectionChangeH\x00R\rsectionChange\x12\"\n" +
+	"\x04drop\x18h \x01(\v2\f.tracks.DropH\x00R\x04drop\x12%\n" +
 	"\x05click\x18n \x01(\v2\r.tracks.ClickH\x00R\x05click\x12=\n" +
 	"\rdiscontinuity\x18o \x01(\v2\x15.tracks.DiscontinuityH\x00R\rdiscontinuity\x125\n" +
 	"\vnoise_burst\x18p \x01(\v2\x12.tracks.NoiseBurstH\x00R\n" +
@@ -3060,7 +3894,14 @@ const file_tracks_proto_rawDesc = "" +
 	"\bposition\x18\x01 \x01(\x01R\bposition\"$\n" +
 	"\n" +
 	"TrackAbort\x12\x16\n" +
-	"\x06reason\x18\x01 \x01(\tR\x06reason\"&\n" +
+	"\x06reason\x18\x01 \x01(\tR\x06reason\"H\n" +
+	"\fTrackPrepare\x12\x1c\n" +
+	"\tcountdown\x18\x01 \x01(\x01R\tcountdown\x12\x1a\n" +
+	"\bfilename\x18\x02 \x01(\tR\bfilename\"+\n" +
+	"\rTimelineReset\x12\x1a\n" +
+	"\bprevious\x18\x01 \x01(\x01R\bprevious\"/\n" +
+	"\x06Schema\x12%\n" +
+	"\x0edescriptor_set\x18\x01 \x01(\fR\rdescriptorSet\"&\n" +
 	"\x04Beat\x12\x1e\n" +
 	"\n" +
 	"confidence\x18\x01 \x01(\x01R\n" +
@@ -3070,6 +3911,20 @@ const file_tracks_proto_rawDesc = "" +
 	"\bDownbeat\x12\x1e\n" +
 	"\n" +
 	"confidence\x18\x01 \x01(\x01R\n" +
+	"confidence\"|\n" +
+	"\x06Groove\x12\x14\n" +
+	"\x05swing\x18\x01 \x01(\x01R\x05swing\x12\x14\n" +
+	"\x05ratio\x18\x02 \x01(\x01R\x05ratio\x12\x16\n" +
+	"\x06offset\x18\x03 \x01(\x01R\x06offset\x12\x16\n" +
+	"\x06jitter\x18\x04 \x01(\x01R\x06jitter\x12\x16\n" +
+	"\x06onsets\x18\x05 \x01(\x05R\x06onsets\"\x99\x01\n" +
+	"\vMeterChange\x12\x14\n" +
+	"\x05meter\x18\x01 \x01(\tR\x05meter\x12\x1c\n" +
+	"\tnumerator\x18\x02 \x01(\x05R\tnumerator\x12 \n" +
+	"\vdenominator\x18\x03 \x01(\x05R\vdenominator\x12\x14\n" +
+	"\x05beats\x18\x04 \x01(\x05R\x05beats\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x05 \x01(\x01R\n" +
 	"confidence\"#\n" +
 	"\x05Onset\x12\x1a\n" +
 	"\bstrength\x18\x01 \x01(\x01R\bstrength\"\x1f\n" +
@@ -3092,7 +3947,11 @@ const file_tracks_proto_rawDesc = "" +
 	"Dissonance\x12\x14\n" +
 	"\x05value\x18\x01 \x01(\x01R\x05value\"%\n" +
 	"\rInharmonicity\x12\x14\n" +
-	"\x05value\x18\x01 \x01(\x01R\x05value\"E\n" +
+	"\x05value\x18\x01 \x01(\x01R\x05value\"P\n" +
+	"\fRomanNumeral\x12\x18\n" +
+	"\anumeral\x18\x01 \x01(\tR\anumeral\x12\x14\n" +
+	"\x05chord\x18\x02 \x01(\tR\x05chord\x12\x10\n" +
+	"\x03key\x18\x03 \x01(\tR\x03key\"E\n" +
 	"\x05Pitch\x12\x1c\n" +
 	"\tfrequency\x18\x01 \x01(\x01R\tfrequency\x12\x1e\n" +
 	"\n" +
@@ -3110,7 +3969,11 @@ const file_tracks_proto_rawDesc = "" +
 	"\x06Energy\x12\x14\n" +
 	"\x05value\x18\x01 \x01(\x01R\x05value\"-\n" +
 	"\rDynamicChange\x12\x1c\n" +
-	"\tmagnitude\x18\x01 \x01(\x01R\tmagnitude\"\x0e\n" +
+	"\tmagnitude\x18\x01 \x01(\x01R\tmagnitude\"_\n" +
+	"\rLoudnessTrend\x12\x1c\n" +
+	"\tdirection\x18\x01 \x01(\tR\tdirection\x12\x14\n" +
+	"\x05slope\x18\x02 \x01(\x01R\x05slope\x12\x1a\n" +
+	"\bloudness\x18\x03 \x01(\x01R\bloudness\"\x0e\n" +
 	"\fSilenceStart\"\f\n" +
 	"\n" +
 	"SilenceEnd\"!\n" +
@@ -3129,7 +3992,13 @@ const file_tracks_proto_rawDesc = "" +
 	"\x04Mfcc\x12\x16\n" +
 	"\x06values\x18\x01 \x03(\x02R\x06values\"*\n" +
 	"\fTimbreChange\x12\x1a\n" +
-	"\bdistance\x18\x01 \x01(\x01R\bdistance\"\"\n" +
+	"\bdistance\x18\x01 \x01(\x01R\bdistance\"D\n" +
+	"\x10BrightnessRising\x12\x14\n" +
+	"\x05slope\x18\x01 \x01(\x01R\x05slope\x12\x1a\n" +
+	"\bcentroid\x18\x02 \x01(\x01R\bcentroid\"E\n" +
+	"\x11BrightnessFalling\x12\x14\n" +
+	"\x05slope\x18\x01 \x01(\x01R\x05slope\x12\x1a\n" +
+	"\bcentroid\x18\x02 \x01(\x01R\bcentroid\"\"\n" +
 	"\bBandsMel\x12\x16\n" +
 	"\x06values\x18\x01 \x03(\x02R\x06values\"#\n" +
 	"\tBandsBark\x12\x16\n" +
@@ -3143,7 +4012,18 @@ const file_tracks_proto_rawDesc = "" +
 	"\bend_time\x18\x01 \x01(\x01R\aendTime\"(\n" +
 	"\aFadeOut\x12\x1d\n" +
 	"\n" +
-	"start_time\x18\x01 \x01(\x01R\tstartTime\"\a\n" +
+	"start_time\x18\x01 \x01(\x01R\tstartTime\"I\n" +
+	"\rSectionChange\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x01 \x01(\x01R\n" +
+	"confidence\x12\x18\n" +
+	"\anovelty\x18\x02 \x01(\x01R\anovelty\"T\n" +
+	"\x04Drop\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x01 \x01(\x01R\n" +
+	"confidence\x12\x12\n" +
+	"\x04gain\x18\x02 \x01(\x01R\x04gain\x12\x18\n" +
+	"\abuildup\x18\x03 \x01(\x01R\abuildup\"\a\n" +
 	"\x05Click\"\x0f\n" +
 	"\rDiscontinuity\"\f\n" +
 	"\n" +
@@ -3172,112 +4052,134 @@ func file_tracks_proto_rawDescGZIP() []byte {
 	return file_tracks_proto_rawDescData
 }
 
-var file_tracks_proto_msgTypes = make([]protoimpl.MessageInfo, 49)
+var file_tracks_proto_msgTypes = make([]protoimpl.MessageInfo, 60)
 var file_tracks_proto_goTypes = []any{
 	(*Envelope)(nil),           // 0: tracks.Envelope
 	(*TrackStart)(nil),         // 1: tracks.TrackStart
 	(*TrackEnd)(nil),           // 2: tracks.TrackEnd
 	(*TrackPosition)(nil),      // 3: tracks.TrackPosition
 	(*TrackAbort)(nil),         // 4: tracks.TrackAbort
-	(*Beat)(nil),               // 5: tracks.Beat
-	(*TempoChange)(nil),        // 6: tracks.TempoChange
-	(*Downbeat)(nil),           // 7: tracks.Downbeat
-	(*Onset)(nil),              // 8: tracks.Onset
-	(*OnsetRate)(nil),          // 9: tracks.OnsetRate
-	(*Novelty)(nil),            // 10: tracks.Novelty
-	(*KeyChange)(nil),          // 11: tracks.KeyChange
-	(*ChordChange)(nil),        // 12: tracks.ChordChange
-	(*Chroma)(nil),             // 13: tracks.Chroma
-	(*Tuning)(nil),             // 14: tracks.Tuning
-	(*Dissonance)(nil),         // 15: tracks.Dissonance
-	(*Inharmonicity)(nil),      // 16: tracks.Inharmonicity
-	(*Pitch)(nil),              // 17: tracks.Pitch
-	(*PitchChange)(nil),        // 18: tracks.PitchChange
-	(*Melody)(nil),             // 19: tracks.Melody
-	(*Loudness)(nil),           // 20: tracks.Loudness
-	(*LoudnessPeak)(nil),       // 21: tracks.LoudnessPeak
-	(*Energy)(nil),             // 22: tracks.Energy
-	(*DynamicChange)(nil),      // 23: tracks.DynamicChange
-	(*SilenceStart)(nil),       // 24: tracks.SilenceStart
-	(*SilenceEnd)(nil),         // 25: tracks.SilenceEnd
-	(*Gap)(nil),                // 26: tracks.Gap
-	(*SpectralCentroid)(nil),   // 27: tracks.SpectralCentroid
-	(*SpectralFlux)(nil),       // 28: tracks.SpectralFlux
-	(*SpectralComplexity)(nil), // 29: tracks.SpectralComplexity
-	(*SpectralContrast)(nil),   // 30: tracks.SpectralContrast
-	(*SpectralRolloff)(nil),    // 31: tracks.SpectralRolloff
-	(*Mfcc)(nil),               // 32: tracks.Mfcc
-	(*TimbreChange)(nil),       // 33: tracks.TimbreChange
-	(*BandsMel)(nil),           // 34: tracks.BandsMel
-	(*BandsBark)(nil),          // 35: tracks.BandsBark
-	(*BandsErb)(nil),           // 36: tracks.BandsErb
-	(*Hfc)(nil),                // 37: tracks.Hfc
-	(*SegmentBoundary)(nil),    // 38: tracks.SegmentBoundary
-	(*FadeIn)(nil),             // 39: tracks.FadeIn
-	(*FadeOut)(nil),            // 40: tracks.FadeOut
-	(*Click)(nil),              // 41: tracks.Click
-	(*Discontinuity)(nil),      // 42: tracks.Discontinuity
-	(*NoiseBurst)(nil),         // 43: tracks.NoiseBurst
-	(*Saturation)(nil),         // 44: tracks.Saturation
-	(*Hum)(nil),                // 45: tracks.Hum
-	(*EnvelopeEvent)(nil),      // 46: tracks.EnvelopeEvent
-	(*Attack)(nil),             // 47: tracks.Attack
-	(*Decay)(nil),              // 48: tracks.Decay
+	(*TrackPrepare)(nil),       // 5: tracks.TrackPrepare
+	(*TimelineReset)(nil),      // 6: tracks.TimelineReset
+	(*Schema)(nil),             // 7: tracks.Schema
+	(*Beat)(nil),               // 8: tracks.Beat
+	(*TempoChange)(nil),        // 9: tracks.TempoChange
+	(*Downbeat)(nil),           // 10: tracks.Downbeat
+	(*Groove)(nil),             // 11: tracks.Groove
+	(*MeterChange)(nil),        // 12: tracks.MeterChange
+	(*Onset)(nil),              // 13: tracks.Onset
+	(*OnsetRate)(nil),          // 14: tracks.OnsetRate
+	(*Novelty)(nil),            // 15: tracks.Novelty
+	(*KeyChange)(nil),          // 16: tracks.KeyChange
+	(*ChordChange)(nil),        // 17: tracks.ChordChange
+	(*Chroma)(nil),             // 18: tracks.Chroma
+	(*Tuning)(nil),             // 19: tracks.Tuning
+	(*Dissonance)(nil),         // 20: tracks.Dissonance
+	(*Inharmonicity)(nil),      // 21: tracks.Inharmonicity
+	(*RomanNumeral)(nil),       // 22: tracks.RomanNumeral
+	(*Pitch)(nil),              // 23: tracks.Pitch
+	(*PitchChange)(nil),        // 24: tracks.PitchChange
+	(*Melody)(nil),             // 25: tracks.Melody
+	(*Loudness)(nil),           // 26: tracks.Loudness
+	(*LoudnessPeak)(nil),       // 27: tracks.LoudnessPeak
+	(*Energy)(nil),             // 28: tracks.Energy
+	(*DynamicChange)(nil),      // 29: tracks.DynamicChange
+	(*LoudnessTrend)(nil),      // 30: tracks.LoudnessTrend
+	(*SilenceStart)(nil),       // 31: tracks.SilenceStart
+	(*SilenceEnd)(nil),         // 32: tracks.SilenceEnd
+	(*Gap)(nil),                // 33: tracks.Gap
+	(*SpectralCentroid)(nil),   // 34: tracks.SpectralCentroid
+	(*SpectralFlux)(nil),       // 35: tracks.SpectralFlux
+	(*SpectralComplexity)(nil), // 36: tracks.SpectralComplexity
+	(*SpectralContrast)(nil),   // 37: tracks.SpectralContrast
+	(*SpectralRolloff)(nil),    // 38: tracks.SpectralRolloff
+	(*Mfcc)(nil),               // 39: tracks.Mfcc
+	(*TimbreChange)(nil),       // 40: tracks.TimbreChange
+	(*BrightnessRising)(nil),   // 41: tracks.BrightnessRising
+	(*BrightnessFalling)(nil),  // 42: tracks.BrightnessFalling
+	(*BandsMel)(nil),           // 43: tracks.BandsMel
+	(*BandsBark)(nil),          // 44: tracks.BandsBark
+	(*BandsErb)(nil),           // 45: tracks.BandsErb
+	(*Hfc)(nil),                // 46: tracks.Hfc
+	(*SegmentBoundary)(nil),    // 47: tracks.SegmentBoundary
+	(*FadeIn)(nil),             // 48: tracks.FadeIn
+	(*FadeOut)(nil),            // 49: tracks.FadeOut
+	(*SectionChange)(nil),      // 50: tracks.SectionChange
+	(*Drop)(nil),               // 51: tracks.Drop
+	(*Click)(nil),              // 52: tracks.Click
+	(*Discontinuity)(nil),      // 53: tracks.Discontinuity
+	(*NoiseBurst)(nil),         // 54: tracks.NoiseBurst
+	(*Saturation)(nil),         // 55: tracks.Saturation
+	(*Hum)(nil),                // 56: tracks.Hum
+	(*EnvelopeEvent)(nil),      // 57: tracks.EnvelopeEvent
+	(*Attack)(nil),             // 58: tracks.Attack
+	(*Decay)(nil),              // 59: tracks.Decay
 }
 var file_tracks_proto_depIdxs = []int32{
 	1,  // 0: tracks.Envelope.track_start:type_name -> tracks.TrackStart
 	2,  // 1: tracks.Envelope.track_end:type_name -> tracks.TrackEnd
 	3,  // 2: tracks.Envelope.track_position:type_name -> tracks.TrackPosition
 	4,  // 3: tracks.Envelope.track_abort:type_name -> tracks.TrackAbort
-	5,  // 4: tracks.Envelope.beat:type_name -> tracks.Beat
-	6,  // 5: tracks.Envelope.tempo_change:type_name -> tracks.TempoChange
-	7,  // 6: tracks.Envelope.downbeat:type_name -> tracks.Downbeat
-	8,  // 7: tracks.Envelope.onset:type_name -> tracks.Onset
-	9,  // 8: tracks.Envelope.onset_rate:type_name -> tracks.OnsetRate
-	10, // 9: tracks.Envelope.novelty:type_name -> tracks.Novelty
-	11, // 10: tracks.Envelope.key_change:type_name -> tracks.KeyChange
-	12, // 11: tracks.Envelope.chord_change:type_name -> tracks.ChordChange
-	13, // 12: tracks.Envelope.chroma:type_name -> tracks.Chroma
-	14, // 13: tracks.Envelope.tuning:type_name -> tracks.Tuning
-	15, // 14: tracks.Envelope.dissonance:type_name -> tracks.Dissonance
-	16, // 15: tracks.Envelope.inharmonicity:type_name -> tracks.Inharmonicity
-	17, // 16: tracks.Envelope.pitch:type_name -> tracks.Pitch
-	18, // 17: tracks.Envelope.pitch_change:type_name -> tracks.PitchChange
-	19, // 18: tracks.Envelope.melody:type_name -> tracks.Melody
-	20, // 19: tracks.Envelope.loudness:type_name -> tracks.Loudness
-	21, // 20: tracks.Envelope.loudness_peak:type_name -> tracks.LoudnessPeak
-	22, // 21: tracks.Envelope.energy:type_name -> tracks.Energy
-	23, // 22: tracks.Envelope.dynamic_change:type_name -> tracks.DynamicChange
-	24, // 23: tracks.Envelope.silence_start:type_name -> tracks.SilenceStart
-	25, // 24: tracks.Envelope.silence_end:type_name -> tracks.SilenceEnd
-	26, // 25: tracks.Envelope.gap:type_name -> tracks.Gap
-	27, // 26: tracks.Envelope.spectral_centroid:type_name -> tracks.SpectralCentroid
-	28, // 27: tracks.Envelope.spectral_flux:type_name -> tracks.SpectralFlux
-	29, // 28: tracks.Envelope.spectral_complexity:type_name -> tracks.SpectralComplexity
-	30, // 29: tracks.Envelope.spectral_contrast:type_name -> tracks.SpectralContrast
-	31, // 30: tracks.Envelope.spectral_rolloff:type_name -> tracks.SpectralRolloff
-	32, // 31: tracks.Envelope.mfcc:type_name -> tracks.Mfcc
-	33, // 32: tracks.Envelope.timbre_change:type_name -> tracks.TimbreChange
-	34, // 33: tracks.Envelope.bands_mel:type_name -> tracks.BandsMel
-	35, // 34: tracks.Envelope.bands_bark:type_name -> tracks.BandsBark
-	36, // 35: tracks.Envelope.bands_erb:type_name -> tracks.BandsErb
-	37, // 36: tracks.Envelope.hfc:type_name -> tracks.Hfc
-	38, // 37: tracks.Envelope.segment_boundary:type_name -> tracks.SegmentBoundary
-	39, // 38: tracks.Envelope.fade_in:type_name -> tracks.FadeIn
-	40, // 39: tracks.Envelope.fade_out:type_name -> tracks.FadeOut
-	41, // 40: tracks.Envelope.click:type_name -> tracks.Click
-	42, // 41: tracks.Envelope.discontinuity:type_name -> tracks.Discontinuity
-	43, // 42: tracks.Envelope.noise_burst:type_name -> tracks.NoiseBurst
-	44, // 43: tracks.Envelope.saturation:type_name -> tracks.Saturation
-	45, // 44: tracks.Envelope.hum:type_name -> tracks.Hum
-	46, // 45: tracks.Envelope.envelope_event:type_name -> tracks.EnvelopeEvent
-	47, // 46: tracks.Envelope.attack:type_name -> tracks.Attack
-	48, // 47: tracks.Envelope.decay:type_name -> tracks.Decay
-	48, // [48:48] is the sub-list for method output_type
-	48, // [48:48] is the sub-list for method input_type
-	48, // [48:48] is the sub-list for extension type_name
-	48, // [48:48] is the sub-list for extension extendee
-	0,  // [0:48] is the sub-list for field type_name
+	5,  // 4: tracks.Envelope.track_prepare:type_name -> tracks.TrackPrepare
+	6,  // 5: tracks.Envelope.timeline_reset:type_name -> tracks.TimelineReset
+	7,  // 6: tracks.Envelope.schema:type_name -> tracks.Schema
+	8,  // 7: tracks.Envelope.beat:type_name -> tracks.Beat
+	9,  // 8: tracks.Envelope.tempo_change:type_name -> tracks.TempoChange
+	10, // 9: tracks.Envelope.downbeat:type_name -> tracks.Downbeat
+	11, // 10: tracks.Envelope.groove:type_name -> tracks.Groove
+	12, // 11: tracks.Envelope.meter_change:type_name -> tracks.MeterChange
+	13, // 12: tracks.Envelope.onset:type_name -> tracks.Onset
+	14, // 13: tracks.Envelope.onset_rate:type_name -> tracks.OnsetRate
+	15, // 14: tracks.Envelope.novelty:type_name -> tracks.Novelty
+	16, // 15: tracks.Envelope.key_change:type_name -> tracks.KeyChange
+	17, // 16: tracks.Envelope.chord_change:type_name -> tracks.ChordChange
+	18, // 17: tracks.Envelope.chroma:type_name -> tracks.Chroma
+	19, // 18: tracks.Envelope.tuning:type_name -> tracks.Tuning
+	20, // 19: tracks.Envelope.dissonance:type_name -> tracks.Dissonance
+	21, // 20: tracks.Envelope.inharmonicity:type_name -> tracks.Inharmonicity
+	22, // 21: tracks.Envelope.roman_numeral:type_name -> tracks.RomanNumeral
+	23, // 22: tracks.Envelope.pitch:type_name -> tracks.Pitch
+	24, // 23: tracks.Envelope.pitch_change:type_name -> tracks.PitchChange
+	25, // 24: tracks.Envelope.melody:type_name -> tracks.Melody
+	26, // 25: tracks.Envelope.loudness:type_name -> tracks.Loudness
+	27, // 26: tracks.Envelope.loudness_peak:type_name -> tracks.LoudnessPeak
+	28, // 27: tracks.Envelope.energy:type_name -> tracks.Energy
+	29, // 28: tracks.Envelope.dynamic_change:type_name -> tracks.DynamicChange
+	30, // 29: tracks.Envelope.loudness_trend:type_name -> tracks.LoudnessTrend
+	31, // 30: tracks.Envelope.silence_start:type_name -> tracks.SilenceStart
+	32, // 31: tracks.Envelope.silence_end:type_name -> tracks.SilenceEnd
+	33, // 32: tracks.Envelope.gap:type_name -> tracks.Gap
+	34, // 33: tracks.Envelope.spectral_centroid:type_name -> tracks.SpectralCentroid
+	35, // 34: tracks.Envelope.spectral_flux:type_name -> tracks.SpectralFlux
+	36, // 35: tracks.Envelope.spectral_complexity:type_name -> tracks.SpectralComplexity
+	37, // 36: tracks.Envelope.spectral_contrast:type_name -> tracks.SpectralContrast
+	38, // 37: tracks.Envelope.spectral_rolloff:type_name -> tracks.SpectralRolloff
+	39, // 38: tracks.Envelope.mfcc:type_name -> tracks.Mfcc
+	40, // 39: tracks.Envelope.timbre_change:type_name -> tracks.TimbreChange
+	41, // 40: tracks.Envelope.brightness_rising:type_name -> tracks.BrightnessRising
+	42, // 41: tracks.Envelope.brightness_falling:type_name -> tracks.BrightnessFalling
+	43, // 42: tracks.Envelope.bands_mel:type_name -> tracks.BandsMel
+	44, // 43: tracks.Envelope.bands_bark:type_name -> tracks.BandsBark
+	45, // 44: tracks.Envelope.bands_erb:type_name -> tracks.BandsErb
+	46, // 45: tracks.Envelope.hfc:type_name -> tracks.Hfc
+	47, // 46: tracks.Envelope.segment_boundary:type_name -> tracks.SegmentBoundary
+	48, // 47: tracks.Envelope.fade_in:type_name -> tracks.FadeIn
+	49, // 48: tracks.Envelope.fade_out:type_name -> tracks.FadeOut
+	50, // 49: tracks.Envelope.section_change:type_name -> tracks.SectionChange
+	51, // 50: tracks.Envelope.drop:type_name -> tracks.Drop
+	52, // 51: tracks.Envelope.click:type_name -> tracks.Click
+	53, // 52: tracks.Envelope.discontinuity:type_name -> tracks.Discontinuity
+	54, // 53: tracks.Envelope.noise_burst:type_name -> tracks.NoiseBurst
+	55, // 54: tracks.Envelope.saturation:type_name -> tracks.Saturation
+	56, // 55: tracks.Envelope.hum:type_name -> tracks.Hum
+	57, // 56: tracks.Envelope.envelope_event:type_name -> tracks.EnvelopeEvent
+	58, // 57: tracks.Envelope.attack:type_name -> tracks.Attack
+	59, // 58: tracks.Envelope.decay:type_name -> tracks.Decay
+	59, // [59:59] is the sub-list for method output_type
+	59, // [59:59] is the sub-list for method input_type
+	59, // [59:59] is the sub-list for extension type_name
+	59, // [59:59] is the sub-list for extension extendee
+	0,  // [0:59] is the sub-list for field type_name
 }
 
 func init() { file_tracks_proto_init() }
@@ -3290,9 +4192,14 @@ func file_tracks_proto_init() {
 		(*Envelope_TrackEnd)(nil),
 		(*Envelope_TrackPosition)(nil),
 		(*Envelope_TrackAbort)(nil),
+		(*Envelope_TrackPrepare)(nil),
+		(*Envelope_TimelineReset)(nil),
+		(*Envelope_Schema)(nil),
 		(*Envelope_Beat)(nil),
 		(*Envelope_TempoChange)(nil),
 		(*Envelope_Downbeat)(nil),
+		(*Envelope_Groove)(nil),
+		(*Envelope_MeterChange)(nil),
 		(*Envelope_Onset)(nil),
 		(*Envelope_OnsetRate)(nil),
 		(*Envelope_Novelty)(nil),
@@ -3302,6 +4209,7 @@ func file_tracks_proto_init() {
 		(*Envelope_Tuning)(nil),
 		(*Envelope_Dissonance)(nil),
 		(*Envelope_Inharmonicity)(nil),
+		(*Envelope_RomanNumeral)(nil),
 		(*Envelope_Pitch)(nil),
 		(*Envelope_PitchChange)(nil),
 		(*Envelope_Melody)(nil),
@@ -3309,6 +4217,7 @@ func file_tracks_proto_init() {
 		(*Envelope_LoudnessPeak)(nil),
 		(*Envelope_Energy)(nil),
 		(*Envelope_DynamicChange)(nil),
+		(*Envelope_LoudnessTrend)(nil),
 		(*Envelope_SilenceStart)(nil),
 		(*Envelope_SilenceEnd)(nil),
 		(*Envelope_Gap)(nil),
@@ -3319,6 +4228,8 @@ func file_tracks_proto_init() {
 		(*Envelope_SpectralRolloff)(nil),
 		(*Envelope_Mfcc)(nil),
 		(*Envelope_TimbreChange)(nil),
+		(*Envelope_BrightnessRising)(nil),
+		(*Envelope_BrightnessFalling)(nil),
 		(*Envelope_BandsMel)(nil),
 		(*Envelope_BandsBark)(nil),
 		(*Envelope_BandsErb)(nil),
@@ -3326,6 +4237,8 @@ func file_tracks_proto_init() {
 		(*Envelope_SegmentBoundary)(nil),
 		(*Envelope_FadeIn)(nil),
 		(*Envelope_FadeOut)(nil),
+		(*Envelope_SectionChange)(nil),
+		(*Envelope_Drop)(nil),
 		(*Envelope_Click)(nil),
 		(*Envelope_Discontinuity)(nil),
 		(*Envelope_NoiseBurst)(nil),
@@ -3341,7 +4254,7 @@ func file_tracks_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_tracks_proto_rawDesc), len(file_tracks_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   49,
+			NumMessages:   60,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
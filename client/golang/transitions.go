@@ -0,0 +1,179 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"sort"
+)
+
+// Transitions (-cue-transitions, and the track summary's "transitions"):
+// where a continuous capture, like a DJ mix analysed as one file, plays two
+// tracks at once. The track is scanned in windows of transitionWindow every
+// transitionHop, and a window is part of a transition when its loudness
+// holds a plateau — no dip more than transitionDip below the level around
+// it, as a gap or hard cut would make — and it shows two tracks:
+//
+//   - tempo: its beat intervals spread by more than transitionBeatSpread
+//     (two beat grids running side by side), or its tempo.change values
+//     differ by more than transitionTempoSpread;
+//   - key: the keys of the transitionContext before and after it differ,
+//     and its chroma fits both within transitionKeyShare of its best key.
+//
+// Overlapping windows make one transition, which runs over the middle
+// transitionHop of each, as a window partly over a transition shows it too.
+const (
+	transitionWindow      = 8.0  // seconds
+	transitionHop         = 2.0  // seconds
+	transitionContext     = 30.0 // seconds
+	transitionDip         = 6.0  // dB
+	transitionMinBeats    = 6
+	transitionBeatSpread  = 0.15 // coefficient of variation
+	transitionTempoSpread = 0.03 // relative
+	transitionKeyShare    = 0.8
+)
+
+type transition struct {
+	Start    float64  `json:"start"`
+	End      float64  `json:"end"`
+	Evidence []string `json:"evidence"` // tempo and/or key
+}
+
+// trackTransitions finds d's transitions, in time order.
+func trackTransitions(d *trackData) []transition {
+	beats := eventTimes(d, "beat")
+	sort.Float64s(beats)
+	tempo := sortedPoints(d.series["tempo.change"])
+	loud := sortedPoints(d.series["loudness"])
+	chroma := d.features["chroma"]
+
+	var out []transition
+	for start := 0.0; start+transitionWindow <= d.duration(); start += transitionHop {
+		end := start + transitionWindow
+		if !loudnessPlateau(loud, start, end) {
+			continue
+		}
+		var evidence []string
+		if tempoOverlap(beats, tempo, start, end) {
+			evidence = append(evidence, "tempo")
+		}
+		if keyOverlap(chroma, start, end) {
+			evidence = append(evidence, "key")
+		}
+		if len(evidence) == 0 {
+			continue
+		}
+		mid := start + transitionWindow/2
+		if n := len(out); n > 0 && mid-transitionHop/2 <= out[n-1].End {
+			last := &out[n-1]
+			last.End = mid + transitionHop/2
+			for _, e := range evidence {
+				if !slices.Contains(last.Evidence, e) {
+					last.Evidence = append(last.Evidence, e)
+				}
+			}
+			continue
+		}
+		out = append(out, transition{Start: mid - transitionHop/2, End: mid + transitionHop/2, Evidence: evidence})
+	}
+	return out
+}
+
+func sortedPoints(pts []point) []point {
+	pts = append([]point(nil), pts...)
+	sort.SliceStable(pts, func(i, j int) bool { return pts[i].t < pts[j].t })
+	return pts
+}
+
+// loudnessPlateau reports whether loud stays within transitionDip of the
+// median level of the context around [start, end]. Without loudness frames
+// it can't tell, and doesn't object.
+func loudnessPlateau(loud []point, start, end float64) bool {
+	around := pointsBetween(loud, start-transitionContext, end+transitionContext)
+	within := pointsBetween(loud, start, end)
+	if len(around) == 0 || len(within) == 0 {
+		return true
+	}
+	levels := make([]float64, len(around))
+	for i, p := range around {
+		levels[i] = p.v
+	}
+	sort.Float64s(levels)
+	median := levels[len(levels)/2]
+	for _, p := range within {
+		if p.v < median-transitionDip {
+			return false
+		}
+	}
+	return true
+}
+
+func tempoOverlap(beats []float64, tempo []point, start, end float64) bool {
+	lo := sort.SearchFloat64s(beats, start)
+	hi := sort.SearchFloat64s(beats, end)
+	if hi-lo >= transitionMinBeats {
+		var sum, sq float64
+		n := float64(hi - lo - 1)
+		for i := lo + 1; i < hi; i++ {
+			sum += beats[i] - beats[i-1]
+		}
+		mean := sum / n
+		for i := lo + 1; i < hi; i++ {
+			g := beats[i] - beats[i-1] - mean
+			sq += g * g
+		}
+		if mean > 0 && math.Sqrt(sq/n)/mean > transitionBeatSpread {
+			return true
+		}
+	}
+	bpm := pointsBetween(tempo, start, end)
+	if len(bpm) < 2 {
+		return false
+	}
+	lowest, highest := bpm[0].v, bpm[0].v
+	for _, p := range bpm {
+		lowest, highest = min(lowest, p.v), max(highest, p.v)
+	}
+	return lowest > 0 && highest/lowest-1 > transitionTempoSpread
+}
+
+func keyOverlap(chroma []frame, start, end float64) bool {
+	sum := func(from, to float64) ([12]float64, bool) {
+		var c [12]float64
+		n := 0
+		lo := sort.Search(len(chroma), func(i int) bool { return chroma[i].t >= from })
+		for _, f := range chroma[lo:] {
+			if f.t >= to {
+				break
+			}
+			if len(f.v) == 12 {
+				for i, x := range f.v {
+					c[i] += float64(x)
+				}
+				n++
+			}
+		}
+		return c, n > 0
+	}
+	before, ok1 := sum(start-transitionContext, start)
+	after, ok2 := sum(end, end+transitionContext)
+	window, ok3 := sum(start, end)
+	if !ok1 || !ok2 || !ok3 {
+		return false
+	}
+	k1, k2 := keyStrengths(before)[0], keyStrengths(after)[0]
+	if k1.Key == k2.Key && k1.Scale == k2.Scale {
+		return false
+	}
+	fits := keyStrengths(window)
+	best := fits[0].Strength
+	if best <= 0 {
+		return false
+	}
+	both := 0
+	for _, k := range fits {
+		if (k.Key == k1.Key && k.Scale == k1.Scale || k.Key == k2.Key && k.Scale == k2.Scale) && k.Strength >= transitionKeyShare*best {
+			both++
+		}
+	}
+	return both == 2
+}
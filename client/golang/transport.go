@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-zeromq/zmq4"
+)
+
+// packetSource yields raw packets from one transport. Each packet is either a
+// serialized Envelope or an FEC-framed datagram (see fec.go).
+type packetSource interface {
+	// ReadPacket blocks for the next packet and returns it together with a
+	// label identifying its sender. The slice is only valid until the next
+	// call.
+	ReadPacket() ([]byte, string, error)
+	Close() error
+}
+
+// udpSource receives datagrams from a multicast group.
+type udpSource struct {
+	conn *net.UDPConn
+	buf  []byte
+}
+
+// newUDPSource joins group on port. With sources, it joins only the
+// (source, group) channels of those senders (source-specific multicast),
+// on the interface with address iface.
+func newUDPSource(group string, port int, sources []net.IP, iface string) (*udpSource, error) {
+	groupAddr := net.ParseIP(group)
+	if groupAddr == nil || groupAddr.To4() == nil {
+		return nil, fmt.Errorf("invalid multicast group %q", group)
+	}
+	var conn *net.UDPConn
+	var err error
+	if len(sources) > 0 {
+		ifaddr := net.ParseIP(iface)
+		if ifaddr == nil || ifaddr.To4() == nil {
+			return nil, fmt.Errorf("invalid interface address %q", iface)
+		}
+		conn, err = listenSSM(groupAddr, port, sources, ifaddr)
+	} else {
+		conn, err = net.ListenMulticastUDP("udp4", nil, &net.UDPAddr{
+			IP:   groupAddr,
+			Port: port,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+	return &udpSource{conn: conn, buf: make([]byte, 65536)}, nil
+}
+
+func (s *udpSource) ReadPacket() ([]byte, string, error) {
+	n, src, err := s.conn.ReadFromUDP(s.buf)
+	if err != nil {
+		return nil, "", err
+	}
+	return s.buf[:n], src.String(), nil
+}
+
+func (s *udpSource) Close() error { return s.conn.Close() }
+
+// multiUDPSource joins a group on several interfaces (-all-interfaces),
+// with a socket each, and merges what they receive. Depending on the
+// platform every socket may get every packet, so the copies are left to
+// duplicate suppression (dedup.go).
+type multiUDPSource struct {
+	conns   []*net.UDPConn
+	packets chan udpPacket
+}
+
+type udpPacket struct {
+	data []byte
+	src  string
+}
+
+// multicastInterfaces lists the interfaces that are up, multicast-capable
+// and have an IPv4 address. Loopback is only included if nothing else is.
+func multicastInterfaces() ([]net.Interface, error) {
+	all, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var ifaces, loopback []net.Interface
+	for _, ifi := range all {
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+				if ifi.Flags&net.FlagLoopback != 0 {
+					loopback = append(loopback, ifi)
+				} else {
+					ifaces = append(ifaces, ifi)
+				}
+				break
+			}
+		}
+	}
+	if len(ifaces) == 0 {
+		ifaces = loopback
+	}
+	if len(ifaces) == 0 {
+		return nil, fmt.Errorf("no multicast-capable interface is up")
+	}
+	return ifaces, nil
+}
+
+// newMultiUDPSource joins group on port on every eligible interface and
+// returns the names of those it joined on. An interface that refuses the
+// join is skipped with a warning.
+func newMultiUDPSource(group string, port int) (*multiUDPSource, []string, error) {
+	groupAddr := net.ParseIP(group)
+	if groupAddr == nil || groupAddr.To4() == nil {
+		return nil, nil, fmt.Errorf("invalid multicast group %q", group)
+	}
+	ifaces, err := multicastInterfaces()
+	if err != nil {
+		return nil, nil, err
+	}
+	s := &multiUDPSource{packets: make(chan udpPacket, 256)}
+	var names []string
+	for _, ifi := range ifaces {
+		conn, err := net.ListenMulticastUDP("udp4", &ifi, &net.UDPAddr{IP: groupAddr, Port: port})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: cannot join %s on %s: %v\n", group, ifi.Name, err)
+			continue
+		}
+		s.conns = append(s.conns, conn)
+		names = append(names, ifi.Name)
+	}
+	if len(s.conns) == 0 {
+		return nil, nil, fmt.Errorf("listen: could not join %s on any interface", group)
+	}
+	var wg sync.WaitGroup
+	for _, conn := range s.conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, 65536)
+			for {
+				n, src, err := conn.ReadFromUDP(buf)
+				if err != nil {
+					return
+				}
+				s.packets <- udpPacket{append([]byte(nil), buf[:n]...), src.String()}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(s.packets)
+	}()
+	return s, names, nil
+}
+
+func (s *multiUDPSource) ReadPacket() ([]byte, string, error) {
+	p, ok := <-s.packets
+	if !ok {
+		return nil, "", net.ErrClosed
+	}
+	return p.data, p.src, nil
+}
+
+func (s *multiUDPSource) Close() error {
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	return nil
+}
+
+// portSource receives from several ports at once (-port with a list), with
+// a source each, and merges what they receive. Each sender's label gets
+// the port the packet arrived on ("10.0.0.5:41234@5001"), which keeps
+// per-sender state (FEC, fragments, statistics) apart for each port and
+// lets envelopes be tagged with it.
+type portSource struct {
+	sources []packetSource
+	packets chan udpPacket
+}
+
+// newPortSource opens a source on every port with open. If one fails,
+// those already open are closed.
+func newPortSource(ports []int, open func(port int) (packetSource, error)) (*portSource, error) {
+	s := &portSource{packets: make(chan udpPacket, 256)}
+	for _, port := range ports {
+		src, err := open(port)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("port %d: %w", port, err)
+		}
+		s.sources = append(s.sources, src)
+	}
+	var wg sync.WaitGroup
+	for i, src := range s.sources {
+		suffix := "@" + strconv.Itoa(ports[i])
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				pkt, from, err := src.ReadPacket()
+				if err != nil {
+					return
+				}
+				s.packets <- udpPacket{append([]byte(nil), pkt...), from + suffix}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(s.packets)
+	}()
+	return s, nil
+}
+
+func (s *portSource) ReadPacket() ([]byte, string, error) {
+	p, ok := <-s.packets
+	if !ok {
+		return nil, "", net.ErrClosed
+	}
+	return p.data, p.src, nil
+}
+
+func (s *portSource) Close() error {
+	for _, src := range s.sources {
+		src.Close()
+	}
+	return nil
+}
+
+// sourcePort splits the port a packet arrived on from a portSource label.
+// ok is false for labels of a single-port source.
+func sourcePort(label string) (sender, port string, ok bool) {
+	i := strings.LastIndexByte(label, '@')
+	if i < 0 {
+		return label, "", false
+	}
+	if _, err := strconv.Atoi(label[i+1:]); err != nil {
+		return label, "", false
+	}
+	return label[:i], label[i+1:], true
+}
+
+// parsePorts parses a comma-separated list of UDP ports.
+func parsePorts(spec string) ([]int, error) {
+	var ports []int
+	seen := make(map[int]bool)
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		port, err := strconv.Atoi(item)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %q", item)
+		}
+		if seen[port] {
+			return nil, fmt.Errorf("port %d given twice", port)
+		}
+		seen[port] = true
+		ports = append(ports, port)
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("no port given")
+	}
+	return ports, nil
+}
+
+// parseSources parses a comma-separated list of IPv4 sender addresses.
+func parseSources(spec string) ([]net.IP, error) {
+	var sources []net.IP
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		ip := net.ParseIP(item)
+		if ip == nil || ip.To4() == nil {
+			return nil, fmt.Errorf("invalid source address %q (want an IPv4 address)", item)
+		}
+		sources = append(sources, ip)
+	}
+	return sources, nil
+}
+
+// zmqSource subscribes to a ZeroMQ PUB socket. Every message is a single
+// frame holding one serialized Envelope.
+type zmqSource struct {
+	sock     zmq4.Socket
+	endpoint string
+	cancel   context.CancelFunc
+}
+
+func newZMQSource(endpoint string) (*zmqSource, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sock := zmq4.NewSub(ctx,
+		zmq4.WithDialerRetry(time.Second),
+		zmq4.WithDialerMaxRetries(-1),
+		zmq4.WithAutomaticReconnect(true))
+	if err := sock.Dial(endpoint); err != nil {
+		cancel()
+		return nil, fmt.Errorf("zmq dial %s: %w", endpoint, err)
+	}
+	if err := sock.SetOption(zmq4.OptionSubscribe, ""); err != nil {
+		sock.Close()
+		cancel()
+		return nil, fmt.Errorf("zmq subscribe: %w", err)
+	}
+	return &zmqSource{sock: sock, endpoint: endpoint, cancel: cancel}, nil
+}
+
+func (s *zmqSource) ReadPacket() ([]byte, string, error) {
+	for {
+		msg, err := s.sock.Recv()
+		if err != nil {
+			return nil, "", err
+		}
+		if len(msg.Frames) > 0 {
+			return msg.Frames[0], s.endpoint, nil
+		}
+	}
+}
+
+func (s *zmqSource) Close() error {
+	s.cancel()
+	return s.sock.Close()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"math"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Trend events (-derive=brightness.rising, brightness.falling,
+// loudness.trend). The slope of a feature is fitted by least squares over
+// the last trend window of frames, and events fire when it crosses a
+// threshold, with hysteresis: a trend ends only once the slope has fallen
+// below half the threshold, so a slow filter sweep or crescendo gives one
+// event rather than a burst. Brightness follows spectral.centroid, with
+// the threshold relative to the mean centroid (0.05 = 5% per second);
+// loudness follows loudness in dB per second.
+
+type trendDeriver struct {
+	cfg     *deriveConfig
+	kind    string // "brightness.rising", "brightness.falling" or "loudness.trend"
+	streams map[string]*trendState
+}
+
+type trendState struct {
+	frames []point
+	dir    int // current trend: 1 rising, -1 falling, 0 steady
+}
+
+func newTrendDeriver(kind string, cfg *deriveConfig) *trendDeriver {
+	return &trendDeriver{cfg: cfg, kind: kind, streams: make(map[string]*trendState)}
+}
+
+func (d *trendDeriver) derive(env *trackspb.Envelope) []*trackspb.Envelope {
+	stream := env.GetStreamId()
+	var v float64
+	switch e := env.Event.(type) {
+	case *trackspb.Envelope_TrackStart, *trackspb.Envelope_TimelineReset:
+		delete(d.streams, stream)
+		return nil
+	case *trackspb.Envelope_SpectralCentroid:
+		if d.kind == "loudness.trend" {
+			return nil
+		}
+		v = e.SpectralCentroid.GetValue()
+	case *trackspb.Envelope_Loudness:
+		if d.kind != "loudness.trend" {
+			return nil
+		}
+		v = e.Loudness.GetValue()
+	default:
+		return nil
+	}
+
+	st := d.streams[stream]
+	if st == nil {
+		st = &trendState{}
+		d.streams[stream] = st
+	}
+	t := env.GetTimestamp()
+	st.frames = appendRecent(st.frames, point{t, v}, d.cfg.trendWindow)
+	// Judge only full windows, so the first frames of a track don't count.
+	if len(st.frames) < 3 || st.frames[0].t > t-d.cfg.trendWindow*0.9 {
+		return nil
+	}
+	slope, mean := fitSlope(st.frames)
+
+	rate, threshold := slope, d.cfg.loudnessTrendThreshold
+	if d.kind != "loudness.trend" {
+		if mean <= 0 {
+			return nil
+		}
+		rate, threshold = slope/mean, d.cfg.brightnessThreshold
+	}
+	dir := st.dir
+	switch {
+	case rate >= threshold:
+		dir = 1
+	case rate <= -threshold:
+		dir = -1
+	case math.Abs(rate) < threshold/2:
+		dir = 0
+	}
+	if dir == st.dir {
+		return nil
+	}
+	st.dir = dir
+
+	var event any
+	switch d.kind {
+	case "brightness.rising":
+		if dir != 1 {
+			return nil
+		}
+		event = &trackspb.BrightnessRising{Slope: slope, Centroid: mean}
+	case "brightness.falling":
+		if dir != -1 {
+			return nil
+		}
+		event = &trackspb.BrightnessFalling{Slope: slope, Centroid: mean}
+	case "loudness.trend":
+		event = &trackspb.LoudnessTrend{Direction: trendDirections[dir+1], Slope: slope, Loudness: mean}
+	}
+	return []*trackspb.Envelope{derivedEnvelope(env, event)}
+}
+
+var trendDirections = []string{"falling", "steady", "rising"}
+
+// fitSlope returns the least-squares slope of pts, per second, and their
+// mean value.
+func fitSlope(pts []point) (slope, mean float64) {
+	var mt float64
+	for _, p := range pts {
+		mt += p.t
+		mean += p.v
+	}
+	n := float64(len(pts))
+	mt /= n
+	mean /= n
+	var num, den float64
+	for _, p := range pts {
+		num += (p.t - mt) * (p.v - mean)
+		den += (p.t - mt) * (p.t - mt)
+	}
+	if den == 0 {
+		return 0, mean
+	}
+	return num / den, mean
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"github.com/gorilla/websocket"
+)
+
+// Web dashboard (-web). Serves the embedded UI from web/, the current state
+// as JSON at /api/state, traffic statistics at /stats (see stats.go), state
+// and actions for control surfaces under /api/companion and /api/action
+// (see companion.go), chroma and key strengths at /api/tonal (see
+// tonal.go), rhythmic band activity at /api/rhythm (see rhythmbands.go),
+// and a live event feed at /ws where every event is one JSON text message
+// (access control is in auth.go):
+//
+//	{"timestamp":1.5,"stream":"deckA","event":"beat","value":0.8,
+//	 "line":"[   1.500] beat ...","data":{"confidence":0.8}}
+const (
+	webClientQueue  = 512
+	webWriteTimeout = 5 * time.Second
+)
+
+//go:embed web
+var webAssets embed.FS
+
+type webMessage struct {
+	Timestamp float64         `json:"timestamp"`
+	Stream    string          `json:"stream,omitempty"`
+	Event     string          `json:"event"`
+	Value     *float64        `json:"value,omitempty"`
+	KeyCode   string          `json:"key_code,omitempty"` // key.change in -key-notation
+	Note      *note           `json:"note,omitempty"`
+	FromNote  *note           `json:"from_note,omitempty"`
+	Line      string          `json:"line"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+type webServer struct {
+	srv     *http.Server
+	ln      net.Listener
+	state   *stateTracker
+	stats   *liveStats
+	prios   *priorityMap
+	control *liveControl // nil without -web-actions
+	tonal   *tonalTracker
+	rhythm  *rhythmTracker
+	auth    *authenticator
+	origins []string // -web-origin
+	ws      websocket.Upgrader
+
+	mu      sync.Mutex
+	clients map[*eventQueue]*webClient
+}
+
+type webClient struct {
+	grant *grant
+	feed  *feedStats
+}
+
+func newWebServer(addr string, state *stateTracker, stats *liveStats, prios *priorityMap, control *liveControl, auth *authenticator, origins string) (*webServer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("web: %w", err)
+	}
+	w := &webServer{ln: ln, state: state, stats: stats, prios: prios, control: control, tonal: &tonalTracker{}, rhythm: &rhythmTracker{}, clients: make(map[*eventQueue]*webClient), auth: auth}
+	for _, o := range strings.Split(origins, ",") {
+		if o = strings.TrimSuffix(strings.TrimSpace(o), "/"); o != "" {
+			w.origins = append(w.origins, o)
+		}
+	}
+	w.ws.CheckOrigin = w.allowOrigin
+
+	static, _ := fs.Sub(webAssets, "web")
+	mux := http.NewServeMux()
+	mux.Handle("GET /", http.FileServer(http.FS(static)))
+	mux.HandleFunc("GET /api/state", allStreams(w.handleState))
+	mux.HandleFunc("GET /stats", allStreams(w.handleStats))
+	mux.HandleFunc("GET /api/schema", handleSchema)
+	mux.HandleFunc("GET /api/tonal", allStreams(w.handleTonal))
+	mux.HandleFunc("GET /api/rhythm", allStreams(w.handleRhythm))
+	mux.HandleFunc("GET /api/companion", allStreams(w.handleCompanion))
+	mux.HandleFunc("GET /api/companion/{name}", allStreams(w.handleCompanionVariable))
+	mux.HandleFunc("POST /api/action/{name}", allStreams(w.handleAction))
+	mux.HandleFunc("GET /ws", w.handleWS)
+	w.srv = &http.Server{Handler: auth.middleware(mux), ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := w.srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			fmt.Fprintf(os.Stderr, "web: %v\n", err)
+		}
+	}()
+	return w, nil
+}
+
+// allowOrigin lets a browser page use the WebSocket or the actions only if
+// the receiver served it or its origin is one of -web-origin ("*" for
+// any). Otherwise any site the dashboard's user visits could read the feed,
+// which the cookie set by a ?token= would even authorize, or post actions
+// to a receiver without tokens. Requests without an Origin don't come from
+// a page, such as those of control surfaces.
+func (w *webServer) allowOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, o := range w.origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleState serves the state of the stream named by ?stream=, or of the
+// one whose track started last.
+func (w *webServer) handleState(rw http.ResponseWriter, r *http.Request) {
+	st, ok := w.state.streamSnapshot(r.URL.Query().Get("stream"))
+	if !ok {
+		http.Error(rw, "unknown stream", http.StatusNotFound)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(st)
+}
+
+func (w *webServer) handleStats(rw http.ResponseWriter, _ *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(w.stats.snapshot())
+}
+
+// handleSchema serves this receiver's tracks.proto as a serialized
+// FileDescriptorSet, for clients built against an older one (see schema.go).
+func handleSchema(rw http.ResponseWriter, _ *http.Request) {
+	b, err := ownSchema()
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/x-protobuf")
+	rw.Write(b)
+}
+
+func (w *webServer) handleWS(rw http.ResponseWriter, r *http.Request) {
+	g := requestGrant(r)
+	release, err := w.auth.acquire(g)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	defer release()
+	conn, err := w.ws.Upgrade(rw, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	q := newEventQueue(webClientQueue)
+	w.mu.Lock()
+	c := &webClient{grant: g, feed: newFeedStats("web", r.RemoteAddr, g, "*", q)}
+	w.clients[q] = c
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		delete(w.clients, q)
+		w.mu.Unlock()
+		q.close()
+	}()
+
+	// The dashboard never sends anything; reading detects disconnects.
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				q.close()
+				return
+			}
+		}
+	}()
+
+	for env := q.pop(); env != nil; env = q.pop() {
+		b, err := json.Marshal(newWebMessage(env))
+		if err != nil {
+			continue
+		}
+		conn.SetWriteDeadline(time.Now().Add(webWriteTimeout))
+		if err := conn.WriteMessage(websocket.TextMessage, b); err != nil {
+			return
+		}
+		c.feed.count(len(b), time.Now())
+	}
+}
+
+func newWebMessage(env *trackspb.Envelope) *webMessage {
+	m := &webMessage{
+		Timestamp: env.GetTimestamp(),
+		Stream:    env.GetStreamId(),
+		Event:     eventName(env),
+		Line:      formatEvent(env),
+	}
+	if v, ok := eventValue(env); ok {
+		m.Value = &v
+	}
+	m.Note, m.FromNote = envelopeNotes(env)
+	if k := env.GetKeyChange(); k != nil {
+		m.KeyCode = keyCode(k.GetKey(), k.GetScale())
+	}
+	if fd := env.ProtoReflect().WhichOneof(envelopeEventOneof); fd != nil {
+		if b, err := jsonlOptions.Marshal(env.ProtoReflect().Get(fd).Message().Interface()); err == nil {
+			m.Data = b
+		}
+	}
+	return m
+}
+
+// publish queues env for every connected dashboard allowed its stream.
+func (w *webServer) publish(env *trackspb.Envelope) {
+	w.tonal.observe(env)
+	w.rhythm.observe(env)
+	prio := w.prios.classify(env)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for q, c := range w.clients {
+		if c.grant.allows(env.GetStreamId()) {
+			q.push(env, prio)
+		}
+	}
+}
+
+func (w *webServer) clientStatuses(now time.Time) []clientStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var st []clientStatus
+	for _, c := range w.clients {
+		st = append(st, c.feed.status(now))
+	}
+	return st
+}
+
+func (w *webServer) close() {
+	w.srv.Close()
+}
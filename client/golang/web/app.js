@@ -0,0 +1,257 @@
+// Live dashboard: polls /api/state once, then follows /ws.
+(function () {
+  "use strict";
+
+  var WINDOW = 60;        // seconds of history in the line charts
+  var EXTRAPOLATE = 3;    // seconds the position advances past the last update
+  var LOG_LINES = 200;
+  var TONAL_POLL = 2000;  // ms between /api/tonal and /api/rhythm requests
+  var PROFILE_WIDTH = 40; // px of the rhythm canvas showing the track's profile
+  var NOTES = ["C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"];
+
+  var bpm = [], loudness = [], chroma = [], meanChroma = [];
+  var logLines = [];
+  // Playback position, advanced locally between track.position heartbeats.
+  // The display holds still rather than step back for jitter under a second.
+  var pos = { value: 0, at: 0, playing: false }, shown = 0;
+
+  function $(id) { return document.getElementById(id); }
+
+  function clock(sec) {
+    var m = Math.floor(sec / 60), s = sec - m * 60;
+    return m + ":" + (s < 10 ? "0" : "") + s.toFixed(1);
+  }
+
+  function keyText(key, scale, code) {
+    return key + " " + scale + (code ? " (" + code + ")" : "");
+  }
+
+  function trim(series, now) {
+    while (series.length > 1 && series[0].t < now - WINDOW) series.shift();
+  }
+
+  function drawLine(canvas, series, color) {
+    var ctx = canvas.getContext("2d"), w = canvas.width, h = canvas.height;
+    ctx.clearRect(0, 0, w, h);
+    if (series.length < 2) return;
+    var lo = Infinity, hi = -Infinity;
+    series.forEach(function (p) { lo = Math.min(lo, p.v); hi = Math.max(hi, p.v); });
+    if (hi - lo < 1e-9) { hi += 1; lo -= 1; }
+    var t1 = series[series.length - 1].t, t0 = t1 - WINDOW;
+    ctx.strokeStyle = color;
+    ctx.lineWidth = 1.5;
+    ctx.beginPath();
+    series.forEach(function (p, i) {
+      var x = (p.t - t0) / WINDOW * w;
+      var y = h - 8 - (p.v - lo) / (hi - lo) * (h - 16);
+      if (i === 0) ctx.moveTo(x, y); else ctx.lineTo(x, y);
+    });
+    ctx.stroke();
+    ctx.fillStyle = "#888";
+    ctx.font = "10px sans-serif";
+    ctx.fillText(hi.toFixed(1), 4, 12);
+    ctx.fillText(lo.toFixed(1), 4, h - 4);
+  }
+
+  function drawChroma(canvas, values) {
+    var ctx = canvas.getContext("2d"), w = canvas.width, h = canvas.height;
+    var cx = w / 2, cy = h / 2, r = Math.min(cx, cy) - 18;
+    ctx.clearRect(0, 0, w, h);
+    var max = 0;
+    values.forEach(function (v) { max = Math.max(max, v); });
+    for (var i = 0; i < 12; i++) {
+      var a0 = (i - 0.5) / 12 * 2 * Math.PI - Math.PI / 2;
+      var a1 = (i + 0.5) / 12 * 2 * Math.PI - Math.PI / 2;
+      var v = max > 0 && i < values.length ? values[i] / max : 0;
+      ctx.fillStyle = "hsl(" + (i * 30) + ",70%," + (15 + v * 45) + "%)";
+      ctx.beginPath();
+      ctx.moveTo(cx, cy);
+      ctx.arc(cx, cy, r * (0.15 + 0.85 * v), a0, a1);
+      ctx.closePath();
+      ctx.fill();
+      if (i < meanChroma.length) {
+        // The track's average so far, as an outline.
+        ctx.strokeStyle = "#ddd";
+        ctx.beginPath();
+        ctx.arc(cx, cy, r * (0.15 + 0.85 * meanChroma[i]), a0, a1);
+        ctx.stroke();
+      }
+      var am = (a0 + a1) / 2;
+      ctx.fillStyle = "#aaa";
+      ctx.font = "10px sans-serif";
+      ctx.textAlign = "center";
+      ctx.fillText(NOTES[i], cx + Math.cos(am) * (r + 10), cy + Math.sin(am) * (r + 10) + 3);
+    }
+  }
+
+  // Keys are coloured by their place on the circle of fifths, so related
+  // keys get neighbouring hues; minor keys are darker.
+  function keyColor(k) {
+    return "hsl(" + (k.fifths * 30) + ",65%," + (k.scale === "minor" ? 35 : 55) + "%)";
+  }
+
+  function drawKeyscape(canvas, ks) {
+    var ctx = canvas.getContext("2d"), w = canvas.width, h = canvas.height;
+    ctx.clearRect(0, 0, w, h);
+    if (!ks) return;
+    var n = ks.bins, cw = w / n, rh = h / n;
+    ks.rows.forEach(function (row, r) {
+      row.forEach(function (k, j) {
+        if (!k) return;
+        ctx.fillStyle = keyColor(k);
+        // Row r is centred under the windows it spans, rising to the apex.
+        ctx.fillRect((j + r / 2) * cw, h - (r + 1) * rh, Math.ceil(cw), Math.ceil(rh));
+      });
+    });
+  }
+
+  function applyTonal(t) {
+    meanChroma = t.mean || [];
+    drawKeyscape($("keyscape"), t.keyscape);
+    $("keys").textContent = (t.keys || []).slice(0, 3).map(function (k) {
+      return keyText(k.key, k.scale, k.code) + " " + k.strength.toFixed(2);
+    }).join(", ") || "-";
+  }
+
+  function pollTonal() {
+    fetch("api/tonal").then(function (r) { return r.json(); }).then(applyTonal).catch(function () {});
+  }
+
+  function activityColor(v) {
+    return "hsl(30,80%," + (8 + v * 52) + "%)";
+  }
+
+  // The last minute of band activity as a heatmap, low bands at the
+  // bottom, with the track's profile as bars on the right.
+  function drawRhythm(canvas, r) {
+    var ctx = canvas.getContext("2d"), w = canvas.width, h = canvas.height;
+    ctx.clearRect(0, 0, w, h);
+    var p = r.profile;
+    if (!p) return;
+    var n = p.activity.length, rh = h / n;
+    var cols = r.timeline.columns, cw = (w - PROFILE_WIDTH - 4) / cols.length;
+    cols.forEach(function (col, i) {
+      if (!col) return;
+      col.forEach(function (v, k) {
+        ctx.fillStyle = activityColor(v);
+        ctx.fillRect(i * cw, h - (k + 1) * rh, Math.ceil(cw), Math.ceil(rh));
+      });
+    });
+    p.activity.forEach(function (v, k) {
+      ctx.fillStyle = activityColor(v);
+      ctx.fillRect(w - PROFILE_WIDTH, h - (k + 1) * rh, v * PROFILE_WIDTH, Math.ceil(rh));
+    });
+  }
+
+  function applyRhythm(r) {
+    drawRhythm($("rhythm"), r);
+    var p = r.profile;
+    $("rhythm-regions").textContent = p ?
+      "low " + p.low.toFixed(2) + ", mid " + p.mid.toFixed(2) + ", high " + p.high.toFixed(2) + " (" + p.onsets + " onsets)" : "-";
+  }
+
+  function pollRhythm() {
+    fetch("api/rhythm").then(function (r) { return r.json(); }).then(applyRhythm).catch(function () {});
+  }
+
+  function redraw() {
+    drawLine($("bpm-chart"), bpm, "#6cf");
+    drawLine($("loudness-chart"), loudness, "#fc6");
+    drawChroma($("chroma"), chroma);
+  }
+
+  function position() {
+    if (!pos.playing) return (shown = pos.value);
+    var v = pos.value + Math.min((performance.now() - pos.at) / 1000, EXTRAPOLATE);
+    if (v < shown && shown - v < 1) v = shown;
+    return (shown = v);
+  }
+
+  function setPosition(value, playing) {
+    pos = { value: value, at: performance.now(), playing: playing };
+  }
+
+  function applyState(st) {
+    if (st.track) $("track").textContent = st.track.filename;
+    setPosition(st.position || 0, st.playing);
+    if (st.bpm) $("bpm").textContent = st.bpm.toFixed(1);
+    if (st.key) $("key").textContent = keyText(st.key, st.scale, st.key_code);
+    if (st.chord) $("chord").textContent = st.chord;
+    if (st.loudness !== undefined) $("loudness").textContent = st.loudness.toFixed(1);
+    if (st.chroma) chroma = st.chroma;
+    redraw();
+  }
+
+  function log(line) {
+    logLines.push(line);
+    if (logLines.length > LOG_LINES) logLines.shift();
+    var el = $("log");
+    var follow = el.scrollTop + el.clientHeight >= el.scrollHeight - 4;
+    el.textContent = logLines.join("\n");
+    if (follow) el.scrollTop = el.scrollHeight;
+  }
+
+  function handle(m) {
+    var d = m.data || {};
+    switch (m.event) {
+    case "track.start":
+      setPosition(0, true);
+      shown = 0;
+      bpm = []; loudness = []; chroma = []; meanChroma = [];
+      $("track").textContent = d.filename || "-";
+      ["bpm", "key", "chord", "loudness"].forEach(function (id) { $(id).textContent = "-"; });
+      break;
+    case "track.position":
+      setPosition(d.position || 0, true);
+      break;
+    case "track.end":
+    case "track.abort":
+      setPosition(position(), false);
+      break;
+    case "tempo.change":
+      bpm.push({ t: m.timestamp, v: d.bpm || 0 });
+      trim(bpm, m.timestamp);
+      $("bpm").textContent = (d.bpm || 0).toFixed(1);
+      break;
+    case "loudness":
+      loudness.push({ t: m.timestamp, v: d.value || 0 });
+      trim(loudness, m.timestamp);
+      $("loudness").textContent = (d.value || 0).toFixed(1);
+      break;
+    case "chroma":
+      chroma = d.values || [];
+      break;
+    case "key.change":
+      $("key").textContent = keyText(d.key, d.scale, m.key_code);
+      break;
+    case "chord.change":
+      $("chord").textContent = d.chord;
+      break;
+    }
+    log(m.line);
+  }
+
+  function connect() {
+    var proto = location.protocol === "https:" ? "wss://" : "ws://";
+    var ws = new WebSocket(proto + location.host + "/ws");
+    ws.onopen = function () {
+      $("status").textContent = "live";
+      $("status").className = "status live";
+    };
+    ws.onmessage = function (e) { handle(JSON.parse(e.data)); };
+    ws.onclose = function () {
+      $("status").textContent = "disconnected";
+      $("status").className = "status";
+      setTimeout(connect, 2000);
+    };
+  }
+
+  fetch("api/state").then(function (r) { return r.json(); }).then(applyState).catch(function () {});
+  setInterval(redraw, 250);
+  pollTonal();
+  setInterval(pollTonal, TONAL_POLL);
+  pollRhythm();
+  setInterval(pollRhythm, TONAL_POLL);
+  setInterval(function () { $("position").textContent = clock(position()); }, 100);
+  connect();
+})();
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// startWeb serves the dashboard on a free port, with actions if control is
+// not nil, and returns its address.
+func startWeb(t *testing.T, control *liveControl, origins string) string {
+	t.Helper()
+	auth, err := newAuthenticator("", "", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := newWebServer("127.0.0.1:0", newStateTracker(), newLiveStats(), defaultPriorityMap(), control, auth, origins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(w.close)
+	return w.ln.Addr().String()
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	addr := startWeb(t, nil, "https://overlay.example.com/, http://studio.local:3000")
+	for _, tc := range []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true}, // not a browser
+		{"http://" + addr, true},
+		{"https://overlay.example.com", true},
+		{"http://studio.local:3000", true},
+		{"https://evil.example", false},
+		{"http://studio.local:3001", false},
+		{"null", false},
+	} {
+		h := http.Header{}
+		if tc.origin != "" {
+			h.Set("Origin", tc.origin)
+		}
+		conn, resp, err := websocket.DefaultDialer.Dial("ws://"+addr+"/ws", h)
+		if conn != nil {
+			conn.Close()
+		}
+		if ok := err == nil; ok != tc.ok {
+			status := 0
+			if resp != nil {
+				status = resp.StatusCode
+			}
+			t.Errorf("Origin %q: connected %v (status %d), want %v", tc.origin, ok, status, tc.ok)
+		}
+	}
+
+	// Any origin with *.
+	addr = startWeb(t, nil, "*")
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/ws", http.Header{"Origin": {"https://evil.example"}})
+	if err != nil {
+		t.Fatalf("-web-origin=*: %v", err)
+	}
+	conn.Close()
+}
+
+func TestActionOrigin(t *testing.T) {
+	control := newLiveControl()
+	addr := startWeb(t, control, "")
+	post := func(action, origin string) int {
+		req, err := http.NewRequest("POST", "http://"+addr+"/api/action/"+action, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := post("hold", "https://evil.example"); code != http.StatusForbidden || control.held() {
+		t.Errorf("action from another site: %d, held %v; want 403 and not held", code, control.held())
+	}
+	if code := post("hold", ""); code != http.StatusOK || !control.held() {
+		t.Errorf("action without an origin: %d, held %v; want 200 and held", code, control.held())
+	}
+	if code := post("release", "http://"+addr); code != http.StatusOK || control.held() {
+		t.Errorf("action from the dashboard: %d, held %v; want 200 and released", code, control.held())
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+)
+
+// Chat notifications (-webhook). Selected events are posted as short
+// messages to Slack or Discord incoming webhooks, for teams monitoring a
+// broadcast. Rules in -webhook-on name an event or category, optionally
+// with a threshold on the event's value ("saturation>0.5" for saturated
+// stretches longer than half a second). Repeats of the same event within
+// -webhook-cooldown are counted rather than posted and mentioned in the
+// next message, so a run of clicks doesn't flood the channel; transport
+// events are always posted. Messages are sent in the background at most
+// one per second, the rate both services allow for a webhook.
+const (
+	webhookTimeout  = 5 * time.Second
+	webhookInterval = time.Second
+	webhookQueue    = 32
+)
+
+type webhookTarget struct {
+	url     string
+	discord bool // Discord's payload rather than Slack's
+}
+
+type webhookSink struct {
+	targets  []webhookTarget
+	rules    []eventRule
+	cooldown time.Duration
+	client   *http.Client
+
+	title      map[string]string // track title per stream
+	last       map[string]time.Time
+	suppressed map[string]int
+	dropped    atomic.Int64
+
+	queue chan string
+	done  chan struct{}
+}
+
+// newWebhookSink posts to the comma-separated webhook URLs; Discord URLs
+// are recognised by their host, anything else gets Slack's format, which
+// Mattermost and Rocket.Chat accept too.
+func newWebhookSink(urls, on string, cooldown time.Duration) (*webhookSink, error) {
+	rules, err := parseEventRules(on)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("no events given (use -webhook-on)")
+	}
+	w := &webhookSink{
+		rules:      rules,
+		cooldown:   cooldown,
+		client:     &http.Client{Timeout: webhookTimeout},
+		title:      make(map[string]string),
+		last:       make(map[string]time.Time),
+		suppressed: make(map[string]int),
+		queue:      make(chan string, webhookQueue),
+		done:       make(chan struct{}),
+	}
+	for _, raw := range strings.Split(urls, ",") {
+		if raw = strings.TrimSpace(raw); raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid webhook URL %q", raw)
+		}
+		host := strings.ToLower(u.Hostname())
+		discord := host == "discord.com" || host == "discordapp.com" || strings.HasSuffix(host, ".discord.com")
+		w.targets = append(w.targets, webhookTarget{url: raw, discord: discord})
+	}
+	if len(w.targets) == 0 {
+		return nil, fmt.Errorf("no webhook URL given")
+	}
+	go w.run()
+	return w, nil
+}
+
+func (w *webhookSink) handle(env *trackspb.Envelope, received time.Time) {
+	stream := env.GetStreamId()
+	if start := env.GetTrackStart(); start != nil {
+		w.title[stream] = filepath.Base(start.GetFilename())
+	}
+	if !w.matches(env) {
+		return
+	}
+	name := eventName(env)
+	key := stream + "\x00" + name
+	repeats := 0
+	if eventCategory(env) != "transport" {
+		if received.Sub(w.last[key]) < w.cooldown {
+			w.suppressed[key]++
+			return
+		}
+		w.last[key] = received
+		repeats = w.suppressed[key]
+		delete(w.suppressed, key)
+	}
+
+	msg := w.message(env, name)
+	if repeats > 0 {
+		msg += fmt.Sprintf(" (%d more since the last message)", repeats)
+	}
+	w.notify(msg)
+}
+
+// notify queues a message for posting.
+func (w *webhookSink) notify(msg string) {
+	select {
+	case w.queue <- msg:
+	default:
+		w.dropped.Add(1)
+	}
+}
+
+func (w *webhookSink) status() sinkStatus {
+	return sinkStatus{Name: "webhook", Policy: "drop-newest", Depth: len(w.queue), Capacity: cap(w.queue), Dropped: int(w.dropped.Load())}
+}
+
+// setRules replaces the events posted, when -config changes -webhook-on.
+func (w *webhookSink) setRules(on string) error {
+	rules, err := parseEventRules(on)
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		return fmt.Errorf("no events given")
+	}
+	w.rules = rules
+	return nil
+}
+
+func (w *webhookSink) matches(env *trackspb.Envelope) bool {
+	for _, r := range w.rules {
+		if r.matches(env) {
+			return true
+		}
+	}
+	return false
+}
+
+// message renders env as one line of chat text.
+func (w *webhookSink) message(env *trackspb.Envelope, name string) string {
+	track := w.title[env.GetStreamId()]
+	if track == "" {
+		track = "unknown track"
+	}
+	if s := env.GetStreamId(); s != "" {
+		track += " [" + s + "]"
+	}
+	switch e := env.Event.(type) {
+	case *trackspb.Envelope_TrackStart:
+		s := e.TrackStart
+		return fmt.Sprintf("Track started: %s (%s, %.1f kHz, %d ch)", track, shortClock(s.GetDuration()), float64(s.GetSampleRate())/1000, s.GetChannels())
+	case *trackspb.Envelope_TrackEnd:
+		return "Track ended: " + track
+	case *trackspb.Envelope_TrackAbort:
+		return fmt.Sprintf("Track aborted: %s (%s)", track, e.TrackAbort.GetReason())
+	}
+	msg := fmt.Sprintf("%s at %s in %s", name, formatClock(env.GetTimestamp()), track)
+	if fields := eventFields(env); fields != "" {
+		msg += ": " + fields
+	}
+	return msg
+}
+
+func (w *webhookSink) run() {
+	defer close(w.done)
+	var next time.Time
+	for msg := range w.queue {
+		if wait := time.Until(next); wait > 0 {
+			time.Sleep(wait)
+		}
+		next = time.Now().Add(webhookInterval)
+		for _, t := range w.targets {
+			if err := w.post(t, msg); err != nil {
+				fmt.Fprintf(os.Stderr, "webhook: %v\n", err)
+			}
+		}
+	}
+}
+
+func (w *webhookSink) post(t webhookTarget, msg string) error {
+	payload := map[string]string{"text": msg}
+	if t.discord {
+		payload = map[string]string{"content": msg}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(t.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// The URL holds the webhook's secret, so keep it out of the message.
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	return nil
+}
+
+// close sends the messages still queued and stops.
+func (w *webhookSink) close() {
+	close(w.queue)
+	<-w.done
+	if n := w.dropped.Load(); n > 0 {
+		fmt.Fprintf(os.Stderr, "webhook: %d messages dropped (queue full)\n", n)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/davesmith10/tracks/client/golang/trackspb"
+	"github.com/fxamacker/cbor/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Wire encodings (-wire). Senders normally send serialized protobuf
+// Envelopes, but a small embedded sender without a protobuf library can send
+// the same Envelope as JSON or CBOR instead: an object in the protobuf JSON
+// mapping, with field names as in the schema or in lowerCamelCase, e.g.
+//
+//	{"timestamp": 12.5, "stream_id": "pad1", "onset": {"strength": 0.8}}
+//
+// which is also the layout of -out .jsonl lines, so a recording can be sent
+// back as it is. CBOR is the same object in CBOR, with text keys. Unknown
+// fields are ignored, as protobuf ignores them. Fragmentation, FEC and
+// duplicate suppression work on the payloads whatever their encoding.
+type wireDecoder interface {
+	// decode parses one payload into env.
+	decode(payload []byte, env *trackspb.Envelope) error
+}
+
+// wire is the decoder of received payloads, chosen with -wire.
+var wire wireDecoder = protobufWire{}
+
+var wireDecoders = map[string]wireDecoder{
+	"protobuf": protobufWire{},
+	"json":     jsonWire{},
+	"cbor":     cborWire{},
+}
+
+func setWire(name string) error {
+	d, ok := wireDecoders[name]
+	if !ok {
+		return fmt.Errorf("unknown wire encoding %q (want protobuf, json or cbor)", name)
+	}
+	wire = d
+	return nil
+}
+
+type protobufWire struct{}
+
+func (protobufWire) decode(payload []byte, env *trackspb.Envelope) error {
+	return proto.Unmarshal(payload, env)
+}
+
+var wireJSON = protojson.UnmarshalOptions{DiscardUnknown: true}
+
+type jsonWire struct{}
+
+func (jsonWire) decode(payload []byte, env *trackspb.Envelope) error {
+	if err := wireJSON.Unmarshal(payload, env); err != nil {
+		return err
+	}
+	if env.Event == nil {
+		return fmt.Errorf("an envelope without an event")
+	}
+	return nil
+}
+
+// cborObjects decodes CBOR maps with string keys, as JSON objects have.
+var cborObjects, _ = cbor.DecOptions{
+	DefaultMapType: reflect.TypeOf(map[string]any(nil)),
+}.DecMode()
+
+type cborWire struct{}
+
+// decode maps the CBOR object onto JSON and parses that, so both encodings
+// follow the protobuf JSON mapping exactly.
+func (cborWire) decode(payload []byte, env *trackspb.Envelope) error {
+	var v any
+	if err := cborObjects.Unmarshal(payload, &v); err != nil {
+		return err
+	}
+	if _, ok := v.(map[string]any); !ok {
+		return fmt.Errorf("not a CBOR map")
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return jsonWire{}.decode(b, env)
+}
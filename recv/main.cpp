@@ -5,6 +5,10 @@
 #include <iostream>
 #include <sstream>
 #include <array>
+#include <cstdint>
+#include <map>
+#include <string>
+#include <vector>
 
 namespace po = boost::program_options;
 using boost::asio::ip::udp;
@@ -31,6 +35,9 @@ static std::string format_event(const tracks::Envelope& env) {
 
     snprintf(buf, sizeof(buf), "[%8.3f] ", env.timestamp());
     result += buf;
+    if (!env.stream_id().empty()) {
+        result += "<" + env.stream_id() + "> ";
+    }
 
     switch (env.event_case()) {
         // Transport
@@ -63,6 +70,12 @@ static std::string format_event(const tracks::Envelope& env) {
             result += buf;
             break;
         }
+        case tracks::Envelope::kSchema: {
+            const auto& e = env.schema();
+            snprintf(buf, sizeof(buf), "schema            size=%zuB", e.descriptor_set().size());
+            result += buf;
+            break;
+        }
 
         // Beat/Rhythm
         case tracks::Envelope::kBeat: {
@@ -365,6 +378,15 @@ int main(int argc, char* argv[]) {
 
     std::cout << "Waiting for events...\n" << std::endl;
 
+    // Fragmented envelopes (sender --mtu N) being joined, by message id.
+    // Messages that never complete are dropped once too many are pending.
+    struct Message {
+        std::vector<std::string> parts;
+        size_t have = 0;
+    };
+    std::map<uint32_t, Message> pending;
+    const size_t max_pending = 64;
+
     std::array<char, 65536> recv_buf;
     for (;;) {
         udp::endpoint sender;
@@ -375,8 +397,39 @@ int main(int argc, char* argv[]) {
             continue;
         }
 
+        // FEC-framed stream (sender --fec N): unwrap data packets and skip
+        // parity. This reference receiver does not attempt recovery.
+        const char* payload = recv_buf.data();
+        if (len >= 8 && static_cast<uint8_t>(recv_buf[0]) == 0xF7) {
+            if (recv_buf[1] != 0x00) continue;
+            payload += 8;
+            len -= 8;
+        }
+
+        // Fragment (sender --mtu N): hold it until the whole envelope is in.
+        std::string joined;
+        if (len >= 8 && static_cast<uint8_t>(payload[0]) == 0xF6) {
+            uint32_t id = (uint32_t(uint8_t(payload[2])) << 24) | (uint32_t(uint8_t(payload[3])) << 16) |
+                          (uint32_t(uint8_t(payload[4])) << 8) | uint32_t(uint8_t(payload[5]));
+            size_t index = uint8_t(payload[6]);
+            size_t count = uint8_t(payload[7]);
+            if (index >= count) continue;
+            if (!pending.count(id) && pending.size() >= max_pending) {
+                pending.erase(pending.begin());
+            }
+            Message& m = pending[id];
+            if (m.parts.empty()) m.parts.resize(count);
+            if (m.parts.size() != count || !m.parts[index].empty()) continue;
+            m.parts[index].assign(payload + 8, len - 8);
+            if (++m.have < count) continue;
+            for (const auto& p : m.parts) joined += p;
+            pending.erase(id);
+            payload = joined.data();
+            len = joined.size();
+        }
+
         tracks::Envelope env;
-        if (!env.ParseFromArray(recv_buf.data(), len)) {
+        if (!env.ParseFromArray(payload, len)) {
             std::cerr << "failed to parse envelope (" << len << " bytes)" << std::endl;
             continue;
         }
@@ -1,5 +1,7 @@
 #include "analyzer.h"
 #include "tracks.pb.h"
+#include <google/protobuf/descriptor.h>
+#include <google/protobuf/descriptor.pb.h>
 
 #include <algorithm>
 #include <cmath>
@@ -1073,6 +1075,20 @@ Timeline analyze(const Config& cfg) {
         add_envelope(timeline, t, env);
     }
 
+    // schema: the FileDescriptorSet of tracks.proto, so receivers built
+    // against an older copy can still decode and name newer events
+    if (cfg.schema_interval > 0) {
+        google::protobuf::FileDescriptorSet set;
+        ::tracks::Envelope::descriptor()->file()->CopyTo(set.add_file());
+        const std::string descriptor_set = set.SerializeAsString();
+        for (double t = 0.0; t < duration; t += cfg.schema_interval) {
+            ::tracks::Envelope env;
+            env.set_timestamp(t);
+            env.mutable_schema()->set_descriptor_set(descriptor_set);
+            add_envelope(timeline, t, env);
+        }
+    }
+
     // track.end
     {
         ::tracks::Envelope env;
@@ -1081,8 +1097,9 @@ Timeline analyze(const Config& cfg) {
         add_envelope(timeline, duration, env);
     }
 
-    // Sort by timestamp
-    std::sort(timeline.begin(), timeline.end(),
+    // Sort by timestamp; stable, so track.start stays ahead of the other
+    // envelopes at 0
+    std::stable_sort(timeline.begin(), timeline.end(),
         [](const TimelineEvent& a, const TimelineEvent& b) {
             return a.timestamp < b.timestamp;
         });
@@ -21,6 +21,12 @@ static void load_yaml(Config& cfg, const std::string& path) {
         if (net["ttl"])             cfg.ttl  = net["ttl"].as<int>();
         if (net["loopback"])        cfg.loopback  = net["loopback"].as<bool>();
         if (net["interface"])       cfg.interface = net["interface"].as<std::string>();
+        if (net["fec_block"])       cfg.fec_block = net["fec_block"].as<int>();
+        if (net["mtu"])             cfg.mtu = net["mtu"].as<int>();
+        if (net["transport"])       cfg.transport = net["transport"].as<std::string>();
+        if (net["zmq_endpoint"])    cfg.zmq_endpoint = net["zmq_endpoint"].as<std::string>();
+        if (net["shm_name"])        cfg.shm_name = net["shm_name"].as<std::string>();
+        if (net["shm_size"])        cfg.shm_size = net["shm_size"].as<uint32_t>();
         if (net["enable_unicast"])  cfg.enable_unicast = net["enable_unicast"].as<bool>();
         if (net["unicast_target"])  cfg.unicast_target = net["unicast_target"].as<std::string>();
     }
@@ -32,6 +38,9 @@ static void load_yaml(Config& cfg, const std::string& path) {
     if (auto tr = root["transport"]) {
         if (tr["position_interval"]) cfg.position_interval = tr["position_interval"].as<double>();
         if (tr["prepare_time"])      cfg.prepare_time      = tr["prepare_time"].as<double>();
+        if (tr["send_time"])         cfg.send_time         = tr["send_time"].as<bool>();
+        if (tr["schema_interval"])   cfg.schema_interval   = tr["schema_interval"].as<double>();
+        if (tr["stream_id"])         cfg.stream_id         = tr["stream_id"].as<std::string>();
     }
     if (auto ev = root["events"]) {
         if (ev["continuous_interval"]) cfg.continuous_interval = ev["continuous_interval"].as<double>();
@@ -63,14 +72,23 @@ bool load_config(Config& cfg, int argc, char* argv[]) {
         ("config,c",  po::value<std::string>(), "Config YAML file")
         ("multicast-group", po::value<std::string>(), "Multicast group address")
         ("port,p",    po::value<uint16_t>(),    "UDP port")
-        ("ttl",       po::value<int>(),         "Multicast TTL")
-        ("loopback",  po::value<bool>(),        "Enable multicast loopback")
-        ("interface", po::value<std::string>(), "Outbound interface address")
+        ("ttl",       po::value<int>(),         "Multicast TTL: routers the stream may cross (0 = this host only, max 255)")
+        ("loopback",  po::value<bool>(),        "Deliver the stream to receivers on this host too")
+        ("interface", po::value<std::string>(), "Outbound interface, by IPv4 address or name (e.g. eth0)")
+        ("fec",       po::value<int>(),         "Send one XOR parity packet per N data packets (0 = off, max 255)")
+        ("mtu",       po::value<int>(),         "Split envelopes into fragments that fit this path MTU (0 = off, 576-65535)")
+        ("transport", po::value<std::string>(), "Transport: udp (multicast, default), zmq (ZeroMQ PUB) or shm (shared memory)")
+        ("zmq-endpoint", po::value<std::string>(), "ZeroMQ PUB bind endpoint (default tcp://*:5556)")
+        ("shm-name",  po::value<std::string>(), "Shared-memory object name (default /tracks)")
+        ("shm-size",  po::value<uint32_t>(),    "Shared-memory ring capacity in bytes (default 4194304)")
         ("sample-rate",        po::value<int>(),    "Analysis sample rate")
         ("frame-size",         po::value<int>(),    "Analysis frame size")
         ("hop-size",           po::value<int>(),    "Analysis hop size")
         ("position-interval",  po::value<double>(), "Seconds between position heartbeats")
         ("prepare-time",       po::value<double>(), "Seconds before track.start to send track.prepare (default 5.0)")
+        ("stream-id",          po::value<std::string>(), "Stream label stamped on every envelope (e.g. deckA)")
+        ("send-time",          po::value<bool>(),   "Stamp every envelope with the wall-clock send time, for latency measurement (default true)")
+        ("schema-interval",    po::value<double>(), "Seconds between schema envelopes describing tracks.proto (default 10, 0 = off)")
         ("events,e",  po::value<std::string>(), "Comma-separated event types (e.g. beat,onset,pitch)")
         ("all",       "Enable all event types")
         ("primary",   "Enable tier 1 events (beat, onset, silence, loudness, energy)")
@@ -119,11 +137,20 @@ bool load_config(Config& cfg, int argc, char* argv[]) {
     if (vm.count("ttl"))               cfg.ttl              = vm["ttl"].as<int>();
     if (vm.count("loopback"))          cfg.loopback         = vm["loopback"].as<bool>();
     if (vm.count("interface"))         cfg.interface        = vm["interface"].as<std::string>();
+    if (vm.count("fec"))               cfg.fec_block        = vm["fec"].as<int>();
+    if (vm.count("mtu"))               cfg.mtu              = vm["mtu"].as<int>();
+    if (vm.count("transport"))         cfg.transport        = vm["transport"].as<std::string>();
+    if (vm.count("zmq-endpoint"))      cfg.zmq_endpoint     = vm["zmq-endpoint"].as<std::string>();
+    if (vm.count("shm-name"))          cfg.shm_name         = vm["shm-name"].as<std::string>();
+    if (vm.count("shm-size"))          cfg.shm_size         = vm["shm-size"].as<uint32_t>();
     if (vm.count("sample-rate"))       cfg.sample_rate      = vm["sample-rate"].as<int>();
     if (vm.count("frame-size"))        cfg.frame_size       = vm["frame-size"].as<int>();
     if (vm.count("hop-size"))          cfg.hop_size         = vm["hop-size"].as<int>();
     if (vm.count("position-interval")) cfg.position_interval= vm["position-interval"].as<double>();
     if (vm.count("prepare-time"))    cfg.prepare_time     = vm["prepare-time"].as<double>();
+    if (vm.count("send-time"))         cfg.send_time        = vm["send-time"].as<bool>();
+    if (vm.count("schema-interval"))   cfg.schema_interval  = vm["schema-interval"].as<double>();
+    if (vm.count("stream-id"))         cfg.stream_id        = vm["stream-id"].as<std::string>();
     if (vm.count("continuous-interval")) cfg.continuous_interval = vm["continuous-interval"].as<double>();
     if (vm["enable-unicast"].as<bool>())  cfg.enable_unicast = true;
     if (vm.count("unicast-target"))       cfg.unicast_target = vm["unicast-target"].as<std::string>();
@@ -143,6 +170,42 @@ bool load_config(Config& cfg, int argc, char* argv[]) {
         cfg.enabled_events = default_events();
     }
 
+    if (cfg.ttl < 0 || cfg.ttl > 255) {
+        std::cerr << "Error: --ttl must be between 0 and 255\n";
+        return false;
+    }
+    if (cfg.interface.empty()) {
+        std::cerr << "Error: --interface must not be empty (0.0.0.0 for the default)\n";
+        return false;
+    }
+    if (cfg.schema_interval < 0) {
+        std::cerr << "Error: --schema-interval must not be negative\n";
+        return false;
+    }
+    if (cfg.fec_block < 0 || cfg.fec_block > 255) {
+        std::cerr << "Error: --fec must be between 0 and 255\n";
+        return false;
+    }
+    if (cfg.mtu != 0 && (cfg.mtu < 576 || cfg.mtu > 65535)) {
+        std::cerr << "Error: --mtu must be 0 or between 576 and 65535\n";
+        return false;
+    }
+
+    if (cfg.transport != "udp" && cfg.transport != "zmq" && cfg.transport != "shm") {
+        std::cerr << "Error: --transport must be udp, zmq or shm\n";
+        return false;
+    }
+    if (cfg.transport == "shm" && (cfg.shm_size < 65536 || cfg.shm_size % 8 != 0)) {
+        std::cerr << "Error: --shm-size must be a multiple of 8 and at least 65536\n";
+        return false;
+    }
+#ifndef TRACKS_HAVE_ZMQ
+    if (cfg.transport == "zmq") {
+        std::cerr << "Error: this build has no ZeroMQ support (libzmq not found at configure time)\n";
+        return false;
+    }
+#endif
+
     if (cfg.input_file.empty()) {
         std::cerr << "Error: no input file specified\n" << desc << "\n";
         return false;
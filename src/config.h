@@ -13,6 +13,12 @@ struct Config {
     int         ttl             = 1;
     bool        loopback        = true;
     std::string interface       = "0.0.0.0";
+    int         fec_block       = 0;    // data packets per XOR parity packet (0 = off)
+    int         mtu             = 0;    // path MTU to fragment envelopes for (0 = off)
+    std::string transport       = "udp";            // "udp", "zmq" or "shm"
+    std::string zmq_endpoint    = "tcp://*:5556";   // ZeroMQ PUB bind address
+    std::string shm_name        = "/tracks";        // POSIX shared-memory object name
+    uint32_t    shm_size        = 4 << 20;          // ring capacity in bytes
 
     // analysis
     int    sample_rate = 44100;
@@ -22,6 +28,8 @@ struct Config {
     // transport
     double position_interval = 1.0;
     double prepare_time      = 5.0;  // seconds before track.start to send track.prepare
+    bool   send_time         = true; // stamp Envelope.send_time_ns on every envelope
+    double schema_interval   = 10.0; // seconds between schema envelopes (0 = off)
 
     // event filtering
     EventFilter enabled_events;         // which non-transport events to analyze/emit
@@ -31,6 +39,9 @@ struct Config {
     bool        enable_unicast = false;
     std::string unicast_target;  // empty = auto-detect WSL2 host IP
 
+    // stream label stamped on every envelope (empty = unset)
+    std::string stream_id;
+
     // input
     std::string input_file;
 };
@@ -17,6 +17,7 @@ static const std::vector<EventNameEntry>& all_entries() {
         {EventType::TRACK_POSITION, "track.position"},
         {EventType::TRACK_ABORT,    "track.abort"},
         {EventType::TRACK_PREPARE,  "track.prepare"},
+        {EventType::SCHEMA,         "schema"},
         // Beat/Rhythm
         {EventType::BEAT,           "beat"},
         {EventType::TEMPO_CHANGE,   "tempo.change"},
@@ -103,7 +104,8 @@ bool is_transport_event(EventType et) {
            et == EventType::TRACK_END   ||
            et == EventType::TRACK_POSITION ||
            et == EventType::TRACK_ABORT ||
-           et == EventType::TRACK_PREPARE;
+           et == EventType::TRACK_PREPARE ||
+           et == EventType::SCHEMA;
 }
 
 EventFilter default_events() {
@@ -15,6 +15,7 @@ enum class EventType {
     TRACK_POSITION,
     TRACK_ABORT,
     TRACK_PREPARE,
+    SCHEMA,
 
     // Beat/Rhythm
     BEAT,
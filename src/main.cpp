@@ -6,6 +6,7 @@
 #include <essentia/algorithmfactory.h>
 #include <iostream>
 #include <csignal>
+#include <memory>
 
 static void signal_handler(int) {
     tracks::g_interrupted.store(true, std::memory_order_relaxed);
@@ -40,9 +41,17 @@ int main(int argc, char* argv[]) {
 
     // Phase 2: Emit in real-time
     std::cout << "\n--- Emission Phase ---" << std::endl;
-    tracks::Transport transport(cfg);
+    std::unique_ptr<tracks::Transport> transport;
+    try {
+        transport = std::make_unique<tracks::Transport>(cfg);
+    } catch (const std::exception& e) {
+        std::cerr << "Error: " << e.what() << std::endl;
+        essentia::shutdown();
+        return 1;
+    }
     tracks::Emitter emitter;
-    emitter.run(timeline, transport, cfg);
+    emitter.run(timeline, *transport, cfg);
+    transport->flush();
 
     if (tracks::g_interrupted.load()) {
         std::cout << "Aborted." << std::endl;
@@ -1,10 +1,76 @@
 #include "transport.h"
 #include <iostream>
 #include <array>
+#include <chrono>
+#include <cerrno>
 #include <cstdio>
+#include <cstring>
+#include <stdexcept>
+
+#include <fcntl.h>
+#include <ifaddrs.h>
+#include <net/if.h>
+#include <netinet/in.h>
+#include <sys/mman.h>
+#include <unistd.h>
+
+#ifdef TRACKS_HAVE_ZMQ
+#include <zmq.h>
+#endif
 
 namespace tracks {
 
+// FEC datagram header: magic, kind, block id (u32 BE), index, block size.
+// 0xF7 has protobuf wire type 7, so it can never start a valid Envelope.
+static constexpr uint8_t FEC_MAGIC       = 0xF7;
+static constexpr uint8_t FEC_KIND_DATA   = 0x00;
+static constexpr uint8_t FEC_KIND_PARITY = 0x01;
+static constexpr size_t  FEC_HEADER_LEN  = 8;
+
+static std::string fec_header(uint8_t kind, uint32_t block, uint8_t index, uint8_t size) {
+    std::string h(FEC_HEADER_LEN, '\0');
+    h[0] = static_cast<char>(FEC_MAGIC);
+    h[1] = static_cast<char>(kind);
+    h[2] = static_cast<char>((block >> 24) & 0xFF);
+    h[3] = static_cast<char>((block >> 16) & 0xFF);
+    h[4] = static_cast<char>((block >> 8) & 0xFF);
+    h[5] = static_cast<char>(block & 0xFF);
+    h[6] = static_cast<char>(index);
+    h[7] = static_cast<char>(size);
+    return h;
+}
+
+// Fragment header: magic, reserved, message id (u32 BE), index, count.
+// 0xF6 has protobuf wire type 6, so it can never start a valid Envelope
+// either. Fragments sit below FEC: each one is a data packet of its own.
+static constexpr uint8_t FRAG_MAGIC      = 0xF6;
+static constexpr size_t  FRAG_HEADER_LEN = 8;
+static constexpr size_t  FRAG_MAX_COUNT  = 255;
+
+// IPv4 and UDP headers, which come out of the MTU before the payload.
+static constexpr size_t  UDP_IP_OVERHEAD = 28;
+
+static std::string frag_header(uint32_t message, uint8_t index, uint8_t count) {
+    std::string h(FRAG_HEADER_LEN, '\0');
+    h[0] = static_cast<char>(FRAG_MAGIC);
+    h[2] = static_cast<char>((message >> 24) & 0xFF);
+    h[3] = static_cast<char>((message >> 16) & 0xFF);
+    h[4] = static_cast<char>((message >> 8) & 0xFF);
+    h[5] = static_cast<char>(message & 0xFF);
+    h[6] = static_cast<char>(index);
+    h[7] = static_cast<char>(count);
+    return h;
+}
+
+// Shared-memory ring header. Data records start at SHM_HEADER_LEN and are
+// laid out as [u32 length][payload], padded to 8 bytes; a length of
+// SHM_WRAP means "continue at the start of the data area".
+static constexpr char     SHM_MAGIC[8]        = {'T', 'R', 'K', 'S', 'H', 'M', '0', '1'};
+static constexpr size_t   SHM_HEADER_LEN      = 64;
+static constexpr size_t   SHM_OFF_CAPACITY    = 8;
+static constexpr size_t   SHM_OFF_WRITE_POS   = 16;
+static constexpr uint32_t SHM_WRAP            = 0xFFFFFFFF;
+
 std::string Transport::detect_wsl2_host() {
     std::array<char, 256> buf;
     std::string result;
@@ -24,21 +90,119 @@ std::string Transport::detect_wsl2_host() {
     return result.substr(pos, end - pos);
 }
 
+// Resolves --interface: an IPv4 address, or the name of an interface
+// (e.g. eth0), which is replaced by its first IPv4 address.
+boost::asio::ip::address_v4 Transport::resolve_interface(const std::string& iface) {
+    boost::system::error_code ec;
+    auto addr = boost::asio::ip::make_address_v4(iface, ec);
+    if (!ec) return addr;
+
+    ifaddrs* list = nullptr;
+    if (getifaddrs(&list) != 0) {
+        throw std::runtime_error("getifaddrs: " + std::string(std::strerror(errno)));
+    }
+    bool found = false;
+    for (ifaddrs* ifa = list; ifa; ifa = ifa->ifa_next) {
+        if (iface != ifa->ifa_name || !ifa->ifa_addr || ifa->ifa_addr->sa_family != AF_INET) continue;
+        if (!(ifa->ifa_flags & IFF_UP)) continue;
+        auto* sin = reinterpret_cast<sockaddr_in*>(ifa->ifa_addr);
+        addr = boost::asio::ip::address_v4(ntohl(sin->sin_addr.s_addr));
+        found = true;
+        break;
+    }
+    freeifaddrs(list);
+    if (!found) {
+        throw std::runtime_error("--interface " + iface +
+                                 ": not an IPv4 address or the name of an up interface with one");
+    }
+    return addr;
+}
+
+// Encodes Envelope.stream_id (field 2, length-delimited). Protobuf merges
+// concatenated messages, so appending this to an already serialized
+// envelope sets the field without re-encoding the event.
+static std::string stream_id_suffix(const std::string& stream_id) {
+    if (stream_id.empty()) return {};
+    std::string out;
+    out.push_back(static_cast<char>((2 << 3) | 2));
+    size_t len = stream_id.size();
+    while (len >= 0x80) {
+        out.push_back(static_cast<char>((len & 0x7F) | 0x80));
+        len >>= 7;
+    }
+    out.push_back(static_cast<char>(len));
+    out += stream_id;
+    return out;
+}
+
+// Encodes Envelope.send_time_ns (field 3, varint) with the current wall
+// clock, appended the same way as the stream id.
+static std::string send_time_field() {
+    auto ns = std::chrono::duration_cast<std::chrono::nanoseconds>(
+        std::chrono::system_clock::now().time_since_epoch()).count();
+    std::string out;
+    out.push_back(static_cast<char>((3 << 3) | 0));
+    uint64_t v = static_cast<uint64_t>(ns);
+    while (v >= 0x80) {
+        out.push_back(static_cast<char>((v & 0x7F) | 0x80));
+        v >>= 7;
+    }
+    out.push_back(static_cast<char>(v));
+    return out;
+}
+
 Transport::Transport(const Config& cfg)
     : endpoint_(boost::asio::ip::address::from_string(cfg.multicast_group), cfg.port)
     , socket_(io_, endpoint_.protocol())
+    , stream_suffix_(stream_id_suffix(cfg.stream_id))
+    , send_time_(cfg.send_time)
+    , fec_block_(cfg.fec_block)
 {
-    // Set multicast TTL
-    socket_.set_option(boost::asio::ip::multicast::hops(cfg.ttl));
+#ifdef TRACKS_HAVE_ZMQ
+    if (cfg.transport == "zmq") {
+        zmq_ctx_ = zmq_ctx_new();
+        zmq_pub_ = zmq_socket(zmq_ctx_, ZMQ_PUB);
+        if (zmq_bind(zmq_pub_, cfg.zmq_endpoint.c_str()) != 0) {
+            std::string err = zmq_strerror(zmq_errno());
+            zmq_close(zmq_pub_);
+            zmq_ctx_term(zmq_ctx_);
+            throw std::runtime_error("zmq bind " + cfg.zmq_endpoint + ": " + err);
+        }
+        std::cout << "ZeroMQ PUB bound to " << cfg.zmq_endpoint << std::endl;
+        return;
+    }
+#endif
 
-    // Enable/disable loopback
-    socket_.set_option(boost::asio::ip::multicast::enable_loopback(cfg.loopback));
+    if (cfg.transport == "shm") {
+        open_shm(cfg);
+        return;
+    }
+
+    // Largest packet handed to FEC framing. Parity packets are 2 bytes
+    // longer than the longest data packet they cover, so leave room for
+    // that too.
+    if (cfg.mtu > 0) {
+        max_packet_ = cfg.mtu - UDP_IP_OVERHEAD;
+        if (fec_block_ > 0) {
+            max_packet_ -= FEC_HEADER_LEN + 2;
+        }
+    }
 
-    // Bind to outbound interface
+    // Scope of the stream: how many routers it crosses (0 = this host
+    // only), whether this host's own receivers get it, and which interface
+    // it leaves by (0.0.0.0 = the kernel's choice, usually the default
+    // route's).
+    socket_.set_option(boost::asio::ip::multicast::hops(cfg.ttl));
+    socket_.set_option(boost::asio::ip::multicast::enable_loopback(cfg.loopback));
+    std::string via = "default interface";
     if (cfg.interface != "0.0.0.0") {
-        socket_.set_option(boost::asio::ip::multicast::outbound_interface(
-            boost::asio::ip::address_v4::from_string(cfg.interface)));
+        auto addr = resolve_interface(cfg.interface);
+        socket_.set_option(boost::asio::ip::multicast::outbound_interface(addr));
+        via = cfg.interface;
+        if (addr.to_string() != cfg.interface) via += " (" + addr.to_string() + ")";
     }
+    std::cout << "Multicast TTL " << cfg.ttl << ", loopback " << (cfg.loopback ? "on" : "off")
+              << ", via " << via << std::endl;
 
     // Unicast dual-send for WSL2
     if (cfg.enable_unicast) {
@@ -58,15 +222,180 @@ Transport::Transport(const Config& cfg)
     }
 }
 
-void Transport::send(const std::string& serialized_envelope) {
+void Transport::open_shm(const Config& cfg) {
+    shm_fd_ = shm_open(cfg.shm_name.c_str(), O_CREAT | O_RDWR, 0644);
+    if (shm_fd_ < 0) {
+        throw std::runtime_error("shm_open " + cfg.shm_name + ": " + std::strerror(errno));
+    }
+    shm_capacity_ = cfg.shm_size;
+    shm_map_size_ = SHM_HEADER_LEN + shm_capacity_;
+    if (ftruncate(shm_fd_, static_cast<off_t>(shm_map_size_)) != 0) {
+        std::string err = std::strerror(errno);
+        close(shm_fd_);
+        throw std::runtime_error("ftruncate " + cfg.shm_name + ": " + err);
+    }
+    void* base = mmap(nullptr, shm_map_size_, PROT_READ | PROT_WRITE, MAP_SHARED, shm_fd_, 0);
+    if (base == MAP_FAILED) {
+        std::string err = std::strerror(errno);
+        close(shm_fd_);
+        throw std::runtime_error("mmap " + cfg.shm_name + ": " + err);
+    }
+    shm_base_ = static_cast<uint8_t*>(base);
+
+    // Reset the write position first so attached readers resync, then
+    // publish the header.
+    __atomic_store_n(reinterpret_cast<uint64_t*>(shm_base_ + SHM_OFF_WRITE_POS),
+                     uint64_t{0}, __ATOMIC_RELEASE);
+    std::memcpy(shm_base_, SHM_MAGIC, sizeof(SHM_MAGIC));
+    std::memcpy(shm_base_ + SHM_OFF_CAPACITY, &shm_capacity_, sizeof(shm_capacity_));
+
+    std::cout << "Shared memory ring: " << cfg.shm_name << " (" << shm_capacity_ << " bytes)" << std::endl;
+}
+
+void Transport::send_shm(const std::string& record) {
+    uint8_t* data = shm_base_ + SHM_HEADER_LEN;
+    uint64_t need = (4 + record.size() + 7) & ~uint64_t{7};
+    if (need > shm_capacity_ / 2) {
+        std::cerr << "shm send error: record of " << record.size() << " bytes exceeds ring capacity\n";
+        return;
+    }
+
+    uint64_t off = shm_write_pos_ % shm_capacity_;
+    if (off + need > shm_capacity_) {
+        // Not enough room before the end: mark the wrap and start over
+        uint32_t wrap = SHM_WRAP;
+        std::memcpy(data + off, &wrap, sizeof(wrap));
+        shm_write_pos_ += shm_capacity_ - off;
+        off = 0;
+    }
+
+    uint32_t len = static_cast<uint32_t>(record.size());
+    std::memcpy(data + off, &len, sizeof(len));
+    std::memcpy(data + off + 4, record.data(), record.size());
+    shm_write_pos_ += need;
+
+    __atomic_store_n(reinterpret_cast<uint64_t*>(shm_base_ + SHM_OFF_WRITE_POS),
+                     shm_write_pos_, __ATOMIC_RELEASE);
+}
+
+Transport::~Transport() {
+    if (shm_base_) {
+        munmap(shm_base_, shm_map_size_);
+        close(shm_fd_);
+    }
+#ifdef TRACKS_HAVE_ZMQ
+    if (zmq_pub_) {
+        // Give subscribers a moment to drain the final track.end/abort
+        int linger = 1000;
+        zmq_setsockopt(zmq_pub_, ZMQ_LINGER, &linger, sizeof(linger));
+        zmq_close(zmq_pub_);
+        zmq_ctx_term(zmq_ctx_);
+    }
+#endif
+}
+
+void Transport::send(const std::string& envelope) {
+    if (stream_suffix_.empty() && !send_time_) {
+        send_envelope(envelope);
+        return;
+    }
+    std::string message = envelope + stream_suffix_;
+    if (send_time_) {
+        message += send_time_field();
+    }
+    send_envelope(message);
+}
+
+void Transport::send_envelope(const std::string& serialized_envelope) {
+    if (shm_base_) {
+        send_shm(serialized_envelope);
+        return;
+    }
+
+#ifdef TRACKS_HAVE_ZMQ
+    if (zmq_pub_) {
+        // ZeroMQ runs over a reliable stream, so FEC framing is not applied
+        if (zmq_send(zmq_pub_, serialized_envelope.data(), serialized_envelope.size(), 0) < 0) {
+            std::cerr << "zmq send error: " << zmq_strerror(zmq_errno()) << "\n";
+        }
+        return;
+    }
+#endif
+
+    if (max_packet_ == 0 || serialized_envelope.size() <= max_packet_) {
+        send_packet(serialized_envelope);
+        return;
+    }
+
+    // Too large for one packet: send it in fragments the receiver joins
+    // again, rather than leave it to IP fragmentation, which firewalls and
+    // some switches drop.
+    size_t chunk = max_packet_ - FRAG_HEADER_LEN;
+    size_t count = (serialized_envelope.size() + chunk - 1) / chunk;
+    if (count > FRAG_MAX_COUNT) {
+        std::cerr << "send error: envelope of " << serialized_envelope.size()
+                  << " bytes needs more than " << FRAG_MAX_COUNT << " fragments\n";
+        return;
+    }
+    uint32_t message = frag_message_id_++;
+    for (size_t i = 0; i < count; ++i) {
+        send_packet(frag_header(message, static_cast<uint8_t>(i), static_cast<uint8_t>(count)) +
+                    serialized_envelope.substr(i * chunk, chunk));
+    }
+}
+
+void Transport::send_packet(const std::string& packet) {
+    if (fec_block_ <= 0) {
+        send_datagram(packet);
+        return;
+    }
+
+    send_datagram(fec_header(FEC_KIND_DATA, fec_block_id_,
+                             static_cast<uint8_t>(fec_count_),
+                             static_cast<uint8_t>(fec_block_)) + packet);
+
+    // Parity covers a 2-byte big-endian length prefix followed by the
+    // payload, zero-padded to the longest packet in the block.
+    std::string item;
+    item.reserve(packet.size() + 2);
+    item.push_back(static_cast<char>((packet.size() >> 8) & 0xFF));
+    item.push_back(static_cast<char>(packet.size() & 0xFF));
+    item += packet;
+    if (fec_parity_.size() < item.size()) {
+        fec_parity_.resize(item.size(), '\0');
+    }
+    for (size_t i = 0; i < item.size(); ++i) {
+        fec_parity_[i] ^= item[i];
+    }
+
+    if (++fec_count_ >= fec_block_) {
+        send_parity();
+    }
+}
+
+void Transport::flush() {
+    if (fec_block_ > 0 && fec_count_ > 0 && !zmq_pub_ && !shm_base_) {
+        send_parity();
+    }
+}
+
+void Transport::send_parity() {
+    send_datagram(fec_header(FEC_KIND_PARITY, fec_block_id_, 0,
+                             static_cast<uint8_t>(fec_count_)) + fec_parity_);
+    fec_parity_.clear();
+    fec_count_ = 0;
+    ++fec_block_id_;
+}
+
+void Transport::send_datagram(const std::string& datagram) {
     boost::system::error_code ec;
-    socket_.send_to(boost::asio::buffer(serialized_envelope), endpoint_, 0, ec);
+    socket_.send_to(boost::asio::buffer(datagram), endpoint_, 0, ec);
     if (ec) {
         std::cerr << "send error: " << ec.message() << "\n";
     }
 
     if (unicast_enabled_) {
-        socket_.send_to(boost::asio::buffer(serialized_envelope), unicast_endpoint_, 0, ec);
+        socket_.send_to(boost::asio::buffer(datagram), unicast_endpoint_, 0, ec);
         if (ec) {
             std::cerr << "unicast send error: " << ec.message() << "\n";
         }
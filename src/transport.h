@@ -1,6 +1,7 @@
 #pragma once
 
 #include "config.h"
+#include <cstdint>
 #include <string>
 #include <boost/asio.hpp>
 
@@ -9,16 +10,60 @@ namespace tracks {
 class Transport {
 public:
     explicit Transport(const Config& cfg);
+    ~Transport();
+
+    Transport(const Transport&) = delete;
+    Transport& operator=(const Transport&) = delete;
+
     void send(const std::string& serialized_envelope);
 
+    // Sends the parity packet for a partially filled FEC block, so the tail
+    // of the stream is protected too. No-op when FEC is disabled.
+    void flush();
+
 private:
     static std::string detect_wsl2_host();
+    static boost::asio::ip::address_v4 resolve_interface(const std::string& iface);
+    void send_envelope(const std::string& serialized_envelope);
+    void send_packet(const std::string& packet);
+    void send_datagram(const std::string& datagram);
+    void send_parity();
+    void open_shm(const Config& cfg);
+    void send_shm(const std::string& record);
 
     boost::asio::io_context        io_;
     boost::asio::ip::udp::endpoint endpoint_;
     boost::asio::ip::udp::socket   socket_;
     bool                           unicast_enabled_ = false;
     boost::asio::ip::udp::endpoint unicast_endpoint_;
+
+    // Serialized Envelope.stream_id field appended to every message; empty
+    // when no stream id is configured
+    std::string                    stream_suffix_;
+
+    // Whether Envelope.send_time_ns is appended to every message
+    bool                           send_time_ = true;
+
+    // Forward error correction (see CLIENT.md, "Forward Error Correction")
+    int                            fec_block_ = 0;
+    uint32_t                       fec_block_id_ = 0;
+    int                            fec_count_ = 0;
+    std::string                    fec_parity_;
+
+    // Fragmentation (see CLIENT.md, "Fragmentation"); 0 when off
+    size_t                         max_packet_ = 0;
+    uint32_t                       frag_message_id_ = 0;
+
+    // ZeroMQ PUB socket (--transport zmq); null when sending over UDP
+    void*                          zmq_ctx_ = nullptr;
+    void*                          zmq_pub_ = nullptr;
+
+    // Shared-memory ring (--transport shm); see CLIENT.md for the layout
+    int                            shm_fd_ = -1;
+    uint8_t*                       shm_base_ = nullptr;
+    size_t                         shm_map_size_ = 0;
+    uint64_t                       shm_capacity_ = 0;
+    uint64_t                       shm_write_pos_ = 0;
 };
 
 } // namespace tracks